@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/taironas/route"
+
+	activitiesctrl "github.com/taironas/gonawin/controllers/activities"
+	teamsctrl "github.com/taironas/gonawin/controllers/teams"
+	tournamentsctrl "github.com/taironas/gonawin/controllers/tournaments"
+	usersctrl "github.com/taironas/gonawin/controllers/users"
+	"github.com/taironas/gonawin/helpers"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// routeID returns the id of a route parameter, or a bad request error with the given code.
+//
+func routeID(r *http.Request, name, code string) (int64, error) {
+	str, err := route.Context.Get(r, name)
+	if err != nil {
+		return 0, &helpers.BadRequest{Err: errors.New(code)}
+	}
+	id, err := strconv.ParseInt(str, 0, 64)
+	if err != nil {
+		return 0, &helpers.BadRequest{Err: errors.New(code)}
+	}
+	return id, nil
+}
+
+// formInt returns an integer form value of a request or a default value.
+//
+func formInt(r *http.Request, name string, d int64) int64 {
+	if n, err := strconv.ParseInt(r.FormValue(name), 0, 64); err == nil {
+		return n
+	}
+	return d
+}
+
+// showUser handler, use it to get the data of a user.
+//	GET	/j/users/show/[0-9]+/	Retrieves the user with the given id.
+//
+// The 'including' param adds the teams and the tournaments of the user, the teams are not paged.
+// Team requests and invitations are not served.
+//
+func (s *server) showUser(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	userID, err := routeID(r, "userId", helpers.ErrorCodeUserNotFound)
+	if err != nil {
+		return err
+	}
+	var user *mdl.User
+	if user, err = s.repos.Users.ByID(userID); err != nil {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	var teams []*mdl.Team
+	var tournaments []*mdl.Tournament
+	for _, param := range helpers.SetOfStrings(r.FormValue("including")) {
+		switch param {
+		case "teams":
+			if teams, err = s.repos.Teams.ByIDs(user.TeamIds); err != nil {
+				return err
+			}
+		case "tournaments":
+			if tournaments, err = s.repos.Tournaments.ByIDs(user.TournamentIds); err != nil {
+				return err
+			}
+		}
+	}
+	return templateshlp.RenderJSON(w, nil, usersctrl.BuildShowViewModel(user, teams, tournaments, nil, nil))
+}
+
+// showTeam handler, use it to get the team data to show.
+//	GET	/j/teams/show/[0-9]+/	Retrieves the team with the given id, its players and tournaments.
+//
+// Team requests are not served, RequestSent is always false.
+//
+func (s *server) showTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	team, err := s.team(r)
+	if err != nil {
+		return err
+	}
+
+	var players []*mdl.User
+	if players, err = s.repos.Users.ByIDs(team.UserIds); err != nil {
+		return err
+	}
+	var tournaments []*mdl.Tournament
+	if tournaments, err = s.repos.Tournaments.ByIDs(team.TournamentIds); err != nil {
+		return err
+	}
+
+	return templateshlp.RenderJSON(w, nil, teamsctrl.BuildShowViewModel(team, u, false, players, tournaments))
+}
+
+// newTeam handler, use it to create a new team.
+//	POST	/j/teams/new/	Creates a new team whose admin is the user, who joins it.
+//
+func (s *server) newTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	var tData struct {
+		Name        string
+		Description string
+		Visibility  string
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&tData); err != nil {
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamCannotCreate)}
+	}
+	if len(tData.Name) <= 0 {
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeNameCannotBeEmpty)}
+	}
+
+	team, err := s.repos.NewTeam(u, tData.Name, tData.Description, tData.Visibility == "Private")
+	if err == mdl.ErrTeamExists {
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamAlreadyExists)}
+	} else if err != nil {
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamCannotCreate)}
+	}
+
+	return templateshlp.RenderJSON(w, nil, teamsctrl.BuildNewTeamViewModel(team))
+}
+
+// joinTeam handler, use it to join a public team.
+//	POST	/j/teams/join/[0-9]+/	Make a user join a team with the given id.
+//
+func (s *server) joinTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	team, err := s.team(r)
+	if err != nil {
+		return err
+	}
+	if team.Private {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTeamPrivateJoinForbiden)}
+	}
+	if err = s.repos.JoinTeam(team, u); err != nil {
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	if err = s.repos.Publish(u, "team", "joined team", team.Entity(), mdl.ActivityEntity{}); err != nil {
+		return err
+	}
+
+	return templateshlp.RenderJSON(w, nil, teamsctrl.BuildTeamJoinViewModel(team))
+}
+
+// showTournament handler, use it to get the tournament data to show.
+//	GET	/j/tournaments/show/[0-9]+/	Retrieves the tournament with the given id, its participants and teams.
+//
+func (s *server) showTournament(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	tournamentID, err := routeID(r, "tournamentId", helpers.ErrorCodeTournamentNotFound)
+	if err != nil {
+		return err
+	}
+	var tournament *mdl.Tournament
+	if tournament, err = s.repos.Tournaments.ByID(tournamentID); err != nil {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
+	}
+
+	var participants []*mdl.User
+	if participants, err = s.repos.Users.ByIDs(tournament.UserIds); err != nil {
+		return err
+	}
+	var teams []*mdl.Team
+	if teams, err = s.repos.Teams.ByIDs(tournament.TeamIds); err != nil {
+		return err
+	}
+
+	return templateshlp.RenderJSON(w, nil, tournamentsctrl.BuildShowViewModel(tournament, u, participants, teams))
+}
+
+// activities handler, use it to get the activities of the user.
+//	GET	/j/activities/	Retrieves the activities of the user, pass 'count' and 'page', or 'cursor', empty for the first page, to page them.
+//
+func (s *server) activities(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	count := formInt(r, "count", 20)
+	cursor := r.FormValue("cursor")
+
+	var activities []*mdl.Activity
+	var page, lastPage int64
+	var next string
+	var err error
+	if _, ok := r.Form["cursor"]; !ok {
+		page = formInt(r, "page", 1)
+		activities, err = s.repos.Activities.ByUser(u, count, page)
+		lastPage = int64(math.Ceil(float64(len(u.ActivityIds)) / float64(count)))
+	} else {
+		activities, next, err = s.repos.Activities.ByUserCursor(u, count, cursor)
+	}
+	if err == mdl.ErrInvalidCursor {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeInvalidCursor)}
+	} else if err != nil {
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	return templateshlp.RenderJSON(w, nil, activitiesctrl.BuildIndexViewModel(activities, count, page, lastPage, next))
+}
+
+// team returns the team of the teamId route parameter.
+//
+func (s *server) team(r *http.Request) (*mdl.Team, error) {
+	teamID, err := routeID(r, "teamId", helpers.ErrorCodeTeamNotFound)
+	if err != nil {
+		return nil, err
+	}
+	var team *mdl.Team
+	if team, err = s.repos.Teams.ByID(teamID); err != nil {
+		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
+	}
+	return team, nil
+}
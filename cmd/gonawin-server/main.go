@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Command gonawin-server serves a partial subset of the gonawin /j/ API outside of App Engine
+// with plain net/http. It is not a replacement of the App Engine app.
+// The configuration is read from a config.json file like the one of the App Engine app.
+//
+//	gonawin-server -addr :8080 -config config.json -data gonawin.db
+//
+// The entities are kept in memory and persisted in a local file, or in a SQL database
+// when the "store" of the configuration has a driver:
+//
+//	"store": {"driver": "sqlite3", "dataSource": "gonawin.sqlite"}
+//
+// Build with the sqlite tag to register the SQLite driver.
+//
+// Requests are authenticated by the authentication key of a user in the
+// Authorization header. The offline user and the dev users of the configuration
+// are created at start up and their keys are logged. In offline mode every request
+// is made by the offline user.
+//
+// It serves the routes whose data only need the repositories of the models package.
+// The handlers read the entities from the repositories, the changes are made by the operations
+// of the repositories, like joining a team, and the responses are built by the view models of
+// the controllers, all shared with the App Engine app:
+//
+//	GET	/j/users/show/:userId
+//	GET	/j/teams/show/:teamId
+//	POST	/j/teams/new
+//	POST	/j/teams/join/:teamId
+//	GET	/j/tournaments/show/:tournamentId
+//	GET	/j/activities
+//
+// Team requests and invitations are not served. The other /j/ routes still depend on the App Engine
+// services and answer 501 Not Implemented. The events of the teams are not posted to their webhooks,
+// they are delivered by the task queue of App Engine.
+//
+// The models and the controllers import the App Engine packages, so the server is built with the
+// App Engine SDK packages in the GOPATH even though it does not run on App Engine.
+//
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/taironas/gonawin/config"
+	mdl "github.com/taironas/gonawin/models"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	configFile := flag.String("config", "config.json", "configuration file")
+	data := flag.String("data", "gonawin.db", "file the entities are stored in when no SQL store is configured")
+	flag.Parse()
+
+	conf, err := config.ReadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("unable to read configuration %s: %v", *configFile, err)
+	}
+
+	var repos *mdl.Repositories
+	if len(conf.Store.Driver) > 0 {
+		repos, err = mdl.OpenSQLRepositories(conf.Store.Driver, conf.Store.DataSource)
+	} else {
+		repos, err = mdl.OpenFileRepositories(*data)
+	}
+	if err != nil {
+		log.Fatalf("unable to open store: %v", err)
+	}
+
+	s := newServer(repos, conf)
+	if err = s.createConfigUsers(); err != nil {
+		log.Fatalf("unable to create the users of the configuration: %v", err)
+	}
+
+	s.routes()
+	http.Handle("/j/", s)
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
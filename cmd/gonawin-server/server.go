@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/taironas/route"
+
+	"github.com/taironas/gonawin/config"
+	"github.com/taironas/gonawin/helpers"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// server serves the /j/ API from repositories.
+//
+type server struct {
+	repos    *mdl.Repositories
+	config   *config.GwConfig
+	router   *route.Router
+	patterns [][]string // routes of the router split on "/".
+}
+
+func newServer(repos *mdl.Repositories, conf *config.GwConfig) *server {
+	return &server{repos: repos, config: conf, router: new(route.Router)}
+}
+
+// routes registers the routes served outside of App Engine.
+//
+func (s *server) routes() {
+	s.handle("/j/users/show/:userId", s.authorized(s.showUser))
+	s.handle("/j/teams/show/:teamId", s.authorized(s.showTeam))
+	s.handle("/j/teams/new", s.authorized(s.newTeam))
+	s.handle("/j/teams/join/:teamId", s.authorized(s.joinTeam))
+	s.handle("/j/tournaments/show/:tournamentId", s.authorized(s.showTournament))
+	s.handle("/j/activities", s.authorized(s.activities))
+}
+
+// handle registers the handler of a route.
+//
+func (s *server) handle(pattern string, f func(http.ResponseWriter, *http.Request) error) {
+	s.patterns = append(s.patterns, splitPath(pattern))
+	s.router.HandleFunc(pattern, checkErrors(f))
+}
+
+// ServeHTTP routes the requests, the routes not served outside of App Engine answer 501 Not Implemented.
+//
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.serves(r.URL.Path) {
+		http.Error(w, helpers.ErrorCodeNotSupported, http.StatusNotImplemented)
+		return
+	}
+	s.router.ServeHTTP(w, r)
+}
+
+// serves reports whether a path matches one of the routes, ":name" segments match any value.
+//
+func (s *server) serves(path string) bool {
+	segments := splitPath(path)
+	for _, p := range s.patterns {
+		if len(p) != len(segments) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if !strings.HasPrefix(p[i], ":") && p[i] != segments[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// checkErrors writes the status of the error returned by a handler, like handlers.ErrorHandler.
+//
+func checkErrors(f func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := f(w, r)
+		if err == nil {
+			return
+		}
+		switch err.(type) {
+		case *helpers.BadRequest:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case *helpers.NotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case *helpers.Forbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case *helpers.Unauthorized:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case *helpers.InternalServerError:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "Sorry, something went wrong.", http.StatusInternalServerError)
+		}
+	}
+}
+
+// authorized runs a handler with the user of the request.
+// The user is the offline user in offline mode, otherwise the user whose authentication key
+// is in the Authorization header.
+//
+func (s *server) authorized(f func(http.ResponseWriter, *http.Request, *mdl.User) error) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var users []*mdl.User
+		var err error
+		if s.config.OfflineMode {
+			users, err = s.repos.Users.Find("Username", s.config.OfflineUser.Username)
+		} else if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); len(token) > 0 {
+			users, err = s.repos.Users.Find("Auth", token)
+		}
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return &helpers.BadRequest{Err: errors.New("Bad Authentication data")}
+		}
+		return f(w, r, users[0])
+	}
+}
+
+// createConfigUsers creates the offline user and the dev users of the configuration if they do not exist.
+// The offline user is an admin.
+//
+func (s *server) createConfigUsers() error {
+	if s.config.OfflineMode {
+		if err := s.createUser(s.config.OfflineUser, true); err != nil {
+			return err
+		}
+	}
+	for _, du := range s.config.DevUsers {
+		if err := s.createUser(du, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) createUser(cu config.User, isAdmin bool) error {
+	if len(cu.Username) == 0 {
+		return nil
+	}
+	users, err := s.repos.Users.Find("Username", cu.Username)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		log.Printf("user %s: authentication key %s", cu.Username, users[0].Auth)
+		return nil
+	}
+	u := &mdl.User{
+		Email:    cu.Email,
+		Username: cu.Username,
+		Name:     cu.Name,
+		IsAdmin:  isAdmin,
+		Auth:     mdl.GenerateAuthKey(),
+		Created:  time.Now(),
+	}
+	if err = s.repos.Users.Save(u); err != nil {
+		return err
+	}
+	log.Printf("user %s created: authentication key %s", u.Username, u.Auth)
+	return nil
+}
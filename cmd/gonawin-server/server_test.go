@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/taironas/gonawin/config"
+	activitiesctrl "github.com/taironas/gonawin/controllers/activities"
+	teamsctrl "github.com/taironas/gonawin/controllers/teams"
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// testServer returns a server of the users of the configuration on a file store,
+// and their authentication keys by username.
+func testServer(t *testing.T, path string) (*server, map[string]string) {
+	repos, err := mdl.OpenFileRepositories(path)
+	if err != nil {
+		t.Fatalf("unable to open file store: %v", err)
+	}
+	conf := &config.GwConfig{DevUsers: []config.User{
+		{Email: "john@example.com", Username: "john", Name: "John Snow"},
+		{Email: "arya@example.com", Username: "arya", Name: "Arya Stark"},
+	}}
+	s := newServer(repos, conf)
+	if err = s.createConfigUsers(); err != nil {
+		t.Fatalf("unable to create users: %v", err)
+	}
+	s.routes()
+
+	keys := make(map[string]string)
+	for _, cu := range conf.DevUsers {
+		users, err := repos.Users.Find("Username", cu.Username)
+		if err != nil || len(users) != 1 {
+			t.Fatalf("unable to find user %s: %v", cu.Username, err)
+		}
+		keys[cu.Username] = users[0].Auth
+	}
+	return s, keys
+}
+
+// serve sends a request with an authentication key to the server and decodes its JSON response in v.
+func serve(s *server, method, path, key, body string, v interface{}) int {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if len(key) > 0 {
+		r.Header.Set("Authorization", key)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code == http.StatusOK && v != nil {
+		json.NewDecoder(w.Body).Decode(v)
+	}
+	return w.Code
+}
+
+func TestServerTeams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonawin-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gonawin.db")
+
+	s, keys := testServer(t, path)
+
+	var created teamsctrl.NewTeamViewModel
+	if code := serve(s, "POST", "/j/teams/new", keys["john"], `{"Name":"night's watch","Description":"guards of the wall"}`, &created); code != http.StatusOK {
+		t.Fatalf("TestServerTeams(%q): got status %v wanted %v", "new team", code, http.StatusOK)
+	}
+	if created.Team.Id == nil || created.Team.Name == nil || *created.Team.Name != "night's watch" {
+		t.Fatalf("TestServerTeams(%q): got team %+v", "new team", created.Team)
+	}
+	teamID := *created.Team.Id
+
+	teamPath := func(action string) string {
+		return "/j/teams/" + action + "/" + strconv.FormatInt(teamID, 10)
+	}
+	if code := serve(s, "POST", teamPath("join"), keys["arya"], "", nil); code != http.StatusOK {
+		t.Errorf("TestServerTeams(%q): got status %v wanted %v", "join team", code, http.StatusOK)
+	}
+
+	var shown teamsctrl.ShowViewModel
+	if code := serve(s, "GET", teamPath("show"), keys["arya"], "", &shown); code != http.StatusOK {
+		t.Fatalf("TestServerTeams(%q): got status %v wanted %v", "show team", code, http.StatusOK)
+	}
+	if !shown.Joined || len(shown.Players) != 2 || shown.Team.Description == nil || *shown.Team.Description != "guards of the wall" {
+		t.Errorf("TestServerTeams(%q): got %+v", "show team", shown)
+	}
+
+	var activities activitiesctrl.IndexViewModel
+	if code := serve(s, "GET", "/j/activities?cursor=", keys["john"], "", &activities); code != http.StatusOK {
+		t.Fatalf("TestServerTeams(%q): got status %v wanted %v", "activities", code, http.StatusOK)
+	}
+	if len(activities.Results.Activities) == 0 || activities.Results.Activities[0].Object == nil || activities.Results.Activities[0].Object.DisplayName != "night's watch" {
+		t.Errorf("TestServerTeams(%q): got %+v", "activities", activities.Results)
+	}
+
+	tests := []struct {
+		title  string
+		method string
+		path   string
+		key    string
+		code   int
+	}{
+		{"unknown team", "GET", "/j/teams/show/12345", keys["john"], http.StatusNotFound},
+		{"no authentication key", "GET", teamPath("show"), "", http.StatusBadRequest},
+		{"route of App Engine", "GET", "/j/tournaments", keys["john"], http.StatusNotImplemented},
+	}
+	for _, test := range tests {
+		if code := serve(s, test.method, test.path, test.key, "", nil); code != test.code {
+			t.Errorf("TestServerTeams(%q): got status %v wanted %v", test.title, code, test.code)
+		}
+	}
+
+	// the changes are kept in the file store.
+	var repos *mdl.Repositories
+	if repos, err = mdl.OpenFileRepositories(path); err != nil {
+		t.Fatalf("TestServerTeams: unable to reopen file store: %v", err)
+	}
+	var team *mdl.Team
+	if team, err = repos.Teams.ByID(teamID); err != nil || len(team.UserIds) != 2 || team.MembersCount != 2 {
+		t.Errorf("TestServerTeams(%q): got %+v, %v", "reopened store", team, err)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Command webhookreceiver runs a local receiver of gonawin webhooks.
+// It verifies the signature of each request and prints it, use it to test
+// the webhooks of a team without any external service.
+//
+//	webhookreceiver -addr :9090 -secret <webhook secret> -failures 2
+//
+// With the -failures flag, the first attempts of each delivery are answered
+// with an error so that the retries of the task queue can be verified.
+//
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/taironas/gonawin/helpers/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "address to listen on")
+	secret := flag.String("secret", "", "secret of the webhook")
+	failures := flag.Int("failures", 0, "number of attempts of each delivery to answer with an error")
+	flag.Parse()
+
+	receiver := &webhook.Receiver{Secret: *secret, Failures: *failures}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		receiver.ServeHTTP(w, r)
+		received := receiver.Received()
+		if len(received) == 0 {
+			return
+		}
+		rec := received[len(received)-1]
+		log.Printf("%s delivery %s attempt %d: verified=%v status=%d\n%s", rec.Event, rec.ID, rec.Attempt, rec.Verified, rec.Status, rec.Payload)
+	})
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -18,6 +18,20 @@ type GwConfig struct {
 	Twitter     Twitter    `json:"twitter"`
 	Facebook    Facebook   `json:"facebook"`
 	GooglePlus  GooglePlus `json:"googlePlus"`
+	Results     []Results  `json:"results"`
+	Slack       Slack      `json:"slack"`
+	// LegacyAuthUntil is the last day, "2006-01-02", the legacy authentication key of a user is accepted
+	// instead of a session token. It is not accepted if empty.
+	LegacyAuthUntil string `json:"legacyAuthUntil"`
+	// MagicLink enables the sign in of local accounts with a link sent by email.
+	MagicLink bool `json:"magicLink"`
+	// RequireTwoFactor requires the admins to enable two-factor authentication before the sensitive admin actions.
+	// Otherwise the second factor is only checked for the admins who enabled it.
+	RequireTwoFactor bool `json:"requireTwoFactor"`
+	// Providers are the OAuth 2.0 and OpenID Connect providers users can sign in with.
+	Providers []Provider `json:"providers"`
+	// Store selects where gonawin-server keeps the entities.
+	Store Store `json:"store"`
 }
 
 // User is the user structure used for authentication.
@@ -47,6 +61,49 @@ type GooglePlus struct {
 	ClientId string `json:"clientId"`
 }
 
+// Results holds data needed to fetch the results of a tournament from an external feed.
+//
+type Results struct {
+	TournamentId int64  `json:"tournamentId"`
+	Provider     string `json:"provider"` // "file" or "http"
+	Source       string `json:"source"`   // path of the file or URL of the feed.
+	Format       string `json:"format"`   // "json" or "csv"
+}
+
+// Slack holds data needed to answer the Slack slash commands.
+//
+type Slack struct {
+	SigningSecret string `json:"signingSecret"` // signing secret of the Slack app.
+	TournamentId  int64  `json:"tournamentId"`  // tournament the commands apply to.
+}
+
+// Provider holds data needed to sign in with an OAuth 2.0 or OpenID Connect provider.
+// Type is "oidc", the endpoints are then discovered from the issuer, "oauth2" or "twitter".
+//
+type Provider struct {
+	Name         string            `json:"name"` // name of the provider in the routes, e.g. "github".
+	Type         string            `json:"type"`
+	ClientId     string            `json:"clientId"`
+	ClientSecret string            `json:"clientSecret"`
+	Issuer       string            `json:"issuer"`       // oidc: URL of the issuer.
+	AuthURL      string            `json:"authUrl"`      // oauth2: authorization endpoint.
+	TokenURL     string            `json:"tokenUrl"`     // oauth2: token endpoint.
+	UserInfoURL  string            `json:"userInfoUrl"`  // oauth2: endpoint returning the user in JSON.
+	TokenInfoURL string            `json:"tokenInfoUrl"` // endpoint returning the client an access token was issued to, needed to sign in with an access token.
+	Scopes       []string          `json:"scopes"`
+	Fields       map[string]string `json:"fields"`     // oauth2: user info fields of the "id", "email", "name" and "username".
+	TrustEmail   bool              `json:"trustEmail"` // oauth2: the provider only returns verified emails.
+}
+
+// Store holds data needed to open the store of gonawin-server.
+// The entities are kept in a local file when Driver is empty, otherwise in the SQL database of the
+// database/sql Driver, e.g. "sqlite3", at DataSource.
+//
+type Store struct {
+	Driver     string `json:"driver"`
+	DataSource string `json:"dataSource"`
+}
+
 // ReadConfig reads configuration file and return it.
 //
 func ReadConfig(filename string) (*GwConfig, error) {
@@ -26,15 +26,17 @@ import (
 
 	"github.com/taironas/gonawin/extract"
 	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
 	templateshlp "github.com/taironas/gonawin/helpers/templates"
 
 	mdl "github.com/taironas/gonawin/models"
 )
 
 // Index activity handler, use it to get the activities of a user.
-// You can pass a 'count' and a 'page' param to the http.Request to
-// filter the activities that you want. default values are 20 and 1
-// respectively.
+// You can pass a 'count' and a 'cursor' param to the http.Request to
+// filter the activities that you want, the default count is 20 and the
+// cursor of the next page is returned as 'nextCursor', an empty cursor
+// gets the first page. The legacy 'page' param is used when no cursor is given.
 //
 func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	if r.Method != "GET" {
@@ -46,25 +48,42 @@ func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	extract := extract.NewContext(c, desc, r)
 
 	count := extract.Count()
-	page := extract.Page()
 
-	activities := mdl.FindActivities(c, u, count, page)
-
-	lastPage := math.Ceil(float64(int64(len(activities)) / count))
+	var activities []*mdl.Activity
+	var page, lastPage int64
+	var next string
+	var err error
+	if extract.LegacyPage() {
+		page = extract.Page()
+		activities, err = mdl.Repos(c).Activities.ByUser(u, count, page)
+		lastPage = int64(math.Ceil(float64(int64(len(activities)) / count)))
+	} else {
+		activities, next, err = mdl.Repos(c).Activities.ByUserCursor(u, count, extract.Cursor())
+	}
+	if err == mdl.ErrInvalidCursor {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeInvalidCursor)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to find activities of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
 
-	vm := buildIndexActivitiesViewModel(activities, count, page, int64(lastPage))
+	vm := BuildIndexViewModel(activities, count, page, lastPage, next)
 
 	return templateshlp.RenderJSON(w, c, vm)
 }
 
-type indexActivitiesViewModel struct {
+// IndexViewModel is the view model of the Index handler.
+//
+type IndexViewModel struct {
 	Results activitiesViewModel
 	Status  string
 }
 
-func buildIndexActivitiesViewModel(activities []*mdl.Activity, perPage, currentPage, lastPage int64) indexActivitiesViewModel {
-	return indexActivitiesViewModel{
-		Results: buildActivitiesViewModel(activities, perPage, currentPage, lastPage),
+// BuildIndexViewModel returns the view model of a page of activities, next is the cursor of the next page.
+//
+func BuildIndexViewModel(activities []*mdl.Activity, perPage, currentPage, lastPage int64, next string) IndexViewModel {
+	return IndexViewModel{
+		Results: buildActivitiesViewModel(activities, perPage, currentPage, lastPage, next),
 		Status:  "OK",
 	}
 }
@@ -74,15 +93,17 @@ type activitiesViewModel struct {
 	PerPage     int64
 	CurrentPage int64
 	LastPage    int64
+	NextCursor  string `json:"nextCursor,omitempty"`
 	Activities  []mdl.ActivityJSON
 }
 
-func buildActivitiesViewModel(activities []*mdl.Activity, perPage, currentPage, lastPage int64) activitiesViewModel {
+func buildActivitiesViewModel(activities []*mdl.Activity, perPage, currentPage, lastPage int64, next string) activitiesViewModel {
 	return activitiesViewModel{
 		Total:       int64(len(activities)),
 		PerPage:     perPage,
 		CurrentPage: currentPage,
 		LastPage:    lastPage,
+		NextCursor:  next,
 		Activities:  buildJSONActivities(activities),
 	}
 }
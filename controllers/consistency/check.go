@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package consistency provides the JSON handlers to check the links between the entities of gonawin app.
+package consistency
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"appengine"
+
+	"github.com/taironas/route"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+var checkFieldsToKeep = []string{"Id", "Fix", "Kind", "Checked", "Inconsistencies", "Fixed", "Problems", "Started", "Finished"}
+
+// Check handler, use it to start a consistency check. The users, teams, tournaments, predicts, scores and
+// accuracies are checked in batches by a chain of tasks, which report the dangling ids, the asymmetric links
+// and the wrong members counts. Set the 'fix' param to 'true' to also repair the inconsistencies found.
+//
+//	POST	/j/consistency/check?fix=:fix
+//
+func Check(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Consistency Check Handler:"
+
+	fix := r.FormValue("fix") == "true"
+
+	check, err := mdl.CreateConsistencyCheck(c, fix)
+	if err != nil {
+		log.Errorf(c, "%s unable to create check: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeConsistencyCheckCannotStart)}
+	}
+	if err = check.Enqueue(c); err != nil {
+		log.Errorf(c, "%s unable to add task to taskqueue. %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeConsistencyCheckCannotStart)}
+	}
+
+	var cJSON mdl.ConsistencyCheckJSON
+	helpers.InitPointerStructure(check, &cJSON, checkFieldsToKeep)
+
+	msg := fmt.Sprintf("The consistency check %d has started.", check.Id)
+	if fix {
+		msg = fmt.Sprintf("The consistency check %d has started, the inconsistencies found will be fixed.", check.Id)
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Check       mdl.ConsistencyCheckJSON
+	}{
+		msg,
+		cJSON,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// CheckStatus handler, use it to get the progress and the report of a consistency check.
+//
+//	GET	/j/consistency/check/:checkId
+//
+func CheckStatus(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Consistency Check Status Handler:"
+
+	strCheckID, err := route.Context.Get(r, "checkId")
+	if err != nil {
+		log.Errorf(c, "%s error getting check id, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeConsistencyCheckNotFound)}
+	}
+
+	var checkID int64
+	if checkID, err = strconv.ParseInt(strCheckID, 0, 64); err != nil {
+		log.Errorf(c, "%s error converting check id from string to int64, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeConsistencyCheckNotFound)}
+	}
+
+	var check *mdl.ConsistencyCheck
+	if check, err = mdl.ConsistencyCheckByID(c, checkID); err != nil {
+		log.Errorf(c, "%s check %v not found: %v", desc, checkID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeConsistencyCheckNotFound)}
+	}
+
+	var cJSON mdl.ConsistencyCheckJSON
+	helpers.InitPointerStructure(check, &cJSON, checkFieldsToKeep)
+
+	data := struct {
+		Check mdl.ConsistencyCheckJSON
+	}{
+		cJSON,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
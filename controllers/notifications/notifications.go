@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package notifications provides the JSON handlers to get and manage the notifications of a user.
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// Index notification handler, use it to get the notifications of a user.
+// You can pass a 'count' and a 'page' param to the http.Request to
+// filter the notifications that you want. default values are 20 and 1
+// respectively.
+// Set the 'unread' param to 'true' to only get the unread notifications.
+//
+//	GET	/j/notifications
+//
+func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	desc := "Index notification handler:"
+	c := appengine.NewContext(r)
+	extract := extract.NewContext(c, desc, r)
+
+	count := extract.Count()
+	page := extract.Page()
+	unreadOnly := r.FormValue("unread") == "true"
+
+	notifications := mdl.FindNotifications(c, u, unreadOnly, count, page)
+
+	fieldsToKeep := []string{"Id", "Type", "Message", "Object", "Read", "Created"}
+	notificationsJSON := make([]mdl.NotificationJSON, len(notifications))
+	helpers.TransformFromArrayOfPointers(&notifications, &notificationsJSON, fieldsToKeep)
+
+	data := struct {
+		Notifications []mdl.NotificationJSON
+		Unread        int
+		PerPage       int64
+		CurrentPage   int64
+	}{
+		notificationsJSON,
+		mdl.UnreadNotificationsCount(c, u),
+		count,
+		page,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// Read notification handler, use it to mark notifications of a user as read.
+// Pass the ids of the notifications in the 'ids' param, separated by commas.
+// All the notifications of the user are marked as read when no id is given.
+//
+//	POST	/j/notifications/read?ids=:ids
+//
+func Read(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	desc := "Read notification handler:"
+	c := appengine.NewContext(r)
+
+	var ids []int64
+	if s := r.FormValue("ids"); len(s) > 0 {
+		for _, v := range strings.Split(s, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+			if err != nil {
+				log.Errorf(c, "%s error when extracting notification id: %v", desc, err)
+				return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotificationNotFound)}
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	if err := mdl.MarkNotificationsRead(c, u, ids); err != nil {
+		log.Errorf(c, "%s unable to mark notifications as read: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeNotificationCannotUpdate)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Unread      int
+	}{
+		"Notifications were marked as read.",
+		mdl.UnreadNotificationsCount(c, u),
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// Preferences notification handler, use it to get or set the channel used to deliver each type of notification.
+// To set a preference, POST the 'type' param (invitation, request, admin or result)
+// and the 'channel' param (inapp, email or none).
+//
+//	GET	/j/notifications/preferences
+//	POST	/j/notifications/preferences?type=:type&channel=:channel
+//
+func Preferences(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" && r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	desc := "Preferences notification handler:"
+	c := appengine.NewContext(r)
+
+	var msg string
+	if r.Method == "POST" {
+		notificationType := r.FormValue("type")
+		channel := r.FormValue("channel")
+		if !mdl.IsNotificationTypeValid(notificationType) || !mdl.IsChannelValid(channel) {
+			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotificationPreferenceInvalid)}
+		}
+		if err := u.SetNotificationChannel(c, notificationType, channel); err != nil {
+			log.Errorf(c, "%s unable to set notification channel: %v", desc, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeUserCannotUpdate)}
+		}
+		msg = fmt.Sprintf("Notifications of type %s are now delivered with channel %s.", notificationType, channel)
+	}
+
+	preferences := make([]mdl.NotificationPreference, len(mdl.NotificationTypes))
+	for i, t := range mdl.NotificationTypes {
+		preferences[i] = mdl.NotificationPreference{Type: t, Channel: u.NotificationChannel(t)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Preferences []mdl.NotificationPreference
+	}{
+		msg,
+		preferences,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
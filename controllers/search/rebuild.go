@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"appengine"
+
+	"github.com/taironas/route"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+var rebuildFieldsToKeep = []string{"Id", "DryRun", "Kind", "Stage", "Checked", "Inconsistencies", "Legacy", "Problems", "Started", "Finished"}
+
+// Rebuild handler, use it to start a rebuild of the search index. The teams, tournaments and users are
+// checked against the index in batches by a chain of tasks, which repair the inconsistencies found.
+// Set the 'dryrun' param to 'true' to only report the inconsistencies.
+//
+//	POST	/j/search/rebuild?dryrun=:dryrun
+//
+func Rebuild(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Search Rebuild Handler:"
+
+	dryRun := r.FormValue("dryrun") == "true"
+
+	rebuild, err := mdl.CreateSearchRebuild(c, dryRun)
+	if err != nil {
+		log.Errorf(c, "%s unable to create rebuild: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSearchRebuildCannotStart)}
+	}
+	if err = rebuild.Enqueue(c); err != nil {
+		log.Errorf(c, "%s unable to add task to taskqueue. %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSearchRebuildCannotStart)}
+	}
+
+	var rJSON mdl.SearchRebuildJSON
+	helpers.InitPointerStructure(rebuild, &rJSON, rebuildFieldsToKeep)
+
+	msg := fmt.Sprintf("The rebuild %d of the search index has started.", rebuild.Id)
+	if dryRun {
+		msg = fmt.Sprintf("The dry run %d of the rebuild of the search index has started.", rebuild.Id)
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Rebuild     mdl.SearchRebuildJSON
+	}{
+		msg,
+		rJSON,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// RebuildStatus handler, use it to get the progress and the report of a rebuild of the search index.
+//
+//	GET	/j/search/rebuild/:rebuildId
+//
+func RebuildStatus(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Search Rebuild Status Handler:"
+
+	strRebuildID, err := route.Context.Get(r, "rebuildId")
+	if err != nil {
+		log.Errorf(c, "%s error getting rebuild id, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSearchRebuildNotFound)}
+	}
+
+	var rebuildID int64
+	if rebuildID, err = strconv.ParseInt(strRebuildID, 0, 64); err != nil {
+		log.Errorf(c, "%s error converting rebuild id from string to int64, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSearchRebuildNotFound)}
+	}
+
+	var rebuild *mdl.SearchRebuild
+	if rebuild, err = mdl.SearchRebuildByID(c, rebuildID); err != nil {
+		log.Errorf(c, "%s rebuild %v not found: %v", desc, rebuildID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSearchRebuildNotFound)}
+	}
+
+	var rJSON mdl.SearchRebuildJSON
+	helpers.InitPointerStructure(rebuild, &rJSON, rebuildFieldsToKeep)
+
+	data := struct {
+		Rebuild mdl.SearchRebuildJSON
+	}{
+		rJSON,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
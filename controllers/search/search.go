@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package search provides the JSON handlers to search teams, tournaments and users together.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+type resultViewModel struct {
+	Kind     string
+	Id       int64 `json:"Id"`
+	Name     string
+	ImageURL string `json:",omitempty"`
+}
+
+// Search handler returns the teams, tournaments and users matching the query 'q' in a JSON format,
+// the best matches first. Use the 'count' and 'page' params to get a page of the results,
+// default values are 20 and 1 respectively.
+//
+//	GET	/j/search?q=:q
+//
+func Search(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+
+	keywords := r.FormValue("q")
+	if r.Method != "GET" || len(keywords) == 0 {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Search Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	count := extract.Count()
+	page := extract.Page()
+
+	results, total, err := mdl.Search(c, mdl.SearchKinds, keywords, count, page)
+	if err != nil {
+		log.Errorf(c, "%s error occurred during search: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeCannotSearch)}
+	}
+
+	var rvm []resultViewModel
+	if rvm, err = buildResultsViewModel(c, results); err != nil {
+		log.Errorf(c, "%s unable to get the entities of the results: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeCannotSearch)}
+	}
+
+	var msg string
+	if total == 0 {
+		msg = fmt.Sprintf("Oops! Your search - %s - did not match any team, tournament or user.", keywords)
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Results     []resultViewModel
+		Total       int
+		PerPage     int64
+		CurrentPage int64
+	}{
+		msg,
+		rvm,
+		total,
+		count,
+		page,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// buildResultsViewModel gets the teams, tournaments and users of the results and returns them in the order of the results.
+// The results whose entity no longer exists are skipped.
+//
+func buildResultsViewModel(c appengine.Context, results []mdl.SearchResult) ([]resultViewModel, error) {
+	byKind := make(map[string]map[int64]resultViewModel)
+	for _, kind := range mdl.SearchKinds {
+		byKind[kind] = make(map[int64]resultViewModel)
+	}
+
+	teams, err := mdl.Repos(c).Teams.ByIDs(mdl.SearchResultIds(results, "Team"))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		byKind["Team"][t.Id] = resultViewModel{"Team", t.Id, t.Name, helpers.TeamImageURL(t.Name, t.Id)}
+	}
+
+	var tournaments []*mdl.Tournament
+	if tournaments, err = mdl.Repos(c).Tournaments.ByIDs(mdl.SearchResultIds(results, "Tournament")); err != nil {
+		return nil, err
+	}
+	for _, t := range tournaments {
+		byKind["Tournament"][t.Id] = resultViewModel{"Tournament", t.Id, t.Name, helpers.TournamentImageURL(t.Name, t.Id)}
+	}
+
+	var users []*mdl.User
+	if users, err = mdl.Repos(c).Users.ByIDs(mdl.SearchResultIds(results, "User")); err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		byKind["User"][u.Id] = resultViewModel{"User", u.Id, u.Username, helpers.UserImageURL(u.Name, u.Id)}
+	}
+
+	rvm := make([]resultViewModel, 0, len(results))
+	for _, res := range results {
+		if vm, ok := byKind[res.Kind][res.Id]; ok {
+			rvm = append(rvm, vm)
+		}
+	}
+	return rvm, nil
+}
+
+// maxSuggestions is the maximum number of suggestions returned by the Suggest handler.
+const maxSuggestions = 50
+
+// Suggest handler returns the teams, tournaments and users whose words start with the words of the query 'q'
+// in a JSON format, to complete the query as the user types. Use the 'count' param to set the number of
+// suggestions, default value is 10, and the 'kind' param to only get teams, tournaments or users.
+//
+//	GET	/j/search/suggest?q=:q&kind=:kind
+//
+func Suggest(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+
+	query := r.FormValue("q")
+	if r.Method != "GET" || len(query) == 0 {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Search Suggest Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	count := extract.CountOrDefault(10)
+	if count > maxSuggestions {
+		count = maxSuggestions
+	}
+
+	var kinds []string
+	if kind := r.FormValue("kind"); len(kind) > 0 {
+		if !helpers.SliceContains(mdl.SearchKinds, kind) {
+			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+		}
+		kinds = []string{kind}
+	}
+
+	suggestions, err := mdl.Suggest(c, query, kinds, int(count))
+	if err != nil {
+		log.Errorf(c, "%s unable to get suggestions: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeCannotSearch)}
+	}
+
+	svm := make([]resultViewModel, len(suggestions))
+	for i, s := range suggestions {
+		svm[i] = resultViewModel{Kind: s.Kind, Id: s.Id, Name: s.Name}
+	}
+
+	data := struct {
+		Suggestions []resultViewModel
+	}{
+		svm,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
@@ -0,0 +1,426 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"appengine"
+	"appengine/datastore"
+	"appengine/mail"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	"github.com/taironas/gonawin/helpers/password"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+const accountEmailSender = "No Reply gonawin <no-reply@gonawin.com>"
+
+// renderSignin creates a session for a signed in user and renders the user data, as the other sign in handlers.
+//
+func renderSignin(w http.ResponseWriter, r *http.Request, c appengine.Context, desc string, user *mdl.User) error {
+	session, err := newSession(c, r, user)
+	if err != nil {
+		log.Errorf(c, "%s unable to create session for user %v: %v", desc, user.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotCreate)}
+	}
+
+	// Auth is left out, the session tokens are the credentials of the client.
+	fieldsToKeep := []string{"Id", "Username", "Name", "Alias", "Email", "Created", "IsAdmin", "TeamIds", "TournamentIds", "Score", "Timezone", "Language", "ReminderOptOutIds", "DigestFrequency"}
+	var uJSON mdl.UserJSON
+	helpers.InitPointerStructure(user, &uJSON, fieldsToKeep)
+
+	userData := struct {
+		User     mdl.UserJSON
+		ImageURL string
+		Session  *mdl.SessionTokens
+	}{
+		uJSON,
+		helpers.UserImageURL(user.Username, user.Id),
+		session,
+	}
+
+	return templateshlp.RenderJSON(w, c, userData)
+}
+
+// sendAccountEmail sends an email with a token to the owner of a local account.
+//
+func sendAccountEmail(c appengine.Context, email, subject, body string) error {
+	msg := &mail.Message{
+		Sender:  accountEmailSender,
+		To:      []string{email},
+		Subject: subject,
+		Body:    body,
+	}
+	return mail.Send(c, msg)
+}
+
+// Register handler, use it to create a local account with an 'email' and a 'password'.
+// An email is sent to verify the email, the account can be used once verified.
+//
+//	POST	/j/auth/register
+//
+func Register(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Register Handler:"
+
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	if !helpers.IsEmailValid(email) {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsEmailInvalid)}
+	}
+	pass := r.FormValue("password")
+	if !helpers.IsPasswordValid(pass) {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsPasswordInvalid)}
+	}
+
+	hash, err := password.Hash(pass)
+	if err != nil {
+		log.Errorf(c, "%s unable to hash password: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotRegister)}
+	}
+
+	if _, err = mdl.CreateLocalAccount(c, email, hash); err == mdl.ErrAccountExists {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsAccountExists)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to create account: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotRegister)}
+	}
+
+	var token string
+	if token, err = mdl.CreateAccountToken(c, email, mdl.TokenVerifyEmail); err != nil {
+		log.Errorf(c, "%s unable to create verification token: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotRegister)}
+	}
+
+	link := fmt.Sprintf("https://%s/j/auth/verify?token=%s", r.Host, token)
+	if err = sendAccountEmail(c, email, "gonawin: verify your email", fmt.Sprintf(verifyEmailMessage, link)); err != nil {
+		log.Errorf(c, "%s couldn't send email: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotSendEmail)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		fmt.Sprintf("We sent an email to %s, follow its link to verify your email.", email),
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// Verify handler, use it to verify the email of a local account with the 'token' sent by email.
+// The account is linked to the user with the same email, the user is created if there is none.
+// It redirects to the home page.
+//
+//	GET	/j/auth/verify
+//
+func Verify(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Verify Email Handler:"
+
+	email, err := mdl.UseAccountToken(c, r.FormValue("token"), mdl.TokenVerifyEmail)
+	if err != nil {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsTokenInvalid)}
+	}
+
+	var account *mdl.LocalAccount
+	if account, err = mdl.LocalAccountByEmail(c, email); err != nil {
+		log.Errorf(c, "%s account of %v not found: %v", desc, email, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsTokenInvalid)}
+	}
+
+	account.Verified = true
+	if _, err = account.Link(c); err != nil {
+		log.Errorf(c, "%s unable to link account of %v: %v", desc, email, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
+	}
+
+	http.Redirect(w, r, "https://"+r.Host+"/#/", http.StatusFound)
+	return nil
+}
+
+// Login handler, use it to sign in with the 'email' and the 'password' of a verified local account.
+// It returns the JSON data of the user and the tokens of a new session.
+//
+//	POST	/j/auth/login
+//
+func Login(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Login Handler:"
+
+	account, err := mdl.LocalAccountByEmail(c, strings.TrimSpace(r.FormValue("email")))
+	if err != nil || !password.Verify(account.PasswordHash, r.FormValue("password")) {
+		return &helpers.Unauthorized{Err: errors.New(helpers.ErrorCodeSessionsWrongCredentials)}
+	}
+	if !account.Verified {
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeSessionsEmailNotVerified)}
+	}
+
+	var user *mdl.User
+	if user, err = mdl.Repos(c).Users.ByID(account.UserId); err != nil {
+		log.Errorf(c, "%s user %v of account %v not found: %v", desc, account.UserId, account.Email, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
+	}
+
+	return renderSignin(w, r, c, desc, user)
+}
+
+// ForgotPassword handler, use it to get an email to reset the password of a local account.
+// The response does not tell whether the 'email' has an account.
+//
+//	POST	/j/auth/password/forgot
+//
+func ForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Forgot Password Handler:"
+
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	if account, err := mdl.LocalAccountByEmail(c, email); err == nil && account.Verified {
+		var token string
+		if token, err = mdl.CreateAccountToken(c, email, mdl.TokenResetPass); err != nil {
+			log.Errorf(c, "%s unable to create reset token: %v", desc, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotSendEmail)}
+		}
+		if err = sendAccountEmail(c, email, "gonawin: reset your password", fmt.Sprintf(resetPasswordMessage, token)); err != nil {
+			log.Errorf(c, "%s couldn't send email: %v", desc, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotSendEmail)}
+		}
+	} else if err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(c, "%s unable to get account: %v", desc, err)
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		fmt.Sprintf("If %s has an account, we sent it an email to reset its password.", email),
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// ResetPassword handler, use it to set a new 'password' with the 'token' sent by email.
+// The sessions of the user are revoked.
+//
+//	POST	/j/auth/password/reset
+//
+func ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Reset Password Handler:"
+
+	pass := r.FormValue("password")
+	if !helpers.IsPasswordValid(pass) {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsPasswordInvalid)}
+	}
+
+	email, err := mdl.UseAccountToken(c, r.FormValue("token"), mdl.TokenResetPass)
+	if err != nil {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsTokenInvalid)}
+	}
+
+	var account *mdl.LocalAccount
+	if account, err = mdl.LocalAccountByEmail(c, email); err != nil {
+		log.Errorf(c, "%s account of %v not found: %v", desc, email, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsTokenInvalid)}
+	}
+
+	if account.PasswordHash, err = password.Hash(pass); err != nil {
+		log.Errorf(c, "%s unable to hash password: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	if err = account.Update(c); err != nil {
+		log.Errorf(c, "%s unable to update account: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	if err = mdl.DestroySessions(c, account.UserId); err != nil {
+		log.Errorf(c, "%s unable to revoke sessions of user %v: %v", desc, account.UserId, err)
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		"Your password has been changed, you can now sign in.",
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// MagicLink handler, use it to get an email with a link to sign in.
+// The link signs in the user with the same 'email', the user is created if there is none.
+// It is only available if 'magicLink' is set in the config file.
+//
+//	POST	/j/auth/magic
+//
+func MagicLink(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Magic Link Handler:"
+
+	if !config.MagicLink {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsMagicLinkDisabled)}
+	}
+
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	if !helpers.IsEmailValid(email) {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsEmailInvalid)}
+	}
+
+	token, err := mdl.CreateAccountToken(c, email, mdl.TokenMagicLink)
+	if err != nil {
+		log.Errorf(c, "%s unable to create magic link token: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotSendEmail)}
+	}
+
+	link := fmt.Sprintf("https://%s/j/auth/magic/signin?token=%s", r.Host, token)
+	if err = sendAccountEmail(c, email, "gonawin: sign in", fmt.Sprintf(magicLinkMessage, link)); err != nil {
+		log.Errorf(c, "%s couldn't send email: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotSendEmail)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		fmt.Sprintf("We sent an email to %s, follow its link to sign in.", email),
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// MagicSignin handler, the link of the magic link email.
+// It redirects to the app with the 'token', which the app exchanges with MagicUser.
+// The token is not used here so that the session tokens are never the response of a link.
+//
+//	GET	/j/auth/magic/signin
+//
+func MagicSignin(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	if !config.MagicLink {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsMagicLinkDisabled)}
+	}
+
+	http.Redirect(w, r, "https://"+r.Host+"/#/auth/magic/callback?token="+url.QueryEscape(r.FormValue("token")), http.StatusFound)
+	return nil
+}
+
+// MagicUser handler, use it to sign in with the 'token' of a magic link.
+// The token can only be used once.
+// It returns the JSON data of the user and the tokens of a new session.
+//
+//	POST	/j/auth/magic/user
+//
+func MagicUser(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Magic User Handler:"
+
+	if !config.MagicLink {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsMagicLinkDisabled)}
+	}
+
+	email, err := mdl.UseAccountToken(c, r.FormValue("token"), mdl.TokenMagicLink)
+	if err != nil {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsTokenInvalid)}
+	}
+
+	var user *mdl.User
+	if account, err := mdl.LocalAccountByEmail(c, email); err == nil {
+		// following the link proves the account owns the email.
+		account.Verified = true
+		user, err = account.Link(c)
+		if err != nil {
+			log.Errorf(c, "%s unable to link account of %v: %v", desc, email, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
+		}
+	} else {
+		if user, err = mdl.SigninUserByEmail(c, email); err != nil {
+			log.Errorf(c, "%s unable to signin user %v: %v", desc, email, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
+		}
+	}
+
+	return renderSignin(w, r, c, desc, user)
+}
+
+const verifyEmailMessage = `
+Hi there,
+
+Welcome to gonawin! Please verify your email by following this link:
+
+%s
+
+If you did not create an account on gonawin, you can ignore this email.
+
+Have fun,
+Your friends @ Gonawin
+`
+
+const resetPasswordMessage = `
+Hi there,
+
+Someone asked to reset the password of your gonawin account. Use this code to set a new password:
+
+%s
+
+The code is valid for one hour. If you did not ask to reset your password, you can ignore this email.
+
+Have fun,
+Your friends @ Gonawin
+`
+
+const magicLinkMessage = `
+Hi there,
+
+Follow this link to sign in to gonawin:
+
+%s
+
+The link is valid for 15 minutes and can only be used once. If you did not ask to sign in, you can ignore this email.
+
+Have fun,
+Your friends @ Gonawin
+`
@@ -0,0 +1,299 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"appengine"
+	"appengine/urlfetch"
+
+	"github.com/taironas/route"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	"github.com/taironas/gonawin/helpers/provider"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// loginCookiePrefix is the prefix of the cookie binding a login to the browser which started it.
+const loginCookiePrefix = "gw_login_"
+
+// routeProvider returns the provider of the 'provider' route param.
+//
+func routeProvider(c appengine.Context, r *http.Request, desc string) (provider.Provider, error) {
+	name, err := route.Context.Get(r, "provider")
+	if err != nil {
+		log.Errorf(c, "%s error getting provider name, err:%v", desc, err)
+		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsProviderNotFound)}
+	}
+	p, ok := providers.Get(name)
+	if !ok {
+		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsProviderNotFound)}
+	}
+	return p, nil
+}
+
+// callbackURL returns the URL the provider redirects the user to once signed in.
+// Twitter keeps the callback URL registered for the application.
+//
+func callbackURL(r *http.Request, p provider.Provider) string {
+	if _, ok := p.(*provider.Twitter); ok {
+		return "https://" + r.Host + twitterCallbackURL
+	}
+	return "https://" + r.Host + "/j/auth/oauth/" + p.Name() + "/callback"
+}
+
+// setLoginCookie sets the cookie binding a login of a provider to the browser, an empty value deletes it.
+//
+func setLoginCookie(w http.ResponseWriter, p provider.Provider, value string) {
+	cookie := http.Cookie{
+		Name:     loginCookiePrefix + p.Name(),
+		Value:    value,
+		Path:     "/j/auth/",
+		MaxAge:   int(mdl.LoginStateDuration.Seconds()),
+		Secure:   !appengine.IsDevAppServer(),
+		HttpOnly: true,
+	}
+	if len(value) == 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// beginLogin starts a login with a provider and returns the URL the user is redirected to.
+// The state of the login is stored until the callback, bound to the browser with a cookie.
+// If linkUserID is set, the identity is linked to this user instead of signing in.
+//
+func beginLogin(w http.ResponseWriter, r *http.Request, c appengine.Context, desc string, p provider.Provider, linkUserID int64) (*provider.Login, string, error) {
+	login, err := provider.NewLogin()
+	if err != nil {
+		log.Errorf(c, "%s unable to create login: %v", desc, err)
+		return nil, "", &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotStartLogin)}
+	}
+
+	var authURL string
+	if authURL, err = p.Begin(urlfetch.Client(c), login, callbackURL(r, p)); err != nil {
+		log.Errorf(c, "%s unable to begin login with %v: %v", desc, p.Name(), err)
+		return nil, "", &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotStartLogin)}
+	}
+
+	binding := mdl.GenerateAuthKey()
+	s := &mdl.LoginState{Provider: p.Name(), State: login.State, Nonce: login.Nonce, Verifier: login.Verifier, Secret: login.Secret, LinkUserId: linkUserID}
+	if len(binding) == 0 {
+		err = errors.New("unable to generate binding")
+	} else {
+		err = mdl.CreateLoginState(c, s, binding)
+	}
+	if err != nil {
+		log.Errorf(c, "%s unable to store login state: %v", desc, err)
+		return nil, "", &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotStartLogin)}
+	}
+
+	setLoginCookie(w, p, binding)
+	return login, authURL, nil
+}
+
+// signinIdentity returns the user of an identity, it is created if needed.
+// If linkUserID is set, the identity is linked to this user.
+//
+func signinIdentity(c appengine.Context, id *provider.Identity, linkUserID int64) (*mdl.User, error) {
+	if linkUserID == 0 {
+		profile := mdl.IdentityProfile{Email: id.Email, EmailVerified: id.EmailVerified, Username: id.Username, Name: id.Name}
+		return mdl.SigninIdentity(c, id.Provider, id.Subject, profile)
+	}
+
+	email := ""
+	if id.EmailVerified {
+		email = id.Email
+	}
+	if _, err := mdl.LinkIdentity(c, linkUserID, id.Provider, id.Subject, email); err != nil {
+		return nil, err
+	}
+	return mdl.Repos(c).Users.ByID(linkUserID)
+}
+
+// completeLogin ends a login with the params of the callback of a provider and returns the signed in user,
+// or the user the identity was linked to. The login state is used once and must have been started by the same browser.
+//
+func completeLogin(w http.ResponseWriter, r *http.Request, c appengine.Context, desc string, p provider.Provider) (*mdl.User, error) {
+	params := r.URL.Query()
+
+	var binding string
+	if cookie, err := r.Cookie(loginCookiePrefix + p.Name()); err == nil {
+		binding = cookie.Value
+	}
+	setLoginCookie(w, p, "")
+
+	s, err := mdl.UseLoginState(c, p.Name(), p.State(params), binding)
+	if err == mdl.ErrLoginStateInvalid {
+		log.Infof(c, "%s invalid login state for %v", desc, p.Name())
+		return nil, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsLoginStateInvalid)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to get login state: %v", desc, err)
+		return nil, &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
+	}
+
+	login := &provider.Login{State: s.State, Nonce: s.Nonce, Verifier: s.Verifier, Secret: s.Secret}
+
+	var id *provider.Identity
+	if id, err = p.Complete(urlfetch.Client(c), login, callbackURL(r, p), params); err != nil {
+		log.Errorf(c, "%s unable to complete login with %v: %v", desc, p.Name(), err)
+		return nil, &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotGetIdentity)}
+	}
+
+	var user *mdl.User
+	if user, err = signinIdentity(c, id, s.LinkUserId); err == mdl.ErrIdentityLinked {
+		return nil, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeIdentityLinked)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to signin %v user %v: %v", desc, p.Name(), id.Subject, err)
+		return nil, &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
+	}
+	return user, nil
+}
+
+// Providers handler, use it to get the names of the providers users can sign in with.
+//
+//	GET	/j/auth/providers
+//
+func Providers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+
+	data := struct {
+		Providers []string
+	}{
+		providers.Names(),
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// ProviderLogin handler, use it to start signing in with a provider.
+// It returns the URL of the provider the user is redirected to.
+//
+//	GET	/j/auth/oauth/:provider/login
+//
+func ProviderLogin(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Provider Login Handler:"
+
+	p, err := routeProvider(c, r, desc)
+	if err != nil {
+		return err
+	}
+
+	var authURL string
+	if _, authURL, err = beginLogin(w, r, c, desc, p, 0); err != nil {
+		return err
+	}
+
+	data := struct {
+		URL string `json:"Url"`
+	}{
+		authURL,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// ProviderLink handler, use it to start linking an account at a provider to the current user.
+// The login then goes through the callback and ProviderUser as a sign in.
+//
+//	GET	/j/auth/oauth/:provider/link
+//
+func ProviderLink(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Provider Link Handler:"
+
+	p, err := routeProvider(c, r, desc)
+	if err != nil {
+		return err
+	}
+
+	var authURL string
+	if _, authURL, err = beginLogin(w, r, c, desc, p, u.Id); err != nil {
+		return err
+	}
+
+	data := struct {
+		URL string `json:"Url"`
+	}{
+		authURL,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// ProviderCallback handler, the provider redirects the user to it once signed in.
+// It redirects the user to the app with the params of the callback.
+//
+//	GET	/j/auth/oauth/:provider/callback
+//
+func ProviderCallback(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Provider Callback Handler:"
+
+	p, err := routeProvider(c, r, desc)
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, "https://"+r.Host+"/#/auth/oauth/"+url.QueryEscape(p.Name())+"/callback?"+r.URL.Query().Encode(), http.StatusFound)
+	return nil
+}
+
+// ProviderUser handler, use it to end signing in with a provider with the params of its callback.
+// It returns the JSON data of the user and the tokens of a new session.
+//
+//	GET	/j/auth/oauth/:provider/user
+//
+func ProviderUser(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Provider User Handler:"
+
+	p, err := routeProvider(c, r, desc)
+	if err != nil {
+		return err
+	}
+
+	var user *mdl.User
+	if user, err = completeLogin(w, r, c, desc, p); err != nil {
+		return err
+	}
+	return renderSignin(w, r, c, desc, user)
+}
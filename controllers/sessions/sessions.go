@@ -21,19 +21,15 @@ import (
 	"errors"
 	golog "log"
 	"net/http"
-	"net/url"
 
 	"appengine"
-	"appengine/datastore"
 	"appengine/urlfetch"
 	"appengine/user"
 
-	oauth "github.com/garyburd/go-oauth/oauth"
-
 	"github.com/taironas/gonawin/helpers"
 	authhlp "github.com/taironas/gonawin/helpers/auth"
 	"github.com/taironas/gonawin/helpers/log"
-	"github.com/taironas/gonawin/helpers/memcache"
+	"github.com/taironas/gonawin/helpers/provider"
 	templateshlp "github.com/taironas/gonawin/helpers/templates"
 
 	gwconfig "github.com/taironas/gonawin/config"
@@ -41,11 +37,9 @@ import (
 )
 
 var (
-	config                 *gwconfig.GwConfig
-	twitterConfig          oauth.Client
-	twitterCallbackURL     string
-	googleVerifyTokenURL   string
-	facebookVerifyTokenURL string
+	config             *gwconfig.GwConfig
+	providers          *provider.Registry
+	twitterCallbackURL string
 )
 
 func init() {
@@ -54,19 +48,17 @@ func init() {
 	if config, err = gwconfig.ReadConfig(""); err != nil {
 		golog.Printf("Error: unable to read config file; %v", err)
 	}
-	// Set up a configuration for twitter.
-	twitterConfig = oauth.Client{
-		Credentials:                   oauth.Credentials{Token: config.Twitter.Token, Secret: config.Twitter.Secret},
-		TemporaryCredentialRequestURI: "https://api.twitter.com/oauth/request_token",
-		ResourceOwnerAuthorizationURI: "https://api.twitter.com/oauth/authorize",
-		TokenRequestURI:               "https://api.twitter.com/oauth/access_token",
+	// set up the sign in providers.
+	var errs []error
+	providers, errs = provider.NewRegistry(config)
+	for _, err = range errs {
+		golog.Printf("Error: unable to set up sign in provider; %v", err)
 	}
 	twitterCallbackURL = "/j/auth/twitter/callback"
-	googleVerifyTokenURL = "https://www.google.com/accounts/AuthSubTokenInfo?bearer_token"
-	facebookVerifyTokenURL = "https://graph.facebook.com/me?access_token"
 }
 
-// Authenticate handler, use it to authenticate a user.
+// Authenticate handler, use it to authenticate a user with the access token of a provider client library.
+// The access token is verified with the 'provider', the user is the one of the token.
 // It returns the JSON data of the requested user.
 func Authenticate(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "GET" {
@@ -74,40 +66,33 @@ func Authenticate(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	c := appengine.NewContext(r)
+	desc := "Authenticate Handler:"
 
-	userInfo := authhlp.UserInfo{Id: r.FormValue("id"), Email: r.FormValue("email"), Name: r.FormValue("name")}
-
-	var verifyURL string
-	if r.FormValue("provider") == "google" {
-		verifyURL = googleVerifyTokenURL
-	} else if r.FormValue("provider") == "facebook" {
-		verifyURL = facebookVerifyTokenURL
+	p, ok := providers.Get(r.FormValue("provider"))
+	if !ok {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsProviderNotFound)}
+	}
+	verifier, ok := p.(provider.TokenVerifier)
+	if !ok {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsAccessTokenNotValid)}
 	}
 
-	if !authhlp.CheckUserValidity(r, verifyURL, r.FormValue("access_token")) {
+	id, err := verifier.VerifyToken(urlfetch.Client(c), r.FormValue("access_token"))
+	if err != nil {
+		log.Infof(c, "%s unable to verify %v access token: %v", desc, p.Name(), err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsAccessTokenNotValid)}
 	}
 
 	var user *mdl.User
-	var err error
-	if user, err = mdl.SigninUser(c, "Username", userInfo.Email, userInfo.Name, userInfo.Name); err != nil {
+	if user, err = signinIdentity(c, id, 0); err != nil {
+		log.Errorf(c, "%s unable to signin %v user %v: %v", desc, p.Name(), id.Subject, err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
 	}
-
-	imageURL := helpers.UserImageURL(user.Username, user.Id)
-
-	userData := struct {
-		User     *mdl.User
-		ImageURL string
-	}{
-		user,
-		imageURL,
-	}
-
-	return templateshlp.RenderJSON(w, c, userData)
+	return renderSignin(w, r, c, desc, user)
 }
 
 // TwitterAuth handler, use it to authenticate via twitter.
+// It returns the OAuth token the user authorizes on Twitter, as ProviderLogin for the 'twitter' provider.
 func TwitterAuth(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "GET" {
 		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
@@ -116,33 +101,23 @@ func TwitterAuth(w http.ResponseWriter, r *http.Request) error {
 	c := appengine.NewContext(r)
 	desc := "Twitter Auth handler:"
 
-	credentials, err := twitterConfig.RequestTemporaryCredentials(urlfetch.Client(c), "https://"+r.Host+twitterCallbackURL, nil)
-	if err != nil {
-		c.Errorf("JsonTwitterAuth, error = %v", err)
-		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotGetTempCredentials)}
+	p, ok := providers.Get("twitter")
+	if !ok {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsProviderNotFound)}
 	}
 
-	if err = memcache.Set(c, "secret", credentials.Secret); err != nil {
-		// store secret in datastore
-		secretId, _, err := datastore.AllocateIDs(c, "Secret", nil, 1)
-		if err != nil {
-			log.Errorf(c, "%s Cannot allocate Id for secret. %v", desc, err)
-		}
-
-		key := datastore.NewKey(c, "Secret", "", secretId, nil)
-
-		_, err = datastore.Put(c, key, credentials.Secret)
-		if err != nil {
-			log.Errorf(c, "%s Cannot put secret in Datastore. %v", desc, err)
-			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotSetSecretValue)}
-		}
+	login, authURL, err := beginLogin(w, r, c, desc, p, 0)
+	if err != nil {
+		return err
 	}
 
 	// return OAuth token
 	oAuthToken := struct {
 		OAuthToken string
+		URL        string `json:"Url"`
 	}{
-		credentials.Token,
+		login.State,
+		authURL,
 	}
 
 	return templateshlp.RenderJSON(w, c, oAuthToken)
@@ -158,7 +133,7 @@ func TwitterAuthCallback(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-// TwitterUser handler, use it to get the Twitter user data.
+// TwitterUser handler, use it to get the Twitter user data from the 'oauth_token' and 'oauth_verifier' of the callback.
 func TwitterUser(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "GET" {
 		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
@@ -167,74 +142,16 @@ func TwitterUser(w http.ResponseWriter, r *http.Request) error {
 	c := appengine.NewContext(r)
 	desc := "Twitter User handler:"
 
-	var user *mdl.User
-
-	// get the request token
-	requestToken := r.FormValue("oauth_token")
-
-	// update credentials with request token and 'secret cookie value'
-	var cred oauth.Credentials
-	cred.Token = requestToken
-	if secret, err := memcache.Get(c, "secret"); secret != nil {
-		cred.Secret = string(secret.([]byte))
-	} else {
-		log.Errorf(c, "%s cannot get secret value from memcache: %v", desc, err)
-		// try to get secret from datastore
-		q := datastore.NewQuery("Secret")
-		var secrets []string
-		if keys, err := q.GetAll(c, &secrets); err == nil && len(secrets) > 0 {
-			// delete secret from datastore
-			if err = datastore.Delete(c, keys[0]); err != nil {
-				log.Errorf(c, "%s Error when trying to delete 'secret' key in Datastore: %v", desc, err)
-			}
-
-		} else if err != nil || len(secrets) == 0 {
-			log.Errorf(c, "%s cannot get secret value from Datastore: %v", desc, err)
-			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotGetSecretValue)}
-		}
-	}
-
-	if err := memcache.Delete(c, "secret"); err != nil {
-		log.Errorf(c, "%s Error when trying to delete memcached 'secret' key: %v", desc, err)
+	p, ok := providers.Get("twitter")
+	if !ok {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsProviderNotFound)}
 	}
 
-	token, values, err := twitterConfig.RequestToken(urlfetch.Client(c), &cred, r.FormValue("oauth_verifier"))
+	user, err := completeLogin(w, r, c, desc, p)
 	if err != nil {
-		log.Errorf(c, "%s Error when trying to delete memcached 'secret' key: %v", desc, err)
-		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotGetRequestToken)}
+		return err
 	}
-
-	// get user info
-	urlValues := url.Values{}
-	urlValues.Set("user_id", values.Get("user_id"))
-	resp, err := twitterConfig.Get(urlfetch.Client(c), token, "https://api.twitter.com/1.1/users/show.json", urlValues)
-	if err != nil {
-		log.Errorf(c, "%s Cannot get user info from twitter. %v", desc, err)
-		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotGetUserInfo)}
-	}
-
-	userInfo, err := authhlp.FetchTwitterUserInfo(resp)
-	if err != nil {
-		log.Errorf(c, "%s Cannot get user info by fetching twitter response. %v", desc, err)
-		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotGetUserInfo)}
-	}
-
-	if user, err = mdl.SigninUser(c, "Username", "", userInfo.ScreenName, userInfo.Name); err != nil {
-		log.Errorf(c, "%s Unable to signin user %s. %v", desc, userInfo.Name, err)
-		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
-	}
-
-	imageURL := helpers.UserImageURL(user.Username, user.Id)
-
-	userData := struct {
-		User     *mdl.User
-		ImageURL string
-	}{
-		user,
-		imageURL,
-	}
-
-	return templateshlp.RenderJSON(w, c, userData)
+	return renderSignin(w, r, c, desc, user)
 }
 
 // GoogleAccountsLoginURL handler, use it to get Google accounts login URL.
@@ -311,17 +228,7 @@ func GoogleUser(w http.ResponseWriter, r *http.Request) error {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsUnableToSignin)}
 	}
 
-	imageURL := helpers.UserImageURL(user.Username, user.Id)
-
-	userData := struct {
-		User     *mdl.User
-		ImageURL string
-	}{
-		user,
-		imageURL,
-	}
-
-	return templateshlp.RenderJSON(w, c, userData)
+	return renderSignin(w, r, c, desc, user)
 }
 
 // GoogleDeleteCookie handler, use it to delete cookie created by Google account.
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"appengine"
+
+	"github.com/taironas/route"
+
+	"github.com/taironas/gonawin/helpers"
+	authhlp "github.com/taironas/gonawin/helpers/auth"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// maxDeviceLength is the maximum length of the device name of a session.
+const maxDeviceLength = 100
+
+// newSession creates a session for a signed in user on the device of the request.
+// The name of the device is the 'device' param of the request or its user agent.
+//
+func newSession(c appengine.Context, r *http.Request, u *mdl.User) (*mdl.SessionTokens, error) {
+	device := r.FormValue("device")
+	if len(device) == 0 {
+		device = r.UserAgent()
+	}
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+
+	_, tokens, err := mdl.CreateSession(c, u.Id, device, r.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+// currentSessionID returns the id of the session of the request, 0 if it is not authenticated with a session token.
+//
+func currentSessionID(r *http.Request) int64 {
+	id, _, err := mdl.ParseSessionToken(authhlp.AuthorizationToken(r))
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// Refresh handler, use it to get new tokens for a session from its 'refresh_token'.
+// The previous tokens of the session are no longer valid.
+//
+//	POST	/j/auth/refresh
+//
+func Refresh(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Session Refresh Handler:"
+
+	_, tokens, err := mdl.RefreshSession(c, r.FormValue("refresh_token"))
+	if err == mdl.ErrSessionInvalid || err == mdl.ErrSessionExpired {
+		return &helpers.Unauthorized{Err: errors.New(helpers.ErrorCodeSessionsExpired)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to refresh session: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotRefresh)}
+	}
+
+	data := struct {
+		Session mdl.SessionTokens
+	}{
+		tokens,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// Devices handler, use it to get the sessions of the current user.
+// The session of the request is flagged as current.
+//
+//	GET	/j/sessions
+//
+func Devices(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+
+	sessions := mdl.FindSessions(c, u.Id)
+
+	type session struct {
+		mdl.SessionJSON
+		Current bool
+	}
+
+	fieldsToKeep := []string{"Id", "Device", "UserAgent", "Created", "LastUsed", "Expires", "RefreshExpires"}
+	currentID := currentSessionID(r)
+
+	sessionsJSON := make([]session, len(sessions))
+	for i, s := range sessions {
+		helpers.InitPointerStructure(s, &sessionsJSON[i].SessionJSON, fieldsToKeep)
+		sessionsJSON[i].Current = s.Id == currentID
+	}
+
+	data := struct {
+		Sessions []session
+	}{
+		sessionsJSON,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// Revoke handler, use it to revoke a session of the current user.
+// Its tokens are no longer valid.
+//
+//	POST	/j/sessions/revoke/:sessionId
+//
+func Revoke(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Session Revoke Handler:"
+
+	strID, err := route.Context.Get(r, "sessionId")
+	if err != nil {
+		log.Errorf(c, "%s error getting session id, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsNotFound)}
+	}
+
+	var id int64
+	if id, err = strconv.ParseInt(strID, 0, 64); err != nil {
+		log.Errorf(c, "%s error converting session id from string to int64, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSessionsNotFound)}
+	}
+
+	return revoke(w, c, desc, u, id)
+}
+
+// Logout handler, use it to revoke the session of the request.
+//
+//	POST	/j/auth/logout
+//
+func Logout(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Session Logout Handler:"
+
+	id := currentSessionID(r)
+	if id == 0 {
+		// legacy authentication key, there is no session to revoke.
+		return templateshlp.RenderEmptyJSON(w, c)
+	}
+	return revoke(w, c, desc, u, id)
+}
+
+func revoke(w http.ResponseWriter, c appengine.Context, desc string, u *mdl.User, id int64) error {
+	s, err := mdl.SessionByID(c, id)
+	if err != nil || s.UserId != u.Id {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsNotFound)}
+	}
+
+	if err = s.Destroy(c); err != nil {
+		log.Errorf(c, "%s unable to destroy session %v: %v", desc, id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSessionsCannotRevoke)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		"The session has been revoked.",
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+	"github.com/taironas/gonawin/helpers/totp"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// twoFactorIssuer is the name of the account in the authenticator application.
+const twoFactorIssuer = "gonawin"
+
+// verifySession records a second factor check on the session of the request.
+// It returns the time until the check is considered recent.
+//
+func verifySession(c appengine.Context, r *http.Request, desc string, u *mdl.User) (time.Time, error) {
+	id := currentSessionID(r)
+	if id == 0 {
+		return time.Time{}, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorNoSession)}
+	}
+
+	s, err := mdl.SessionByID(c, id)
+	if err != nil || s.UserId != u.Id {
+		return time.Time{}, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSessionsNotFound)}
+	}
+
+	s.SecondFactorAt = time.Now()
+	if err = s.Update(c); err != nil {
+		log.Errorf(c, "%s unable to update session %v: %v", desc, id, err)
+		return time.Time{}, &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+	return s.SecondFactorAt.Add(mdl.SecondFactorMaxAge), nil
+}
+
+// TwoFactor handler, use it to get the two-factor authentication settings of the current user.
+//
+//	GET	/j/auth/2fa
+//
+func TwoFactor(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+
+	var tfJSON mdl.TwoFactorJSON
+	if tf, err := mdl.TwoFactorByUser(c, u.Id); err == nil {
+		tfJSON = tf.JSON()
+	}
+
+	data := struct {
+		TwoFactor mdl.TwoFactorJSON
+	}{
+		tfJSON,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// TwoFactorSetup handler, use it to generate a new secret for the authenticator application of the current user.
+// The URI is meant to be displayed as a QR code. Two-factor authentication is enabled with TwoFactorEnable.
+//
+//	POST	/j/auth/2fa/setup
+//
+func TwoFactorSetup(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Two Factor Setup Handler:"
+
+	tf, err := mdl.SetupTwoFactor(c, u.Id)
+	if err == mdl.ErrTwoFactorEnabled {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorEnabled)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to setup two-factor authentication of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+
+	account := u.Email
+	if len(account) == 0 {
+		account = u.Username
+	}
+
+	data := struct {
+		Secret string
+		URI    string
+	}{
+		tf.Secret,
+		totp.URI(twoFactorIssuer, account, tf.Secret),
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// TwoFactorEnable handler, use it to enable two-factor authentication with a first 'code' of the authenticator application.
+// It returns the recovery codes of the user, they are not shown again.
+//
+//	POST	/j/auth/2fa/enable?code=<code>
+//
+func TwoFactorEnable(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Two Factor Enable Handler:"
+
+	codes, err := mdl.EnableTwoFactor(c, u.Id, r.FormValue("code"))
+	switch err {
+	case nil:
+	case datastore.ErrNoSuchEntity:
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorDisabled)}
+	case mdl.ErrTwoFactorEnabled:
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorEnabled)}
+	case mdl.ErrTwoFactorInvalid:
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorInvalid)}
+	default:
+		log.Errorf(c, "%s unable to enable two-factor authentication of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+
+	// the code was just verified, the session does not need to verify it again.
+	var expires *time.Time
+	if currentSessionID(r) != 0 {
+		var t time.Time
+		if t, err = verifySession(c, r, desc, u); err != nil {
+			return err
+		}
+		expires = &t
+	}
+
+	data := struct {
+		RecoveryCodes       []string
+		SecondFactorExpires *time.Time `json:",omitempty"`
+	}{
+		codes,
+		expires,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// TwoFactorVerify handler, use it to verify a 'code' of the authenticator application, or a recovery code,
+// before a sensitive admin action. The check is valid on the session of the request for mdl.SecondFactorMaxAge.
+//
+//	POST	/j/auth/2fa/verify?code=<code>
+//
+func TwoFactorVerify(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Two Factor Verify Handler:"
+
+	if currentSessionID(r) == 0 {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorNoSession)}
+	}
+
+	tf, err := mdl.VerifyTwoFactor(c, u.Id, r.FormValue("code"))
+	switch err {
+	case nil:
+	case datastore.ErrNoSuchEntity, mdl.ErrTwoFactorDisabled:
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorDisabled)}
+	case mdl.ErrTwoFactorLocked:
+		log.Infof(c, "%s too many invalid codes for user %v", desc, u.Id)
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeTwoFactorLocked)}
+	case mdl.ErrTwoFactorInvalid:
+		log.Infof(c, "%s invalid code for user %v", desc, u.Id)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorInvalid)}
+	default:
+		log.Errorf(c, "%s unable to verify code of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+
+	var expires time.Time
+	if expires, err = verifySession(c, r, desc, u); err != nil {
+		return err
+	}
+
+	data := struct {
+		TwoFactor           mdl.TwoFactorJSON
+		SecondFactorExpires time.Time
+	}{
+		tf.JSON(),
+		expires,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// TwoFactorRecoveryCodes handler, use it to replace the recovery codes of the current user.
+// It requires a recent second factor check.
+//
+//	POST	/j/auth/2fa/recovery
+//
+func TwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Two Factor Recovery Codes Handler:"
+
+	tf, err := mdl.TwoFactorByUser(c, u.Id)
+	if err != nil || !tf.Enabled {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorDisabled)}
+	}
+
+	var codes []string
+	if codes, err = tf.GenerateRecoveryCodes(); err == nil {
+		err = tf.Update(c)
+	}
+	if err != nil {
+		log.Errorf(c, "%s unable to update recovery codes of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+
+	data := struct {
+		RecoveryCodes []string
+	}{
+		codes,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// TwoFactorDisable handler, use it to disable two-factor authentication with a 'code' of the authenticator application
+// or a recovery code.
+//
+//	POST	/j/auth/2fa/disable?code=<code>
+//
+func TwoFactorDisable(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Two Factor Disable Handler:"
+
+	tf, err := mdl.VerifyTwoFactor(c, u.Id, r.FormValue("code"))
+	switch err {
+	case nil:
+	case datastore.ErrNoSuchEntity, mdl.ErrTwoFactorDisabled:
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorDisabled)}
+	case mdl.ErrTwoFactorLocked:
+		log.Infof(c, "%s too many invalid codes for user %v", desc, u.Id)
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeTwoFactorLocked)}
+	case mdl.ErrTwoFactorInvalid:
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTwoFactorInvalid)}
+	default:
+		log.Errorf(c, "%s unable to verify code of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+
+	if err = tf.Destroy(c); err != nil {
+		log.Errorf(c, "%s unable to disable two-factor authentication of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTwoFactorCannotUpdate)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		"Two-factor authentication has been disabled.",
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
@@ -0,0 +1,362 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package slack provides the handlers to answer the Slack slash commands.
+//
+// Users link their Slack account to their gonawin account with a code they get from
+// gonawin and send with the command '/gonawin link <code>'.
+// Once linked, they can get the ranking, their next matches and set predictions
+// of the tournament defined in the configuration file.
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	golog "log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	slackhlp "github.com/taironas/gonawin/helpers/slack"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	gwconfig "github.com/taironas/gonawin/config"
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// provider is the name used to store the Slack accounts linked to gonawin users.
+const provider = "slack"
+
+// rankingLimit is the number of users displayed by the 'ranking' command.
+const rankingLimit = 10
+
+const helpText = "Available commands:\n" +
+	"`link <code>` link your Slack account to gonawin, get the code on gonawin.\n" +
+	"`unlink` unlink your Slack account.\n" +
+	"`ranking` show the ranking of the tournament.\n" +
+	"`next` show your predictions for the next matches.\n" +
+	"`predict <match> <result1>-<result2>` set your prediction for a match, e.g. `predict 12 2-1`."
+
+var config *gwconfig.GwConfig
+
+func init() {
+	// read config file.
+	var err error
+	if config, err = gwconfig.ReadConfig(""); err != nil {
+		golog.Printf("Error: unable to read config file; %v", err)
+	}
+}
+
+// Command handler, use it to answer a Slack slash command.
+// The request must be signed with the signing secret of the Slack app.
+// The reply is a message in the Slack message format.
+//
+//	POST	/j/slack/command
+//
+func Command(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Slack Command Handler:"
+
+	if config == nil || len(config.Slack.SigningSecret) == 0 {
+		log.Errorf(c, "%s slack is not configured", desc)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	// the signature is computed on the raw body so it has to be read before parsing the form.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Errorf(c, "%s unable to read body: %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSlackInvalidRequest)}
+	}
+
+	timestamp := r.Header.Get(slackhlp.TimestampHeader)
+	signature := r.Header.Get(slackhlp.SignatureHeader)
+	if err = slackhlp.Verify(config.Slack.SigningSecret, timestamp, signature, body, time.Now()); err != nil {
+		log.Errorf(c, "%s %v", desc, err)
+		return &helpers.Unauthorized{Err: errors.New(helpers.ErrorCodeSlackInvalidRequest)}
+	}
+
+	var form url.Values
+	if form, err = url.ParseQuery(string(body)); err != nil {
+		log.Errorf(c, "%s unable to parse body: %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeSlackInvalidRequest)}
+	}
+
+	cmd := slackhlp.ParseCommand(form)
+	name, args := cmd.Args()
+
+	var msg slackhlp.Message
+	switch name {
+	case "link":
+		msg = link(c, cmd, args)
+	case "unlink":
+		msg = unlink(c, cmd)
+	case "ranking", "next", "predict":
+		var u *mdl.User
+		if u, err = chatUser(c, cmd); err != nil {
+			log.Errorf(c, "%s unable to get user of slack account: %v", desc, err)
+			msg = reply("Sorry, we were unable to get your gonawin account.")
+			break
+		}
+		if u == nil {
+			msg = reply("Your Slack account is not linked to gonawin yet. Get a code on gonawin and send `" + cmd.Command + " link <code>`.")
+			break
+		}
+
+		var t *mdl.Tournament
+		if t, err = mdl.Repos(c).Tournaments.ByID(config.Slack.TournamentId); err != nil {
+			log.Errorf(c, "%s tournament %v not found: %v", desc, config.Slack.TournamentId, err)
+			msg = reply(helpers.ErrorCodeTournamentNotFound)
+			break
+		}
+
+		switch name {
+		case "ranking":
+			msg = ranking(c, t)
+		case "next":
+			msg = next(c, t, u)
+		case "predict":
+			msg = predict(c, t, u, args)
+		}
+	default:
+		msg = reply(helpText)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return templateshlp.RenderJSON(w, c, msg)
+}
+
+// LinkCode handler, use it to get a code to link a Slack account to the current user.
+// The code is valid for a few minutes and can only be used once.
+//
+//	POST	/j/slack/link
+//
+func LinkCode(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Slack Link Code Handler:"
+
+	lc, err := mdl.CreateChatLinkCode(c, u.Id)
+	if err != nil {
+		log.Errorf(c, "%s unable to create link code for user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeSlackCannotCreateLinkCode)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Code        string
+		Expires     time.Time
+	}{
+		fmt.Sprintf("Send '/gonawin link %s' in Slack to link your account.", lc.Code),
+		lc.Code,
+		lc.Expires,
+	}
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// reply returns an ephemeral message with the given text.
+func reply(text string) slackhlp.Message {
+	return slackhlp.Message{ResponseType: slackhlp.Ephemeral, Text: text}
+}
+
+// chatUser returns the gonawin user linked to the Slack account that sent the command.
+// It returns nil if the account is not linked.
+func chatUser(c appengine.Context, cmd slackhlp.Command) (*mdl.User, error) {
+	a, err := mdl.ChatAccountByChatUser(c, provider, cmd.TeamID, cmd.UserID)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return mdl.Repos(c).Users.ByID(a.UserId)
+}
+
+func link(c appengine.Context, cmd slackhlp.Command, args []string) slackhlp.Message {
+	if len(args) != 1 {
+		return reply("Usage: `" + cmd.Command + " link <code>`, get the code on gonawin.")
+	}
+
+	userID, err := mdl.UseChatLinkCode(c, args[0])
+	if err != nil {
+		log.Infof(c, "Slack link: invalid code %v: %v", args[0], err)
+		return reply("This code is not valid or has expired, get a new one on gonawin.")
+	}
+
+	var u *mdl.User
+	if u, err = mdl.Repos(c).Users.ByID(userID); err != nil {
+		log.Errorf(c, "Slack link: user %v not found: %v", userID, err)
+		return reply(helpers.ErrorCodeUserNotFound)
+	}
+
+	if _, err = mdl.LinkChatAccount(c, provider, cmd.TeamID, cmd.UserID, u.Id); err != nil {
+		log.Errorf(c, "Slack link: unable to link account: %v", err)
+		return reply(helpers.ErrorCodeSlackCannotLink)
+	}
+	return reply(fmt.Sprintf("Your Slack account is now linked to the gonawin user %s.", u.Username))
+}
+
+func unlink(c appengine.Context, cmd slackhlp.Command) slackhlp.Message {
+	a, err := mdl.ChatAccountByChatUser(c, provider, cmd.TeamID, cmd.UserID)
+	if err == datastore.ErrNoSuchEntity {
+		return reply("Your Slack account is not linked to gonawin.")
+	} else if err != nil {
+		log.Errorf(c, "Slack unlink: unable to get account: %v", err)
+		return reply(helpers.ErrorCodeInternal)
+	}
+
+	if err = a.Destroy(c); err != nil {
+		log.Errorf(c, "Slack unlink: unable to delete account: %v", err)
+		return reply(helpers.ErrorCodeInternal)
+	}
+	return reply("Your Slack account is no longer linked to gonawin.")
+}
+
+// ranking replies with the best users of the tournament, visible by everyone in the channel.
+func ranking(c appengine.Context, t *mdl.Tournament) slackhlp.Message {
+	users := t.RankingByUser(c, rankingLimit)
+
+	var lines []string
+	// users are sorted by ascending score.
+	for i := len(users) - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("%d. %s %d", len(users)-i, users[i].Username, users[i].Score))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No participants yet.")
+	}
+
+	return slackhlp.Message{
+		ResponseType: slackhlp.InChannel,
+		Text:         fmt.Sprintf("Ranking of %s", t.Name),
+		Attachments:  []slackhlp.Attachment{{Text: strings.Join(lines, "\n")}},
+	}
+}
+
+// next replies with the matches of the next day of the tournament and the predictions of the user.
+func next(c appengine.Context, t *mdl.Tournament, u *mdl.User) slackhlp.Message {
+	now := time.Now()
+	var matches []mdl.Tmatch
+	for _, m := range mdl.GetAllMatchesFromTournament(c, t) {
+		if !m.Finished && m.Date.After(now) {
+			matches = append(matches, *m)
+		}
+	}
+
+	days := mdl.MatchesGroupByDay(matches, u.Location())
+	if len(days) == 0 {
+		return reply(fmt.Sprintf("There are no more matches in %s.", t.Name))
+	}
+
+	predicts, err := mdl.Repos(c).Predicts.ByIDs(u.PredictIds)
+	if err != nil {
+		log.Errorf(c, "Slack next: unable to get predicts of user %v: %v", u.Id, err)
+		return reply(helpers.ErrorCodeInternal)
+	}
+
+	mapIDTeams := mdl.MapOfIDTeams(c, t)
+	var lines []string
+	for _, m := range days[0].Matches {
+		team1, team2 := m.TeamNames(mapIDTeams)
+		prediction := "not predicted"
+		if ok, i := mdl.Predicts(predicts).ContainsMatchID(m.Id); ok {
+			prediction = fmt.Sprintf("%d-%d", predicts[i].Result1, predicts[i].Result2)
+		}
+		kickoff := "time not set"
+		if m.Kickoff {
+			kickoff = m.DateIn(u.Location()).Format("15:04")
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s - %s, %s: %s", m.IdNumber, team1, team2, kickoff, prediction))
+	}
+
+	return slackhlp.Message{
+		ResponseType: slackhlp.Ephemeral,
+		Text:         fmt.Sprintf("%s, %s", t.Name, days[0].Date.Format("Mon Jan 2")),
+		Attachments:  []slackhlp.Attachment{{Text: strings.Join(lines, "\n")}},
+	}
+}
+
+// predict sets the prediction of the user, args are the match id number and the result, e.g. ["12", "2-1"].
+func predict(c appengine.Context, t *mdl.Tournament, u *mdl.User, args []string) slackhlp.Message {
+	usage := reply("Usage: `predict <match> <result1>-<result2>`, e.g. `predict 12 2-1`.")
+	if len(args) != 2 {
+		return usage
+	}
+
+	matchIDNumber, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return usage
+	}
+	results := strings.Split(args[1], "-")
+	if len(results) != 2 {
+		return usage
+	}
+	var r1, r2 int64
+	if r1, err = strconv.ParseInt(results[0], 10, 64); err != nil || r1 < 0 {
+		return usage
+	}
+	if r2, err = strconv.ParseInt(results[1], 10, 64); err != nil || r2 < 0 {
+		return usage
+	}
+
+	match := mdl.GetMatchByIDNumber(c, *t, matchIDNumber)
+	if match == nil {
+		return reply(helpers.ErrorCodeMatchNotFoundCannotSetPrediction)
+	}
+	if !match.Ready || !match.CanPredict || match.Finished {
+		return reply(helpers.ErrorCodeSlackMatchLocked)
+	}
+
+	if !t.Joined(c, u) {
+		if err = mdl.Repos(c).JoinTournament(t, u); err != nil {
+			log.Errorf(c, "Slack predict: error on Join tournament: %v", err)
+			return reply(helpers.ErrorCodeInternal)
+		}
+	}
+
+	var p *mdl.Predict
+	var created bool
+	if p, created, err = mdl.Repos(c).Predict(u, match, r1, r2); err != nil {
+		log.Errorf(c, "Slack predict: %v", err)
+		return reply(helpers.ErrorCodeCannotSetPrediction)
+	}
+
+	mapIDTeams := mdl.MapOfIDTeams(c, t)
+	team1, team2 := match.TeamNames(mapIDTeams)
+
+	// publish activity
+	verb := fmt.Sprintf("predicted %d-%d for", p.Result1, p.Result2)
+	object := mdl.ActivityEntity{Id: match.Id, Type: "match", DisplayName: team1 + "-" + team2}
+	mdl.Repos(c).Publish(u, "predict", verb, object, t.Entity())
+
+	if created {
+		return reply(fmt.Sprintf("You set a prediction: %s %d:%d %s.", team1, p.Result1, p.Result2, team2))
+	}
+	return reply(fmt.Sprintf("Your prediction is now updated: %s %d:%d %s.", team1, p.Result1, p.Result2, team2))
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// CheckConsistency task handler, use it to run the next step of a consistency check.
+// It adds a task for the following step until the check is finished.
+//
+//	POST	/a/consistency/check/
+//
+func CheckConsistency(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Task queue - Check Consistency Handler:"
+
+	checkID, err := strconv.ParseInt(r.FormValue("checkId"), 0, 64)
+	if err != nil {
+		log.Errorf(c, "%s unable to extract checkId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	var check *mdl.ConsistencyCheck
+	if check, err = mdl.ConsistencyCheckByID(c, checkID); err != nil {
+		log.Errorf(c, "%s check %v not found: %v", desc, checkID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeConsistencyCheckNotFound)}
+	}
+
+	log.Infof(c, "%s check %v: %s", desc, check.Id, check.Kind)
+	if err = check.Step(c); err != nil {
+		log.Errorf(c, "%s check %v failed at %s: %v", desc, check.Id, check.Kind, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	if check.Done() {
+		log.Infof(c, "%s check %v finished, %d checked, %d inconsistencies, %d fixed", desc, check.Id, check.Checked, check.Inconsistencies, check.Fixed)
+		return nil
+	}
+	if err = check.Enqueue(c); err != nil {
+		log.Errorf(c, "%s unable to add task to taskqueue. %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	return nil
+}
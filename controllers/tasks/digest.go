@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"appengine"
+	"appengine/mail"
+	"appengine/taskqueue"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// SendDigests dispatches a task to send the activity digest email of each user who subscribed to it.
+// Daily digests are sent every day, weekly digests are sent on Mondays.
+// It is triggered by a cron job.
+//
+//	GET	/a/digest/
+//
+func SendDigests(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Cron job - Send Digests Handler:"
+
+	now := time.Now()
+	frequencies := []string{mdl.DigestDaily}
+	if now.Weekday() == time.Monday {
+		frequencies = append(frequencies, mdl.DigestWeekly)
+	}
+
+	for _, f := range frequencies {
+		since := now.Add(-mdl.DigestPeriod(f))
+		users, err := mdl.Repos(c).Users.Find("DigestFrequency", f)
+		if err != nil {
+			log.Errorf(c, "%s unable to find users with %v digest: %v", desc, f, err)
+			continue
+		}
+		for _, u := range users {
+			if len(u.Email) == 0 {
+				continue
+			}
+			if err := addDigestTask(c, desc, u.Id, since); err != nil {
+				log.Errorf(c, "%s unable to add digest task for user %v: %v", desc, u.Id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func addDigestTask(c appengine.Context, desc string, userID int64, since time.Time) error {
+
+	var err error
+	var buserID, bsince []byte
+
+	if buserID, err = json.Marshal(userID); err != nil {
+		log.Errorf(c, "%s Error marshaling %v", desc, err)
+	}
+	if bsince, err = json.Marshal(since); err != nil {
+		log.Errorf(c, "%s Error marshaling %v", desc, err)
+	}
+
+	task := taskqueue.NewPOSTTask("/a/digest/user/", url.Values{
+		"userId": []string{string(buserID)},
+		"since":  []string{string(bsince)},
+	})
+	_, err = taskqueue.Add(c, task, "")
+	return err
+}
+
+// SendUserDigest task handler, use it to send the activity digest email of a user.
+// The digest covers the activities published since the 'since' parameter.
+// No email is sent when there is nothing to report.
+//
+//	POST	/a/digest/user/
+//
+func SendUserDigest(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Task queue - Send User Digest Handler:"
+
+	var err error
+	var userID int64
+	var since time.Time
+
+	if err = json.Unmarshal([]byte(r.FormValue("userId")), &userID); err != nil {
+		log.Errorf(c, "%s unable to extract userId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+	if err = json.Unmarshal([]byte(r.FormValue("since")), &since); err != nil {
+		log.Errorf(c, "%s unable to extract since from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	var u *mdl.User
+	if u, err = mdl.Repos(c).Users.ByID(userID); err != nil {
+		log.Errorf(c, "%s user %v not found: %v", desc, userID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	digest := mdl.NewDigest(u.ActivitiesSince(c, since))
+	if digest.IsEmpty() {
+		log.Infof(c, "%s nothing to report to user %v", desc, u.Id)
+		return nil
+	}
+
+	url := fmt.Sprintf("https://%s/#", r.Host)
+	var msg *mail.Message
+	if msg, err = digestMessage(u, digest, url); err != nil {
+		log.Errorf(c, "%s unable to build digest message: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	if err = mail.Send(c, msg); err != nil {
+		log.Errorf(c, "%s: couldn't send email: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	return nil
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`Hi {{.Name}},
+
+Here is what happened on gonawin since your last digest.
+{{with .Digest.Results}}
+Match results:
+{{range .}}* {{.Actor.DisplayName}}: {{.Object.DisplayName}} {{.Verb}} {{.Target.DisplayName}}
+{{end}}{{end}}{{with .Digest.Score}}
+Your points:
+* {{.Actor.DisplayName}}{{.Verb}}
+{{end}}{{with .Digest.Rankings}}
+Your teams:
+{{range .}}* {{.Actor.DisplayName}} {{.Verb}}
+{{end}}{{end}}{{with .Digest.Requests}}
+Requests to join your teams:
+{{range .}}* {{.Actor.DisplayName}} {{.Verb}} {{.Object.DisplayName}}
+{{end}}{{end}}
+See more here: {{.URL}}
+
+You can unsubscribe from this digest in your profile.
+
+Have fun,
+Your friends @ Gonawin
+`))
+
+// digestMessage builds the digest email of a user.
+func digestMessage(u *mdl.User, d mdl.Digest, url string) (*mail.Message, error) {
+
+	name := u.Alias
+	if len(name) == 0 {
+		name = u.Name
+	}
+
+	data := struct {
+		Name   string
+		Digest mdl.Digest
+		URL    string
+	}{
+		name,
+		d,
+		url,
+	}
+
+	var body bytes.Buffer
+	if err := digestTemplate.Execute(&body, data); err != nil {
+		return nil, err
+	}
+
+	return &mail.Message{
+		Sender:  "No Reply gonawin <no-reply@gonawin.com>",
+		To:      []string{u.Email},
+		Subject: fmt.Sprintf("Your %s gonawin digest", u.DigestFrequency),
+		Body:    body.String(),
+	}, nil
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"appengine"
+	"appengine/mail"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// NotifyByEmail task handler, use it to send a notification via email.
+//
+//	POST	/a/notify/email/
+//
+func NotifyByEmail(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Task queue - Notify By Email Handler:"
+
+	id, err := strconv.ParseInt(r.FormValue("notificationId"), 0, 64)
+	if err != nil {
+		log.Errorf(c, "%s unable to extract notificationId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotificationNotFound)}
+	}
+
+	var n *mdl.Notification
+	if n, err = mdl.NotificationByID(c, id); err != nil {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeNotificationNotFound)}
+	}
+
+	var u *mdl.User
+	if u, err = mdl.Repos(c).Users.ByID(n.UserId); err != nil {
+		log.Errorf(c, "%s user %v not found: %v", desc, n.UserId, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	// the user may have changed their preferences or read the notification since it was created.
+	if n.Read || u.NotificationChannel(n.Type) != mdl.ChannelEmail {
+		return nil
+	}
+
+	msg := &mail.Message{
+		Sender:  "No Reply gonawin <no-reply@gonawin.com>",
+		To:      []string{u.Email},
+		Subject: fmt.Sprintf("gonawin: %s", n.Message),
+		Body:    fmt.Sprintf(notificationMessage, n.Message, fmt.Sprintf("https://%s/#", r.Host)),
+	}
+
+	if err = mail.Send(c, msg); err != nil {
+		log.Errorf(c, "%s: couldn't send email: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInviteEmailCannotSend)}
+	}
+	return nil
+}
+
+const notificationMessage = `
+Hi there,
+
+%s
+
+See your notifications here: %s
+
+You can choose how you receive notifications in your profile.
+
+Have fun,
+Your friends @ Gonawin
+`
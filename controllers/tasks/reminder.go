@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"appengine"
+	"appengine/mail"
+	"appengine/taskqueue"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+const (
+	reminderLead   = 3 * time.Hour // time between the reminder and the first match of the day.
+	reminderWindow = time.Hour     // must match the schedule of the reminders cron job.
+)
+
+// RemindPredictions sends an email to the participants of the tournaments who did not predict
+// the matches of the day, a few hours before the first match of the day.
+// It is triggered by a cron job and dispatches a task per user to remind.
+//
+//	GET	/a/remind/predictions/
+//
+func RemindPredictions(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Cron job - Remind Predictions Handler:"
+
+	now := time.Now()
+	// matches of the last day of a tournament can start after its end date.
+	for _, t := range mdl.TournamentsEndingAfter(c, now.AddDate(0, 0, -1)) {
+		days := mdl.DaysToRemind(mdl.GetAllMatchesFromTournament(c, t), now, reminderLead, reminderWindow)
+		if len(days) == 0 {
+			continue
+		}
+
+		participants := t.Participants(c)
+		for _, day := range days {
+			for _, u := range participants {
+				if !u.RemindersEnabled(t.Id) || len(u.Email) == 0 {
+					continue
+				}
+
+				predicts, err := mdl.Repos(c).Predicts.ByIDs(u.PredictIds)
+				if err != nil {
+					log.Errorf(c, "%s unable to get predicts of user %v: %v", desc, u.Id, err)
+					continue
+				}
+
+				missing := mdl.Predicts(predicts).MissingMatches(day.Matches)
+				if len(missing) == 0 {
+					continue
+				}
+
+				if err = addReminderTask(c, desc, t.Id, u.Id, missing); err != nil {
+					log.Errorf(c, "%s unable to add reminder task for user %v: %v", desc, u.Id, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func addReminderTask(c appengine.Context, desc string, tournamentID, userID int64, matches []mdl.Tmatch) error {
+
+	matchIds := make([]int64, len(matches))
+	for i, m := range matches {
+		matchIds[i] = m.Id
+	}
+
+	var err error
+	var btournamentID, buserID, bmatchIds []byte
+
+	if btournamentID, err = json.Marshal(tournamentID); err != nil {
+		log.Errorf(c, "%s Error marshaling %v", desc, err)
+	}
+	if buserID, err = json.Marshal(userID); err != nil {
+		log.Errorf(c, "%s Error marshaling %v", desc, err)
+	}
+	if bmatchIds, err = json.Marshal(matchIds); err != nil {
+		log.Errorf(c, "%s Error marshaling %v", desc, err)
+	}
+
+	task := taskqueue.NewPOSTTask("/a/remind/predictions/user/", url.Values{
+		"tournamentId": []string{string(btournamentID)},
+		"userId":       []string{string(buserID)},
+		"matchIds":     []string{string(bmatchIds)},
+	})
+	_, err = taskqueue.Add(c, task, "")
+	return err
+}
+
+// RemindUserPredictions task handler, use it to send a reminder email to a user
+// who did not predict some matches of a tournament.
+// Matches predicted since the task was added are not listed in the email.
+//
+//	POST	/a/remind/predictions/user/
+//
+func RemindUserPredictions(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Task queue - Remind User Predictions Handler:"
+
+	var err error
+	var tournamentID, userID int64
+	var matchIds []int64
+
+	if err = json.Unmarshal([]byte(r.FormValue("tournamentId")), &tournamentID); err != nil {
+		log.Errorf(c, "%s unable to extract tournamentId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+	if err = json.Unmarshal([]byte(r.FormValue("userId")), &userID); err != nil {
+		log.Errorf(c, "%s unable to extract userId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+	if err = json.Unmarshal([]byte(r.FormValue("matchIds")), &matchIds); err != nil {
+		log.Errorf(c, "%s unable to extract matchIds from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	var t *mdl.Tournament
+	if t, err = mdl.Repos(c).Tournaments.ByID(tournamentID); err != nil {
+		log.Errorf(c, "%s tournament %v not found: %v", desc, tournamentID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
+	}
+
+	var u *mdl.User
+	if u, err = mdl.Repos(c).Users.ByID(userID); err != nil {
+		log.Errorf(c, "%s user %v not found: %v", desc, userID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	if !u.RemindersEnabled(t.Id) {
+		return nil
+	}
+
+	var predicts mdl.Predicts
+	if predicts, err = mdl.Repos(c).Predicts.ByIDs(u.PredictIds); err != nil {
+		log.Errorf(c, "%s unable to get predicts of user %v: %v", desc, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	found, err := mdl.Repos(c).Matches.ByIDs(matchIds)
+	if err != nil {
+		log.Errorf(c, "%s unable to get matches: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	var matches []mdl.Tmatch
+	for _, m := range found {
+		matches = append(matches, *m)
+	}
+
+	missing := predicts.MissingMatches(matches)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	url := fmt.Sprintf("https://%s/#/tournaments/%d", r.Host, t.Id)
+	var msg *mail.Message
+	if msg, err = reminderMessage(u, t, missing, mdl.MapOfIDTeams(c, t), url); err != nil {
+		log.Errorf(c, "%s unable to build reminder message: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	if err = mail.Send(c, msg); err != nil {
+		log.Errorf(c, "%s: couldn't send email: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	return nil
+}
+
+// reminderMatch holds the data of a match displayed in a reminder email.
+type reminderMatch struct {
+	Team1    string
+	Team2    string
+	Date     string
+	Location string
+}
+
+// reminderTemplate holds the subject and body templates of a reminder email in a language.
+type reminderTemplate struct {
+	subject  *template.Template
+	body     *template.Template
+	dateForm string
+	dayForm  string // form of the date of matches without kickoff time.
+}
+
+// reminderTemplates holds the reminder email templates by language.
+// There must be a template for each language of mdl.Languages.
+var reminderTemplates = map[string]reminderTemplate{
+	"en": {
+		template.Must(template.New("subject").Parse(`{{.Tournament}}: you have matches to predict today`)),
+		template.Must(template.New("body").Parse(`Hi {{.Name}},
+
+You have not predicted the following matches of {{.Tournament}} yet:
+{{range .Matches}}
+* {{.Team1}} - {{.Team2}}, {{.Date}}{{if .Location}}, {{.Location}}{{end}}{{end}}
+
+Predictions are closed when a match starts, predict them here: {{.URL}}
+
+You can turn off these reminders on the tournament page.
+
+Have fun,
+Your friends @ Gonawin
+`)),
+		"Mon Jan 2 15:04 MST",
+		"Mon Jan 2",
+	},
+	"es": {
+		template.Must(template.New("subject").Parse(`{{.Tournament}}: tienes partidos por pronosticar hoy`)),
+		template.Must(template.New("body").Parse(`Hola {{.Name}},
+
+Todavía no has pronosticado los siguientes partidos de {{.Tournament}}:
+{{range .Matches}}
+* {{.Team1}} - {{.Team2}}, {{.Date}}{{if .Location}}, {{.Location}}{{end}}{{end}}
+
+Los pronósticos se cierran cuando empieza el partido, pronostícalos aquí: {{.URL}}
+
+Puedes desactivar estos recordatorios en la página del torneo.
+
+Diviértete,
+Tus amigos @ Gonawin
+`)),
+		"02/01 15:04 MST",
+		"02/01",
+	},
+	"fr": {
+		template.Must(template.New("subject").Parse(`{{.Tournament}} : vous avez des matchs à pronostiquer aujourd'hui`)),
+		template.Must(template.New("body").Parse(`Bonjour {{.Name}},
+
+Vous n'avez pas encore pronostiqué les matchs suivants de {{.Tournament}} :
+{{range .Matches}}
+* {{.Team1}} - {{.Team2}}, {{.Date}}{{if .Location}}, {{.Location}}{{end}}{{end}}
+
+Les pronostics sont fermés au début de chaque match, pronostiquez-les ici : {{.URL}}
+
+Vous pouvez désactiver ces rappels sur la page du tournoi.
+
+Amusez-vous bien,
+Vos amis @ Gonawin
+`)),
+		"02/01 15:04 MST",
+		"02/01",
+	},
+}
+
+// reminderMessage builds the reminder email of a user in their language.
+// Match dates are displayed in the time zone of the user.
+func reminderMessage(u *mdl.User, t *mdl.Tournament, matches []mdl.Tmatch, mapIDTeams map[int64]string, url string) (*mail.Message, error) {
+
+	tmpl := reminderTemplates[u.Lang()]
+	loc := u.Location()
+
+	name := u.Alias
+	if len(name) == 0 {
+		name = u.Name
+	}
+
+	data := struct {
+		Name       string
+		Tournament string
+		Matches    []reminderMatch
+		URL        string
+	}{
+		name,
+		t.Name,
+		make([]reminderMatch, len(matches)),
+		url,
+	}
+
+	for i, m := range matches {
+		team1, team2 := m.TeamNames(mapIDTeams)
+		form := tmpl.dateForm
+		if !m.Kickoff {
+			form = tmpl.dayForm
+		}
+		data.Matches[i] = reminderMatch{team1, team2, m.DateIn(loc).Format(form), m.Location}
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return nil, err
+	}
+
+	return &mail.Message{
+		Sender:  "No Reply gonawin <no-reply@gonawin.com>",
+		To:      []string{u.Email},
+		Subject: subject.String(),
+		Body:    body.String(),
+	}, nil
+}
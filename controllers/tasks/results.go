@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	golog "log"
+	"net/http"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	"github.com/taironas/gonawin/results"
+
+	gwconfig "github.com/taironas/gonawin/config"
+	mdl "github.com/taironas/gonawin/models"
+)
+
+var config *gwconfig.GwConfig
+
+func init() {
+	// read config file.
+	var err error
+	if config, err = gwconfig.ReadConfig(""); err != nil {
+		golog.Printf("Error: unable to read config file; %v", err)
+	}
+}
+
+// UpdateResults fetches the results of the tournaments from the results feeds
+// defined in the configuration file and sets the result of the newly finished matches.
+// It is triggered by a cron job.
+//
+//	GET	/a/update/results/
+//
+func UpdateResults(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Cron job - Update Results Handler:"
+
+	if config == nil {
+		log.Errorf(c, "%s no configuration available", desc)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	for _, feed := range config.Results {
+		var p results.Provider
+		if p = newResultsProvider(feed); p == nil {
+			log.Errorf(c, "%s unknown provider %q for tournament %v", desc, feed.Provider, feed.TournamentId)
+			continue
+		}
+
+		if err := updateTournamentResults(c, desc, feed.TournamentId, p); err != nil {
+			log.Errorf(c, "%s unable to update results of tournament %v: %v", desc, feed.TournamentId, err)
+		}
+	}
+	return nil
+}
+
+// newResultsProvider returns the results provider defined by a results configuration.
+func newResultsProvider(feed gwconfig.Results) results.Provider {
+	switch feed.Provider {
+	case "file":
+		return results.FileProvider{Path: feed.Source, Format: feed.Format}
+	case "http":
+		return results.HTTPProvider{URL: feed.Source, Format: feed.Format}
+	}
+	return nil
+}
+
+// updateTournamentResults sets the results of the matches of a tournament
+// that are finished with respect to the provider but not yet in gonawin.
+func updateTournamentResults(c appengine.Context, desc string, tournamentID int64, p results.Provider) error {
+
+	t, err := mdl.Repos(c).Tournaments.ByID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	var tb mdl.TournamentBuilder
+	if tb = mdl.GetTournamentBuilder(t); tb == nil {
+		return errors.New("tournament builder not found")
+	}
+
+	var fixtures []results.Fixture
+	if fixtures, err = p.Results(c); err != nil {
+		return err
+	}
+
+	mapIDTeams := tb.MapOfIDTeams(c, t)
+	matches := mdl.GetAllMatchesFromTournament(c, t)
+	updates := results.NewUpdates(fixtures, tb.MapOfTeamCodes(), mapIDTeams, matches)
+
+	log.Infof(c, "%s %d new results for tournament %v", desc, len(updates), t.Id)
+
+	for _, u := range updates {
+		if err = mdl.SetResult(c, u.Match, u.Result1, u.Result2, t); err != nil {
+			log.Errorf(c, "%s unable to set result for match with id:%v error: %v", desc, u.Match.IdNumber, err)
+			continue
+		}
+
+		// publish new activity
+		m := u.Match
+		object := mdl.ActivityEntity{Id: m.TeamId1, Type: "tteam", DisplayName: mapIDTeams[m.TeamId1]}
+		target := mdl.ActivityEntity{Id: m.TeamId2, Type: "tteam", DisplayName: mapIDTeams[m.TeamId2]}
+		verb := ""
+		if m.Result1 > m.Result2 {
+			verb = fmt.Sprintf("won %d-%d against", m.Result1, m.Result2)
+		} else if m.Result1 < m.Result2 {
+			verb = fmt.Sprintf("lost %d-%d against", m.Result1, m.Result2)
+		} else {
+			verb = fmt.Sprintf("tied %d-%d against", m.Result1, m.Result2)
+		}
+		mdl.Repos(c).PublishTournament(t, "match", verb, object, target)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// RebuildSearchIndex task handler, use it to run the next step of a rebuild of the search index.
+// It adds a task for the following step until the rebuild is finished.
+//
+//	POST	/a/search/rebuild/
+//
+func RebuildSearchIndex(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Task queue - Rebuild Search Index Handler:"
+
+	rebuildID, err := strconv.ParseInt(r.FormValue("rebuildId"), 0, 64)
+	if err != nil {
+		log.Errorf(c, "%s unable to extract rebuildId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	var rebuild *mdl.SearchRebuild
+	if rebuild, err = mdl.SearchRebuildByID(c, rebuildID); err != nil {
+		log.Errorf(c, "%s rebuild %v not found: %v", desc, rebuildID, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeSearchRebuildNotFound)}
+	}
+
+	log.Infof(c, "%s rebuild %v: %s %s", desc, rebuild.Id, rebuild.Kind, rebuild.Stage)
+	if err = rebuild.Step(c); err != nil {
+		log.Errorf(c, "%s rebuild %v failed at %s %s: %v", desc, rebuild.Id, rebuild.Kind, rebuild.Stage, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	if rebuild.Done() {
+		log.Infof(c, "%s rebuild %v finished, %d checked, %d inconsistencies, %d legacy entities", desc, rebuild.Id, rebuild.Checked, rebuild.Inconsistencies, rebuild.Legacy)
+		return nil
+	}
+	if err = rebuild.Enqueue(c); err != nil {
+		log.Errorf(c, "%s unable to add task to taskqueue. %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	return nil
+}
@@ -240,7 +240,7 @@ func UpdateUsersScores(w http.ResponseWriter, r *http.Request) error {
 	log.Infof(c, "%s get users", desc)
 	var usersToUpdate []*mdl.User
 	for i, id := range userIds {
-		if u, err := mdl.UserByID(c, id); err != nil {
+		if u, err := mdl.Repos(c).Users.ByID(id); err != nil {
 			log.Errorf(c, "%s cannot find user with id=%v", desc, id)
 		} else {
 			u.Score += scores[i]
@@ -306,7 +306,7 @@ func CreateScoreEntities(w http.ResponseWriter, r *http.Request) error {
 	}
 	log.Infof(c, "%s get users", desc)
 	for i, id := range userIds {
-		if u, err := mdl.UserByID(c, id); err != nil {
+		if u, err := mdl.Repos(c).Users.ByID(id); err != nil {
 			log.Errorf(c, "%s cannot find user with id=%d", desc, id)
 		} else {
 			log.Infof(c, "%s score ready add it to tournament %v", desc, scores[i])
@@ -366,7 +366,7 @@ func AddScoreToScoreEntities(w http.ResponseWriter, r *http.Request) error {
 	tournamentScores := make([]*mdl.Score, len(userIds))
 	log.Infof(c, "%s get users", desc)
 	for i, id := range userIds {
-		if u, err := mdl.UserByID(c, id); err != nil {
+		if u, err := mdl.Repos(c).Users.ByID(id); err != nil {
 			log.Errorf(c, "%s cannot find user with id=%v", desc, id)
 		} else {
 			users[i] = u
@@ -420,7 +420,7 @@ func PublishUsersScoreActivities(w http.ResponseWriter, r *http.Request) error {
 
 	log.Infof(c, "%s get users", desc)
 	var users []*mdl.User
-	if users, err = mdl.UsersByIds(c, userIds); err != nil {
+	if users, err = mdl.Repos(c).Users.ByIDs(userIds); err != nil {
 		log.Errorf(c, "%s something went wrong when getting users by IDs: %v", desc, err)
 	}
 
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tasks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"appengine"
+	"appengine/urlfetch"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	"github.com/taironas/gonawin/helpers/webhook"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+const (
+	predictionLockLead   = time.Hour // time between the event and the prediction lock.
+	predictionLockWindow = time.Hour // must match the schedule of the prediction lock cron job.
+)
+
+// DeliverWebhook task handler, use it to post an event to a webhook.
+// An error is returned when the delivery fails so that the webhooks queue retries it with backoff.
+//
+//	POST	/a/webhooks/deliver/
+//
+func DeliverWebhook(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Task queue - Deliver Webhook Handler:"
+
+	id, err := strconv.ParseInt(r.FormValue("webhookId"), 0, 64)
+	if err != nil {
+		log.Errorf(c, "%s unable to extract webhookId from data, %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeWebhookNotFound)}
+	}
+
+	var wh *mdl.Webhook
+	if wh, err = mdl.WebhookByID(c, id); err != nil {
+		// the webhook was removed since the event was published, nothing to deliver.
+		log.Infof(c, "%s webhook %v not found: %v", desc, id, err)
+		return nil
+	}
+
+	// the retry count header is set by the task queue, it is 0 on the first attempt.
+	retries, _ := strconv.Atoi(r.Header.Get("X-AppEngine-TaskRetryCount"))
+
+	d := webhook.Delivery{
+		URL:     wh.URL,
+		Secret:  wh.Secret,
+		Event:   r.FormValue("event"),
+		ID:      r.FormValue("deliveryId"),
+		Attempt: retries + 1,
+		Payload: []byte(r.FormValue("payload")),
+	}
+
+	if err = webhook.Deliver(urlfetch.Client(c), d); err != nil {
+		log.Errorf(c, "%s %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeWebhookCannotDeliver)}
+	}
+	return nil
+}
+
+// PublishPredictionLocks posts a prediction lock event to the webhooks of the teams
+// of a tournament for each match whose predictions will be locked soon.
+// It is triggered by a cron job.
+//
+//	GET	/a/webhooks/predictionlocks/
+//
+func PublishPredictionLocks(w http.ResponseWriter, r *http.Request) error {
+
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+
+	from := time.Now().Add(predictionLockLead)
+	to := from.Add(predictionLockWindow)
+	// matches of the last day of a tournament can start after its end date.
+	for _, t := range mdl.TournamentsEndingAfter(c, from.AddDate(0, 0, -1)) {
+		matches := mdl.MatchesLockedBetween(mdl.GetAllMatchesFromTournament(c, t), from, to)
+		if len(matches) == 0 {
+			continue
+		}
+
+		mapIDTeams := mdl.MapOfIDTeams(c, t)
+		for _, m := range matches {
+			t.PublishEvent(c, mdl.EventPredictionLock, mdl.NewWebhookMatch(m, mapIDTeams))
+		}
+	}
+	return nil
+}
@@ -62,6 +62,11 @@ func AddAdmin(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
 
+	notification := fmt.Sprintf("%s added you as admin of team %s.", u.Username, team.Name)
+	if err = mdl.Notify(c, []*mdl.User{newAdmin}, mdl.NotificationAdmin, notification, team.Entity()); err != nil {
+		log.Errorf(c, "%s unable to notify user %v: %v", desc, newAdmin.Id, err)
+	}
+
 	vm := buildTeamAddAdminViewModel(team, newAdmin)
 	return templateshlp.RenderJSON(w, c, vm)
 }
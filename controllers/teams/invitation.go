@@ -18,6 +18,7 @@ package teams
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"appengine"
@@ -32,6 +33,7 @@ import (
 
 // RequestInvite handler, use it to request an invitation to a team.
 //  POST	/j/teams/requestinvite/[0-9]+/     Request an invitation to a private team with the given id.
+// An activity and a notification are published to the team admins when the request is created.
 // Response: a JSON formatted status message.
 //
 func RequestInvite(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
@@ -59,13 +61,25 @@ func RequestInvite(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamCannotInvite)}
 	}
 
+	// publish new activity to the team admins
+	if admins, err := mdl.Repos(c).Users.ByIDs(team.AdminIds); err != nil {
+		log.Errorf(c, "%s unable to get admins of team %v: %v", desc, team.Id, err)
+	} else {
+		mdl.Repos(c).PublishTo(u, admins, "request", "requested to join team", team.Entity(), mdl.ActivityEntity{})
+
+		msg := fmt.Sprintf("%s requested to join team %s.", u.Username, team.Name)
+		if err := mdl.Notify(c, admins, mdl.NotificationRequest, msg, team.Entity()); err != nil {
+			log.Errorf(c, "%s unable to notify admins of team %v: %v", desc, team.Id, err)
+		}
+	}
+
 	// return status message
 	return templateshlp.RenderJSON(w, c, "team request was created")
 }
 
 // SendInvite handler, use it to send an invitation to gonawin.
 //	POST	/j/teams/sendinvite/[0-9]+/			Send an invitation to a user with the given team id and user id.
-// An activity is published when the invitation is sent.
+// An activity and a notification are published when the invitation is sent.
 // Response: a JSON formatted status message.
 //
 func SendInvite(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
@@ -96,7 +110,12 @@ func SendInvite(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	// publish new activity
-	user.Publish(c, "invitation", "has been invited to join team ", team.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).Publish(user, "invitation", "has been invited to join team ", team.Entity(), mdl.ActivityEntity{})
+
+	msg := fmt.Sprintf("%s invited you to join team %s.", u.Username, team.Name)
+	if err := mdl.Notify(c, []*mdl.User{user}, mdl.NotificationInvitation, msg, team.Entity()); err != nil {
+		log.Errorf(c, "%s unable to notify user %v: %v", desc, user.Id, err)
+	}
 
 	return templateshlp.RenderJSON(w, c, "user request was created")
 }
@@ -128,7 +147,7 @@ func Invited(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	var users []*mdl.User
-	if users, err = mdl.UsersByIds(c, ids); err != nil {
+	if users, err = mdl.Repos(c).Users.ByIDs(ids); err != nil {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
 
@@ -185,18 +204,23 @@ func AllowRequest(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	// join user to the team
 	var team *mdl.Team
-	team, err = mdl.TeamByID(c, teamRequest.TeamId)
+	team, err = mdl.Repos(c).Teams.ByID(teamRequest.TeamId)
 	if err != nil {
 		log.Errorf(c, "%s team not found. id: %v, err: %v", desc, teamRequest.TeamId, err)
 		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamRequestNotFound)}
 	}
-	user, err := mdl.UserByID(c, teamRequest.UserId)
+
+	if !team.RoleOf(u).Includes(mdl.RoleTeamAdmin) {
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeRoleForbiden)}
+	}
+
+	user, err := mdl.Repos(c).Users.ByID(teamRequest.UserId)
 	if err != nil {
 		log.Errorf(c, "%s user not found, err: %v", desc, err)
 		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
 	}
 
-	team.Join(c, user)
+	mdl.Repos(c).JoinTeam(team, user)
 	// request is no more needed so clear it from datastore
 	teamRequest.Destroy(c)
 
@@ -225,6 +249,16 @@ func DenyRequest(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return err
 	}
 
+	var team *mdl.Team
+	if team, err = mdl.Repos(c).Teams.ByID(teamRequest.TeamId); err != nil {
+		log.Errorf(c, "%s team not found. id: %v, err: %v", desc, teamRequest.TeamId, err)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamRequestNotFound)}
+	}
+
+	if !team.RoleOf(u).Includes(mdl.RoleTeamAdmin) {
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeRoleForbiden)}
+	}
+
 	// request is no more needed so clear it from datastore
 	teamRequest.Destroy(c)
 
@@ -23,6 +23,7 @@ import (
 
 	"appengine"
 
+	"github.com/taironas/gonawin/extract"
 	"github.com/taironas/gonawin/helpers"
 	"github.com/taironas/gonawin/helpers/log"
 	templateshlp "github.com/taironas/gonawin/helpers/templates"
@@ -31,7 +32,8 @@ import (
 )
 
 // Search handler returns the result of a team search in a JSON format.
-// It uses parameter 'q' to make the query.
+// It uses parameter 'q' to make the query, and the 'count' and 'page' params
+// to get a page of the result, default values are 20 and 1 respectively.
 //
 //	GET	/j/teams/search/			Search for all teams respecting the query "q"
 //
@@ -44,20 +46,15 @@ func Search(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	c := appengine.NewContext(r)
 	desc := "Team Search Handler:"
+	extract := extract.NewContext(c, desc, r)
 
-	words := helpers.SetOfStrings(keywords)
-
-	var ids []int64
-	var err error
-	if ids, err = mdl.GetTeamInvertedIndexes(c, words); err != nil {
+	results, _, err := mdl.Search(c, []string{"Team"}, keywords, extract.Count(), extract.Page())
+	if err != nil {
 		return unableToPerformSearch(c, w, desc, err)
 	}
 
-	result := mdl.TeamScore(c, keywords, ids)
-	log.Infof(c, "%s result from TeamScore: %v", desc, result)
-
 	var teams []*mdl.Team
-	if teams, err = mdl.TeamsByIDs(c, result); err != nil {
+	if teams, err = mdl.Repos(c).Teams.ByIDs(mdl.SearchResultIds(results, "Team")); err != nil {
 		log.Infof(c, "%v something failed when calling TeamsByIDs: %v", desc, err)
 		return notFound(c, w, keywords)
 	}
@@ -112,7 +109,7 @@ func notFound(c appengine.Context, w http.ResponseWriter, keywords string) error
 }
 
 func unableToPerformSearch(c appengine.Context, w http.ResponseWriter, desc string, err error) error {
-	log.Errorf(c, "%s teams.Index, error occurred when getting indexes of words: %v", desc, err)
+	log.Errorf(c, "%s teams.Index, error occurred during search: %v", desc, err)
 	data := struct {
 		MessageDanger string `json:",omitempty"`
 	}{
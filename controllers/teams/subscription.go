@@ -59,19 +59,19 @@ func Join(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTeamPrivateJoinForbiden)}
 	}
 
-	if err = team.Join(c, u); err != nil {
+	if err = mdl.Repos(c).JoinTeam(team, u); err != nil {
 		log.Errorf(c, "%s  error on Join team: %v", desc, err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
 
 	// publish new activity
-	if updatedUser, err := mdl.UserByID(c, u.Id); err != nil {
+	if updatedUser, err := mdl.Repos(c).Users.ByID(u.Id); err != nil {
 		log.Errorf(c, "%s  User not found %v", desc, u.Id)
 	} else {
-		updatedUser.Publish(c, "team", "joined team", team.Entity(), mdl.ActivityEntity{})
+		mdl.Repos(c).Publish(updatedUser, "team", "joined team", team.Entity(), mdl.ActivityEntity{})
 	}
 
-	vm := buildTeamJoinViewModel(team)
+	vm := BuildTeamJoinViewModel(team)
 	return templateshlp.RenderJSON(w, c, vm)
 }
 
@@ -82,7 +82,9 @@ type TeamJoinViewModel struct {
 	Team        mdl.TeamJSON
 }
 
-func buildTeamJoinViewModel(team *mdl.Team) TeamJoinViewModel {
+// BuildTeamJoinViewModel returns the view model of a team joined by a user.
+//
+func BuildTeamJoinViewModel(team *mdl.Team) TeamJoinViewModel {
 	var t mdl.TeamJSON
 	fieldsToKeep := []string{"Id", "Name", "AdminIds", "Private"}
 	helpers.InitPointerStructure(team, &t, fieldsToKeep)
@@ -116,7 +118,7 @@ func Leave(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeTeamAdminCannotLeave)}
 	}
 
-	if err := team.Leave(c, u); err != nil {
+	if err := mdl.Repos(c).LeaveTeam(team, u); err != nil {
 		log.Errorf(c, "%s error on Leave team: %v", desc, err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
@@ -127,10 +129,10 @@ func Leave(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	helpers.KeepFields(&tJSON, fieldsToKeep)
 
 	// publish new activity
-	if updatedUser, err := mdl.UserByID(c, u.Id); err != nil {
+	if updatedUser, err := mdl.Repos(c).Users.ByID(u.Id); err != nil {
 		log.Errorf(c, "User not found %v", u.Id)
 	} else {
-		updatedUser.Publish(c, "team", "left team", team.Entity(), mdl.ActivityEntity{})
+		mdl.Repos(c).Publish(updatedUser, "team", "left team", team.Entity(), mdl.ActivityEntity{})
 	}
 
 	msg := fmt.Sprintf("You left team %s.", team.Name)
@@ -42,7 +42,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strconv"
+	"time"
 
 	"appengine"
 
@@ -73,7 +73,8 @@ type PriceData struct {
 // Parameters:
 //   'page' a int indicating the page number.
 //   'count' a int indicating the number of teams per page number. default value is 25
-// Response: array of JSON formatted teams.
+//   'cursor' the cursor of the page, returned as 'nextCursor' with the previous page. Pass an empty cursor for the first page.
+// Response: array of JSON formatted teams, or with 'cursor' JSON formatted teams and the cursor of the next page.
 //
 func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	if r.Method != "GET" {
@@ -82,35 +83,31 @@ func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	c := appengine.NewContext(r)
 	desc := "teams index handler:"
+	extract := extract.NewContext(c, desc, r)
 
-	// get count parameter, if not present count is set to 25
-	strcount := r.FormValue("count")
-	count := int64(25)
-	if len(strcount) > 0 {
-		if n, err := strconv.ParseInt(strcount, 0, 64); err != nil {
-			log.Errorf(c, "%s: error during conversion of count parameter: %v", desc, err)
-		} else {
-			count = n
-		}
-	}
+	count := extract.CountOrDefault(25)
 
-	// get page parameter, if not present set page to the first one.
-	strpage := r.FormValue("page")
-	page := int64(1)
-	if len(strpage) > 0 {
-		if p, err := strconv.ParseInt(strpage, 0, 64); err != nil {
-			log.Errorf(c, "%s error during conversion of page parameter: %v", desc, err)
-		} else {
-			page = p
-		}
+	if extract.LegacyPage() {
+		teams := mdl.GetNotJoinedTeams(c, u, count, extract.Page())
+		return templateshlp.RenderJSON(w, c, buildIndexTeamsViewModel(teams))
 	}
 
-	// fetch teams
-	teams := mdl.GetNotJoinedTeams(c, u, count, page)
-
-	tvm := buildIndexTeamsViewModel(teams)
+	teams, next, err := mdl.GetNotJoinedTeamsByCursor(c, u, count, extract.Cursor())
+	if err == mdl.ErrInvalidCursor {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeInvalidCursor)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to get teams: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
 
-	return templateshlp.RenderJSON(w, c, tvm)
+	data := struct {
+		Teams      []indexTeamViewModel
+		NextCursor string `json:"nextCursor,omitempty"`
+	}{
+		buildIndexTeamsViewModel(teams),
+		next,
+	}
+	return templateshlp.RenderJSON(w, c, data)
 }
 
 type indexTeamViewModel struct {
@@ -166,47 +163,38 @@ func New(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeNameCannotBeEmpty)}
 	}
 
-	if t := mdl.FindTeams(c, "KeyName", helpers.TrimLower(tData.Name)); t != nil {
+	team, err := mdl.Repos(c).NewTeam(u, tData.Name, tData.Description, tData.Visibility == "Private")
+	if err == mdl.ErrTeamExists {
 		log.Errorf(c, "%s That team name already exists.", desc)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamAlreadyExists)}
-	}
-
-	team, err := mdl.CreateTeam(c, tData.Name, tData.Description, u.Id, tData.Visibility == "Private")
-	if err != nil {
+	} else if err != nil {
 		log.Errorf(c, "%s error when trying to create a team: %v", desc, err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamCannotCreate)}
 	}
-	// join the team
-	if err = team.Join(c, u); err != nil {
-		log.Errorf(c, "%s error when trying to create a team relationship: %v", desc, err)
-		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamCannotCreate)}
-	}
-	// publish new activity
-	if updatedUser, err := mdl.UserByID(c, u.Id); err != nil {
-		log.Errorf(c, "User not found %v", u.Id)
-	} else {
-		updatedUser.Publish(c, "team", "created a new team", team.Entity(), mdl.ActivityEntity{})
-	}
 
 	// return the newly created team
-	tvm := buildNewTeamsViewModel(team)
+	tvm := BuildNewTeamViewModel(team)
 
 	return templateshlp.RenderJSON(w, c, tvm)
 }
 
-type newTeamViewModel struct {
+// NewTeamViewModel is the view model of the New handler.
+//
+type NewTeamViewModel struct {
 	MessageInfo string `json:",omitempty"`
 	Team        mdl.TeamJSON
 }
 
-func buildNewTeamsViewModel(team *mdl.Team) newTeamViewModel {
+// BuildNewTeamViewModel returns the view model of a newly created team.
+//
+func BuildNewTeamViewModel(team *mdl.Team) NewTeamViewModel {
 	var tJSON mdl.TeamJSON
 	fieldsToKeep := []string{"Id", "Name", "AdminIds", "Private"}
 	helpers.InitPointerStructure(team, &tJSON, fieldsToKeep)
 
 	msg := fmt.Sprintf("The team %s was correctly created!", team.Name)
 
-	tvm := newTeamViewModel{MessageInfo: msg, Team: tJSON}
+	tvm := NewTeamViewModel{MessageInfo: msg, Team: tJSON}
 
 	return tvm
 }
@@ -240,13 +228,15 @@ func Show(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	// build tournaments json
 	tournaments := team.Tournaments(c)
 
-	svm := buildShowViewModel(c, team, u, players, tournaments)
+	svm := BuildShowViewModel(team, u, mdl.WasTeamRequestSent(c, team.Id, u.Id), players, tournaments)
 
 	return templateshlp.RenderJSON(w, c, svm)
 
 }
 
-type showViewModel struct {
+// ShowViewModel is the view model of the Show handler.
+//
+type ShowViewModel struct {
 	Team        mdl.TeamJSON              `json:",omitempty"`
 	Joined      bool                      `json:",omitempty"`
 	RequestSent bool                      `json:",omitempty"`
@@ -255,19 +245,23 @@ type showViewModel struct {
 	ImageURL    string                    `json:",omitempty"`
 }
 
-func buildShowViewModel(c appengine.Context, t *mdl.Team, u *mdl.User, players []*mdl.User, tournaments []*mdl.Tournament) showViewModel {
+// BuildShowViewModel returns the view model of a team shown to a user, with its players and tournaments.
+// requestSent tells if the user sent a request to join the team.
+//
+func BuildShowViewModel(t *mdl.Team, u *mdl.User, requestSent bool, players []*mdl.User, tournaments []*mdl.Tournament) ShowViewModel {
 	// build team json
 	var tJSON mdl.TeamJSON
 	fieldsToKeep := []string{"Id", "Name", "Description", "AdminIds", "Private", "TournamentIds", "Accuracy"}
 	helpers.InitPointerStructure(t, &tJSON, fieldsToKeep)
 
-	pvm := buildPlayersViewModel(c, players)
-	tvm := buildShowTournamentViewModel(c, tournaments)
+	pvm := buildPlayersViewModel(players)
+	tvm := buildShowTournamentViewModel(tournaments)
 
-	return showViewModel{
+	joined, _ := u.ContainsTeamID(t.Id)
+	return ShowViewModel{
 		tJSON,
-		t.Joined(c, u),
-		mdl.WasTeamRequestSent(c, t.Id, u.Id),
+		joined,
+		requestSent,
 		pvm,
 		tvm,
 		helpers.TeamImageURL(t.Name, t.Id),
@@ -282,7 +276,7 @@ type playerViewModel struct {
 	ImageURL string
 }
 
-func buildPlayersViewModel(c appengine.Context, players []*mdl.User) []playerViewModel {
+func buildPlayersViewModel(players []*mdl.User) []playerViewModel {
 	pvm := make([]playerViewModel, len(players))
 	for i, p := range players {
 		pvm[i].Id = p.Id
@@ -304,14 +298,14 @@ type showTournamentViewModel struct {
 	ImageURL          string
 }
 
-func buildShowTournamentViewModel(c appengine.Context, tournaments []*mdl.Tournament) []showTournamentViewModel {
+func buildShowTournamentViewModel(tournaments []*mdl.Tournament) []showTournamentViewModel {
 	tvm := make([]showTournamentViewModel, len(tournaments))
 	for i, t := range tournaments {
 		tvm[i].Id = t.Id
 		tvm[i].Name = t.Name
 		tvm[i].ParticipantsCount = len(t.UserIds)
 		tvm[i].TeamsCount = len(t.TeamIds)
-		tvm[i].Progress = t.Progress(c)
+		tvm[i].Progress = t.ProgressAt(time.Now())
 		tvm[i].ImageURL = helpers.TournamentImageURL(t.Name, t.Id)
 	}
 
@@ -338,11 +332,6 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return err
 	}
 
-	if !mdl.IsTeamAdmin(c, team.Id, u.Id) {
-		log.Errorf(c, "%s user is not admin", desc)
-		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTeamUpdateForbiden)}
-	}
-
 	// only work on name and private. Other values should not be editable
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
@@ -364,7 +353,7 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		(updatedData.Name != team.Name || updatedData.Description != team.Description || updatedPrivate != team.Private) {
 		if updatedData.Name != team.Name {
 			// be sure that a team with that name does not exist in datastore.
-			if t := mdl.FindTeams(c, "KeyName", helpers.TrimLower(updatedData.Name)); t != nil {
+			if t, _ := mdl.Repos(c).Teams.Find("KeyName", helpers.TrimLower(updatedData.Name)); len(t) > 0 {
 				log.Errorf(c, "%s That team name already exists.", desc)
 				return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTeamAlreadyExists)}
 			}
@@ -381,7 +370,7 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	// publish new activity
-	u.Publish(c, "team", "updated team", team.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).Publish(u, "team", "updated team", team.Entity(), mdl.ActivityEntity{})
 
 	tvm := buildUpdateTeamsViewModel(team)
 
@@ -425,11 +414,6 @@ func Destroy(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return err
 	}
 
-	if !mdl.IsTeamAdmin(c, team.Id, u.Id) {
-		log.Errorf(c, "%s user is not admin", desc)
-		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTeamDeleteForbiden)}
-	}
-
 	// delete all team-user relationships
 	var players []*mdl.User
 	if players, err = team.Players(c); err != nil {
@@ -457,7 +441,7 @@ func Destroy(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	team.Destroy(c)
 
 	// publish new activity
-	u.Publish(c, "team", "deleted team", team.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).Publish(u, "team", "deleted team", team.Entity(), mdl.ActivityEntity{})
 
 	tvm := buildDestroyTeamsViewModel(team)
 
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// Webhooks handler, use it to get the webhooks registered on a team.
+// Only the admins of the team can see its webhooks.
+//
+//	GET	/j/teams/:teamId/webhooks
+//
+func Webhooks(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Team Webhooks Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var team *mdl.Team
+	var err error
+	if team, err = extract.Team(); err != nil {
+		return err
+	}
+
+	webhooks := mdl.FindWebhooksByTeam(c, team.Id)
+
+	fieldsToKeep := []string{"Id", "URL", "Secret", "Events", "Created"}
+	webhooksJSON := make([]mdl.WebhookJSON, len(webhooks))
+	helpers.TransformFromArrayOfPointers(&webhooks, &webhooksJSON, fieldsToKeep)
+
+	data := struct {
+		Webhooks []mdl.WebhookJSON
+		Events   []string
+	}{
+		webhooksJSON,
+		mdl.WebhookEvents,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// NewWebhook handler, use it to register a webhook on a team.
+// It expects the 'url' param and an optional 'events' param, the events separated by commas.
+// The webhook is sent all the events when no event is given.
+// The response holds the secret used to sign the payloads.
+//
+//	POST	/j/teams/:teamId/webhooks/new?url=:url&events=:events
+//
+func NewWebhook(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Team New Webhook Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var team *mdl.Team
+	var err error
+	if team, err = extract.Team(); err != nil {
+		return err
+	}
+
+	rawURL := r.FormValue("url")
+	if parsed, err := url.Parse(rawURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeWebhookInvalid)}
+	}
+
+	events := mdl.WebhookEvents
+	if e := r.FormValue("events"); len(e) > 0 {
+		events = nil
+		for _, event := range strings.Split(e, ",") {
+			event = strings.TrimSpace(event)
+			if !mdl.IsWebhookEventValid(event) {
+				return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeWebhookInvalid)}
+			}
+			events = append(events, event)
+		}
+	}
+
+	var wh *mdl.Webhook
+	if wh, err = mdl.CreateWebhook(c, team.Id, rawURL, events); err != nil {
+		log.Errorf(c, "%s unable to create webhook: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeWebhookCannotCreate)}
+	}
+
+	var whJSON mdl.WebhookJSON
+	fieldsToKeep := []string{"Id", "URL", "Secret", "Events", "Created"}
+	helpers.InitPointerStructure(wh, &whJSON, fieldsToKeep)
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Webhook     mdl.WebhookJSON
+	}{
+		fmt.Sprintf("You added a webhook to team %s.", team.Name),
+		whJSON,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// DestroyWebhook handler, use it to remove a webhook from a team.
+//
+//	POST	/j/teams/:teamId/webhooks/destroy/:webhookId
+//
+func DestroyWebhook(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Team Destroy Webhook Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var team *mdl.Team
+	var err error
+	if team, err = extract.Team(); err != nil {
+		return err
+	}
+
+	var wh *mdl.Webhook
+	if wh, err = extract.Webhook(team); err != nil {
+		return err
+	}
+
+	if err = wh.Destroy(c); err != nil {
+		log.Errorf(c, "%s unable to destroy webhook: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeWebhookCannotDelete)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		fmt.Sprintf("You removed a webhook from team %s.", team.Name),
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
@@ -67,6 +67,11 @@ func AddAdmin(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
 
+	notification := fmt.Sprintf("%s added you as admin of tournament %s.", u.Username, tournament.Name)
+	if err = mdl.Notify(c, []*mdl.User{newAdmin}, mdl.NotificationAdmin, notification, tournament.Entity()); err != nil {
+		log.Errorf(c, "%s unable to notify user %v: %v", desc, newAdmin.Id, err)
+	}
+
 	// send response
 	var tJSON mdl.TournamentJSON
 	fieldsToKeep := []string{"Id", "Name", "AdminIds", "Private"}
@@ -81,6 +81,7 @@ type PhaseJSON struct {
 // * the teams involved
 // * the date
 // by default the data returned is grouped by days.This means we will return an array of days, each of which can have an array of matches.
+// Days and dates are computed in the time zone of the user, use the 'timezone' parameter to override it.
 // You can also specify the 'groupby' parameter to be 'day' or 'phase' in which case you would have an array of phases,
 // each of which would have an array of days who would have an array of matches.
 //
@@ -110,7 +111,7 @@ func Calendar(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	if groupby == "day" {
 		matchesJSON := buildMatchesFromTournament(c, t, u)
 
-		days := matchesGroupByDay(t, matchesJSON)
+		days := matchesGroupByDay(t, matchesJSON, userLocation(r, u))
 
 		data := struct {
 			Days []DayJSON
@@ -122,7 +123,7 @@ func Calendar(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	} else if groupby == "phase" {
 		matchesJSON := buildMatchesFromTournament(c, t, u)
-		phases := matchesGroupByPhase(t, matchesJSON)
+		phases := matchesGroupByPhase(t, matchesJSON, userLocation(r, u))
 		data := struct {
 			Phases []PhaseJSON
 		}{
@@ -170,7 +171,7 @@ func CalendarWithPrediction(w http.ResponseWriter, r *http.Request, u *mdl.User)
 	predictsByPlayer := make([]mdl.Predicts, len(players))
 	for i, p := range players {
 		var predicts []*mdl.Predict
-		if predicts, err = mdl.PredictsByIds(c, p.PredictIds); err != nil {
+		if predicts, err = mdl.Repos(c).Predicts.ByIDs(p.PredictIds); err != nil {
 			log.Infof(c, "%v something failed when calling PredictsByIds for player %v : %v", desc, p.Id, err)
 			continue
 		}
@@ -184,7 +185,7 @@ func CalendarWithPrediction(w http.ResponseWriter, r *http.Request, u *mdl.User)
 	}
 
 	if groupby == "day" {
-		vm := buildTournamentCalendarViewModel(c, t, u, predictsByPlayer, players)
+		vm := buildTournamentCalendarViewModel(c, t, u, predictsByPlayer, players, userLocation(r, u))
 		return templateshlp.RenderJSON(w, c, vm)
 
 	} else if groupby == "phase" {
@@ -198,10 +199,10 @@ type tournamentCalendarViewModel struct {
 	Days []DayWithPredictionJSON
 }
 
-func buildTournamentCalendarViewModel(c appengine.Context, t *mdl.Tournament, u *mdl.User, predictsByPlayer []mdl.Predicts, players []*mdl.User) tournamentCalendarViewModel {
+func buildTournamentCalendarViewModel(c appengine.Context, t *mdl.Tournament, u *mdl.User, predictsByPlayer []mdl.Predicts, players []*mdl.User, loc *time.Location) tournamentCalendarViewModel {
 
 	matches := buildMatchesFromTournament(c, t, u)
-	matchesByDay := matchesGroupByDay(t, matches)
+	matchesByDay := matchesGroupByDay(t, matches, loc)
 
 	daysWithPredictions := make([]DayWithPredictionJSON, len(matchesByDay))
 
@@ -243,7 +244,7 @@ func matchParticipants(m MatchJSON, players []*mdl.User, predictsByPlayer []mdl.
 
 // From an array of Matches, create an array of Phases where the matches are grouped in.
 // We use the Phases intervals and the IdNumber of each match to do this operation.
-func matchesGroupByPhase(t *mdl.Tournament, matches []MatchJSON) []PhaseJSON {
+func matchesGroupByPhase(t *mdl.Tournament, matches []MatchJSON, loc *time.Location) []PhaseJSON {
 
 	var tb mdl.TournamentBuilder
 	if tb = mdl.GetTournamentBuilder(t); tb == nil {
@@ -265,7 +266,7 @@ func matchesGroupByPhase(t *mdl.Tournament, matches []MatchJSON) []PhaseJSON {
 				filteredMatches = append(filteredMatches, v)
 			}
 		}
-		phases[i].Days = matchesGroupByDay(t, filteredMatches)
+		phases[i].Days = matchesGroupByDay(t, filteredMatches, loc)
 		lastDayOfPhase := len(phases[i].Days) - 1
 		lastMatchOfPhase := len(phases[i].Days[lastDayOfPhase].Matches) - 1
 		if phases[i].Days[lastDayOfPhase].Matches[lastMatchOfPhase].Finished {
@@ -278,29 +279,25 @@ func matchesGroupByPhase(t *mdl.Tournament, matches []MatchJSON) []PhaseJSON {
 }
 
 // From an array of matches, create an array of Days where the matches are grouped in.
-// We use the Date of each match to do this.
-func matchesGroupByDay(t *mdl.Tournament, matches []MatchJSON) []DayJSON {
+// We use the Date of each match in the given location to do this.
+// Matches of a day are sorted by kickoff time and their dates are set in the given location.
+func matchesGroupByDay(t *mdl.Tournament, matches []MatchJSON, loc *time.Location) []DayJSON {
 
 	mapOfDays := make(map[string][]MatchJSON)
 
-	const shortForm = "Jan/02/2006"
+	const dayForm = "2006-01-02"
 	for _, m := range matches {
-		currentDate := m.Date.Format(shortForm)
-		_, ok := mapOfDays[currentDate]
-		if ok {
-			mapOfDays[currentDate] = append(mapOfDays[currentDate], m)
-		} else {
-			var arrayMatches []MatchJSON
-			arrayMatches = append(arrayMatches, m)
-			mapOfDays[currentDate] = arrayMatches
-		}
+		m.Date = mdl.MatchDateIn(m.Date, m.Kickoff, loc)
+		currentDate := m.Date.Format(dayForm)
+		mapOfDays[currentDate] = append(mapOfDays[currentDate], m)
 	}
 
 	var days []DayJSON
 	days = make([]DayJSON, len(mapOfDays))
 	i := 0
 	for key, value := range mapOfDays {
-		days[i].Date, _ = time.Parse(shortForm, key)
+		days[i].Date, _ = time.ParseInLocation(dayForm, key, loc)
+		sort.Stable(MatchesByDate(value))
 		days[i].Matches = value
 		i++
 	}
@@ -309,9 +306,27 @@ func matchesGroupByDay(t *mdl.Tournament, matches []MatchJSON) []DayJSON {
 	return days
 }
 
+// userLocation returns the location used to display dates to a user.
+// The 'timezone' parameter of the request overrides the time zone of the user.
+func userLocation(r *http.Request, u *mdl.User) *time.Location {
+	if tz := r.FormValue("timezone"); len(tz) > 0 {
+		if loc, err := helpers.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return u.Location()
+}
+
 // ByDate type implements the sort.Interface for []DayJSON based on the date field.
 type ByDate []DayJSON
 
 func (a ByDate) Len() int           { return len(a) }
 func (a ByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByDate) Less(i, j int) bool { return a[i].Date.Before(a[j].Date) }
+
+// MatchesByDate type implements the sort.Interface for []MatchJSON based on the date field.
+type MatchesByDate []MatchJSON
+
+func (a MatchesByDate) Len() int           { return len(a) }
+func (a MatchesByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a MatchesByDate) Less(i, j int) bool { return a[i].Date.Before(a[j].Date) }
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tournaments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/ical"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+const (
+	icsProdID          = "-//gonawin//gonawin calendar//EN"
+	icsMatchDuration   = 2 * time.Hour
+	icsReminderBefore  = time.Hour // time before the prediction lock to display the alarm.
+	icsContentType     = "text/calendar; charset=utf-8"
+	icsUIDDomainSuffix = "@gonawin.com"
+)
+
+// CalendarICS handler gets you the calendar of a tournament in the iCalendar format.
+// Use this handler to subscribe to the tournament schedule in a calendar application.
+//	GET	/j/tournaments/[0-9]+/calendar.ics
+//
+func CalendarICS(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Tournament Calendar ICS Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var err error
+	var t *mdl.Tournament
+	if t, err = extract.Tournament(); err != nil {
+		return err
+	}
+
+	matches := mdl.GetAllMatchesFromTournament(c, t)
+	cal := tournamentCalendar(t, matches, mdl.MapOfIDTeams(c, t), time.Now(), false)
+
+	w.Header().Set("Content-Type", icsContentType)
+	return cal.Encode(w)
+}
+
+// UserCalendarICS handler gets you the calendar of the matches of a tournament that a user has not predicted yet,
+// in the iCalendar format. Each match has an alarm before its prediction is locked.
+// As calendar applications cannot set the authentication header, an API key of the user with the 'read:calendar'
+// scope is passed in the 'key' parameter. The key only gives access to the calendar and can be revoked.
+//	GET	/j/tournaments/[0-9]+/users/[0-9]+/calendar.ics?key=:key
+//
+func UserCalendarICS(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Tournament User Calendar ICS Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var err error
+	var u *mdl.User
+	if u, err = extract.User(); err != nil {
+		return err
+	}
+
+	k, err := mdl.APIKeyByKey(c, r.FormValue("key"))
+	if err != nil || k.UserId != u.Id || !k.HasScope(mdl.ScopeReadCalendar) {
+		log.Errorf(c, "%s wrong key for user %v", desc, u.Id)
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeSessionsForbiden)}
+	}
+
+	var t *mdl.Tournament
+	if t, err = extract.Tournament(); err != nil {
+		return err
+	}
+
+	var predicts mdl.Predicts
+	if predicts, err = mdl.Repos(c).Predicts.ByIDs(u.PredictIds); err != nil {
+		log.Errorf(c, "%s predictions not found, %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	var matches []*mdl.Tmatch
+	for _, m := range mdl.GetAllMatchesFromTournament(c, t) {
+		if ok, _ := predicts.ContainsMatchID(m.Id); !ok && !m.Finished {
+			matches = append(matches, m)
+		}
+	}
+
+	cal := tournamentCalendar(t, matches, mdl.MapOfIDTeams(c, t), time.Now(), true)
+
+	w.Header().Set("Content-Type", icsContentType)
+	return cal.Encode(w)
+}
+
+// tournamentCalendar builds the calendar of the matches of a tournament.
+// When withAlarms is true, an alarm is set on each match before its prediction is locked.
+// Matches without kickoff time are all day events.
+func tournamentCalendar(t *mdl.Tournament, matches []*mdl.Tmatch, mapIDTeams map[int64]string, now time.Time, withAlarms bool) *ical.Calendar {
+
+	cal := &ical.Calendar{
+		ProdID: icsProdID,
+		Name:   t.Name,
+		Events: make([]ical.Event, len(matches)),
+	}
+
+	for i, m := range matches {
+		team1, team2 := m.TeamNames(mapIDTeams)
+		summary := fmt.Sprintf("%s - %s", team1, team2)
+		if m.Finished {
+			summary = fmt.Sprintf("%s %d - %d %s", team1, m.Result1, m.Result2, team2)
+		}
+
+		e := ical.Event{
+			UID:         fmt.Sprintf("tournament-%d-match-%d%s", t.Id, m.IdNumber, icsUIDDomainSuffix),
+			Stamp:       now,
+			Start:       m.Date,
+			End:         m.Date.Add(icsMatchDuration),
+			Summary:     summary,
+			Location:    m.Location,
+			Description: fmt.Sprintf("%s, match %d", t.Name, m.IdNumber),
+		}
+		if d := m.Date.UTC(); !m.Kickoff {
+			e.AllDay = true
+			e.Start = d
+			e.End = d.AddDate(0, 0, 1)
+		}
+		if withAlarms {
+			e.Alarms = []ical.Alarm{{
+				Trigger:     m.PredictionLock().Add(-icsReminderBefore),
+				Description: fmt.Sprintf("Predictions for %s close at %s UTC.", summary, m.PredictionLock().UTC().Format("Jan 2 15:04")),
+			}}
+		}
+		cal.Events[i] = e
+	}
+	return cal
+}
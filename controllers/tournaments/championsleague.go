@@ -61,8 +61,8 @@ func GetChampionsLeague(w http.ResponseWriter, r *http.Request, u *mdl.User) err
 	c := appengine.NewContext(r)
 	desc := "Get Champions League Handler:"
 
-	tournaments := mdl.FindTournaments(c, "Name", "2015-2016 UEFA Champions League")
-	if tournaments == nil {
+	tournaments, err := mdl.Repos(c).Tournaments.Find("Name", "2015-2016 UEFA Champions League")
+	if err != nil || len(tournaments) == 0 {
 		log.Errorf(c, "%s Champions League tournament was not found.", desc)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
 	}
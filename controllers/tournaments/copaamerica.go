@@ -61,8 +61,8 @@ func GetCopaAmerica(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	c := appengine.NewContext(r)
 	desc := "GetCopaAmerica Handler:"
 
-	tournaments := mdl.FindTournaments(c, "Name", "2016 Copa America")
-	if tournaments == nil {
+	tournaments, err := mdl.Repos(c).Tournaments.Find("Name", "2016 Copa America")
+	if err != nil || len(tournaments) == 0 {
 		log.Errorf(c, "%s Copa America tournament was not found.", desc)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
 	}
@@ -61,8 +61,8 @@ func GetEuro(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	c := appengine.NewContext(r)
 	desc := "Get Euro Handler:"
 
-	tournaments := mdl.FindTournaments(c, "Name", "2016 UEFA Euro")
-	if tournaments == nil {
+	tournaments, err := mdl.Repos(c).Tournaments.Find("Name", "2016 UEFA Euro")
+	if err != nil || len(tournaments) == 0 {
 		log.Errorf(c, "%s Euro tournament was not found.", desc)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
 	}
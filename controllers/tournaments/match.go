@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -52,6 +53,7 @@ type MatchJSON struct {
 	Finished   bool
 	Ready      bool
 	CanPredict bool
+	Kickoff    bool
 }
 
 // Matches is the handler allowing to get the matches of a tournament.
@@ -97,6 +99,174 @@ func Matches(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	return templateshlp.RenderJSON(w, c, data)
 }
 
+// MatchDetailJSON is a variable to hold the detailed information of a match.
+//
+type MatchDetailJSON struct {
+	Match        MatchJSON
+	Rule         string
+	Predicts     []UserPredictionJSON    `json:",omitempty"`
+	Distribution mdl.PredictDistribution `json:",omitempty"`
+	Points       []UserPointsJSON        `json:",omitempty"`
+}
+
+// UserPointsJSON is a variable to hold user data and the points awarded for a match.
+//
+type UserPointsJSON struct {
+	Id       int64
+	Username string
+	Alias    string
+	Predict  string
+	Points   int64
+}
+
+// Match is the handler allowing to get the details of a match of a tournament.
+//	GET	/j/tournaments/[0-9]+/matches/[0-9]+
+//
+// The response holds:
+// * the teams, date, location, rule and result of the match.
+// * the prediction of the current user.
+// * the predictions of the members of the user's teams, once predictions on the match are locked.
+// * the distribution of the predictions on the match.
+// * the points awarded to the user and the members of the user's teams, once the match is finished.
+//
+func Match(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Tournament Match Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var err error
+	var tournament *mdl.Tournament
+	if tournament, err = extract.Tournament(); err != nil {
+		return err
+	}
+
+	var match *mdl.Tmatch
+	if match, err = extract.Match(tournament); err != nil {
+		if _, ok := err.(*helpers.NotFound); ok {
+			return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeMatchNotFound)}
+		}
+		return err
+	}
+
+	var tb mdl.TournamentBuilder
+	if tb = mdl.GetTournamentBuilder(tournament); tb == nil {
+		log.Errorf(c, "%s TournamentBuilder not found", desc)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	var mjson MatchJSON
+	mjson.Id = match.Id
+	mjson.IdNumber = match.IdNumber
+	mjson.Date = match.Date
+	mjson.Kickoff = match.Kickoff
+	mjson.Team1, mjson.Team2 = match.TeamNames(tb.MapOfIDTeams(c, tournament))
+	mapTeamCodes := tb.MapOfTeamCodes()
+	mjson.Iso1 = mapTeamCodes[mjson.Team1]
+	mjson.Iso2 = mapTeamCodes[mjson.Team2]
+	mjson.Location = match.Location
+	mjson.Result1 = match.Result1
+	mjson.Result2 = match.Result2
+	mjson.Finished = match.Finished
+	mjson.Ready = match.Ready
+	mjson.CanPredict = match.CanPredict
+
+	var predicts mdl.Predicts
+	if predicts, err = mdl.Repos(c).Predicts.Find("MatchId", match.Id); err != nil {
+		log.Errorf(c, "%s unable to find predicts of match %v: %v", desc, match.Id, err)
+	}
+
+	if ok, i := predicts.ContainsUserID(u.Id); ok {
+		mjson.HasPredict = true
+		mjson.Predict = fmt.Sprintf("%v - %v", predicts[i].Result1, predicts[i].Result2)
+	}
+
+	detail := MatchDetailJSON{
+		Match:        mjson,
+		Rule:         match.Rule,
+		Distribution: predicts.Distribution(),
+	}
+
+	// predictions of team members are only visible once they cannot be changed anymore.
+	var members []*mdl.User
+	if !match.CanPredict || match.Finished {
+		if members, err = teamMembersInTournament(c, tournament, u); err != nil {
+			log.Errorf(c, "%s unable to get team members of user %v: %v", desc, u.Id, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+		}
+		detail.Predicts = make([]UserPredictionJSON, len(members))
+		for i, m := range members {
+			detail.Predicts[i].Id = m.Id
+			detail.Predicts[i].Username = m.Username
+			detail.Predicts[i].Alias = m.Alias
+			detail.Predicts[i].Predict = "-"
+			if ok, j := predicts.ContainsUserID(m.Id); ok {
+				detail.Predicts[i].Predict = fmt.Sprintf("%v - %v", predicts[j].Result1, predicts[j].Result2)
+			}
+		}
+	}
+
+	// points are only listed for the user and the members of the user's teams.
+	if match.Finished {
+		users := append([]*mdl.User{u}, members...)
+		detail.Points = make([]UserPointsJSON, 0, len(users))
+		for _, user := range users {
+			ok, i := predicts.ContainsUserID(user.Id)
+			if !ok {
+				continue
+			}
+			detail.Points = append(detail.Points, UserPointsJSON{
+				Id:       user.Id,
+				Username: user.Username,
+				Alias:    user.Alias,
+				Predict:  fmt.Sprintf("%v - %v", predicts[i].Result1, predicts[i].Result2),
+				Points:   mdl.MatchScore(c, match, predicts[i]),
+			})
+		}
+		sort.Sort(UserPointsByPoints(detail.Points))
+	}
+
+	return templateshlp.RenderJSON(w, c, detail)
+}
+
+// UserPointsByPoints implements the sort.Interface for []UserPointsJSON based on the points field, highest first.
+type UserPointsByPoints []UserPointsJSON
+
+func (a UserPointsByPoints) Len() int           { return len(a) }
+func (a UserPointsByPoints) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a UserPointsByPoints) Less(i, j int) bool { return a[i].Points > a[j].Points }
+
+// teamMembersInTournament returns the members of the user's teams that joined the tournament.
+// The user is not part of the returned array.
+func teamMembersInTournament(c appengine.Context, t *mdl.Tournament, u *mdl.User) ([]*mdl.User, error) {
+	var teamIDs []int64
+	for _, id := range u.TeamIds {
+		if ok, _ := t.ContainsTeamID(id); ok {
+			teamIDs = append(teamIDs, id)
+		}
+	}
+
+	teams, err := mdl.Repos(c).Teams.ByIDs(teamIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[int64]bool{u.Id: true}
+	var userIDs []int64
+	for _, team := range teams {
+		for _, id := range team.UserIds {
+			if !seen[id] {
+				seen[id] = true
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+	return mdl.Repos(c).Users.ByIDs(userIDs)
+}
+
 // UpdateMatchResult is the handler allowing to update match of tournament with results information.
 // from parameter 'result' with format 'result1 result2' the match information is updated accordingly.
 //
@@ -147,6 +317,7 @@ func UpdateMatchResult(w http.ResponseWriter, r *http.Request, u *mdl.User) erro
 	var mjson MatchJSON
 	mjson.IdNumber = match.IdNumber
 	mjson.Date = match.Date
+	mjson.Kickoff = match.Kickoff
 	rule := strings.Split(match.Rule, " ")
 
 	var tb mdl.TournamentBuilder
@@ -179,7 +350,7 @@ func UpdateMatchResult(w http.ResponseWriter, r *http.Request, u *mdl.User) erro
 	} else {
 		verb = fmt.Sprintf("tied %d-%d against", match.Result1, match.Result2)
 	}
-	tournament.Publish(c, "match", verb, object, target)
+	mdl.Repos(c).PublishTournament(tournament, "match", verb, object, target)
 
 	return templateshlp.RenderJSON(w, c, mjson)
 }
@@ -215,6 +386,7 @@ func BlockMatchPrediction(w http.ResponseWriter, r *http.Request, u *mdl.User) e
 	var mjson MatchJSON
 	mjson.IdNumber = match.IdNumber
 	mjson.Date = match.Date
+	mjson.Kickoff = match.Kickoff
 	rule := strings.Split(match.Rule, " ")
 
 	var tb mdl.TournamentBuilder
@@ -255,10 +427,12 @@ func buildMatchesFromTournament(c appengine.Context, t *mdl.Tournament, u *mdl.U
 func buildFirstPhaseMatches(c appengine.Context, t *mdl.Tournament, u *mdl.User) []MatchJSON {
 	desc := "buildFirstPhaseMatches"
 
-	matches := mdl.Matches(c, t.Matches1stStage)
+	matches, err := mdl.Repos(c).Matches.ByIDs(t.Matches1stStage)
+	if err != nil {
+		log.Errorf(c, "%s matches not found, %v", desc, err)
+	}
 	var predicts mdl.Predicts
-	var err error
-	if predicts, err = mdl.PredictsByIds(c, u.PredictIds); err != nil {
+	if predicts, err = mdl.Repos(c).Predicts.ByIDs(u.PredictIds); err != nil {
 		log.Errorf(c, "%s predictions not found, %v", desc, err)
 		return []MatchJSON{}
 	}
@@ -277,6 +451,7 @@ func buildFirstPhaseMatches(c appengine.Context, t *mdl.Tournament, u *mdl.User)
 		matchesJSON[i].Id = m.Id
 		matchesJSON[i].IdNumber = m.IdNumber
 		matchesJSON[i].Date = m.Date
+		matchesJSON[i].Kickoff = m.Kickoff
 		matchesJSON[i].Team1 = mapIDTeams[m.TeamId1]
 		matchesJSON[i].Team2 = mapIDTeams[m.TeamId2]
 		matchesJSON[i].Iso1 = mapTeamCodes[matchesJSON[i].Team1]
@@ -303,11 +478,13 @@ func buildFirstPhaseMatches(c appengine.Context, t *mdl.Tournament, u *mdl.User)
 // second phase matches will have the specific rules in there team names
 func buildSecondPhaseMatches(c appengine.Context, t *mdl.Tournament, u *mdl.User) []MatchJSON {
 
-	matches2ndPhase := mdl.Matches(c, t.Matches2ndStage)
+	matches2ndPhase, err := mdl.Repos(c).Matches.ByIDs(t.Matches2ndStage)
+	if err != nil {
+		log.Errorf(c, "buildSecondPhaseMatches matches not found, %v", err)
+	}
 
 	var predicts mdl.Predicts
-	var err error
-	if predicts, err = mdl.PredictsByIds(c, u.PredictIds); err != nil {
+	if predicts, err = mdl.Repos(c).Predicts.ByIDs(u.PredictIds); err != nil {
 		return []MatchJSON{}
 	}
 
@@ -326,6 +503,7 @@ func buildSecondPhaseMatches(c appengine.Context, t *mdl.Tournament, u *mdl.User
 		matchesJSON[i].Id = m.Id
 		matchesJSON[i].IdNumber = m.IdNumber
 		matchesJSON[i].Date = m.Date
+		matchesJSON[i].Kickoff = m.Kickoff
 		rule := strings.Split(m.Rule, " ")
 		if len(rule) == 2 {
 			matchesJSON[i].Team1 = rule[0]
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package tournaments
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// ScheduleChangeJSON is a variable to hold the changes of the date and location of a match.
+//
+type ScheduleChangeJSON struct {
+	IdNumber    int64
+	Team1       string
+	Team2       string
+	OldDate     time.Time
+	NewDate     time.Time
+	OldLocation string
+	NewLocation string
+}
+
+// UpdateSchedule handler lets you update the dates and locations of the matches of a tournament.
+//
+// Use this handler to import a schedule in CSV format. The schedule is sent in the
+// 'schedule' file or form value and has the following fields (cf mdl.ReadSchedule):
+//	IdNumber,date,time,timezone,location,team1,team2
+//
+// The schedule is validated against the matches of the tournament. If the 'dryrun'
+// parameter is set to true the changes are only returned, otherwise they are applied.
+//	POST	/j/tournaments/[0-9]+/admin/schedule
+//
+func UpdateSchedule(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Tournament update schedule handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var err error
+	var tournament *mdl.Tournament
+	if tournament, err = extract.Tournament(); err != nil {
+		return err
+	}
+
+	var schedule io.Reader
+	if f, _, err := r.FormFile("schedule"); err == nil {
+		defer f.Close()
+		schedule = f
+	} else if s := r.FormValue("schedule"); len(s) > 0 {
+		schedule = strings.NewReader(s)
+	} else {
+		log.Errorf(c, "%s no schedule found in request", desc)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeScheduleNotFound)}
+	}
+
+	var tb mdl.TournamentBuilder
+	if tb = mdl.GetTournamentBuilder(tournament); tb == nil {
+		log.Errorf(c, "%s TournamentBuilder not found", desc)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+	mapIDTeams := tb.MapOfIDTeams(c, tournament)
+
+	entries, errs := mdl.ReadSchedule(schedule)
+	var changes []mdl.ScheduleChange
+	if len(errs) == 0 {
+		matches := mdl.GetAllMatchesFromTournament(c, tournament)
+		changes, errs = mdl.ScheduleChanges(entries, matches, mapIDTeams)
+	}
+
+	errorsJSON := make([]string, len(errs))
+	for i, e := range errs {
+		errorsJSON[i] = e.Error()
+	}
+
+	changesJSON := make([]ScheduleChangeJSON, len(changes))
+	for i, change := range changes {
+		changesJSON[i].IdNumber = change.Match.IdNumber
+		changesJSON[i].Team1, changesJSON[i].Team2 = change.Match.TeamNames(mapIDTeams)
+		changesJSON[i].OldDate = change.OldDate
+		changesJSON[i].NewDate = change.NewDate
+		changesJSON[i].OldLocation = change.OldLocation
+		changesJSON[i].NewLocation = change.NewLocation
+	}
+
+	dryrun := r.FormValue("dryrun") == "true"
+
+	var msg string
+	if len(errs) > 0 {
+		msg = fmt.Sprintf("The schedule is not valid, %d errors found. No match was updated.", len(errs))
+	} else if dryrun {
+		msg = fmt.Sprintf("Dry run: %d matches would be updated.", len(changes))
+	} else {
+		if err = mdl.UpdateMatches(c, mdl.ApplyScheduleChanges(changes)); err != nil {
+			log.Errorf(c, "%s unable to update matches: %v", desc, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeMatchesCannotUpdate)}
+		}
+		msg = fmt.Sprintf("%d matches were updated.", len(changes))
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		DryRun      bool
+		Changes     []ScheduleChangeJSON
+		Errors      []string `json:",omitempty"`
+	}{
+		msg,
+		dryrun,
+		changesJSON,
+		errorsJSON,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
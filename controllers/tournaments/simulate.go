@@ -94,13 +94,13 @@ func SimulateMatches(w http.ResponseWriter, r *http.Request, u *mdl.User) error
 		} else {
 			verb = fmt.Sprintf("tied %d-%d against", results1[i], results2[i])
 		}
-		t.Publish(c, "match", verb, object, target)
+		mdl.Repos(c).PublishTournament(t, "match", verb, object, target)
 	}
 
 	if phaseID >= 0 {
 		// only return update phase
 		matchesJSON := buildMatchesFromTournament(c, t, u)
-		phasesJSON := matchesGroupByPhase(t, matchesJSON)
+		phasesJSON := matchesGroupByPhase(t, matchesJSON, userLocation(r, u))
 
 		data := struct {
 			Phase PhaseJSON
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"appengine"
@@ -57,7 +58,7 @@ func Join(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.Forbidden{Err: errors.New("Tournament has ended, you cannot join an old tournament")}
 	}
 
-	if err = tournament.Join(c, u); err != nil {
+	if err = mdl.Repos(c).JoinTournament(tournament, u); err != nil {
 		log.Errorf(c, "%s error on Join tournament: %v", desc, err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
@@ -67,10 +68,10 @@ func Join(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	helpers.InitPointerStructure(tournament, &tJSON, fieldsToKeep)
 
 	var updatedUser *mdl.User
-	if updatedUser, err = mdl.UserByID(c, u.Id); err != nil {
+	if updatedUser, err = mdl.Repos(c).Users.ByID(u.Id); err != nil {
 		log.Errorf(c, "User not found %v", u.Id)
 	} else {
-		updatedUser.Publish(c, "tournament", "joined tournament", tournament.Entity(), mdl.ActivityEntity{})
+		mdl.Repos(c).Publish(updatedUser, "tournament", "joined tournament", tournament.Entity(), mdl.ActivityEntity{})
 	}
 
 	data := struct {
@@ -114,7 +115,7 @@ func JoinAsTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	var team *mdl.Team
-	if team, err = mdl.TeamByID(c, teamID); err != nil {
+	if team, err = mdl.Repos(c).Teams.ByID(teamID); err != nil {
 		log.Errorf(c, "%s team not found: %v", desc, err)
 		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
 	}
@@ -130,11 +131,11 @@ func JoinAsTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	// publish new activity
 	var updatedTeam *mdl.Team
-	if updatedTeam, err = mdl.TeamByID(c, teamID); err != nil {
+	if updatedTeam, err = mdl.Repos(c).Teams.ByID(teamID); err != nil {
 		log.Errorf(c, "%s team not found: %v", desc, err)
 		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
 	}
-	updatedTeam.Publish(c, "tournament", "joined tournament", tournament.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).PublishTeam(updatedTeam, "tournament", "joined tournament", tournament.Entity(), mdl.ActivityEntity{})
 
 	msg := fmt.Sprintf("Team %s joined tournament %s.", team.Name, tournament.Name)
 	data := struct {
@@ -172,7 +173,7 @@ func LeaveAsTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	var team *mdl.Team
-	if team, err = mdl.TeamByID(c, teamID); err != nil {
+	if team, err = mdl.Repos(c).Teams.ByID(teamID); err != nil {
 		log.Errorf(c, "team not found: %v", desc, err)
 		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
 	}
@@ -189,12 +190,12 @@ func LeaveAsTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	// publish new activity
 	var updatedTeam *mdl.Team
-	if updatedTeam, err = mdl.TeamByID(c, teamID); err != nil {
+	if updatedTeam, err = mdl.Repos(c).Teams.ByID(teamID); err != nil {
 		log.Errorf(c, "%s team not found: %v", desc, err)
 		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
 	}
 
-	updatedTeam.Publish(c, "tournament", "left tournament", tournament.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).PublishTeam(updatedTeam, "tournament", "left tournament", tournament.Entity(), mdl.ActivityEntity{})
 
 	msg := fmt.Sprintf("Team %s left tournament %s.", team.Name, tournament.Name)
 	data := struct {
@@ -207,3 +208,52 @@ func LeaveAsTeam(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	return templateshlp.RenderJSON(w, c, data)
 }
+
+// Reminders handler lets the user turn on or off the prediction reminders of a tournament.
+// Use the 'enabled' parameter with 'true' or 'false'.
+//
+//	POST	/j/tournaments/:tournamentId/reminders?enabled=:enabled
+//
+func Reminders(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "Tournament Reminders Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	var err error
+	var tournament *mdl.Tournament
+
+	if tournament, err = extract.Tournament(); err != nil {
+		return err
+	}
+
+	var enabled bool
+	if enabled, err = strconv.ParseBool(r.FormValue("enabled")); err != nil {
+		log.Errorf(c, "%s wrong enabled parameter: %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	if err = u.SetReminders(c, tournament.Id, enabled); err != nil {
+		log.Errorf(c, "%s error on SetReminders: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
+
+	msg := fmt.Sprintf("You turned off the prediction reminders of tournament %s.", tournament.Name)
+	if enabled {
+		msg = fmt.Sprintf("You turned on the prediction reminders of tournament %s.", tournament.Name)
+	}
+
+	data := struct {
+		MessageInfo      string `json:",omitempty"`
+		RemindersEnabled bool
+	}{
+		msg,
+		enabled,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
@@ -46,6 +46,9 @@ type TournamentData struct {
 }
 
 // Index handler, use it to get the data of current tournaments.
+// Pass 'page' and 'count' to page them, the response is an array of tournaments.
+// Pass a 'cursor', returned as 'nextCursor' with the previous page, to page them with a cursor,
+// an empty cursor gets the first page.
 func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	if r.Method != "GET" {
 		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
@@ -53,32 +56,26 @@ func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	c := appengine.NewContext(r)
 	desc := "tournament index handler:"
+	extract := extract.NewContext(c, desc, r)
 
-	// get count parameter, if not present count is set to 25
-	strcount := r.FormValue("count")
-	count := int64(25)
-	if len(strcount) > 0 {
-		if n, err := strconv.ParseInt(strcount, 0, 64); err != nil {
-			log.Errorf(c, "%s: error during conversion of count parameter: %v", desc, err)
-		} else {
-			count = n
-		}
-	}
+	count := extract.CountOrDefault(25)
+	legacy := extract.LegacyPage()
 
-	// get page parameter, if not present set page to the first one.
-	strpage := r.FormValue("page")
-	page := int64(1)
-	if len(strpage) > 0 {
-		if p, err := strconv.ParseInt(strpage, 0, 64); err != nil {
-			log.Errorf(c, "%s error during conversion of page parameter: %v", desc, err)
-			page = 1
-		} else {
-			page = p
+	var tournaments []*mdl.Tournament
+	var next string
+	if legacy {
+		tournaments = mdl.FindAllTournaments(c, count, extract.Page())
+		if len(tournaments) == 0 {
+			return templateshlp.RenderEmptyJSONArray(w, c)
+		}
+	} else {
+		var err error
+		if tournaments, next, err = mdl.FindAllTournamentsByCursor(c, count, extract.Cursor()); err == mdl.ErrInvalidCursor {
+			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeInvalidCursor)}
+		} else if err != nil {
+			log.Errorf(c, "%s unable to get tournaments: %v", desc, err)
+			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 		}
-	}
-	tournaments := mdl.FindAllTournaments(c, count, page)
-	if len(tournaments) == 0 {
-		return templateshlp.RenderEmptyJSONArray(w, c)
 	}
 
 	type tournament struct {
@@ -99,7 +96,17 @@ func Index(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		ts[i].ImageURL = helpers.TournamentImageURL(t.Name, t.Id)
 	}
 
-	return templateshlp.RenderJSON(w, c, ts)
+	if legacy {
+		return templateshlp.RenderJSON(w, c, ts)
+	}
+	data := struct {
+		Tournaments []tournament
+		NextCursor  string `json:"nextCursor,omitempty"`
+	}{
+		ts,
+		next,
+	}
+	return templateshlp.RenderJSON(w, c, data)
 }
 
 // New handler, use it to create a new tournament.
@@ -129,7 +136,7 @@ func New(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeNameCannotBeEmpty)}
 	}
 
-	if t := mdl.FindTournaments(c, "KeyName", helpers.TrimLower(tData.Name)); t != nil {
+	if t, _ := mdl.Repos(c).Tournaments.Find("KeyName", helpers.TrimLower(tData.Name)); len(t) > 0 {
 		log.Errorf(c, "%s That tournament name already exists.", desc)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentAlreadyExists)}
 	}
@@ -144,7 +151,7 @@ func New(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	var tJSON mdl.TournamentJSON
 	helpers.InitPointerStructure(tournament, &tJSON, fieldsToKeep)
 
-	u.Publish(c, "tournament", "created a tournament", tournament.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).Publish(u, "tournament", "created a tournament", tournament.Entity(), mdl.ActivityEntity{})
 
 	msg := fmt.Sprintf("The tournament %s was correctly created!", tournament.Name)
 	data := struct {
@@ -179,6 +186,29 @@ func Show(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	participants := tournament.Participants(c)
 	teams := tournament.Teams(c)
 
+	data := BuildShowViewModel(tournament, u, participants, teams)
+
+	return templateshlp.RenderJSON(w, c, data)
+
+}
+
+// ShowViewModel is the view model of the Show handler.
+//
+type ShowViewModel struct {
+	Tournament    mdl.TournamentJSON
+	Joined        bool
+	Participants  []mdl.UserJSON
+	Teams         []mdl.TeamJSON
+	Progress      float64
+	Start         string
+	End           string
+	RemainingDays int64
+	ImageURL      string
+}
+
+// BuildShowViewModel returns the view model of a tournament shown to a user, with its participants and teams.
+//
+func BuildShowViewModel(tournament *mdl.Tournament, u *mdl.User, participants []*mdl.User, teams []*mdl.Team) ShowViewModel {
 	fieldsToKeep := []string{"Id", "Name", "Description", "AdminIds", "IsFirstStageComplete"}
 	var TournamentJSON mdl.TournamentJSON
 	helpers.InitPointerStructure(tournament, &TournamentJSON, fieldsToKeep)
@@ -190,41 +220,29 @@ func Show(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	teamsJSON := make([]mdl.TeamJSON, len(teams))
 	helpers.TransformFromArrayOfPointers(&teams, &teamsJSON, fieldsToKeep)
 
-	progress := tournament.Progress(c)
+	now := time.Now()
 
 	// formatted start and end
 	const layout = "2 January 2006"
 	start := tournament.Start.Format(layout)
 	end := tournament.End.Format(layout)
 
-	remainingDays := int64(tournament.Start.Sub(time.Now()).Hours() / 24)
+	remainingDays := int64(tournament.Start.Sub(now).Hours() / 24)
 
 	imageURL := helpers.TournamentImageURL(tournament.Name, tournament.Id)
 
-	data := struct {
-		Tournament    mdl.TournamentJSON
-		Joined        bool
-		Participants  []mdl.UserJSON
-		Teams         []mdl.TeamJSON
-		Progress      float64
-		Start         string
-		End           string
-		RemainingDays int64
-		ImageURL      string
-	}{
+	joined, _ := u.ContainsTournamentID(tournament.Id)
+	return ShowViewModel{
 		TournamentJSON,
-		tournament.Joined(c, u),
+		joined,
 		participantsJSON,
 		teamsJSON,
-		progress,
+		tournament.ProgressAt(now),
 		start,
 		end,
 		remainingDays,
 		imageURL,
 	}
-
-	return templateshlp.RenderJSON(w, c, data)
-
 }
 
 // Destroy is the handler allowing to detroy a tournament.
@@ -246,11 +264,6 @@ func Destroy(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return err
 	}
 
-	if !mdl.IsTournamentAdmin(c, tournament.Id, u.Id) {
-		log.Errorf(c, "%s user is not admin", desc)
-		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTournamentDeleteForbiden)}
-	}
-
 	// delete all tournament-user relationships
 	for _, participant := range tournament.Participants(c) {
 		if err := participant.RemoveTournamentID(c, tournament.Id); err != nil {
@@ -289,7 +302,7 @@ func Destroy(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	tournament.Destroy(c)
 
 	// publish new activity
-	u.Publish(c, "tournament", "deleted tournament", tournament.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).Publish(u, "tournament", "deleted tournament", tournament.Entity(), mdl.ActivityEntity{})
 
 	msg := fmt.Sprintf("The tournament %s has been destroyed!", tournament.Name)
 	data := struct {
@@ -321,11 +334,6 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		return err
 	}
 
-	if !mdl.IsTournamentAdmin(c, tournament.Id, u.Id) {
-		log.Errorf(c, "%s user is not admin", desc)
-		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTournamentUpdateForbiden)}
-	}
-
 	// only work on name other values should not be editable
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
@@ -345,7 +353,7 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		(updatedData.Name != tournament.Name || updatedData.Description != tournament.Description) {
 		if updatedData.Name != tournament.Name {
 			// be sure that team with that name does not exist in datastore
-			if t := mdl.FindTournaments(c, "KeyName", helpers.TrimLower(updatedData.Name)); t != nil {
+			if t, _ := mdl.Repos(c).Tournaments.Find("KeyName", helpers.TrimLower(updatedData.Name)); len(t) > 0 {
 				log.Errorf(c, "%s that tournament name already exists.", desc)
 				return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentAlreadyExists)}
 			}
@@ -361,7 +369,7 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	// publish new activity
-	u.Publish(c, "tournament", "updated tournament", tournament.Entity(), mdl.ActivityEntity{})
+	mdl.Repos(c).Publish(u, "tournament", "updated tournament", tournament.Entity(), mdl.ActivityEntity{})
 
 	// return the updated tournament
 	fieldsToKeep := []string{"Id", "Name"}
@@ -380,7 +388,8 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	return templateshlp.RenderJSON(w, c, data)
 }
 
-// Search is the handler allowing to get all the tournaments that match the query.
+// Search is the handler allowing to get all the tournaments that match the query 'q'.
+// Use the 'count' and 'page' params to get a page of the result, default values are 20 and 1 respectively.
 //
 func Search(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
@@ -392,18 +401,16 @@ func Search(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	c := appengine.NewContext(r)
 	desc := "Tournament Search handler:"
+	extract := extract.NewContext(c, desc, r)
 
-	words := helpers.SetOfStrings(keywords)
-	ids, err := mdl.GetTournamentInvertedIndexes(c, words)
+	results, _, err := mdl.Search(c, []string{"Tournament"}, keywords, extract.Count(), extract.Page())
 	if err != nil {
-		log.Errorf(c, "%s tournaments.Index, error occurred when getting indexes of words: %v", desc, err)
+		log.Errorf(c, "%s tournaments.Index, error occurred during search: %v", desc, err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentCannotSearch)}
 	}
 
-	result := mdl.TournamentScore(c, keywords, ids)
-
 	var tournaments []*mdl.Tournament
-	if tournaments, err = mdl.TournamentsByIds(c, result); err != nil {
+	if tournaments, err = mdl.Repos(c).Tournaments.ByIDs(mdl.SearchResultIds(results, "Tournament")); err != nil {
 		log.Errorf(c, "%v something failed when calling TournamentsByIds: %v", desc, err)
 	}
 
@@ -464,7 +471,7 @@ func CandidateTeams(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	// query teams
 	var teams []*mdl.Team
 	for _, teamID := range u.TeamIds {
-		if team, err1 := mdl.TeamByID(c, teamID); err1 == nil {
+		if team, err1 := mdl.Repos(c).Teams.ByID(teamID); err1 == nil {
 			for _, aID := range team.AdminIds {
 				if aID == u.Id {
 					teams = append(teams, team)
@@ -590,7 +597,7 @@ func Predict(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	// check if user joined the tournament
 	if !tournament.Joined(c, u) {
 		// add user as participant
-		if err = tournament.Join(c, u); err != nil {
+		if err = mdl.Repos(c).JoinTournament(tournament, u); err != nil {
 			log.Errorf(c, "%s error on Join tournament: %v", desc, err)
 			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 		}
@@ -634,33 +641,15 @@ func Predict(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 	mapIDTeams := tb.MapOfIDTeams(c, tournament)
 	var p *mdl.Predict
-	if p = mdl.FindPredictByUserMatch(c, u.Id, match.Id); p == nil {
-
-		var predict *mdl.Predict
-		var err1 error
-
-		if predict, err1 = mdl.CreatePredict(c, u.Id, int64(r1), int64(r2), match.Id); err1 != nil {
-			log.Errorf(c, "%s unable to create Predict for match with id:%v error: %v", desc, match.Id, err1)
-			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeCannotSetPrediction)}
-		}
-
-		// add p.Id to User predict table.
-		if err = u.AddPredictID(c, predict.Id); err != nil {
-			log.Errorf(c, "%s unable to add predict id in user entity: error: %v", desc, err)
-			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeCannotSetPrediction)}
-		}
-		p = predict
+	var created bool
+	if p, created, err = mdl.Repos(c).Predict(u, match, int64(r1), int64(r2)); err != nil {
+		log.Errorf(c, "%s %v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeCannotSetPrediction)}
+	}
 
+	if created {
 		msg = fmt.Sprintf("You set a prediction: %s %d:%d %s.", mapIDTeams[match.TeamId1], p.Result1, p.Result2, mapIDTeams[match.TeamId2])
-
 	} else {
-		// predict already exist so just update resulst.
-		p.Result1 = int64(r1)
-		p.Result2 = int64(r2)
-		if err := p.Update(c); err != nil {
-			log.Errorf(c, "%s unable to edit predict entity. %v", desc, err)
-			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeCannotSetPrediction)}
-		}
 		msg = fmt.Sprintf("Your prediction is now updated: %s %d:%d %s.", mapIDTeams[match.TeamId1], p.Result1, p.Result2, mapIDTeams[match.TeamId2])
 	}
 
@@ -675,7 +664,7 @@ func Predict(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	// publish activity
 	verb := fmt.Sprintf("predicted %d-%d for", p.Result1, p.Result2)
 	object := mdl.ActivityEntity{Id: match.Id, Type: "match", DisplayName: mapIDTeams[match.TeamId1] + "-" + mapIDTeams[match.TeamId2]}
-	u.Publish(c, "predict", verb, object, tournament.Entity())
+	mdl.Repos(c).Publish(u, "predict", verb, object, tournament.Entity())
 
 	return templateshlp.RenderJSON(w, c, data)
 }
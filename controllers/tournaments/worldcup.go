@@ -55,8 +55,8 @@ func GetWorldCup(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	desc := "Get World Cup Handler:"
 
 	if r.Method == "GET" {
-		tournaments := mdl.FindTournaments(c, "Name", "2018 FIFA World Cup")
-		if tournaments == nil {
+		tournaments, err := mdl.Repos(c).Tournaments.Find("Name", "2018 FIFA World Cup")
+		if err != nil || len(tournaments) == 0 {
 			log.Errorf(c, "%s World Cup tournament was not found.", desc)
 			return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
 		}
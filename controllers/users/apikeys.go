@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/auth"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// maxAPIKeyNameLength is the maximum length of the name of an API key.
+const maxAPIKeyNameLength = 100
+
+// checkAPIKeysOwner checks that the user of the request url is the current user.
+// A user can only manage their own API keys.
+//
+func checkAPIKeysOwner(extract extract.Context, u *mdl.User) error {
+	userID, err := extract.UserId()
+	if err != nil {
+		return err
+	}
+	if userID != u.Id {
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeAPIKeyForbiden)}
+	}
+	return nil
+}
+
+// APIKeys handler, use it to get the API keys of a user.
+//
+//	GET	/j/users/:userId/apikeys
+//
+func APIKeys(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "User API Keys Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	if err := checkAPIKeysOwner(extract, u); err != nil {
+		return err
+	}
+
+	keys := mdl.FindAPIKeys(c, u.Id)
+
+	fieldsToKeep := []string{"Id", "Name", "Scopes", "Created", "LastUsed"}
+	keysJSON := make([]mdl.APIKeyJSON, len(keys))
+	helpers.TransformFromArrayOfPointers(&keys, &keysJSON, fieldsToKeep)
+
+	data := struct {
+		APIKeys []mdl.APIKeyJSON
+		Scopes  []string
+	}{
+		keysJSON,
+		mdl.APIKeyScopes,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// canAdminTournaments indicates if a user is an admin of at least one tournament or a gonawin admin.
+// Only those users can create an API key with the admin:tournament scope.
+//
+func canAdminTournaments(c appengine.Context, u *mdl.User) bool {
+	return auth.IsGonawinAdmin(c) || len(mdl.FindTournaments(c, "AdminIds", u.Id)) > 0
+}
+
+// NewAPIKey handler, use it to create an API key.
+// It expects the 'name' and 'scopes' params, the scopes separated by commas.
+// The response holds the key, it is not possible to get it afterwards.
+//
+//	POST	/j/users/:userId/apikeys/new?name=:name&scopes=:scopes
+//
+func NewAPIKey(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "User New API Key Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	if err := checkAPIKeysOwner(extract, u); err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) == 0 || len(name) > maxAPIKeyNameLength {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeAPIKeyInvalid)}
+	}
+
+	var scopes []string
+	for _, scope := range strings.Split(r.FormValue("scopes"), ",") {
+		scope = strings.TrimSpace(scope)
+		if !mdl.IsAPIKeyScopeValid(scope) {
+			return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeAPIKeyInvalid)}
+		}
+		if scope == mdl.ScopeAdminTournament && !canAdminTournaments(c, u) {
+			return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeAPIKeyScopeAdmin)}
+		}
+		scopes = append(scopes, scope)
+	}
+
+	k, key, err := mdl.CreateAPIKey(c, u.Id, name, scopes, mdl.TwoFactorEnabled(c, u.Id))
+	if err != nil {
+		log.Errorf(c, "%s unable to create api key: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeAPIKeyCannotCreate)}
+	}
+
+	var kJSON mdl.APIKeyJSON
+	fieldsToKeep := []string{"Id", "Name", "Scopes", "Created"}
+	helpers.InitPointerStructure(k, &kJSON, fieldsToKeep)
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		APIKey      mdl.APIKeyJSON
+		Key         string
+	}{
+		fmt.Sprintf("You created the API key %s, copy it now as you will not be able to see it again.", name),
+		kJSON,
+		key,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// DestroyAPIKey handler, use it to revoke an API key.
+//
+//	POST	/j/users/:userId/apikeys/destroy/:apikeyId
+//
+func DestroyAPIKey(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "User Destroy API Key Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	if err := checkAPIKeysOwner(extract, u); err != nil {
+		return err
+	}
+
+	k, err := extract.APIKey(u)
+	if err != nil {
+		return err
+	}
+
+	if err = k.Destroy(c); err != nil {
+		log.Errorf(c, "%s unable to destroy api key: %v", desc, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeAPIKeyCannotDelete)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		fmt.Sprintf("You revoked the API key %s.", k.Name),
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
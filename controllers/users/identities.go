@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/route"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+	templateshlp "github.com/taironas/gonawin/helpers/templates"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// checkIdentitiesOwner checks that the user of the request url is the current user.
+// A user can only manage their own identities.
+//
+func checkIdentitiesOwner(extract extract.Context, u *mdl.User) error {
+	userID, err := extract.UserId()
+	if err != nil {
+		return err
+	}
+	if userID != u.Id {
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeIdentityForbiden)}
+	}
+	return nil
+}
+
+// Identities handler, use it to get the sign in providers linked to a user.
+//
+//	GET	/j/users/:userId/identities
+//
+func Identities(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "GET" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "User Identities Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	if err := checkIdentitiesOwner(extract, u); err != nil {
+		return err
+	}
+
+	identities := mdl.FindIdentities(c, u.Id)
+
+	fieldsToKeep := []string{"Provider", "Email", "Created"}
+	identitiesJSON := make([]mdl.IdentityJSON, len(identities))
+	helpers.TransformFromArrayOfPointers(&identities, &identitiesJSON, fieldsToKeep)
+
+	data := struct {
+		Identities []mdl.IdentityJSON
+	}{
+		identitiesJSON,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// UnlinkIdentity handler, use it to unlink a sign in provider from a user.
+// The last way to sign in of a user without a local account cannot be unlinked.
+//
+//	POST	/j/users/:userId/identities/unlink/:provider
+//
+func UnlinkIdentity(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "User Unlink Identity Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	if err := checkIdentitiesOwner(extract, u); err != nil {
+		return err
+	}
+
+	name, err := route.Context.Get(r, "provider")
+	if err != nil {
+		log.Errorf(c, "%s error getting provider, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeIdentityNotFound)}
+	}
+
+	if err = mdl.UnlinkIdentity(c, u.Id, name); err == datastore.ErrNoSuchEntity {
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeIdentityNotFound)}
+	} else if err == mdl.ErrIdentityLast {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeIdentityLast)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to unlink %v from user %v: %v", desc, name, u.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeIdentityCannotUnlink)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+	}{
+		fmt.Sprintf("You unlinked %s from your account.", name),
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
+
+// Merge handler, use it to merge a user into another one.
+// The predictions, scores, teams, tournaments, activities and sign in providers of the user
+// are moved to the target user and the user is destroyed. Only an admin can merge users.
+//
+//	POST	/j/users/:userId/merge/:targetId
+//
+func Merge(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	if r.Method != "POST" {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
+	}
+
+	c := appengine.NewContext(r)
+	desc := "User Merge Handler:"
+	extract := extract.NewContext(c, desc, r)
+
+	source, err := extract.User()
+	if err != nil {
+		return err
+	}
+
+	var strTargetID string
+	if strTargetID, err = route.Context.Get(r, "targetId"); err != nil {
+		log.Errorf(c, "%s error getting target id, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	var targetID int64
+	if targetID, err = strconv.ParseInt(strTargetID, 0, 64); err != nil {
+		log.Errorf(c, "%s error converting target id from string to int64, err:%v", desc, err)
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	var target *mdl.User
+	if target, err = mdl.Repos(c).Users.ByID(targetID); err != nil {
+		log.Errorf(c, "%s target user not found", desc)
+		return &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
+	}
+
+	var report *mdl.MergeReport
+	if report, err = mdl.MergeUsers(c, source, target); err == mdl.ErrMergeSameUser {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeUserCannotMerge)}
+	} else if err != nil {
+		log.Errorf(c, "%s unable to merge user %v into %v: %v", desc, source.Id, target.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeUserCannotMerge)}
+	}
+
+	data := struct {
+		MessageInfo string `json:",omitempty"`
+		Report      *mdl.MergeReport
+	}{
+		fmt.Sprintf("You merged %s into %s.", source.Username, target.Username),
+		report,
+	}
+
+	return templateshlp.RenderJSON(w, c, data)
+}
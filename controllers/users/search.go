@@ -23,6 +23,7 @@ import (
 
 	"appengine"
 
+	"github.com/taironas/gonawin/extract"
 	"github.com/taironas/gonawin/helpers"
 	"github.com/taironas/gonawin/helpers/log"
 	templateshlp "github.com/taironas/gonawin/helpers/templates"
@@ -38,7 +39,8 @@ type searchUserViewModel struct {
 }
 
 // Search handler returns the result of a user search in a JSON format.
-// It uses parameter 'q' to make the query.
+// It uses parameter 'q' to make the query, and the 'count' and 'page' params
+// to get a page of the result, default values are 20 and 1 respectively.
 //
 //	GET	/j/user/search/			Search for all users respecting the query "q"
 //
@@ -51,19 +53,15 @@ func Search(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 
 	c := appengine.NewContext(r)
 	desc := "User Search Handler:"
+	extract := extract.NewContext(c, desc, r)
 
-	words := helpers.SetOfStrings(keywords)
-
-	var ids []int64
-	var err error
-	if ids, err = mdl.GetUserInvertedIndexes(c, words); err != nil {
+	results, _, err := mdl.Search(c, []string{"User"}, keywords, extract.Count(), extract.Page())
+	if err != nil {
 		return unableToPerformSearch(c, w, desc, err)
 	}
 
-	result := mdl.UserScore(c, keywords, ids)
-
 	var users []*mdl.User
-	if users, err = mdl.UsersByIds(c, result); len(users) == 0 || err != nil {
+	if users, err = mdl.Repos(c).Users.ByIDs(mdl.SearchResultIds(results, "User")); len(users) == 0 || err != nil {
 		return notFound(c, w, keywords)
 	}
 
@@ -100,7 +98,7 @@ func notFound(c appengine.Context, w http.ResponseWriter, keywords string) error
 }
 
 func unableToPerformSearch(c appengine.Context, w http.ResponseWriter, desc string, err error) error {
-	log.Errorf(c, "%s users.Index, error occurred when getting indexes of words: %v", desc, err)
+	log.Errorf(c, "%s users.Index, error occurred during search: %v", desc, err)
 	data := struct {
 		MessageDanger string `json:",omitempty"`
 	}{
@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	"appengine"
 	"appengine/taskqueue"
@@ -89,12 +90,14 @@ func Show(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	tournaments := extractTournaments(c, user, params)
 	invitations := extractInvitations(c, user, params)
 
-	shvm := buildShowViewModel(c, user, teams, tournaments, teamRequests, invitations)
+	shvm := BuildShowViewModel(user, teams, tournaments, teamRequests, invitations)
 
 	return templateshlp.RenderJSON(w, c, shvm)
 }
 
-type showViewModel struct {
+// ShowViewModel is the view model of the Show handler.
+//
+type ShowViewModel struct {
 	User            mdl.UserJSON                   `json:",omitempty"`
 	Teams           []showTeamViewModel            `json:",omitempty"`
 	TeamRequests    []mdl.TeamRequestJSON          `json:",omitempty"`
@@ -104,11 +107,14 @@ type showViewModel struct {
 	ImageURL        string                         `json:",omitempty"`
 }
 
-func buildShowViewModel(c appengine.Context, u *mdl.User, teams []*mdl.Team, tournaments []*mdl.Tournament, trs []*mdl.TeamRequest, invs []*mdl.Team) showViewModel {
+// BuildShowViewModel returns the view model of a user with the included teams, tournaments,
+// team requests and invitations.
+//
+func BuildShowViewModel(u *mdl.User, teams []*mdl.Team, tournaments []*mdl.Tournament, trs []*mdl.TeamRequest, invs []*mdl.Team) ShowViewModel {
 
 	uvm := buildShowUserViewModel(u)
 	tvm := buildShowTeamViewModel(teams)
-	tsvm := buildShowTournamentStatsViewModel(c, tournaments)
+	tsvm := buildShowTournamentStatsViewModel(tournaments)
 	tourvm := buildShowTournamentViewModel(tournaments)
 	trsvm := buildShowTeamRequestsViewModel(trs)
 	ivm := buildShowInvitationsViewModel(invs)
@@ -116,7 +122,7 @@ func buildShowViewModel(c appengine.Context, u *mdl.User, teams []*mdl.Team, tou
 	// imageURL
 	imageURL := helpers.UserImageURL(u.Username, u.Id)
 
-	return showViewModel{
+	return ShowViewModel{
 		uvm,
 		tvm,
 		trsvm,
@@ -128,7 +134,7 @@ func buildShowViewModel(c appengine.Context, u *mdl.User, teams []*mdl.Team, tou
 }
 
 func buildShowUserViewModel(user *mdl.User) (u mdl.UserJSON) {
-	fieldsToKeep := []string{"Id", "Username", "Name", "Alias", "Email", "Created", "IsAdmin", "Auth", "TeamIds", "TournamentIds", "Score"}
+	fieldsToKeep := []string{"Id", "Username", "Name", "Alias", "Email", "Created", "IsAdmin", "TeamIds", "TournamentIds", "Score", "Timezone", "Language", "ReminderOptOutIds", "DigestFrequency"}
 
 	helpers.InitPointerStructure(user, &u, fieldsToKeep)
 	return
@@ -214,7 +220,7 @@ type showTournamentStatsViewModel struct {
 	ImageURL          string
 }
 
-func buildShowTournamentStatsViewModel(c appengine.Context, tournaments []*mdl.Tournament) []showTournamentStatsViewModel {
+func buildShowTournamentStatsViewModel(tournaments []*mdl.Tournament) []showTournamentStatsViewModel {
 
 	stats := make([]showTournamentStatsViewModel, len(tournaments))
 	for i, t := range tournaments {
@@ -222,7 +228,7 @@ func buildShowTournamentStatsViewModel(c appengine.Context, tournaments []*mdl.T
 		stats[i].Name = t.Name
 		stats[i].ParticipantsCount = len(t.UserIds)
 		stats[i].TeamsCount = len(t.TeamIds)
-		stats[i].Progress = t.Progress(c)
+		stats[i].Progress = t.ProgressAt(time.Now())
 		stats[i].ImageURL = helpers.TournamentImageURL(t.Name, t.Id)
 	}
 	return stats
@@ -271,6 +277,9 @@ type userData struct {
 		Name     string
 		Alias    string
 		Email    string
+		Timezone string
+		Language string
+		Digest   string
 	}
 }
 
@@ -311,6 +320,21 @@ func Update(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 		update = true
 	}
 
+	if shouldUpdateUserTimezone(updatedData.User.Timezone, u.Timezone) {
+		u.Timezone = updatedData.User.Timezone
+		update = true
+	}
+
+	if shouldUpdateUserLanguage(updatedData.User.Language, u.Language) {
+		u.Language = updatedData.User.Language
+		update = true
+	}
+
+	if shouldUpdateUserDigest(updatedData.User.Digest, u.DigestFrequency) {
+		u.DigestFrequency = updatedData.User.Digest
+		update = true
+	}
+
 	if !update {
 		return nothingToUpdate(c, w)
 	}
@@ -331,7 +355,7 @@ type updateViewModel struct {
 
 func buildUpdateViewModel(u *mdl.User) updateViewModel {
 
-	fieldsToKeep := []string{"Id", "Username", "Name", "Alias", "Email"}
+	fieldsToKeep := []string{"Id", "Username", "Name", "Alias", "Email", "Timezone", "Language", "DigestFrequency"}
 	var uJSON mdl.UserJSON
 	helpers.InitPointerStructure(u, &uJSON, fieldsToKeep)
 
@@ -349,6 +373,21 @@ func shouldUpdateUserAlias(new, old string) bool {
 	return helpers.IsStringValid(new) && new != old
 }
 
+func shouldUpdateUserTimezone(new, old string) bool {
+	if _, err := helpers.LoadLocation(new); err != nil {
+		return false
+	}
+	return helpers.IsStringValid(new) && new != old
+}
+
+func shouldUpdateUserLanguage(new, old string) bool {
+	return mdl.IsLanguageSupported(new) && new != old
+}
+
+func shouldUpdateUserDigest(new, old string) bool {
+	return mdl.IsDigestFrequencyValid(new) && new != old
+}
+
 func userDataFromHTTPRequest(c appengine.Context, desc string, r *http.Request) (*userData, error) {
 
 	// only work on name other values should not be editable
@@ -427,7 +466,7 @@ func removeTeamUserRels(c appengine.Context, desc string, requestUser, currentUs
 	for _, teamID := range requestUser.TeamIds {
 		if mdl.IsTeamAdmin(c, teamID, currentUser.Id) {
 			var team *mdl.Team
-			if team, err = mdl.TeamByID(c, teamID); err != nil {
+			if team, err = mdl.Repos(c).Teams.ByID(teamID); err != nil {
 				log.Errorf(c, "%s team %d not found", desc, teamID)
 				return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
 			}
@@ -452,7 +491,7 @@ func removeTournameUserRels(c appengine.Context, desc string, requestUser, curre
 	for _, tournamentId := range requestUser.TournamentIds {
 		if mdl.IsTournamentAdmin(c, tournamentId, currentUser.Id) {
 			var tournament *mdl.Tournament
-			if tournament, err = mdl.TournamentByID(c, tournamentId); err != nil {
+			if tournament, err = mdl.Repos(c).Tournaments.ByID(tournamentId); err != nil {
 				log.Errorf(c, "%s tournament %d not found", desc, tournamentId)
 				return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
 			}
@@ -535,7 +574,8 @@ func buildTeamsUserViewModel(teams []*mdl.Team) teamsUserViewModel {
 
 // Tournaments user handler, use this to retrieve the JSON data of the tournaments of the user.
 // count parameter: default 25
-// page parameter: default 1
+// cursor parameter: the cursor of the page, returned as nextCursor with the previous page, empty for the first page.
+// page parameter: legacy paging used when no cursor is given, default 1
 func Tournaments(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	if r.Method != "GET" {
 		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeNotSupported)}
@@ -551,25 +591,34 @@ func Tournaments(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
 	}
 
 	count := extract.CountOrDefault(25)
-	page := extract.Page()
 
-	tournaments := user.TournamentsByPage(c, count, page)
+	var tournaments []*mdl.Tournament
+	var next string
+	if extract.LegacyPage() {
+		tournaments = user.TournamentsByPage(c, count, extract.Page())
+	} else if tournaments, next, err = user.TournamentsByCursor(c, count, extract.Cursor()); err == mdl.ErrInvalidCursor {
+		return &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeInvalidCursor)}
+	} else if err != nil {
+		log.Errorf(c, "User joined tournaments handler: unable to get tournaments of user %v: %v", user.Id, err)
+		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
+	}
 
-	tvm := buildTournamentsUserViewModel(tournaments)
+	tvm := buildTournamentsUserViewModel(tournaments, next)
 
 	return templateshlp.RenderJSON(w, c, tvm)
 }
 
 type tournamentsUserViewModel struct {
 	Tournaments []mdl.TournamentJSON `json:",omitempty"`
+	NextCursor  string               `json:"nextCursor,omitempty"`
 }
 
-func buildTournamentsUserViewModel(tournaments []*mdl.Tournament) tournamentsUserViewModel {
+func buildTournamentsUserViewModel(tournaments []*mdl.Tournament, next string) tournamentsUserViewModel {
 	fieldsToKeep := []string{"Id", "Name"}
 	json := make([]mdl.TournamentJSON, len(tournaments))
 	helpers.TransformFromArrayOfPointers(&tournaments, &json, fieldsToKeep)
 
-	return tournamentsUserViewModel{json}
+	return tournamentsUserViewModel{json, next}
 }
 
 // AllowInvitation handler, use it to allow an invitation to a team.
@@ -597,7 +646,7 @@ func AllowInvitation(w http.ResponseWriter, r *http.Request, u *mdl.User) error
 	}
 
 	// add user as member of team
-	if err = team.Join(c, u); err != nil {
+	if err = mdl.Repos(c).JoinTeam(team, u); err != nil {
 		log.Errorf(c, "Team Join Handler: error on Join team: %v", err)
 		return &helpers.InternalServerError{Err: errors.New(helpers.ErrorCodeInternal)}
 	}
@@ -609,8 +658,8 @@ func AllowInvitation(w http.ResponseWriter, r *http.Request, u *mdl.User) error
 	}
 
 	// publish activity
-	if updatedUser, err := mdl.UserByID(c, u.Id); err == nil && updatedUser != nil {
-		updatedUser.Publish(c, "team", "joined team", team.Entity(), mdl.ActivityEntity{})
+	if updatedUser, err := mdl.Repos(c).Users.ByID(u.Id); err == nil && updatedUser != nil {
+		mdl.Repos(c).Publish(updatedUser, "team", "joined team", team.Entity(), mdl.ActivityEntity{})
 	}
 
 	vm := buildAllowInvitationUserViewModel(team)
@@ -57,7 +57,7 @@ func (c Context) User() (*mdl.User, error) {
 	}
 
 	var u *mdl.User
-	if u, err = mdl.UserByID(c.c, userID); err != nil {
+	if u, err = mdl.Repos(c.c).Users.ByID(userID); err != nil {
 		log.Errorf(c.c, "%s user not found", c.desc)
 		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
 	}
@@ -70,7 +70,7 @@ func (c Context) User() (*mdl.User, error) {
 //
 func (c Context) Admin(userID int64) (*mdl.User, error) {
 
-	a, err := mdl.UserByID(c.c, userID)
+	a, err := mdl.Repos(c.c).Users.ByID(userID)
 	if err != nil {
 		log.Errorf(c.c, "%s user not found", c.desc)
 		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeUserNotFound)}
@@ -107,7 +107,7 @@ func (c Context) Team() (*mdl.Team, error) {
 	}
 
 	var t *mdl.Team
-	t, err = mdl.TeamByID(c.c, teamID)
+	t, err = mdl.Repos(c.c).Teams.ByID(teamID)
 	if err != nil {
 		log.Errorf(c.c, "%s team with id:%v was not found %v", c.desc, teamID, err)
 		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTeamNotFound)}
@@ -151,6 +151,78 @@ func (c Context) TeamRequest() (*mdl.TeamRequest, error) {
 	return teamRequest, nil
 }
 
+// WebhookID returns a int64 webhookId from the HTTP request.
+//
+func (c Context) WebhookID() (int64, error) {
+
+	strWebhookID, err := route.Context.Get(c.r, "webhookId")
+	if err != nil {
+		log.Errorf(c.c, "%s error getting webhook id, err:%v", c.desc, err)
+		return 0, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeWebhookNotFound)}
+	}
+
+	var webhookID int64
+	webhookID, err = strconv.ParseInt(strWebhookID, 0, 64)
+	if err != nil {
+		log.Errorf(c.c, "%s error converting webhook id from string to int64, err:%v", c.desc, err)
+		return 0, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeWebhookNotFound)}
+	}
+	return webhookID, nil
+}
+
+// Webhook returns a webhook of a team from an HTTP request.
+//
+func (c Context) Webhook(team *mdl.Team) (*mdl.Webhook, error) {
+
+	webhookID, err := c.WebhookID()
+	if err != nil {
+		return nil, err
+	}
+
+	var wh *mdl.Webhook
+	if wh, err = mdl.WebhookByID(c.c, webhookID); err != nil || wh.TeamId != team.Id {
+		log.Errorf(c.c, "%s webhook %v not found in team %v: %v", c.desc, webhookID, team.Id, err)
+		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeWebhookNotFound)}
+	}
+	return wh, nil
+}
+
+// APIKeyID returns a int64 apikeyId from the HTTP request.
+//
+func (c Context) APIKeyID() (int64, error) {
+
+	strAPIKeyID, err := route.Context.Get(c.r, "apikeyId")
+	if err != nil {
+		log.Errorf(c.c, "%s error getting api key id, err:%v", c.desc, err)
+		return 0, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeAPIKeyNotFound)}
+	}
+
+	var apikeyID int64
+	apikeyID, err = strconv.ParseInt(strAPIKeyID, 0, 64)
+	if err != nil {
+		log.Errorf(c.c, "%s error converting api key id from string to int64, err:%v", c.desc, err)
+		return 0, &helpers.BadRequest{Err: errors.New(helpers.ErrorCodeAPIKeyNotFound)}
+	}
+	return apikeyID, nil
+}
+
+// APIKey returns an API key of a user from an HTTP request.
+//
+func (c Context) APIKey(user *mdl.User) (*mdl.APIKey, error) {
+
+	apikeyID, err := c.APIKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	var k *mdl.APIKey
+	if k, err = mdl.APIKeyByID(c.c, apikeyID); err != nil || k.UserId != user.Id {
+		log.Errorf(c.c, "%s api key %v not found for user %v: %v", c.desc, apikeyID, user.Id, err)
+		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeAPIKeyNotFound)}
+	}
+	return k, nil
+}
+
 // TournamentId returns the Id of the tournament that the request holds.
 //
 func (c Context) TournamentId() (int64, error) {
@@ -181,7 +253,7 @@ func (c Context) Tournament() (*mdl.Tournament, error) {
 	}
 
 	var tournament *mdl.Tournament
-	if tournament, err = mdl.TournamentByID(c.c, tournamentId); err != nil {
+	if tournament, err = mdl.Repos(c.c).Tournaments.ByID(tournamentId); err != nil {
 		log.Errorf(c.c, "%s tournament not found: %v", c.desc, err)
 		return nil, &helpers.NotFound{Err: errors.New(helpers.ErrorCodeTournamentNotFound)}
 	}
@@ -248,6 +320,25 @@ func (c Context) CountOrDefault(d int64) int64 {
 	return count
 }
 
+// Cursor extracts the 'cursor' value from the given http.Request
+// returns an empty cursor, the first page, if none is found.
+//
+func (c Context) Cursor() string {
+	return c.r.FormValue("cursor")
+}
+
+// LegacyPage reports whether the given http.Request is paged with the
+// legacy 'page' value, that is when it has no 'cursor' value.
+// An empty 'cursor' asks for the first page with a cursor.
+//
+func (c Context) LegacyPage() bool {
+	if err := c.r.ParseForm(); err != nil {
+		log.Infof(c.c, "%s unable to parse form, err:%v", c.desc, err)
+	}
+	_, ok := c.r.Form["cursor"]
+	return !ok
+}
+
 // Page extracts the 'page' value from the given http.Request
 // returns 1 if none is found.
 //
@@ -8,6 +8,7 @@ angular.module('gonawingApp', [
   /* directives */
   'directive.googleplussignin',
   'directive.twittersignin',
+  'directive.providersignin',
   'directive.googlesignin',
   'directive.facebooksignin',
   'directive.formValidation',
@@ -55,6 +56,52 @@ angular.module('gonawingApp').factory('notFoundInterceptor', ['$q', '$location',
   };
 }]);
 
+// refreshInterceptor gets new session tokens from the refresh token when a request
+// is rejected because the session has expired, then replays the request.
+// The user is sent back to the welcome page if the session cannot be refreshed.
+angular.module('gonawingApp').factory('refreshInterceptor', ['$q', '$injector', '$location', '$cookieStore', function($q, $injector, $location, $cookieStore) {
+  var refreshing;
+
+  var refresh = function() {
+    if (!refreshing) {
+      var $http = $injector.get('$http');
+      refreshing = $http({
+        method: 'POST',
+        url: '/j/auth/refresh',
+        data: 'refresh_token=' + encodeURIComponent($cookieStore.get('refresh_token')),
+        headers: { 'Content-Type': 'application/x-www-form-urlencoded' }
+      }).then(function(response) {
+        $injector.get('sAuth').storeSession(response.data.Session);
+        $http.defaults.headers.common['Authorization'] = response.data.Session.Token;
+        return response.data.Session.Token;
+      })['finally'](function() {
+        refreshing = undefined;
+      });
+    }
+    return refreshing;
+  };
+
+  return {
+    responseError: function(response) {
+      if (!response || response.status !== 401 || response.config.url === '/j/auth/refresh' ||
+          response.config.refreshed || !$cookieStore.get('refresh_token')) {
+        return $q.reject(response);
+      }
+      return refresh().then(function(token) {
+        response.config.refreshed = true;
+        response.config.headers['Authorization'] = token;
+        return $injector.get('$http')(response.config);
+      }, function() {
+        $injector.get('sAuth').clearCookies();
+        $injector.get('$rootScope').isLoggedIn = false;
+        $injector.get('$rootScope').currentUser = undefined;
+        $location.path('/welcome');
+        return $q.reject(response);
+      });
+    }
+  };
+}]);
+
 angular.module('gonawingApp').config(['$routeProvider', '$httpProvider',
   function($routeProvider, $httpProvider) {
     $routeProvider.
@@ -86,6 +133,7 @@ angular.module('gonawingApp').config(['$routeProvider', '$httpProvider',
       otherwise( {redirectTo: '/'});
 
     $httpProvider.interceptors.push('notFoundInterceptor');
+    $httpProvider.interceptors.push('refreshInterceptor');
 }]);
 
 angular.module('gonawingApp').run(['$rootScope', '$location', '$window', '$cookieStore', 'sAuth', 'Session', 'User', function($rootScope, $location, $window, $cookieStore, sAuth, Session, User) {
@@ -144,6 +192,10 @@ angular.module('gonawingApp').run(['$rootScope', '$location', '$window', '$cooki
       sAuth.signinWithTwitter(($location.search()).oauth_token, ($location.search()).oauth_verifier);
     } else if($location.$$path === '/auth/google/callback') {
       sAuth.signinWithGoogle(($location.search()).auth_token);
+    } else if($location.$$path === '/auth/magic/callback') {
+      sAuth.signinWithMagicLink(($location.search()).token);
+    } else if(/^\/auth\/oauth\/[^\/]+\/callback$/.test($location.$$path)) {
+      sAuth.signinWithProvider($location.$$path.split('/')[3], $location.search());
     } else {
       // Everytime the route in our app changes check authentication status.
       // Get current user only if we are logged in.
@@ -177,7 +229,7 @@ angular.module('gonawingApp').run(['$rootScope', '$location', '$window', '$cooki
         email:userInfo.emails[0].value } );
       $rootScope.currentUser.$promise.then(function(currentUser){
         console.log('event:google-plus-signin-success: current user = ', currentUser);
-        sAuth.storeCookies(authResult.access_token, currentUser.User.Auth, currentUser.User.Id);
+        sAuth.storeCookies(authResult.access_token, currentUser.Session, currentUser.User.Id);
         $cookieStore.put('provider', 'google_plus');
         $rootScope.isLoggedIn = true;
         $location.path('/');
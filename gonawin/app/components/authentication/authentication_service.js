@@ -38,7 +38,7 @@ authService.factory('sAuth', function($rootScope, $cookieStore, $cookies, $locat
           email:userInfo.email } );
         $rootScope.currentUser.$promise.then(function(currentUser){
           console.log('authServices.getFBUserInfo: current user = ', currentUser);
-          _self.storeCookies(accessToken, currentUser.User.Auth, currentUser.User.Id);
+          _self.storeCookies(accessToken, currentUser.Session, currentUser.User.Id);
           $cookieStore.put('provider', 'facebook');
           $rootScope.isLoggedIn = true;
           $location.path('/');
@@ -47,15 +47,22 @@ authService.factory('sAuth', function($rootScope, $cookieStore, $cookies, $locat
     },
     /* store cookies which will be used to dertermine if a user is logged
      * and to add authentication data in API requests */
-    storeCookies: function(accessToken, auth, userId) {
+    storeCookies: function(accessToken, session, userId) {
       $cookieStore.put('access_token', accessToken);
-      $cookieStore.put('auth', auth);
+      this.storeSession(session);
       $cookieStore.put('user_id', userId);
       $cookieStore.put('logged_in', true);
     },
+    /* store the tokens of a session, the refresh token is used to get new tokens
+     * when the access token has expired */
+    storeSession: function(session) {
+      $cookieStore.put('auth', session.Token);
+      $cookieStore.put('refresh_token', session.RefreshToken);
+    },
     /* delete all the stored cookies*/
     clearCookies: function() {
       $cookieStore.remove('auth');
+      $cookieStore.remove('refresh_token');
       $cookieStore.remove('access_token');
       $cookieStore.remove('user_id');
       $cookieStore.remove('logged_in');
@@ -88,12 +95,47 @@ authService.factory('sAuth', function($rootScope, $cookieStore, $cookies, $locat
       $rootScope.currentUser = Session.fetchTwitterUser({ oauth_token: oauthToken, oauth_verifier: oauthVerifier });
       $rootScope.currentUser.$promise.then(function(currentUser){
         console.log('signinWithTwitter: current user = ', currentUser);
-        _self.storeCookies(oauthToken, currentUser.User.Auth, currentUser.User.Id);
+        _self.storeCookies(oauthToken, currentUser.Session, currentUser.User.Id);
         $cookieStore.put('provider', 'twitter');
         $rootScope.isLoggedIn = true;
         $location.path('/');
       });
     },
+    /* Complete signin with a provider.
+     * Fetch the user with the params of the provider callback then set the current user
+     * and store the cookies */
+    signinWithProvider: function(provider, params) {
+      var _self = this;
+      params.provider = provider;
+      $rootScope.currentUser = Session.fetchProviderUser(params);
+      $rootScope.currentUser.$promise.then(function(currentUser){
+        console.log('signinWithProvider: current user = ', currentUser);
+        _self.storeCookies(params.state || params.oauth_token, currentUser.Session, currentUser.User.Id);
+        $cookieStore.put('provider', provider);
+        $rootScope.isLoggedIn = true;
+        $location.path('/');
+      }, function(error){
+        $rootScope.currentUser = undefined;
+        $location.path('/welcome');
+      });
+    },
+    /* Complete signin with a magic link.
+     * Exchange the token of the link for the current user and a session
+     * and store the cookies */
+    signinWithMagicLink: function(token) {
+      var _self = this;
+      $rootScope.currentUser = Session.fetchMagicUser({ token: token });
+      $rootScope.currentUser.$promise.then(function(currentUser){
+        console.log('signinWithMagicLink: current user = ', currentUser);
+        _self.storeCookies(token, currentUser.Session, currentUser.User.Id);
+        $cookieStore.put('provider', 'magic');
+        $rootScope.isLoggedIn = true;
+        $location.path('/');
+      }, function(error){
+        $rootScope.currentUser = undefined;
+        $location.path('/welcome');
+      });
+    },
     /* Complete signin with Google.
      * Fetch Google user info then set the current user
      * and store the cookies */
@@ -102,7 +144,7 @@ authService.factory('sAuth', function($rootScope, $cookieStore, $cookies, $locat
       $rootScope.currentUser = Session.fetchGoogleUser({ auth_token: authToken });
       $rootScope.currentUser.$promise.then(function(currentUser){
         console.log('signinWithGoogle: current user = ', currentUser);
-        _self.storeCookies(authToken, currentUser.User.Auth, currentUser.User.Id);
+        _self.storeCookies(authToken, currentUser.Session, currentUser.User.Id);
         $cookieStore.put('provider', 'google');
         $rootScope.isLoggedIn = true;
         $location.path('/');
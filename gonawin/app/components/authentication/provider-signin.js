@@ -0,0 +1,23 @@
+'use strict';
+
+angular.module('directive.providersignin', []).
+  directive('providerSignin', function (Session) {
+    return {
+      restrict: 'E',
+      template: '<div><div class="row" ng-repeat="provider in providers"><button class="btn btn-social btn-block btn-default" ng-click="signin(provider)"><i class="fa fa-sign-in"></i> Signin with {{provider}}</button></div></div>',
+      replace: true,
+      link: function (scope, element, attrs) {
+        scope.providers = [];
+        Session.providers().$promise.then(function(data){
+          // twitter has its own directive.
+          scope.providers = (data.Providers || []).filter(function(p) { return p !== 'twitter'; });
+        });
+        scope.signin = function(provider) {
+          console.log('Sign in with ' + provider + ' has started...');
+          Session.providerLogin({ provider: provider }).$promise.then(function(data){
+            window.location.replace(data.Url);
+          });
+        };
+      }
+    };
+  });
@@ -14,6 +14,10 @@ sessionService.factory('Session', function($cookieStore, $resource) {
     fetchGoogleUser: { method:'GET', params: { auth_token: '@auth_token' }, url: '/j/auth/google/user/' },
     DeleteGoogleCookie: { method: 'GET', url: '/j/auth/google/deletecookie'},
     serviceIds: { method:'GET', url: '/j/auth/serviceids/' },
+    providers: { method:'GET', url: '/j/auth/providers' },
+    providerLogin: { method:'GET', params: { provider: '@provider' }, url: '/j/auth/oauth/:provider/login' },
+    fetchProviderUser: { method:'GET', params: { provider: '@provider' }, url: '/j/auth/oauth/:provider/user' },
+    fetchMagicUser: { method:'POST', params: { token: '@token' }, url: '/j/auth/magic/user' },
   });
 
   // Need to define displayname function here again as User can be either returned by the server or the session.
@@ -507,6 +507,17 @@ teamControllers.controller('TeamInviteCtrl', ['$rootScope', '$scope', '$routePar
       });
     }
 
+    // Suggest users as the keywords are typed.
+    $scope.suggestUsers = function() {
+      if(!$scope.keywords || $scope.keywords.length < 2) {
+        $scope.userSuggestions = [];
+        return;
+      }
+      User.suggest({q: $scope.keywords}).$promise.then(function(response) {
+        $scope.userSuggestions = response.Suggestions;
+      });
+    };
+
     // Search function
     $scope.searchTeam = function() {
       console.log('TeamInviteCtrl: searchTeam');
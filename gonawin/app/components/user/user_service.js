@@ -10,6 +10,7 @@ userService.factory('User', function($http, $resource, $cookieStore) {
     delete: { method: 'POST', url: 'j/users/destroy/:id' },
     scores: {method: 'GET', url: 'j/users/:id/scores'},
     search: { method: 'GET', url: 'j/users/search?q=:q', cache : true},
+    suggest: { method: 'GET', url: 'j/search/suggest?q=:q&kind=User'},
     teams: {method : 'GET', url: 'j/users/:id/teams'},
     tournaments: {method : 'GET', url: 'j/users/:id/tournaments'},
     allowInvitation : {method: 'POST', url: 'j/users/allow/:teamId'},
@@ -29,12 +29,18 @@ import (
 	"github.com/taironas/gonawin/helpers/handlers"
 
 	activitiesctrl "github.com/taironas/gonawin/controllers/activities"
+	consistencyctrl "github.com/taironas/gonawin/controllers/consistency"
 	invitectrl "github.com/taironas/gonawin/controllers/invite"
+	notificationsctrl "github.com/taironas/gonawin/controllers/notifications"
+	searchctrl "github.com/taironas/gonawin/controllers/search"
 	sessionsctrl "github.com/taironas/gonawin/controllers/sessions"
+	slackctrl "github.com/taironas/gonawin/controllers/slack"
 	tasksctrl "github.com/taironas/gonawin/controllers/tasks"
 	teamsctrl "github.com/taironas/gonawin/controllers/teams"
 	tournamentsctrl "github.com/taironas/gonawin/controllers/tournaments"
 	usersctrl "github.com/taironas/gonawin/controllers/users"
+
+	mdl "github.com/taironas/gonawin/models"
 )
 
 // entry point of application
@@ -45,6 +51,10 @@ func init() {
 	checkErrors := handlers.ErrorHandler
 	authorized := handlers.Authorized
 	adminAuthorized := handlers.AdminAuthorized
+	keyAuthorized := handlers.KeyAuthorized
+	teamRole := handlers.TeamRole
+	tournamentRole := handlers.TournamentRole
+	secondFactor := handlers.SecondFactor
 	// ------------- Json Server -----------------
 
 	// session
@@ -57,6 +67,41 @@ func init() {
 	r.HandleFunc("/j/auth/google/user", checkErrors(sessionsctrl.GoogleUser))
 	r.HandleFunc("/j/auth/google/deletecookie", checkErrors(sessionsctrl.GoogleDeleteCookie))
 	r.HandleFunc("/j/auth/serviceids", checkErrors(sessionsctrl.AuthServiceIds))
+	r.HandleFunc("/j/auth/providers", checkErrors(sessionsctrl.Providers))
+	r.HandleFunc("/j/auth/oauth/:provider/login", checkErrors(sessionsctrl.ProviderLogin))
+	r.HandleFunc("/j/auth/oauth/:provider/link", checkErrors(authorized(sessionsctrl.ProviderLink)))
+	r.HandleFunc("/j/auth/oauth/:provider/callback", checkErrors(sessionsctrl.ProviderCallback))
+	r.HandleFunc("/j/auth/oauth/:provider/user", checkErrors(sessionsctrl.ProviderUser))
+	r.HandleFunc("/j/auth/refresh", checkErrors(sessionsctrl.Refresh))
+	r.HandleFunc("/j/auth/logout", checkErrors(authorized(sessionsctrl.Logout)))
+	r.HandleFunc("/j/auth/register", checkErrors(sessionsctrl.Register))
+	r.HandleFunc("/j/auth/verify", checkErrors(sessionsctrl.Verify))
+	r.HandleFunc("/j/auth/login", checkErrors(sessionsctrl.Login))
+	r.HandleFunc("/j/auth/password/forgot", checkErrors(sessionsctrl.ForgotPassword))
+	r.HandleFunc("/j/auth/password/reset", checkErrors(sessionsctrl.ResetPassword))
+	r.HandleFunc("/j/auth/magic", checkErrors(sessionsctrl.MagicLink))
+	r.HandleFunc("/j/auth/magic/signin", checkErrors(sessionsctrl.MagicSignin))
+	r.HandleFunc("/j/auth/magic/user", checkErrors(sessionsctrl.MagicUser))
+	r.HandleFunc("/j/auth/2fa", checkErrors(authorized(sessionsctrl.TwoFactor)))
+	r.HandleFunc("/j/auth/2fa/setup", checkErrors(authorized(sessionsctrl.TwoFactorSetup)))
+	r.HandleFunc("/j/auth/2fa/enable", checkErrors(authorized(sessionsctrl.TwoFactorEnable)))
+	r.HandleFunc("/j/auth/2fa/verify", checkErrors(authorized(sessionsctrl.TwoFactorVerify)))
+	r.HandleFunc("/j/auth/2fa/recovery", checkErrors(authorized(secondFactor(sessionsctrl.TwoFactorRecoveryCodes))))
+	r.HandleFunc("/j/auth/2fa/disable", checkErrors(authorized(sessionsctrl.TwoFactorDisable)))
+
+	// sessions
+	r.HandleFunc("/j/sessions", checkErrors(authorized(sessionsctrl.Devices)))
+	r.HandleFunc("/j/sessions/revoke/:sessionId", checkErrors(authorized(sessionsctrl.Revoke)))
+
+	// search
+	r.HandleFunc("/j/search", checkErrors(authorized(searchctrl.Search)))
+	r.HandleFunc("/j/search/suggest", checkErrors(authorized(searchctrl.Suggest)))
+	r.HandleFunc("/j/search/rebuild", checkErrors(adminAuthorized(secondFactor(searchctrl.Rebuild))))
+	r.HandleFunc("/j/search/rebuild/:rebuildId", checkErrors(adminAuthorized(searchctrl.RebuildStatus)))
+
+	// consistency
+	r.HandleFunc("/j/consistency/check", checkErrors(adminAuthorized(secondFactor(consistencyctrl.Check))))
+	r.HandleFunc("/j/consistency/check/:checkId", checkErrors(adminAuthorized(consistencyctrl.CheckStatus)))
 
 	// user
 	r.HandleFunc("/j/users", checkErrors(adminAuthorized(usersctrl.Index)))
@@ -67,6 +112,12 @@ func init() {
 	r.HandleFunc("/j/users/search", checkErrors(authorized(usersctrl.Search)))
 	r.HandleFunc("/j/users/:userId/teams", checkErrors(authorized(usersctrl.Teams)))
 	r.HandleFunc("/j/users/:userId/tournaments", checkErrors(authorized(usersctrl.Tournaments)))
+	r.HandleFunc("/j/users/:userId/apikeys", checkErrors(authorized(usersctrl.APIKeys)))
+	r.HandleFunc("/j/users/:userId/apikeys/new", checkErrors(authorized(secondFactor(usersctrl.NewAPIKey))))
+	r.HandleFunc("/j/users/:userId/apikeys/destroy/:apikeyId", checkErrors(authorized(usersctrl.DestroyAPIKey)))
+	r.HandleFunc("/j/users/:userId/identities", checkErrors(authorized(usersctrl.Identities)))
+	r.HandleFunc("/j/users/:userId/identities/unlink/:provider", checkErrors(authorized(usersctrl.UnlinkIdentity)))
+	r.HandleFunc("/j/users/:userId/merge/:targetId", checkErrors(adminAuthorized(secondFactor(usersctrl.Merge))))
 	r.HandleFunc("/j/users/allow/:teamId", checkErrors(authorized(usersctrl.AllowInvitation)))
 	r.HandleFunc("/j/users/deny/:teamId", checkErrors(authorized(usersctrl.DenyInvitation)))
 
@@ -74,30 +125,33 @@ func init() {
 	r.HandleFunc("/j/teams", checkErrors(authorized(teamsctrl.Index)))
 	r.HandleFunc("/j/teams/new", checkErrors(authorized(teamsctrl.New)))
 	r.HandleFunc("/j/teams/show/:teamId", checkErrors(authorized(teamsctrl.Show)))
-	r.HandleFunc("/j/teams/update/:teamId", checkErrors(authorized(teamsctrl.Update)))
-	r.HandleFunc("/j/teams/destroy/:teamId", checkErrors(authorized(teamsctrl.Destroy)))
+	r.HandleFunc("/j/teams/update/:teamId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.Update))))
+	r.HandleFunc("/j/teams/destroy/:teamId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.Destroy))))
 	r.HandleFunc("/j/teams/requestinvite/:teamId", checkErrors(authorized(teamsctrl.RequestInvite)))
-	r.HandleFunc("/j/teams/sendinvite/:teamId/:userId", checkErrors(authorized(teamsctrl.SendInvite)))
+	r.HandleFunc("/j/teams/sendinvite/:teamId/:userId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.SendInvite))))
 	r.HandleFunc("/j/teams/invited/:teamId", checkErrors(authorized(teamsctrl.Invited)))
 	r.HandleFunc("/j/teams/allow/:requestId", checkErrors(authorized(teamsctrl.AllowRequest)))
 	r.HandleFunc("/j/teams/deny/:requestId", checkErrors(authorized(teamsctrl.DenyRequest)))
 	r.HandleFunc("/j/teams/search", checkErrors(authorized(teamsctrl.Search)))
 	r.HandleFunc("/j/teams/:teamId/members", checkErrors(authorized(teamsctrl.Members)))
-	r.HandleFunc("/j/teams/:teamId/ranking", checkErrors(authorized(teamsctrl.Ranking)))
+	r.HandleFunc("/j/teams/:teamId/ranking", checkErrors(keyAuthorized(mdl.ScopeReadRankings, teamsctrl.Ranking)))
 	r.HandleFunc("/j/teams/:teamId/accuracies/:tournamentId", checkErrors(authorized(teamsctrl.AccuracyByTournament)))
 	r.HandleFunc("/j/teams/:teamId/accuracies", checkErrors(authorized(teamsctrl.Accuracies)))
 	r.HandleFunc("/j/teams/:teamId/prices", checkErrors(authorized(teamsctrl.Prices)))
 	r.HandleFunc("/j/teams/:teamId/prices/:tournamentId", checkErrors(authorized(teamsctrl.PriceByTournament)))
-	r.HandleFunc("/j/teams/:teamId/prices/update/:tournamentId", checkErrors(authorized(teamsctrl.UpdatePrice)))
-	r.HandleFunc("/j/teams/:teamId/admin/add/:userId", checkErrors(authorized(teamsctrl.AddAdmin)))
-	r.HandleFunc("/j/teams/:teamId/admin/remove/:userId", checkErrors(authorized(teamsctrl.RemoveAdmin)))
+	r.HandleFunc("/j/teams/:teamId/prices/update/:tournamentId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.UpdatePrice))))
+	r.HandleFunc("/j/teams/:teamId/admin/add/:userId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.AddAdmin))))
+	r.HandleFunc("/j/teams/:teamId/admin/remove/:userId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.RemoveAdmin))))
+	r.HandleFunc("/j/teams/:teamId/webhooks", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.Webhooks))))
+	r.HandleFunc("/j/teams/:teamId/webhooks/new", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.NewWebhook))))
+	r.HandleFunc("/j/teams/:teamId/webhooks/destroy/:webhookId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, teamsctrl.DestroyWebhook))))
 
 	// tournament
 	r.HandleFunc("/j/tournaments", checkErrors(authorized(tournamentsctrl.Index)))
 	r.HandleFunc("/j/tournaments/new", checkErrors(adminAuthorized(tournamentsctrl.New)))
 	r.HandleFunc("/j/tournaments/show/:tournamentId", checkErrors(authorized(tournamentsctrl.Show)))
-	r.HandleFunc("/j/tournaments/update/:tournamentId", checkErrors(adminAuthorized(tournamentsctrl.Update)))
-	r.HandleFunc("/j/tournaments/destroy/:tournamentId", checkErrors(adminAuthorized(tournamentsctrl.Destroy)))
+	r.HandleFunc("/j/tournaments/update/:tournamentId", checkErrors(keyAuthorized(mdl.ScopeAdminTournament, tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.Update))))
+	r.HandleFunc("/j/tournaments/destroy/:tournamentId", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.Destroy)))))
 	r.HandleFunc("/j/tournaments/search", checkErrors(authorized(tournamentsctrl.Search)))
 	r.HandleFunc("/j/tournaments/:tournamentId/candidates", checkErrors(authorized(tournamentsctrl.CandidateTeams)))
 	r.HandleFunc("/j/tournaments/:tournamentId/participants", checkErrors(authorized(tournamentsctrl.Participants)))
@@ -106,8 +160,8 @@ func init() {
 	r.HandleFunc("/j/teams/join/:teamId", checkErrors(authorized(teamsctrl.Join)))
 	r.HandleFunc("/j/teams/leave/:teamId", checkErrors(authorized(teamsctrl.Leave)))
 	r.HandleFunc("/j/tournaments/join/:tournamentId", checkErrors(authorized(tournamentsctrl.Join)))
-	r.HandleFunc("/j/tournaments/joinasteam/:tournamentId/:teamId", checkErrors(authorized(tournamentsctrl.JoinAsTeam)))
-	r.HandleFunc("/j/tournaments/leaveasteam/:tournamentId/:teamId", checkErrors(authorized(tournamentsctrl.LeaveAsTeam)))
+	r.HandleFunc("/j/tournaments/joinasteam/:tournamentId/:teamId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, tournamentsctrl.JoinAsTeam))))
+	r.HandleFunc("/j/tournaments/leaveasteam/:tournamentId/:teamId", checkErrors(authorized(teamRole(mdl.RoleTeamAdmin, tournamentsctrl.LeaveAsTeam))))
 
 	// invite
 	r.HandleFunc("/j/invite", checkErrors(authorized(invitectrl.Invite)))
@@ -129,25 +183,39 @@ func init() {
 	r.HandleFunc("/j/tournaments/geteuro", checkErrors(authorized(tournamentsctrl.GetEuro)))
 
 	// tournament
+	r.HandleFunc("/j/tournaments/:tournamentId/reminders", checkErrors(authorized(tournamentsctrl.Reminders)))
 	r.HandleFunc("/j/tournaments/:tournamentId/groups", checkErrors(authorized(tournamentsctrl.Groups)))
 	r.HandleFunc("/j/tournaments/:tournamentId/calendar", checkErrors(authorized(tournamentsctrl.Calendar)))
+	r.HandleFunc("/j/tournaments/:tournamentId/calendar.ics", checkErrors(tournamentsctrl.CalendarICS))
+	r.HandleFunc("/j/tournaments/:tournamentId/users/:userId/calendar.ics", checkErrors(tournamentsctrl.UserCalendarICS))
 	r.HandleFunc("/j/tournaments/:tournamentId/:teamId/calendarwithprediction", checkErrors(authorized(tournamentsctrl.CalendarWithPrediction)))
 	r.HandleFunc("/j/tournaments/:tournamentId/matches", checkErrors(authorized(tournamentsctrl.Matches)))
-	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId/update", checkErrors(adminAuthorized(tournamentsctrl.UpdateMatchResult)))
-	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId/predict", checkErrors(authorized(tournamentsctrl.Predict)))
-	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId/blockprediction", checkErrors(adminAuthorized(tournamentsctrl.BlockMatchPrediction)))
-	r.HandleFunc("/j/tournaments/:tournamentId/ranking", checkErrors(authorized(tournamentsctrl.Ranking)))
+	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId/update", checkErrors(keyAuthorized(mdl.ScopeAdminTournament, secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.UpdateMatchResult)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId/predict", checkErrors(keyAuthorized(mdl.ScopeWritePredictions, tournamentsctrl.Predict)))
+	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId/blockprediction", checkErrors(keyAuthorized(mdl.ScopeAdminTournament, secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.BlockMatchPrediction)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/ranking", checkErrors(keyAuthorized(mdl.ScopeReadRankings, tournamentsctrl.Ranking)))
 	r.HandleFunc("/j/tournaments/:tournamentId/teams", checkErrors(authorized(tournamentsctrl.Teams)))
-	r.HandleFunc("/j/tournaments/:tournamentId/admin/reset", checkErrors(adminAuthorized(tournamentsctrl.Reset)))
-	r.HandleFunc("/j/tournaments/:tournamentId/matches/simulate", checkErrors(adminAuthorized(tournamentsctrl.SimulateMatches)))
-	r.HandleFunc("/j/tournaments/:tournamentId/admin/updateteam", checkErrors(adminAuthorized(tournamentsctrl.UpdateTeam)))
-	r.HandleFunc("/j/tournaments/:tournamentId/admin/add/:userId", checkErrors(adminAuthorized(tournamentsctrl.AddAdmin)))
-	r.HandleFunc("/j/tournaments/:tournamentId/admin/remove/:userId", checkErrors(adminAuthorized(tournamentsctrl.RemoveAdmin)))
-	r.HandleFunc("/j/tournaments/:tournamentId/admin/activatephase", checkErrors(adminAuthorized(tournamentsctrl.ActivatePhase)))
+	r.HandleFunc("/j/tournaments/:tournamentId/admin/reset", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.Reset)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/matches/simulate", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.SimulateMatches)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/matches/:matchId", checkErrors(authorized(tournamentsctrl.Match)))
+	r.HandleFunc("/j/tournaments/:tournamentId/admin/updateteam", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.UpdateTeam)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/admin/add/:userId", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.AddAdmin)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/admin/remove/:userId", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.RemoveAdmin)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/admin/activatephase", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.ActivatePhase)))))
+	r.HandleFunc("/j/tournaments/:tournamentId/admin/schedule", checkErrors(authorized(secondFactor(tournamentRole(mdl.RoleTournamentAdmin, tournamentsctrl.UpdateSchedule)))))
 
 	// activities
 	r.HandleFunc("/j/activities", checkErrors(authorized(activitiesctrl.Index)))
 
+	// notifications
+	r.HandleFunc("/j/notifications", checkErrors(authorized(notificationsctrl.Index)))
+	r.HandleFunc("/j/notifications/read", checkErrors(authorized(notificationsctrl.Read)))
+	r.HandleFunc("/j/notifications/preferences", checkErrors(authorized(notificationsctrl.Preferences)))
+
+	// slack
+	r.HandleFunc("/j/slack/command", checkErrors(slackctrl.Command))
+	r.HandleFunc("/j/slack/link", checkErrors(authorized(slackctrl.LinkCode)))
+
 	// admin handlers
 	r.HandleFunc("/a/update/scores", checkErrors(tasksctrl.UpdateScores))
 	r.HandleFunc("/a/update/users/scores", checkErrors(tasksctrl.UpdateUsersScores))
@@ -155,7 +223,17 @@ func init() {
 	r.HandleFunc("/a/create/scoreentities", checkErrors(tasksctrl.CreateScoreEntities))
 	r.HandleFunc("/a/add/scoreentities/score", checkErrors(tasksctrl.AddScoreToScoreEntities))
 	r.HandleFunc("/a/invite", checkErrors(tasksctrl.Invite))
+	r.HandleFunc("/a/update/results", checkErrors(tasksctrl.UpdateResults))
+	r.HandleFunc("/a/remind/predictions", checkErrors(tasksctrl.RemindPredictions))
+	r.HandleFunc("/a/remind/predictions/user", checkErrors(tasksctrl.RemindUserPredictions))
+	r.HandleFunc("/a/digest", checkErrors(tasksctrl.SendDigests))
+	r.HandleFunc("/a/digest/user", checkErrors(tasksctrl.SendUserDigest))
+	r.HandleFunc("/a/notify/email", checkErrors(tasksctrl.NotifyByEmail))
+	r.HandleFunc("/a/webhooks/deliver", checkErrors(tasksctrl.DeliverWebhook))
+	r.HandleFunc("/a/webhooks/predictionlocks", checkErrors(tasksctrl.PublishPredictionLocks))
 	r.HandleFunc("/a/publish/users/deletepredicts", checkErrors(tasksctrl.DeleteUserPredicts))
+	r.HandleFunc("/a/search/rebuild", checkErrors(tasksctrl.RebuildSearchIndex))
+	r.HandleFunc("/a/consistency/check", checkErrors(tasksctrl.CheckConsistency))
 
 	http.Handle("/", r)
 }
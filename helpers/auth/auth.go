@@ -19,15 +19,14 @@
 package auth
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	golog "log"
 	"math/rand"
 	"net/http"
+	"strings"
+	"time"
 
 	"appengine"
-	"appengine/urlfetch"
 	"appengine/user"
 
 	"github.com/taironas/gonawin/helpers/log"
@@ -40,6 +39,8 @@ var (
 	config *gwconfig.GwConfig
 	// KOfflineMode allows the set the offline mode
 	KOfflineMode bool
+	// legacyAuthUntil is the end of the migration window during which the legacy authentication key is accepted.
+	legacyAuthUntil time.Time
 )
 
 func init() {
@@ -47,8 +48,18 @@ func init() {
 	var err error
 	if config, err = gwconfig.ReadConfig(""); err != nil {
 		golog.Printf("Error: unable to read config file; %v", err)
+		// keep the defaults, the package is also imported by gonawin-server which has its own config file.
+		config = &gwconfig.GwConfig{}
 	}
 	KOfflineMode = config.OfflineMode
+
+	if len(config.LegacyAuthUntil) > 0 {
+		if day, err := time.Parse("2006-01-02", config.LegacyAuthUntil); err != nil {
+			golog.Printf("Error: unable to parse legacyAuthUntil; %v", err)
+		} else {
+			legacyAuthUntil = day.AddDate(0, 0, 1)
+		}
+	}
 }
 
 // UserInfo represents the user infor needed for authentication.
@@ -59,38 +70,58 @@ type UserInfo struct {
 	Name  string
 }
 
-// TwitterUserInfo represents the twitter data needed for authentication.
+// AuthorizationToken returns the token of the Authorization header of a request.
+// The token can be prefixed by "Bearer ".
+//
+func AuthorizationToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// LegacyAuthAccepted indicates if the legacy authentication key of a user is accepted at a given time.
 //
-type TwitterUserInfo struct {
-	Id         int64 `json:"id,omitempty"`
-	Name       string
-	ScreenName string `json:"screen_name,omitempty"`
+func LegacyAuthAccepted(now time.Time) bool {
+	return now.Before(legacyAuthUntil)
 }
 
-// CheckUserValidity checks user validity from an accessToken string.
-// verify if google user account is valid.
+// TwoFactorRequired indicates if the admins must enable two-factor authentication before the sensitive admin actions.
 //
-func CheckUserValidity(r *http.Request, url string, accessToken string) bool {
+func TwoFactorRequired() bool {
+	return config.RequireTwoFactor
+}
+
+// CheckAuthenticationData checks if authorization information in HTTP.Request is valid,
+// ie: if it matches a session or, during the migration window, the legacy authentication key of a user.
+// It returns mdl.ErrSessionExpired if the session token has expired.
+//
+func CheckAuthenticationData(r *http.Request) (*mdl.User, error) {
 	c := appengine.NewContext(r)
 
-	if len(url) == 0 || len(accessToken) == 0 {
-		return false
+	token := AuthorizationToken(r)
+	if len(token) == 0 {
+		return nil, mdl.ErrSessionInvalid
 	}
 
-	client := urlfetch.Client(c)
-	resp, err := client.Get(url + "=" + accessToken)
-	if err != nil {
-		log.Errorf(c, " CheckUserValidity: %v", err)
+	if mdl.IsSessionToken(token) {
+		s, err := mdl.SessionByToken(c, token)
+		if err != nil {
+			return nil, err
+		}
+		var u *mdl.User
+		if u, err = mdl.Repos(c).Users.ByID(s.UserId); err != nil {
+			return nil, mdl.ErrSessionInvalid
+		}
+		return u, nil
 	}
 
-	return resp.StatusCode == 200
-}
-
-// CheckAuthenticationData checks if authorization information in HTTP.Request is valid,
-// ie: if it matches a user.
-//
-func CheckAuthenticationData(r *http.Request) *mdl.User {
-	return mdl.FindUser(appengine.NewContext(r), "Auth", r.Header.Get("Authorization"))
+	if !LegacyAuthAccepted(time.Now()) {
+		return nil, mdl.ErrSessionInvalid
+	}
+	u := mdl.FindUser(c, "Auth", token)
+	if u == nil {
+		return nil, mdl.ErrSessionInvalid
+	}
+	log.Infof(c, "CheckAuthenticationData: legacy authentication key used by user %v", u.Id)
+	return u, nil
 }
 
 // Is app in offline mode and email an offline user.
@@ -107,23 +138,6 @@ func IsGonawinAdmin(c appengine.Context) bool {
 	return user.IsAdmin(c)
 }
 
-// FetchTwitterUserInfo unmarshals twitter response
-//
-func FetchTwitterUserInfo(r *http.Response) (*TwitterUserInfo, error) {
-	defer r.Body.Close()
-
-	body, err := ioutil.ReadAll(r.Body)
-
-	if err == nil {
-		var ui *TwitterUserInfo
-
-		if err = json.Unmarshal(body, &ui); err == nil {
-			return ui, err
-		}
-	}
-	return nil, err
-}
-
 // CurrentOfflineUser returns pointer to current user, from authentication cookie.
 //
 func CurrentOfflineUser(r *http.Request, c appengine.Context) *mdl.User {
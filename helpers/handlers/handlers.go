@@ -64,6 +64,20 @@ func ErrorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.Han
 	}
 }
 
+// authenticate returns the user of the authentication data of a request.
+// It returns an unauthorized error if the session has expired so that the client can refresh it.
+//
+func authenticate(r *http.Request) (*mdl.User, error) {
+	user, err := auth.CheckAuthenticationData(r)
+	if err == mdl.ErrSessionExpired {
+		return nil, &helpers.Unauthorized{Err: errors.New(helpers.ErrorCodeSessionsExpired)}
+	}
+	if user == nil {
+		return nil, &helpers.BadRequest{Err: errors.New("Bad Authentication data")}
+	}
+	return user, nil
+}
+
 // Authorized runs the function pass by parameter and checks authentication data prior to any call.
 // Will rise a bad request error handler if authentication fails.
 //
@@ -73,7 +87,10 @@ func Authorized(f func(w http.ResponseWriter, r *http.Request, u *mdl.User) erro
 		if auth.KOfflineMode {
 			user = auth.CurrentOfflineUser(r, appengine.NewContext(r))
 		} else {
-			user = auth.CheckAuthenticationData(r)
+			var err error
+			if user, err = authenticate(r); err != nil {
+				return err
+			}
 		}
 
 		if user == nil {
@@ -86,6 +103,7 @@ func Authorized(f func(w http.ResponseWriter, r *http.Request, u *mdl.User) erro
 
 // AdminAuthorized runs the function pass by parameter and checks authentication data prior to any call.
 // Will rise a bad request error handler if authentication fails. User should be a gonawin admin .
+// Use TeamRole and TournamentRole for the roles on a team or a tournament.
 //
 func AdminAuthorized(f func(w http.ResponseWriter, r *http.Request, u *mdl.User) error) ErrorHandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
@@ -93,9 +111,9 @@ func AdminAuthorized(f func(w http.ResponseWriter, r *http.Request, u *mdl.User)
 		if auth.KOfflineMode {
 			user = auth.CurrentOfflineUser(r, appengine.NewContext(r))
 		} else {
-			user = auth.CheckAuthenticationData(r)
-			if user == nil {
-				return &helpers.BadRequest{Err: errors.New("Bad Authentication data")}
+			var err error
+			if user, err = authenticate(r); err != nil {
+				return err
 			}
 		}
 		if !auth.IsGonawinAdmin(appengine.NewContext(r)) { //user) {
@@ -104,3 +122,42 @@ func AdminAuthorized(f func(w http.ResponseWriter, r *http.Request, u *mdl.User)
 		return f(w, r, user)
 	}
 }
+
+// authenticateKey returns the user of the API key of a request.
+// It returns a forbidden error if the key was not granted the scope.
+//
+func authenticateKey(r *http.Request, scope string) (*mdl.User, error) {
+	c := appengine.NewContext(r)
+
+	k, err := mdl.APIKeyByKey(c, auth.AuthorizationToken(r))
+	if err != nil {
+		return nil, &helpers.BadRequest{Err: errors.New("Bad Authentication data")}
+	}
+	if !k.HasScope(scope) {
+		return nil, &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeAPIKeyScope)}
+	}
+
+	var user *mdl.User
+	if user, err = mdl.Repos(c).Users.ByID(k.UserId); err != nil {
+		log.Errorf(c, "user %v of api key %v not found: %v", k.UserId, k.Id, err)
+		return nil, &helpers.BadRequest{Err: errors.New("Bad Authentication data")}
+	}
+	return user, nil
+}
+
+// KeyAuthorized runs the function pass by parameter and checks authentication data prior to any call.
+// The request is either authenticated as in Authorized or with an API key granted the scope.
+//
+func KeyAuthorized(scope string, f func(w http.ResponseWriter, r *http.Request, u *mdl.User) error) ErrorHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !mdl.IsAPIKey(auth.AuthorizationToken(r)) {
+			return Authorized(f)(w, r)
+		}
+
+		user, err := authenticateKey(r, scope)
+		if err != nil {
+			return err
+		}
+		return f(w, r, user)
+	}
+}
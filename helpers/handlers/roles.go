@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/extract"
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/auth"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// checkRole returns a forbidden error if a role does not include the required role.
+// The App Engine admins are site admins.
+//
+func checkRole(c appengine.Context, desc string, u *mdl.User, role, required mdl.Role) error {
+	if auth.IsGonawinAdmin(c) {
+		role = mdl.RoleSiteAdmin
+	}
+	if !role.Includes(required) {
+		log.Infof(c, "%s user %v is %v, %v required", desc, u.Id, role, required)
+		return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeRoleForbiden)}
+	}
+	return nil
+}
+
+// TeamRole runs the function pass by parameter if the user has the required role on the team
+// given by the 'teamId' route param.
+// Use it inside Authorized or KeyAuthorized: authorized(TeamRole(mdl.RoleTeamAdmin, f)).
+//
+func TeamRole(role mdl.Role, f func(w http.ResponseWriter, r *http.Request, u *mdl.User) error) func(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	return func(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+		c := appengine.NewContext(r)
+		desc := "Team Role:"
+
+		team, err := extract.NewContext(c, desc, r).Team()
+		if err != nil {
+			return err
+		}
+		if err = checkRole(c, desc, u, team.RoleOf(u), role); err != nil {
+			return err
+		}
+		return f(w, r, u)
+	}
+}
+
+// TournamentRole runs the function pass by parameter if the user has the required role on the tournament
+// given by the 'tournamentId' route param.
+// Use it inside Authorized or KeyAuthorized: authorized(TournamentRole(mdl.RoleTournamentAdmin, f)).
+//
+func TournamentRole(role mdl.Role, f func(w http.ResponseWriter, r *http.Request, u *mdl.User) error) func(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	return func(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+		c := appengine.NewContext(r)
+		desc := "Tournament Role:"
+
+		tournament, err := extract.NewContext(c, desc, r).Tournament()
+		if err != nil {
+			return err
+		}
+		if err = checkRole(c, desc, u, tournament.RoleOf(u), role); err != nil {
+			return err
+		}
+		return f(w, r, u)
+	}
+}
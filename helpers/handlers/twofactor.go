@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"appengine"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/auth"
+	"github.com/taironas/gonawin/helpers/log"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// SecondFactor runs the function pass by parameter if the second factor of the user was verified recently on the session of the request.
+// Users who did not enable two-factor authentication pass, unless it is required in the config file.
+// API keys have no session, a key passes only if it was created while two-factor authentication was enabled,
+// as the creation of a key is behind this check.
+// Use it inside the wrappers of the sensitive admin routes: adminAuthorized(SecondFactor(f)).
+//
+func SecondFactor(f func(w http.ResponseWriter, r *http.Request, u *mdl.User) error) func(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+	return func(w http.ResponseWriter, r *http.Request, u *mdl.User) error {
+		if auth.KOfflineMode {
+			return f(w, r, u)
+		}
+
+		c := appengine.NewContext(r)
+		desc := "Second Factor:"
+
+		token := auth.AuthorizationToken(r)
+		if !mdl.TwoFactorEnabled(c, u.Id) {
+			if auth.TwoFactorRequired() {
+				log.Infof(c, "%s user %v has not enabled two-factor authentication", desc, u.Id)
+				return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeTwoFactorMustEnable)}
+			}
+			return f(w, r, u)
+		}
+
+		if mdl.IsAPIKey(token) {
+			k, err := mdl.APIKeyByKey(c, token)
+			if err != nil || !k.SecondFactor {
+				log.Infof(c, "%s api key of user %v created without second factor", desc, u.Id)
+				return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeTwoFactorAPIKey)}
+			}
+			return f(w, r, u)
+		}
+
+		s, err := mdl.SessionByToken(c, token)
+		if err != nil || !s.SecondFactorRecent(time.Now()) {
+			log.Infof(c, "%s no recent second factor for user %v", desc, u.Id)
+			return &helpers.Forbidden{Err: errors.New(helpers.ErrorCodeTwoFactorRequired)}
+		}
+		return f(w, r, u)
+	}
+}
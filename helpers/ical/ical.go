@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package ical provides a way to write calendars in the iCalendar format (RFC 5545).
+//
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	dateForm     = "20060102"
+	dateTimeForm = "20060102T150405Z"
+	maxLineLen   = 75 // maximum length of a content line in octets, without the line break.
+)
+
+// Calendar represents a VCALENDAR object.
+//
+type Calendar struct {
+	ProdID string // identifier of the product that created the calendar.
+	Name   string // name of the calendar, displayed by calendar applications.
+	Events []Event
+}
+
+// Event represents a VEVENT component.
+// When AllDay is true only the date part of Start and End is used.
+//
+type Event struct {
+	UID         string
+	Stamp       time.Time // date the event was created.
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Summary     string
+	Location    string
+	Description string
+	Alarms      []Alarm
+}
+
+// Alarm represents a VALARM component, displayed at the Trigger date.
+//
+type Alarm struct {
+	Trigger     time.Time
+	Description string
+}
+
+// Encode writes the calendar in the iCalendar format.
+//
+func (cal *Calendar) Encode(w io.Writer) error {
+	e := encoder{w: bufio.NewWriter(w)}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", cal.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if len(cal.Name) > 0 {
+		e.line("X-WR-CALNAME", escape(cal.Name))
+	}
+	for _, ev := range cal.Events {
+		e.event(ev)
+	}
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(ev Event) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", ev.UID)
+	e.line("DTSTAMP", ev.Stamp.UTC().Format(dateTimeForm))
+	if ev.AllDay {
+		e.line("DTSTART;VALUE=DATE", ev.Start.Format(dateForm))
+		e.line("DTEND;VALUE=DATE", ev.End.Format(dateForm))
+	} else {
+		e.line("DTSTART", ev.Start.UTC().Format(dateTimeForm))
+		e.line("DTEND", ev.End.UTC().Format(dateTimeForm))
+	}
+	e.line("SUMMARY", escape(ev.Summary))
+	if len(ev.Location) > 0 {
+		e.line("LOCATION", escape(ev.Location))
+	}
+	if len(ev.Description) > 0 {
+		e.line("DESCRIPTION", escape(ev.Description))
+	}
+	for _, a := range ev.Alarms {
+		e.line("BEGIN", "VALARM")
+		e.line("ACTION", "DISPLAY")
+		e.line("TRIGGER;VALUE=DATE-TIME", a.Trigger.UTC().Format(dateTimeForm))
+		e.line("DESCRIPTION", escape(a.Description))
+		e.line("END", "VALARM")
+	}
+	e.line("END", "VEVENT")
+}
+
+// line writes a content line, folded at 75 octets and terminated by CRLF.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	l := name + ":" + value
+	for len(l) > maxLineLen {
+		// do not split a multi-byte UTF-8 character.
+		i := maxLineLen
+		for i > 0 && l[i]&0xC0 == 0x80 {
+			i--
+		}
+		if _, e.err = fmt.Fprintf(e.w, "%s\r\n", l[:i]); e.err != nil {
+			return
+		}
+		// continuation lines start with a space which counts in the line length.
+		l = " " + l[i:]
+	}
+	_, e.err = fmt.Fprintf(e.w, "%s\r\n", l)
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
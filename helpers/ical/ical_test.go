@@ -0,0 +1,84 @@
+package ical
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestEncode(t *testing.T) {
+	stamp := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	tests := []struct {
+		name   string
+		golden string
+		cal    Calendar
+	}{
+		{
+			name:   "empty calendar",
+			golden: "testdata/empty.ics",
+			cal:    Calendar{ProdID: "-//gonawin//test//EN"},
+		},
+		{
+			name:   "calendar with events",
+			golden: "testdata/events.ics",
+			cal: Calendar{
+				ProdID: "-//gonawin//test//EN",
+				Name:   "2018 FIFA World Cup",
+				Events: []Event{
+					{
+						UID:         "1@gonawin.com",
+						Stamp:       stamp,
+						Start:       time.Date(2018, 6, 14, 17, 0, 0, 0, paris),
+						End:         time.Date(2018, 6, 14, 19, 0, 0, 0, paris),
+						Summary:     "Russia - Saudi Arabia",
+						Location:    "Luzhniki Stadium, Moscow",
+						Description: "Opening match; a very long description that has to be folded because it is longer than seventy five octets\nSecond line.",
+						Alarms: []Alarm{
+							{time.Date(2018, 6, 14, 14, 0, 0, 0, time.UTC), "Predictions close at kickoff."},
+						},
+					},
+					{
+						UID:     "2@gonawin.com",
+						Stamp:   stamp,
+						Start:   time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC),
+						End:     time.Date(2018, 6, 16, 0, 0, 0, 0, time.UTC),
+						AllDay:  true,
+						Summary: "Égypte - Uruguay, Ekaterinbourg Arena, Iekaterinbourg, Russie, Coupe du monde",
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.cal.Encode(&buf); err != nil {
+			t.Errorf("TestEncode(%q): got error %v", test.name, err)
+			continue
+		}
+		if *update {
+			if err := ioutil.WriteFile(test.golden, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := ioutil.ReadFile(test.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("TestEncode(%q): got\n%s\nwanted\n%s", test.name, buf.String(), want)
+		}
+		for _, l := range strings.Split(buf.String(), "\r\n") {
+			if len(l) > maxLineLen {
+				t.Errorf("TestEncode(%q): line longer than %d octets: %q", test.name, maxLineLen, l)
+			}
+		}
+	}
+}
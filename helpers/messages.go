@@ -23,17 +23,40 @@ const (
 	ErrorCodeInternal          = "Internal error"
 	ErrorCodeNotFound          = "Not Found"
 	ErrorCodeNameCannotBeEmpty = "Name field cannot be empty"
+	ErrorCodeCannotSearch      = "Something went wrong, we are unable to perform search query"
+	ErrorCodeInvalidCursor     = "Invalid cursor"
+
+	// search index
+	ErrorCodeSearchRebuildNotFound    = "Rebuild of the search index not found"
+	ErrorCodeSearchRebuildCannotStart = "Sorry, we were unable to start the rebuild of the search index"
+
+	// consistency
+	ErrorCodeConsistencyCheckNotFound    = "Consistency check not found"
+	ErrorCodeConsistencyCheckCannotStart = "Sorry, we were unable to start the consistency check"
 
 	// sessions
-	ErrorCodeSessionsAccessTokenNotValid      = "Access token is not valid"
-	ErrorCodeSessionsForbiden                 = "You are not authorized to log in to gonawin"
-	ErrorCodeSessionsUnableToSignin           = "Error occurred during signin process"
-	ErrorCodeSessionsCannotGetTempCredentials = "Error getting temporary credentials"
-	ErrorCodeSessionsCannotSetSecretValue     = "Error setting 'secret' value"
-	ErrorCodeSessionsCannotGetSecretValue     = "Error getting 'secret' value"
-	ErrorCodeSessionsCannotGetRequestToken    = "Error getting request token"
-	ErrorCodeSessionsCannotGetUserInfo        = "Error getting user info from Twitter"
-	ErrorCodeSessionsCannotGetGoogleLoginURL  = "Error getting Google accounts login URL"
+	ErrorCodeSessionsAccessTokenNotValid     = "Access token is not valid"
+	ErrorCodeSessionsForbiden                = "You are not authorized to log in to gonawin"
+	ErrorCodeSessionsUnableToSignin          = "Error occurred during signin process"
+	ErrorCodeSessionsCannotGetGoogleLoginURL = "Error getting Google accounts login URL"
+	ErrorCodeSessionsExpired                 = "Your session has expired, please sign in again"
+	ErrorCodeSessionsCannotCreate            = "Sorry, we were unable to create your session"
+	ErrorCodeSessionsCannotRefresh           = "Sorry, we were unable to refresh your session"
+	ErrorCodeSessionsNotFound                = "Session not found"
+	ErrorCodeSessionsCannotRevoke            = "Sorry, we were unable to revoke the session"
+	ErrorCodeSessionsEmailInvalid            = "Email is not valid"
+	ErrorCodeSessionsPasswordInvalid         = "Password must be between 3 and 20 characters"
+	ErrorCodeSessionsAccountExists           = "An account already exists for this email"
+	ErrorCodeSessionsCannotRegister          = "Sorry, we were unable to create your account"
+	ErrorCodeSessionsWrongCredentials        = "Wrong email or password"
+	ErrorCodeSessionsEmailNotVerified        = "Please verify your email before signing in"
+	ErrorCodeSessionsTokenInvalid            = "This link is not valid or has expired"
+	ErrorCodeSessionsCannotSendEmail         = "Sorry, we were unable to send you an email"
+	ErrorCodeSessionsMagicLinkDisabled       = "Sign in by email is not enabled"
+	ErrorCodeSessionsProviderNotFound        = "Sign in provider not found"
+	ErrorCodeSessionsCannotStartLogin        = "Sorry, we were unable to start signing you in"
+	ErrorCodeSessionsLoginStateInvalid       = "Your sign in has expired, please try again"
+	ErrorCodeSessionsCannotGetIdentity       = "Error getting your identity from the provider"
 
 	// users
 	ErrorCodeUserNotFound                      = "User not found"
@@ -79,12 +102,62 @@ const (
 	ErrorCodeCannotSetPrediction              = "Something went wrong, unable to set prediction"
 	ErrorCodeNotAllowedToSetPrediction        = "You have to join the tournament to be able to set a predict for this match"
 	ErrorCodeTeamsCannotUpdate                = "Could not update teams"
+	ErrorCodeScheduleNotFound                 = "No schedule found, please send a schedule in CSV format"
 
 	// invite
 	ErrorCodeInviteNoEmailAddr     = "No email address has been entered"
 	ErrorCodeInviteEmailsInvalid   = "Emails list is not properly formatted"
 	ErrorCodeInviteEmailCannotSend = "Sorry, we were unable to send the Email"
 
+	// notification
+	ErrorCodeNotificationNotFound          = "Notification not found"
+	ErrorCodeNotificationCannotUpdate      = "Something went wrong, unable to update notifications"
+	ErrorCodeNotificationPreferenceInvalid = "Unknown notification type or channel"
+
+	// webhook
+	ErrorCodeWebhookNotFound      = "Webhook not found"
+	ErrorCodeWebhookInvalid       = "Webhook url or events are not valid"
+	ErrorCodeWebhookCannotCreate  = "Sorry, we were unable to create the webhook"
+	ErrorCodeWebhookCannotDelete  = "Sorry, we were unable to delete the webhook"
+	ErrorCodeWebhookCannotDeliver = "Sorry, we were unable to deliver the webhook"
+
+	// roles
+	ErrorCodeRoleForbiden = "You are not allowed to do this action"
+
+	// api keys
+	ErrorCodeAPIKeyNotFound     = "API key not found"
+	ErrorCodeAPIKeyInvalid      = "API key name or scopes are not valid"
+	ErrorCodeAPIKeyCannotCreate = "Sorry, we were unable to create the API key"
+	ErrorCodeAPIKeyCannotDelete = "Sorry, we were unable to revoke the API key"
+	ErrorCodeAPIKeyForbiden     = "You are not allowed to manage the API keys of this user"
+	ErrorCodeAPIKeyScope        = "This API key is not allowed to access this resource"
+	ErrorCodeAPIKeyScopeAdmin   = "Only the admins of a tournament can create an API key with the admin:tournament scope"
+
+	// slack
+	ErrorCodeSlackInvalidRequest       = "The Slack request is not valid"
+	ErrorCodeSlackCannotCreateLinkCode = "Sorry, we were unable to create a code to link your Slack account"
+	ErrorCodeSlackCannotLink           = "Sorry, we were unable to link your Slack account"
+	ErrorCodeSlackMatchLocked          = "Predictions are closed for this match"
+
+	// identities
+	ErrorCodeIdentityLinked       = "This account is already linked to another user"
+	ErrorCodeIdentityNotFound     = "This sign in provider is not linked to your account"
+	ErrorCodeIdentityLast         = "You cannot unlink your last way to sign in"
+	ErrorCodeIdentityCannotUnlink = "Sorry, we were unable to unlink this sign in provider"
+	ErrorCodeIdentityForbiden     = "You are not allowed to manage the sign in providers of this user"
+	ErrorCodeUserCannotMerge      = "Sorry, we were unable to merge these users"
+
+	// two-factor authentication
+	ErrorCodeTwoFactorRequired     = "Please confirm this action with your authentication code"
+	ErrorCodeTwoFactorMustEnable   = "Please enable two-factor authentication to do this action"
+	ErrorCodeTwoFactorInvalid      = "The authentication code is not valid"
+	ErrorCodeTwoFactorEnabled      = "Two-factor authentication is already enabled"
+	ErrorCodeTwoFactorDisabled     = "Two-factor authentication is not enabled"
+	ErrorCodeTwoFactorNoSession    = "Please sign in again to use two-factor authentication"
+	ErrorCodeTwoFactorCannotUpdate = "Sorry, we were unable to update your two-factor authentication"
+	ErrorCodeTwoFactorLocked       = "Too many invalid authentication codes, please try again in 15 minutes"
+	ErrorCodeTwoFactorAPIKey       = "This API key was created before two-factor authentication was enabled, please create a new one"
+
 	// relations
 
 )
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package password
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// test vectors of RFC 7914 section 11.
+	tests := []struct {
+		password string
+		salt     string
+		iter     int
+		want     string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(test.password), []byte(test.salt), test.iter, len(test.want)/2))
+		if got != test.want {
+			t.Errorf("TestPBKDF2(%q): got %v wanted %v", test.password, got, test.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hash, err := Hash("secret password")
+	if err != nil {
+		t.Fatalf("TestVerify: unable to hash password: %v", err)
+	}
+
+	other, _ := Hash("secret password")
+	if other == hash {
+		t.Errorf("TestVerify: two hashes of a password are equal, the salt is not random")
+	}
+
+	tests := []struct {
+		title    string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"right password", hash, "secret password", true},
+		{"wrong password", hash, "secret passwore", false},
+		{"empty password", hash, "", false},
+		{"invalid hash", "secret password", "secret password", false},
+		{"unknown scheme", "md5$1$c2FsdA$a2V5", "secret password", false},
+	}
+	for _, test := range tests {
+		if got := Verify(test.hash, test.password); got != test.want {
+			t.Errorf("TestVerify(%q): got %v wanted %v", test.title, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	gwconfig "github.com/taironas/gonawin/config"
+)
+
+// defaultFields are the user info fields of an identity, they can be renamed in the config file.
+var defaultFields = map[string]string{
+	"id":       "id",
+	"email":    "email",
+	"name":     "name",
+	"username": "username",
+	"audience": "aud", // field of the token info holding the client of the token.
+}
+
+// OAuth2 is a provider using the OAuth 2.0 authorization code flow with PKCE.
+// The identity of the user is read from the user info endpoint.
+//
+type OAuth2 struct {
+	cfg    gwconfig.Provider
+	fields map[string]string
+}
+
+// NewOAuth2 returns an OAuth 2.0 provider.
+//
+func NewOAuth2(cfg gwconfig.Provider) *OAuth2 {
+	fields := make(map[string]string)
+	for k, v := range defaultFields {
+		fields[k] = v
+	}
+	for k, v := range cfg.Fields {
+		fields[k] = v
+	}
+	return &OAuth2{cfg: cfg, fields: fields}
+}
+
+// Name returns the name of the provider.
+//
+func (p *OAuth2) Name() string {
+	return p.cfg.Name
+}
+
+// authURL returns the URL of the authorization endpoint for a login.
+func authURL(endpoint string, cfg gwconfig.Provider, login *Login, callbackURL string, extra url.Values) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", cfg.ClientId)
+	v.Set("redirect_uri", callbackURL)
+	v.Set("state", login.State)
+	v.Set("code_challenge", codeChallenge(login.Verifier))
+	v.Set("code_challenge_method", "S256")
+	if len(cfg.Scopes) > 0 {
+		v.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	for k := range extra {
+		v.Set(k, extra.Get(k))
+	}
+
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + v.Encode()
+}
+
+// Begin returns the URL of the authorization endpoint.
+//
+func (p *OAuth2) Begin(client *http.Client, login *Login, callbackURL string) (string, error) {
+	return authURL(p.cfg.AuthURL, p.cfg, login, callbackURL, nil), nil
+}
+
+// State returns the 'state' param of the callback.
+//
+func (p *OAuth2) State(params url.Values) string {
+	return params.Get("state")
+}
+
+// tokenResponse is the response of a token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// exchange gets the tokens of the code of a callback from the token endpoint.
+func exchange(client *http.Client, endpoint string, cfg gwconfig.Provider, login *Login, callbackURL string, params url.Values) (*tokenResponse, error) {
+	if len(params.Get("error")) > 0 {
+		return nil, fmt.Errorf("%v: %s", ErrCallback, params.Get("error"))
+	}
+	if len(params.Get("code")) == 0 {
+		return nil, ErrCallback
+	}
+
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", params.Get("code"))
+	v.Set("redirect_uri", callbackURL)
+	v.Set("client_id", cfg.ClientId)
+	v.Set("client_secret", cfg.ClientSecret)
+	v.Set("code_verifier", login.Verifier)
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var t tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return nil, fmt.Errorf("provider: unable to decode token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(t.Error) > 0 {
+		return nil, fmt.Errorf("provider: token endpoint returned %d %s", resp.StatusCode, t.Error)
+	}
+	return &t, nil
+}
+
+// userInfo gets the user of an access token as JSON.
+func userInfo(client *http.Client, endpoint, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider: user info endpoint returned %d", resp.StatusCode)
+	}
+
+	var info map[string]interface{}
+	d := json.NewDecoder(resp.Body)
+	d.UseNumber()
+	if err = d.Decode(&info); err != nil {
+		return nil, fmt.Errorf("provider: unable to decode user info: %v", err)
+	}
+	return info, nil
+}
+
+// checkAudience checks with a token info endpoint that an access token was issued to a client.
+// The client is read from the given fields of the token info, an access token without
+// token info endpoint is rejected.
+func checkAudience(client *http.Client, endpoint, accessToken, clientID string, fields ...string) error {
+	if len(endpoint) == 0 || len(clientID) == 0 {
+		return ErrAudience
+	}
+
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	resp, err := client.Get(endpoint + sep + url.Values{"access_token": {accessToken}}.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider: token info endpoint returned %d", resp.StatusCode)
+	}
+
+	var info map[string]interface{}
+	d := json.NewDecoder(resp.Body)
+	d.UseNumber()
+	if err = d.Decode(&info); err != nil {
+		return fmt.Errorf("provider: unable to decode token info: %v", err)
+	}
+	for _, f := range fields {
+		if len(f) > 0 && field(info, f) == clientID {
+			return nil
+		}
+	}
+	return ErrAudience
+}
+
+// field returns a field of the user info as a string.
+func field(info map[string]interface{}, name string) string {
+	switch v := info[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+// identity returns the identity of a user info.
+func (p *OAuth2) identity(info map[string]interface{}) (*Identity, error) {
+	id := &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       field(info, p.fields["id"]),
+		Email:         field(info, p.fields["email"]),
+		EmailVerified: p.cfg.TrustEmail,
+		Name:          field(info, p.fields["name"]),
+		Username:      field(info, p.fields["username"]),
+	}
+	if len(id.Subject) == 0 {
+		return nil, ErrIdentity
+	}
+	return id, nil
+}
+
+// Complete exchanges the code of the callback and gets the user from the user info endpoint.
+//
+func (p *OAuth2) Complete(client *http.Client, login *Login, callbackURL string, params url.Values) (*Identity, error) {
+	t, err := exchange(client, p.cfg.TokenURL, p.cfg, login, callbackURL, params)
+	if err != nil {
+		return nil, err
+	}
+	info, err := userInfo(client, p.cfg.UserInfoURL, t.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	return p.identity(info)
+}
+
+// VerifyToken checks the access token was issued to the client with the token info endpoint,
+// then gets the user of the access token from the user info endpoint.
+//
+func (p *OAuth2) VerifyToken(client *http.Client, accessToken string) (*Identity, error) {
+	if err := checkAudience(client, p.cfg.TokenInfoURL, accessToken, p.cfg.ClientId, p.fields["audience"], "azp"); err != nil {
+		return nil, err
+	}
+	info, err := userInfo(client, p.cfg.UserInfoURL, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return p.identity(info)
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	gwconfig "github.com/taironas/gonawin/config"
+)
+
+// OpenID Connect errors.
+//
+var (
+	ErrIDToken = errors.New("provider: invalid ID token")
+	ErrNonce   = errors.New("provider: ID token nonce does not match")
+)
+
+// discovery is the OpenID Connect provider metadata used to sign in.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// OIDC is an OpenID Connect provider, its endpoints are discovered from its issuer.
+//
+type OIDC struct {
+	cfg gwconfig.Provider
+	now func() time.Time
+
+	mu        sync.Mutex
+	discovery *discovery
+}
+
+// NewOIDC returns an OpenID Connect provider.
+// The default scopes are "openid email profile".
+//
+func NewOIDC(cfg gwconfig.Provider) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDC{cfg: cfg, now: time.Now}
+}
+
+// Name returns the name of the provider.
+//
+func (p *OIDC) Name() string {
+	return p.cfg.Name
+}
+
+// discover gets the metadata of the provider, they are fetched once.
+func (p *OIDC) discover(client *http.Client) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	resp, err := client.Get(strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider: discovery of %s returned %d", p.cfg.Issuer, resp.StatusCode)
+	}
+
+	var d discovery
+	if err = json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("provider: unable to decode discovery of %s: %v", p.cfg.Issuer, err)
+	}
+	if d.Issuer != p.cfg.Issuer || len(d.AuthorizationEndpoint) == 0 || len(d.TokenEndpoint) == 0 {
+		return nil, fmt.Errorf("provider: invalid discovery of %s", p.cfg.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// Begin returns the URL of the authorization endpoint, the nonce of the login is sent in the request.
+//
+func (p *OIDC) Begin(client *http.Client, login *Login, callbackURL string) (string, error) {
+	d, err := p.discover(client)
+	if err != nil {
+		return "", err
+	}
+	return authURL(d.AuthorizationEndpoint, p.cfg, login, callbackURL, url.Values{"nonce": {login.Nonce}}), nil
+}
+
+// State returns the 'state' param of the callback.
+//
+func (p *OIDC) State(params url.Values) string {
+	return params.Get("state")
+}
+
+// claims are the claims of an ID token used to sign in.
+type claims struct {
+	Issuer            string      `json:"iss"`
+	Subject           string      `json:"sub"`
+	Audience          interface{} `json:"aud"` // a string or an array of strings.
+	Expires           int64       `json:"exp"`
+	Nonce             string      `json:"nonce"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // a boolean, or a string for some providers.
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+}
+
+// hasAudience indicates if the claims are intended for a client.
+func (cl *claims) hasAudience(clientID string) bool {
+	switch aud := cl.Audience.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseIDToken returns the claims of an ID token and checks them.
+// The signature is not checked: the token is received directly from the token endpoint over TLS,
+// as allowed by OpenID Connect Core 1.0, section 3.1.3.7.
+func (p *OIDC) parseIDToken(token, issuer, nonce string) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrIDToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, ErrIDToken
+	}
+
+	var cl claims
+	if err = json.Unmarshal(payload, &cl); err != nil {
+		return nil, ErrIDToken
+	}
+	if cl.Issuer != issuer || !cl.hasAudience(p.cfg.ClientId) || len(cl.Subject) == 0 {
+		return nil, ErrIDToken
+	}
+	if !p.now().Before(time.Unix(cl.Expires, 0)) {
+		return nil, ErrIDToken
+	}
+	if cl.Nonce != nonce {
+		return nil, ErrNonce
+	}
+	return &cl, nil
+}
+
+// identity returns the identity of standard claims.
+func (p *OIDC) identity(cl *claims) *Identity {
+	verified := false
+	switch v := cl.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+	return &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       cl.Subject,
+		Email:         cl.Email,
+		EmailVerified: verified,
+		Name:          cl.Name,
+		Username:      cl.PreferredUsername,
+	}
+}
+
+// Complete exchanges the code of the callback and gets the user from the ID token.
+//
+func (p *OIDC) Complete(client *http.Client, login *Login, callbackURL string, params url.Values) (*Identity, error) {
+	d, err := p.discover(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var t *tokenResponse
+	if t, err = exchange(client, d.TokenEndpoint, p.cfg, login, callbackURL, params); err != nil {
+		return nil, err
+	}
+
+	var cl *claims
+	if cl, err = p.parseIDToken(t.IDToken, d.Issuer, login.Nonce); err != nil {
+		return nil, err
+	}
+	return p.identity(cl), nil
+}
+
+// VerifyToken checks the access token was issued to the client with the token info endpoint,
+// from its 'aud' or 'azp' field, then gets the user of the access token from the user info endpoint.
+//
+func (p *OIDC) VerifyToken(client *http.Client, accessToken string) (*Identity, error) {
+	if err := checkAudience(client, p.cfg.TokenInfoURL, accessToken, p.cfg.ClientId, "aud", "azp"); err != nil {
+		return nil, err
+	}
+
+	d, err := p.discover(client)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.UserInfoEndpoint) == 0 {
+		return nil, fmt.Errorf("provider: %s has no user info endpoint", p.cfg.Issuer)
+	}
+
+	var info map[string]interface{}
+	if info, err = userInfo(client, d.UserInfoEndpoint, accessToken); err != nil {
+		return nil, err
+	}
+
+	cl := claims{
+		Subject:           field(info, "sub"),
+		Email:             field(info, "email"),
+		EmailVerified:     field(info, "email_verified"),
+		Name:              field(info, "name"),
+		PreferredUsername: field(info, "preferred_username"),
+	}
+	if len(cl.Subject) == 0 {
+		return nil, ErrIdentity
+	}
+	return p.identity(&cl), nil
+}
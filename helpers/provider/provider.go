@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package provider provides the identity providers users sign in with: OAuth 2.0, OpenID Connect and Twitter.
+//
+// A login starts with Begin, which returns the URL of the provider the user is redirected to,
+// and ends with Complete, called with the params of the callback. The Login filled by Begin must be
+// stored by the caller until the callback and used once.
+//
+package provider
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	gwconfig "github.com/taironas/gonawin/config"
+)
+
+// Provider errors.
+//
+var (
+	ErrUnknownType = errors.New("provider: unknown provider type")
+	ErrCallback    = errors.New("provider: invalid callback")
+	ErrIdentity    = errors.New("provider: invalid identity")
+	ErrAudience    = errors.New("provider: access token not issued to the client")
+)
+
+// Identity is a user as returned by a provider.
+//
+type Identity struct {
+	Provider      string
+	Subject       string // id of the user at the provider.
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// Login holds the state of a login between its start and the callback of the provider.
+//
+type Login struct {
+	State    string // value sent to the provider and returned in the callback, it identifies the login.
+	Nonce    string // OpenID Connect nonce, checked in the ID token.
+	Verifier string // PKCE code verifier.
+	Secret   string // OAuth 1.0a secret of the temporary credentials.
+}
+
+// Provider is an identity provider.
+//
+type Provider interface {
+	// Name returns the name of the provider in the routes.
+	Name() string
+	// Begin starts a login and returns the URL the user is redirected to.
+	// The login may be updated by the provider and must be stored until the callback.
+	Begin(client *http.Client, login *Login, callbackURL string) (string, error)
+	// State returns the state of the login from the params of the callback.
+	State(params url.Values) string
+	// Complete ends a login with the params of the callback and returns the identity of the user.
+	Complete(client *http.Client, login *Login, callbackURL string, params url.Values) (*Identity, error)
+}
+
+// TokenVerifier is a provider which can get the identity of an access token obtained by a client.
+// The access token must have been issued to the client of the provider.
+//
+type TokenVerifier interface {
+	VerifyToken(client *http.Client, accessToken string) (*Identity, error)
+}
+
+// randomString returns a random string of n bytes encoded in base64 url.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewLogin returns a login with a random state, nonce and PKCE code verifier.
+//
+func NewLogin() (*Login, error) {
+	var l Login
+	var err error
+	if l.State, err = randomString(32); err != nil {
+		return nil, err
+	}
+	if l.Nonce, err = randomString(32); err != nil {
+		return nil, err
+	}
+	if l.Verifier, err = randomString(32); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// codeChallenge returns the S256 PKCE code challenge of a code verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// New returns the provider of a configuration.
+//
+func New(cfg gwconfig.Provider) (Provider, error) {
+	if len(cfg.Name) == 0 {
+		return nil, errors.New("provider: name is missing")
+	}
+	switch cfg.Type {
+	case "oidc":
+		return NewOIDC(cfg), nil
+	case "oauth2":
+		return NewOAuth2(cfg), nil
+	case "twitter":
+		return NewTwitter(cfg), nil
+	}
+	return nil, fmt.Errorf("%v: %q", ErrUnknownType, cfg.Type)
+}
+
+// Registry holds the providers of the config file.
+//
+type Registry struct {
+	providers map[string]Provider
+	names     []string // providers users can be redirected to, in the order of the config file.
+}
+
+// legacyProviders returns the configuration of the providers set with the legacy 'twitter', 'googlePlus' and 'facebook' keys of the config file.
+// Google and Facebook are only used to verify the access tokens of their client libraries.
+func legacyProviders(config *gwconfig.GwConfig) (login, tokens []gwconfig.Provider) {
+	if len(config.Twitter.Token) > 0 {
+		login = append(login, gwconfig.Provider{Name: "twitter", Type: "twitter", ClientId: config.Twitter.Token, ClientSecret: config.Twitter.Secret})
+	}
+	if len(config.GooglePlus.ClientId) > 0 {
+		tokens = append(tokens, gwconfig.Provider{Name: "google", Type: "oidc", ClientId: config.GooglePlus.ClientId, Issuer: "https://accounts.google.com",
+			TokenInfoURL: "https://oauth2.googleapis.com/tokeninfo"})
+	}
+	if len(config.Facebook.AppId) > 0 {
+		// the app endpoint returns the app an access token was issued to.
+		tokens = append(tokens, gwconfig.Provider{Name: "facebook", Type: "oauth2", ClientId: config.Facebook.AppId, UserInfoURL: "https://graph.facebook.com/me?fields=id,name,email",
+			TokenInfoURL: "https://graph.facebook.com/app", Fields: map[string]string{"audience": "id"}})
+	}
+	return
+}
+
+// NewRegistry returns the providers of a config file.
+// The providers with an invalid configuration are skipped and their errors are returned.
+//
+func NewRegistry(config *gwconfig.GwConfig) (*Registry, []error) {
+	r := &Registry{providers: make(map[string]Provider)}
+	var errs []error
+
+	add := func(cfg gwconfig.Provider, login bool) {
+		if _, ok := r.providers[cfg.Name]; ok {
+			return
+		}
+		p, err := New(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		r.providers[cfg.Name] = p
+		if login {
+			r.names = append(r.names, cfg.Name)
+		}
+	}
+
+	login, tokens := legacyProviders(config)
+	for _, cfg := range config.Providers {
+		add(cfg, true)
+	}
+	for _, cfg := range login {
+		add(cfg, true)
+	}
+	for _, cfg := range tokens {
+		add(cfg, false)
+	}
+	return r, errs
+}
+
+// Get returns the provider of a name.
+//
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names returns the names of the providers users can sign in with.
+//
+func (r *Registry) Names() []string {
+	return r.names
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gwconfig "github.com/taironas/gonawin/config"
+)
+
+func TestCodeChallenge(t *testing.T) {
+	// test vector of RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got := codeChallenge(verifier); got != want {
+		t.Errorf("TestCodeChallenge: got %v wanted %v", got, want)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	config := &gwconfig.GwConfig{
+		Twitter:    gwconfig.Twitter{Token: "token", Secret: "secret"},
+		GooglePlus: gwconfig.GooglePlus{ClientId: "google"},
+		Providers: []gwconfig.Provider{
+			{Name: "github", Type: "oauth2"},
+			{Name: "microsoft", Type: "oidc"},
+			{Name: "unknown", Type: "saml"},
+		},
+	}
+	r, errs := NewRegistry(config)
+	if len(errs) != 1 {
+		t.Errorf("TestNewRegistry: got %v errors wanted 1", len(errs))
+	}
+	if got, want := strings.Join(r.Names(), ","), "github,microsoft,twitter"; got != want {
+		t.Errorf("TestNewRegistry: got names %v wanted %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		found bool
+	}{
+		{"github", true},
+		{"twitter", true},
+		{"google", true},
+		{"facebook", false},
+		{"unknown", false},
+	}
+	for _, test := range tests {
+		if _, ok := r.Get(test.name); ok != test.found {
+			t.Errorf("TestNewRegistry(%q): got %v wanted %v", test.name, ok, test.found)
+		}
+	}
+}
+
+// idToken returns an unsigned ID token of some claims.
+func idToken(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+}
+
+// newServer returns a provider server, its token endpoint returns the ID token of claims
+// and its user info endpoint returns the user info. The access token of the client is
+// "theaccesstoken", "otheraccesstoken" is issued to another client.
+func newServer(t *testing.T, claims map[string]interface{}, info string) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":%q,"token_endpoint":%q,"userinfo_endpoint":%q}`,
+			server.URL, server.URL+"/authorize", server.URL+"/token", server.URL+"/userinfo")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "thecode" || codeChallenge(r.FormValue("code_verifier")) != codeChallenge("theverifier") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"theaccesstoken","id_token":%q}`, idToken(claims))
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer theaccesstoken" && auth != "Bearer otheraccesstoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, info)
+	})
+	mux.HandleFunc("/tokeninfo", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("access_token") {
+		case "theaccesstoken":
+			fmt.Fprint(w, `{"aud":"theclient","azp":"theclient","id":"theclient"}`)
+		case "otheraccesstoken":
+			fmt.Fprint(w, `{"aud":"otherclient","azp":"otherclient","id":"otherclient"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func TestOIDC(t *testing.T) {
+	claims := map[string]interface{}{"sub": "1234", "email": "john@example.com", "email_verified": true, "name": "John Smith", "nonce": "thenonce"}
+	server := newServer(t, claims, `{"sub":"1234","email":"john@example.com","email_verified":"true","name":"John Smith"}`)
+	defer server.Close()
+
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+	claims["iss"] = server.URL
+	claims["aud"] = "theclient"
+	claims["exp"] = now.Add(time.Hour).Unix()
+
+	p := NewOIDC(gwconfig.Provider{Name: "test", ClientId: "theclient", Issuer: server.URL})
+	p.now = func() time.Time { return now }
+
+	login := &Login{State: "thestate", Nonce: "thenonce", Verifier: "theverifier"}
+	authURL, err := p.Begin(http.DefaultClient, login, "https://gonawin.com/j/auth/oauth/test/callback")
+	if err != nil {
+		t.Fatalf("TestOIDC: unable to begin login: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if u.Path != "/authorize" || u.Query().Get("state") != "thestate" || u.Query().Get("nonce") != "thenonce" || u.Query().Get("code_challenge") != codeChallenge("theverifier") {
+		t.Errorf("TestOIDC: got authorization URL %v", authURL)
+	}
+
+	tests := []struct {
+		title string
+		nonce string
+		err   error
+	}{
+		{"valid login", "thenonce", nil},
+		{"wrong nonce", "othernonce", ErrNonce},
+	}
+	for _, test := range tests {
+		login.Nonce = test.nonce
+		id, err := p.Complete(http.DefaultClient, login, "", url.Values{"code": {"thecode"}})
+		if err != test.err {
+			t.Errorf("TestOIDC(%q): got %v wanted %v", test.title, err, test.err)
+		}
+		if err == nil && (id.Subject != "1234" || id.Email != "john@example.com" || !id.EmailVerified || id.Provider != "test") {
+			t.Errorf("TestOIDC(%q): got identity %+v", test.title, id)
+		}
+	}
+
+	login.Nonce = "thenonce"
+	if _, err = p.Complete(http.DefaultClient, login, "", url.Values{"code": {"othercode"}}); err == nil {
+		t.Errorf("TestOIDC(%q): got no error wanted an error", "wrong code")
+	}
+
+	// the ID token must be issued for the client and not expired.
+	claims["aud"] = []string{"otherclient"}
+	if _, err = p.Complete(http.DefaultClient, login, "", url.Values{"code": {"thecode"}}); err != ErrIDToken {
+		t.Errorf("TestOIDC(%q): got %v wanted %v", "wrong audience", err, ErrIDToken)
+	}
+	claims["aud"] = []string{"otherclient", "theclient"}
+	claims["exp"] = now.Unix()
+	if _, err = p.Complete(http.DefaultClient, login, "", url.Values{"code": {"thecode"}}); err != ErrIDToken {
+		t.Errorf("TestOIDC(%q): got %v wanted %v", "expired token", err, ErrIDToken)
+	}
+
+	// an access token is only accepted when the token info endpoint reports it was issued to the client.
+	if _, err = p.VerifyToken(http.DefaultClient, "theaccesstoken"); err != ErrAudience {
+		t.Errorf("TestOIDC(%q): got %v wanted %v", "verify token without token info", err, ErrAudience)
+	}
+	p = NewOIDC(gwconfig.Provider{Name: "test", ClientId: "theclient", Issuer: server.URL, TokenInfoURL: server.URL + "/tokeninfo"})
+	var id *Identity
+	if id, err = p.VerifyToken(http.DefaultClient, "theaccesstoken"); err != nil || id.Subject != "1234" || !id.EmailVerified {
+		t.Errorf("TestOIDC(%q): got %+v, %v", "verify token", id, err)
+	}
+	if _, err = p.VerifyToken(http.DefaultClient, "otheraccesstoken"); err != ErrAudience {
+		t.Errorf("TestOIDC(%q): got %v wanted %v", "verify token of another client", err, ErrAudience)
+	}
+}
+
+func TestOAuth2(t *testing.T) {
+	server := newServer(t, nil, `{"id":98765,"login":"jsmith","name":"John Smith","email":"john@example.com"}`)
+	defer server.Close()
+
+	p := NewOAuth2(gwconfig.Provider{
+		Name:        "github",
+		ClientId:    "theclient",
+		AuthURL:     server.URL + "/authorize",
+		TokenURL:    server.URL + "/token",
+		UserInfoURL: server.URL + "/userinfo",
+		Fields:      map[string]string{"username": "login"},
+	})
+
+	login := &Login{State: "thestate", Verifier: "theverifier"}
+	id, err := p.Complete(http.DefaultClient, login, "", url.Values{"code": {"thecode"}, "state": {"thestate"}})
+	if err != nil {
+		t.Fatalf("TestOAuth2: unable to complete login: %v", err)
+	}
+	if id.Subject != "98765" || id.Username != "jsmith" || id.Name != "John Smith" || id.EmailVerified {
+		t.Errorf("TestOAuth2: got identity %+v", id)
+	}
+
+	if _, err = p.Complete(http.DefaultClient, login, "", url.Values{"error": {"access_denied"}}); err == nil {
+		t.Errorf("TestOAuth2(%q): got no error wanted an error", "access denied")
+	}
+
+	if _, err = p.VerifyToken(http.DefaultClient, "theaccesstoken"); err != ErrAudience {
+		t.Errorf("TestOAuth2(%q): got %v wanted %v", "verify token without token info", err, ErrAudience)
+	}
+	p = NewOAuth2(gwconfig.Provider{
+		Name:         "facebook",
+		ClientId:     "theclient",
+		UserInfoURL:  server.URL + "/userinfo",
+		TokenInfoURL: server.URL + "/tokeninfo",
+		Fields:       map[string]string{"audience": "id"},
+	})
+	if id, err = p.VerifyToken(http.DefaultClient, "theaccesstoken"); err != nil || id.Subject != "98765" {
+		t.Errorf("TestOAuth2(%q): got %+v, %v", "verify token", id, err)
+	}
+	if _, err = p.VerifyToken(http.DefaultClient, "otheraccesstoken"); err != ErrAudience {
+		t.Errorf("TestOAuth2(%q): got %v wanted %v", "verify token of another client", err, ErrAudience)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	oauth "github.com/garyburd/go-oauth/oauth"
+
+	gwconfig "github.com/taironas/gonawin/config"
+)
+
+// Twitter is the Twitter provider, it uses OAuth 1.0a.
+// The state of a login is the token of its temporary credentials, their secret is kept in the login.
+//
+type Twitter struct {
+	name   string
+	client oauth.Client
+}
+
+// twitterUser is the Twitter user data needed to sign in.
+type twitterUser struct {
+	Id         int64  `json:"id"`
+	Name       string `json:"name"`
+	ScreenName string `json:"screen_name"`
+}
+
+// NewTwitter returns the Twitter provider, the client id and secret are the consumer key and secret of the application.
+//
+func NewTwitter(cfg gwconfig.Provider) *Twitter {
+	return &Twitter{
+		name: cfg.Name,
+		client: oauth.Client{
+			Credentials:                   oauth.Credentials{Token: cfg.ClientId, Secret: cfg.ClientSecret},
+			TemporaryCredentialRequestURI: "https://api.twitter.com/oauth/request_token",
+			ResourceOwnerAuthorizationURI: "https://api.twitter.com/oauth/authorize",
+			TokenRequestURI:               "https://api.twitter.com/oauth/access_token",
+		},
+	}
+}
+
+// Name returns the name of the provider.
+//
+func (p *Twitter) Name() string {
+	return p.name
+}
+
+// Begin requests temporary credentials and returns the authorization URL.
+//
+func (p *Twitter) Begin(client *http.Client, login *Login, callbackURL string) (string, error) {
+	credentials, err := p.client.RequestTemporaryCredentials(client, callbackURL, nil)
+	if err != nil {
+		return "", err
+	}
+	login.State = credentials.Token
+	login.Secret = credentials.Secret
+	return p.client.AuthorizationURL(credentials, nil), nil
+}
+
+// State returns the 'oauth_token' param of the callback.
+//
+func (p *Twitter) State(params url.Values) string {
+	return params.Get("oauth_token")
+}
+
+// Complete requests the token credentials of the callback and gets the user.
+//
+func (p *Twitter) Complete(client *http.Client, login *Login, callbackURL string, params url.Values) (*Identity, error) {
+	if len(params.Get("oauth_verifier")) == 0 {
+		return nil, ErrCallback
+	}
+
+	temporary := oauth.Credentials{Token: login.State, Secret: login.Secret}
+	token, values, err := p.client.RequestToken(client, &temporary, params.Get("oauth_verifier"))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Get(client, token, "https://api.twitter.com/1.1/users/show.json", url.Values{"user_id": {values.Get("user_id")}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider: twitter user returned %d", resp.StatusCode)
+	}
+
+	var u twitterUser
+	if err = json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		return nil, err
+	}
+	if u.Id == 0 {
+		return nil, ErrIdentity
+	}
+	return &Identity{Provider: p.name, Subject: fmt.Sprintf("%d", u.Id), Name: u.Name, Username: u.ScreenName}, nil
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/taironas/gonawin/helpers"
+)
+
+// MaxPrefix is the maximum length of the prefixes of the prefix index. Longer query words
+// are looked up with their first MaxPrefix letters, then matched on the words of the entries.
+const MaxPrefix = 10
+
+// Suggestion is an entity suggested for a query.
+//
+type Suggestion struct {
+	Kind string
+	Id   int64
+	Name string
+}
+
+// entry is a suggestion in the prefix index with the words it is found by.
+type entry struct {
+	Suggestion
+	words []string
+	order string // folded name, entries are suggested in this order.
+}
+
+// less reports whether a is suggested before b: shorter names first, as they are closer
+// to what is typed, then in alphabetical order.
+func less(a, b *entry) bool {
+	if len(a.order) != len(b.order) {
+		return len(a.order) < len(b.order)
+	}
+	if a.order != b.order {
+		return a.order < b.order
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	return a.Id < b.Id
+}
+
+// entries implements sort.Interface to sort entries in suggestion order.
+type entries []*entry
+
+func (e entries) Len() int           { return len(e) }
+func (e entries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e entries) Less(i, j int) bool { return less(e[i], e[j]) }
+
+// PrefixIndex is an in memory index of the prefixes of the words of entities, the edge n-grams,
+// to suggest entities while their name is typed. It is not safe for concurrent use.
+//
+type PrefixIndex struct {
+	prefixes map[string]entries // entries having a word starting with the prefix.
+	unsorted map[string]bool    // prefixes whose entries are sorted on the next lookup.
+	entries  map[string]*entry  // entries by kind and id.
+}
+
+// NewPrefixIndex returns an empty prefix index.
+//
+func NewPrefixIndex() *PrefixIndex {
+	return &PrefixIndex{
+		prefixes: make(map[string]entries),
+		unsorted: make(map[string]bool),
+		entries:  make(map[string]*entry),
+	}
+}
+
+// entryKey returns the key of an entity in the index.
+func entryKey(kind string, id int64) string {
+	return kind + ":" + strconv.FormatInt(id, 10)
+}
+
+// prefixes returns the prefixes of word up to MaxPrefix letters.
+func prefixes(word string) []string {
+	var p []string
+	n := 0
+	for i := range word {
+		if i > 0 {
+			p = append(p, word[:i])
+		}
+		if n++; n > MaxPrefix {
+			return p
+		}
+	}
+	return append(p, word)
+}
+
+// truncate returns the first MaxPrefix letters of word.
+func truncate(word string) string {
+	n := 0
+	for i := range word {
+		if n == MaxPrefix {
+			return word[:i]
+		}
+		n++
+	}
+	return word
+}
+
+// Len returns the number of entities in the index.
+//
+func (p *PrefixIndex) Len() int {
+	return len(p.entries)
+}
+
+// Add adds an entity to the index with the text it is found by, it replaces the entity if it is already in the index.
+//
+func (p *PrefixIndex) Add(s Suggestion, text string) {
+	p.Remove(s.Kind, s.Id)
+
+	e := &entry{Suggestion: s, words: Words(text), order: Fold(s.Name)}
+	p.entries[entryKey(s.Kind, s.Id)] = e
+
+	added := make(map[string]bool)
+	for _, w := range e.words {
+		for _, prefix := range prefixes(w) {
+			if added[prefix] {
+				continue
+			}
+			added[prefix] = true
+			p.prefixes[prefix] = append(p.prefixes[prefix], e)
+			p.unsorted[prefix] = true
+		}
+	}
+}
+
+// Remove removes an entity from the index.
+//
+func (p *PrefixIndex) Remove(kind string, id int64) {
+	key := entryKey(kind, id)
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	delete(p.entries, key)
+
+	for _, w := range e.words {
+		for _, prefix := range prefixes(w) {
+			list := p.prefixes[prefix]
+			for i := range list {
+				if list[i] == e {
+					list = append(list[:i], list[i+1:]...)
+					break
+				}
+			}
+			if len(list) == 0 {
+				delete(p.prefixes, prefix)
+				delete(p.unsorted, prefix)
+			} else {
+				p.prefixes[prefix] = list
+			}
+		}
+	}
+}
+
+// lookup returns the entries of a prefix in suggestion order.
+func (p *PrefixIndex) lookup(prefix string) entries {
+	list := p.prefixes[prefix]
+	if p.unsorted[prefix] {
+		sort.Sort(list)
+		delete(p.unsorted, prefix)
+	}
+	return list
+}
+
+// matches reports whether every query word starts a word of the entry.
+func (e *entry) matches(query []string) bool {
+	for _, q := range query {
+		found := false
+		for _, w := range e.words {
+			if strings.HasPrefix(w, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Suggest returns at most n entities having, for every word of the query, a word starting with it.
+// Only the entities of the given kinds are returned, of all kinds if none is given. Accents and case
+// are ignored. The entries of the query word with the fewest entries are scanned in suggestion order
+// until n entities match.
+//
+func (p *PrefixIndex) Suggest(query string, n int, kinds ...string) []Suggestion {
+	words := Words(query)
+	if len(words) == 0 || n <= 0 {
+		return nil
+	}
+
+	shortest := 0
+	for i, w := range words {
+		list, ok := p.prefixes[truncate(w)]
+		if !ok {
+			return nil
+		}
+		if len(list) < len(p.prefixes[truncate(words[shortest])]) {
+			shortest = i
+		}
+	}
+
+	// the entries of a single word query up to MaxPrefix letters all match.
+	check := len(words) > 1 || utf8.RuneCountInString(words[0]) > MaxPrefix
+
+	var suggestions []Suggestion
+	for _, e := range p.lookup(truncate(words[shortest])) {
+		if len(kinds) > 0 && !helpers.SliceContains(kinds, e.Kind) {
+			continue
+		}
+		if check && !e.matches(words) {
+			continue
+		}
+		if suggestions = append(suggestions, e.Suggestion); len(suggestions) == n {
+			break
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package search
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// suggestBudget is the latency budget of a suggestion on the seeded index.
+const suggestBudget = 2 * time.Millisecond
+
+// seedEntities is the number of entities of the seeded index.
+const seedEntities = 5000
+
+func TestPrefixes(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"psg", []string{"p", "ps", "psg"}},
+		{"zü", []string{"z", "zü"}},
+		{"internacional", []string{"i", "in", "int", "inte", "inter", "intern", "interna", "internac", "internaci", "internacio"}},
+	}
+	for _, test := range tests {
+		if got := prefixes(test.word); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestPrefixes(%q): got %q wanted %q", test.word, got, test.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	p := NewPrefixIndex()
+	p.Add(Suggestion{"Team", 1, "Real Madrid"}, "Real Madrid")
+	p.Add(Suggestion{"Team", 2, "Atlético de Madrid"}, "Atlético de Madrid")
+	p.Add(Suggestion{"Team", 3, "Real Sociedad"}, "Real Sociedad")
+	p.Add(Suggestion{"Tournament", 4, "Madrid Cup"}, "Madrid Cup")
+	p.Add(Suggestion{"User", 5, "marie"}, "Marie Curie marie")
+	p.Add(Suggestion{"Team", 6, "Internacional de Porto Alegre"}, "Internacional de Porto Alegre")
+
+	tests := []struct {
+		query string
+		n     int
+		kinds []string
+		want  []int64
+	}{
+		{"ma", 10, nil, []int64{5, 4, 1, 2}},
+		{"ma", 2, nil, []int64{5, 4}},
+		{"ma", 10, []string{"Team"}, []int64{1, 2}},
+		{"ma", 10, []string{"User", "Tournament"}, []int64{5, 4}},
+		{"re ma", 10, nil, []int64{1}},
+		{"atle", 10, nil, []int64{2}},
+		{"ATLÉ", 10, nil, []int64{2}},
+		{"internacionale", 10, nil, nil},
+		{"internacional de", 10, nil, []int64{6}},
+		{"xyz", 10, nil, nil},
+		{"", 10, nil, nil},
+	}
+	for _, test := range tests {
+		var got []int64
+		for _, s := range p.Suggest(test.query, test.n, test.kinds...) {
+			got = append(got, s.Id)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestSuggest(%q, %d, %q): got %v wanted %v", test.query, test.n, test.kinds, got, test.want)
+		}
+	}
+}
+
+func TestSuggestRemove(t *testing.T) {
+	p := NewPrefixIndex()
+	p.Add(Suggestion{"Team", 1, "Real Madrid"}, "Real Madrid")
+	p.Add(Suggestion{"Team", 1, "Real Betis"}, "Real Betis")
+	p.Add(Suggestion{"Team", 2, "Real Sociedad"}, "Real Sociedad")
+
+	if got := p.Suggest("madrid", 10); len(got) != 0 {
+		t.Errorf("TestSuggestRemove: got %v for a replaced name wanted none", got)
+	}
+	p.Remove("Team", 2)
+	if got := p.Suggest("real", 10); len(got) != 1 || got[0].Name != "Real Betis" {
+		t.Errorf("TestSuggestRemove: got %v wanted [Real Betis]", got)
+	}
+	if p.Len() != 1 {
+		t.Errorf("TestSuggestRemove: got %d entities wanted 1", p.Len())
+	}
+}
+
+// seedName returns a random name of one to three words.
+func seedName(r *rand.Rand) string {
+	syllables := []string{"ma", "dri", "re", "al", "ber", "lin", "to", "ri", "no", "pa", "ris", "lo", "ndon", "por", "tu", "gal", "se", "vi", "lla", "é"}
+	name := ""
+	for w := 0; w < 1+r.Intn(3); w++ {
+		if w > 0 {
+			name += " "
+		}
+		for s := 0; s < 2+r.Intn(3); s++ {
+			name += syllables[r.Intn(len(syllables))]
+		}
+	}
+	return name
+}
+
+func TestSuggestLatency(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	kinds := []string{"Team", "Tournament", "User"}
+
+	p := NewPrefixIndex()
+	names := make([]string, seedEntities)
+	for i := range names {
+		names[i] = seedName(r)
+		p.Add(Suggestion{kinds[i%len(kinds)], int64(i + 1), names[i]}, names[i])
+	}
+
+	// the queries are the first letters of the names, as they are typed.
+	var queries []string
+	for i := 0; i < 500; i++ {
+		name := []rune(names[r.Intn(len(names))])
+		queries = append(queries, string(name[:1+r.Intn(len(name))]))
+	}
+
+	// at most 1% of the suggestions may exceed the budget, the 99th percentile.
+	slow := 0
+	var total time.Duration
+	for _, q := range queries {
+		start := time.Now()
+		if got := p.Suggest(q, 10); len(got) == 0 {
+			t.Errorf("TestSuggestLatency(%q): got no suggestion for the start of a name", q)
+		}
+		d := time.Since(start)
+		if d > suggestBudget {
+			slow++
+		}
+		total += d
+	}
+
+	if slow > len(queries)/100 {
+		t.Errorf("TestSuggestLatency: got %d of %d suggestions over %v on %d entities wanted at most %d", slow, len(queries), suggestBudget, seedEntities, len(queries)/100)
+	}
+	t.Logf("TestSuggestLatency: %v on average for %d entities", total/time.Duration(len(queries)), seedEntities)
+}
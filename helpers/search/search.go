@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package search provides the text processing of the search index: words are folded to
+// lower case without accents, and query words match indexed words exactly, by prefix or
+// with a few typos.
+package search
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// ExactWeight is the weight of a query word equal to an indexed word.
+	ExactWeight = 1.0
+	// PrefixWeight is the weight of a query word which is a prefix of an indexed word.
+	PrefixWeight = 0.75
+	// FuzzyWeight is the weight of a query word within the allowed typos of an indexed word.
+	FuzzyWeight = 0.5
+)
+
+// folds maps the accented letters to their letters without accents.
+var folds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ģ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'þ': "th",
+}
+
+// Fold returns s in lower case with the accents removed, so that "Zürich" and "zurich" are the same word.
+//
+func Fold(s string) string {
+	var b bytes.Buffer
+	for _, r := range strings.ToLower(s) {
+		if f, ok := folds[r]; ok {
+			b.WriteString(f)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Words returns the folded words of s, split on anything which is not a letter or a digit.
+// A word appears as many times as it is in s.
+//
+func Words(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Frequencies returns the number of times each word is in words.
+//
+func Frequencies(words []string) map[string]int64 {
+	f := make(map[string]int64)
+	for _, w := range words {
+		f[w]++
+	}
+	return f
+}
+
+// MaxEdits returns the number of typos allowed in a query word, longer words allow more typos.
+//
+func MaxEdits(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// Distance returns the Levenshtein distance between a and b, the number of letters to insert,
+// delete or substitute to change a into b.
+//
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// min3 returns the smallest of a, b and c.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Match returns the weight of the match of a query word with an indexed word, 0 if they do not match.
+// The indexed word matches if it is the query word, if it starts with the query word or if it
+// is within the typos allowed by MaxEdits.
+//
+func Match(query, word string) float64 {
+	if query == word {
+		return ExactWeight
+	}
+	if strings.HasPrefix(word, query) {
+		return PrefixWeight
+	}
+	edits := MaxEdits(query)
+	if edits == 0 {
+		return 0
+	}
+	if d := utf8.RuneCountInString(word) - utf8.RuneCountInString(query); d > edits || -d > edits {
+		return 0
+	}
+	if Distance(query, word) <= edits {
+		return FuzzyWeight
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Zürich", "zurich"},
+		{"Atlético de Madrid", "atletico de madrid"},
+		{"Łódź", "lodz"},
+		{"Straße", "strasse"},
+		{"gonawin", "gonawin"},
+	}
+	for _, test := range tests {
+		if got := Fold(test.s); got != test.want {
+			t.Errorf("TestFold(%q): got %q wanted %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"Paris Saint-Germain", []string{"paris", "saint", "germain"}},
+		{"  Real   Madrid ", []string{"real", "madrid"}},
+		{"Euro 2016", []string{"euro", "2016"}},
+		{"go go go!", []string{"go", "go", "go"}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		if got := Words(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestWords(%q): got %q wanted %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"kitten", "sitting", 3},
+		{"madrid", "madird", 2},
+		{"barcelona", "barcelona", 0},
+		{"", "abc", 3},
+		{"zurich", "zurch", 1},
+	}
+	for _, test := range tests {
+		if got := Distance(test.a, test.b); got != test.want {
+			t.Errorf("TestDistance(%q, %q): got %d wanted %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query, word string
+		want        float64
+	}{
+		{"madrid", "madrid", ExactWeight},
+		{"mad", "madrid", PrefixWeight},
+		{"madrd", "madrid", FuzzyWeight},
+		{"barcelnoa", "barcelona", FuzzyWeight},
+		{"psg", "psv", 0},
+		{"madrid", "mad", 0},
+		{"lyon", "paris", 0},
+	}
+	for _, test := range tests {
+		if got := Match(test.query, test.word); got != test.want {
+			t.Errorf("TestMatch(%q, %q): got %v wanted %v", test.query, test.word, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package slack provides the verification of Slack requests and the types
+// of the Slack slash commands and messages.
+//
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a Slack request.
+//
+const (
+	SignatureHeader = "X-Slack-Signature"
+	TimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// Response types of a message.
+//
+const (
+	Ephemeral = "ephemeral"  // message only visible by the user who sent the command.
+	InChannel = "in_channel" // message visible by everyone in the channel.
+)
+
+// MaxRequestAge is the maximum age of a request, older requests are rejected to prevent replay attacks.
+const MaxRequestAge = 5 * time.Minute
+
+const signatureVersion = "v0"
+
+// Errors returned by Verify.
+var (
+	ErrMissingSignature = errors.New("slack: missing signature or timestamp")
+	ErrExpiredRequest   = errors.New("slack: request timestamp is too old")
+	ErrInvalidSignature = errors.New("slack: invalid signature")
+)
+
+// Sign returns the signature of a request body sent at a timestamp.
+//
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a request with the signing secret of the Slack app.
+// The timestamp must not be older than MaxRequestAge at now.
+//
+func Verify(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if len(signature) == 0 || len(timestamp) == 0 {
+		return ErrMissingSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return ErrExpiredRequest
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Command is a slash command sent by Slack.
+//
+type Command struct {
+	TeamID      string // id of the Slack workspace.
+	UserID      string // id of the Slack user.
+	UserName    string
+	Command     string // the slash command, e.g. "/gonawin".
+	Text        string // the text after the command.
+	ResponseURL string
+}
+
+// ParseCommand parses the form of a slash command request.
+//
+func ParseCommand(form url.Values) Command {
+	return Command{
+		TeamID:      form.Get("team_id"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		ResponseURL: form.Get("response_url"),
+	}
+}
+
+// Args returns the name and the arguments of the command text.
+// "predict 12 2-1" returns "predict" and ["12", "2-1"].
+//
+func (cmd Command) Args() (string, []string) {
+	fields := strings.Fields(cmd.Text)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(fields[0]), fields[1:]
+}
+
+// Message is a reply to a slash command, in the Slack message format.
+//
+type Message struct {
+	ResponseType string       `json:"response_type,omitempty"`
+	Text         string       `json:"text"`
+	Attachments  []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a block of text attached to a message.
+//
+type Attachment struct {
+	Title    string   `json:"title,omitempty"`
+	Text     string   `json:"text"`
+	Color    string   `json:"color,omitempty"`
+	MrkdwnIn []string `json:"mrkdwn_in,omitempty"`
+}
@@ -0,0 +1,58 @@
+package slack
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	old := strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10)
+	body := []byte("token=xyz&team_id=T1&user_id=U1&command=%2Fgonawin&text=ranking")
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid request", ts, Sign("secret", ts, body), body, nil},
+		{"wrong secret", ts, Sign("other", ts, body), body, ErrInvalidSignature},
+		{"modified body", ts, Sign("secret", ts, body), []byte("text=next"), ErrInvalidSignature},
+		{"old request", old, Sign("secret", old, body), body, ErrExpiredRequest},
+		{"missing signature", ts, "", body, ErrMissingSignature},
+		{"missing timestamp", "", Sign("secret", ts, body), body, ErrMissingSignature},
+	}
+
+	for _, test := range tests {
+		if got := Verify("secret", test.timestamp, test.signature, test.body, now); got != test.want {
+			t.Errorf("TestVerify(%q): got %v wanted %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantName string
+		wantArgs []string
+	}{
+		{"", "", nil},
+		{"ranking", "ranking", []string{}},
+		{"  Predict 12   2-1 ", "predict", []string{"12", "2-1"}},
+		{"link abc123", "link", []string{"abc123"}},
+	}
+
+	for _, test := range tests {
+		cmd := ParseCommand(url.Values{"text": {test.text}})
+		name, args := cmd.Args()
+		if name != test.wantName || !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("TestCommandArgs(%q): got %q %q wanted %q %q", test.text, name, args, test.wantName, test.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadLocation returns the location with the given name.
+// The name can be an IANA time zone name like "Europe/Paris",
+// "UTC" or a fixed offset like "+02:00", "-0300" or "UTC-3".
+//
+func LoadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || strings.EqualFold(name, "UTC") || name == "Z" {
+		return time.UTC, nil
+	}
+
+	offset := name
+	if len(offset) > 3 && strings.EqualFold(offset[:3], "UTC") {
+		offset = offset[3:]
+	}
+	if offset[0] == '+' || offset[0] == '-' {
+		if seconds, err := parseOffset(offset); err == nil {
+			return time.FixedZone(name, seconds), nil
+		}
+	}
+
+	return time.LoadLocation(name)
+}
+
+// parseOffset parses offsets with format "+hh:mm", "+hhmm" or "+h" and returns it in seconds.
+func parseOffset(s string) (int, error) {
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = strings.Replace(s[1:], ":", "", 1)
+
+	var hours, minutes int
+	var err error
+	switch {
+	case len(s) <= 2:
+		hours, err = strconv.Atoi(s)
+	case len(s) == 4:
+		if hours, err = strconv.Atoi(s[:2]); err == nil {
+			minutes, err = strconv.Atoi(s[2:])
+		}
+	default:
+		err = fmt.Errorf("invalid offset %q", s)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if hours > 14 || minutes > 59 {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	return sign * (hours*3600 + minutes*60), nil
+}
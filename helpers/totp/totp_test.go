@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// test vectors of RFC 6238 appendix B for SHA1.
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, test := range tests {
+		if got := code(key, Counter(time.Unix(test.unix, 0)), 8); got != test.want {
+			t.Errorf("TestCode(%d): got %v wanted %v", test.unix, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	current, _ := Code(secret, Counter(now))
+	previous, _ := Code(secret, Counter(now)-1)
+	old, _ := Code(secret, Counter(now)-2)
+
+	tests := []struct {
+		title   string
+		code    string
+		counter int64
+		ok      bool
+	}{
+		{"current code", current, Counter(now), true},
+		{"previous code", previous, Counter(now) - 1, true},
+		{"old code", old, 0, false},
+		{"wrong code", "000000", 0, false},
+		{"short code", current[:5], 0, false},
+	}
+	for _, test := range tests {
+		counter, ok := Validate(secret, test.code, now)
+		if counter != test.counter || ok != test.ok {
+			t.Errorf("TestValidate(%q): got %v, %v wanted %v, %v", test.title, counter, ok, test.counter, test.ok)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("TestGenerateSecret: %v", err)
+	}
+	if _, err = Code(secret, 1); err != nil {
+		t.Errorf("TestGenerateSecret: got %v wanted a valid base32 secret", err)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Errorf("TestGenerateSecret: two secrets are equal")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Received holds a webhook request received by a Receiver.
+//
+type Received struct {
+	Event    string
+	ID       string
+	Attempt  int
+	Payload  []byte
+	Verified bool // is the signature valid.
+	Status   int  // status code answered to the request.
+}
+
+// Receiver is an http.Handler that verifies and records webhook requests.
+// Use it to test webhooks locally, without any external service.
+//
+// Requests with an invalid signature are answered with 401 Unauthorized.
+// The first Failures attempts of each delivery are answered with 503 Service Unavailable
+// so that retries can be verified.
+//
+type Receiver struct {
+	Secret   string
+	Failures int
+
+	mu       sync.Mutex
+	received []Received
+	attempts map[string]int
+}
+
+// ServeHTTP verifies and records a webhook request.
+//
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	attempt, _ := strconv.Atoi(r.Header.Get(AttemptHeader))
+	rec := Received{
+		Event:    r.Header.Get(EventHeader),
+		ID:       r.Header.Get(DeliveryHeader),
+		Attempt:  attempt,
+		Payload:  payload,
+		Verified: Verify(rc.Secret, payload, r.Header.Get(SignatureHeader)),
+		Status:   http.StatusOK,
+	}
+
+	rc.mu.Lock()
+	if rc.attempts == nil {
+		rc.attempts = make(map[string]int)
+	}
+	rc.attempts[rec.ID]++
+	if !rec.Verified {
+		rec.Status = http.StatusUnauthorized
+	} else if rc.attempts[rec.ID] <= rc.Failures {
+		rec.Status = http.StatusServiceUnavailable
+	}
+	rc.received = append(rc.received, rec)
+	rc.mu.Unlock()
+
+	w.WriteHeader(rec.Status)
+}
+
+// Received returns the requests received, in order of arrival.
+//
+func (rc *Receiver) Received() []Received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	received := make([]Received, len(rc.received))
+	copy(received, rc.received)
+	return received
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package webhook provides the signing and the delivery of gonawin webhooks,
+// and a receiver to verify them offline.
+//
+// A webhook is a JSON payload sent with a POST request. The payload is signed
+// with the secret of the webhook using HMAC-SHA256, the signature is sent
+// in the SignatureHeader header as "sha256=" followed by the hexadecimal digest.
+//
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Headers of a webhook request.
+//
+const (
+	SignatureHeader = "X-Gonawin-Signature"
+	EventHeader     = "X-Gonawin-Event"
+	DeliveryHeader  = "X-Gonawin-Delivery"
+	AttemptHeader   = "X-Gonawin-Attempt"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature of a payload with a secret.
+//
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify indicates if a signature is the signature of a payload with a secret.
+//
+func Verify(secret string, payload []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// Delivery holds a webhook request to send.
+//
+type Delivery struct {
+	URL     string // url of the receiver.
+	Secret  string // secret used to sign the payload.
+	Event   string // event of the payload.
+	ID      string // unique id of the delivery, the same for all the attempts.
+	Attempt int    // attempt number, starting at 1.
+	Payload []byte // JSON payload.
+}
+
+// Deliver sends a webhook request with a client.
+// An error is returned when the receiver does not answer with a 2xx status code,
+// in which case the delivery should be retried.
+//
+func Deliver(client *http.Client, d Delivery) error {
+	req, err := http.NewRequest("POST", d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(d.Secret, d.Payload))
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(AttemptHeader, strconv.Itoa(d.Attempt))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: delivery %s to %s failed with status %d", d.ID, d.URL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	payload := []byte(`{"Event":"match.result"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		{"valid signature", "secret", payload, Sign("secret", payload), true},
+		{"wrong secret", "other", payload, Sign("secret", payload), false},
+		{"modified payload", "secret", []byte(`{"Event":"phase.completed"}`), Sign("secret", payload), false},
+		{"missing prefix", "secret", payload, Sign("secret", payload)[len(signaturePrefix):], false},
+		{"empty signature", "secret", payload, "", false},
+	}
+
+	for _, test := range tests {
+		if got := Verify(test.secret, test.payload, test.signature); got != test.want {
+			t.Errorf("TestSignAndVerify(%q): got %v wanted %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDeliverWithRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       string
+		failures     int
+		maxAttempts  int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"first attempt succeeds", "secret", 0, 3, 1, false},
+		{"succeeds after retries", "secret", 2, 3, 3, false},
+		{"too many failures", "secret", 5, 3, 3, true},
+		{"wrong secret", "wrong", 0, 3, 3, true},
+	}
+
+	for _, test := range tests {
+		receiver := &Receiver{Secret: "secret", Failures: test.failures}
+		server := httptest.NewServer(receiver)
+
+		d := Delivery{URL: server.URL, Secret: test.secret, Event: "match.result", ID: "42", Payload: []byte(`{}`)}
+		var err error
+		for d.Attempt = 1; d.Attempt <= test.maxAttempts; d.Attempt++ {
+			if err = Deliver(http.DefaultClient, d); err == nil {
+				break
+			}
+		}
+		server.Close()
+
+		if (err != nil) != test.wantErr {
+			t.Errorf("TestDeliverWithRetries(%q): got error %v wanted error %v", test.name, err, test.wantErr)
+		}
+		received := receiver.Received()
+		if len(received) != test.wantAttempts {
+			t.Errorf("TestDeliverWithRetries(%q): got %d attempts wanted %d", test.name, len(received), test.wantAttempts)
+			continue
+		}
+		for i, r := range received {
+			if r.Attempt != i+1 || r.ID != "42" || r.Event != "match.result" {
+				t.Errorf("TestDeliverWithRetries(%q): got request %+v", test.name, r)
+			}
+			if r.Verified != (test.secret == receiver.Secret) {
+				t.Errorf("TestDeliverWithRetries(%q): got verified %v", test.name, r.Verified)
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// Purposes of an account token and their validity.
+//
+const (
+	TokenVerifyEmail = "verify" // verify the email of a local account.
+	TokenResetPass   = "reset"  // reset the password of a local account.
+	TokenMagicLink   = "magic"  // sign in from a link sent by email.
+)
+
+var accountTokenDurations = map[string]time.Duration{
+	TokenVerifyEmail: 48 * time.Hour,
+	TokenResetPass:   time.Hour,
+	TokenMagicLink:   15 * time.Minute,
+}
+
+// Local account errors.
+//
+var (
+	ErrAccountExists       = errors.New("model/account: an account already exists for this email")
+	ErrAccountTokenInvalid = errors.New("model/account: invalid or expired token")
+)
+
+// LocalAccount holds the credentials of a user who signs in with an email and a password.
+// Its key name is the email in lower case.
+// The account is linked to a user once its email is verified.
+//
+type LocalAccount struct {
+	Email        string
+	PasswordHash string `datastore:",noindex"`
+	Verified     bool
+	UserId       int64 // id of the linked user, 0 until the email is verified.
+	Created      time.Time
+}
+
+// AccountToken is a single use token sent by email to verify an email, reset a password or sign in.
+// A token is of the form '<token id>.<secret>', only the hash of the secret is stored.
+//
+type AccountToken struct {
+	Id      int64
+	Email   string
+	Purpose string
+	Hash    string `datastore:",noindex"`
+	Expires time.Time
+}
+
+func localAccountKey(c appengine.Context, email string) *datastore.Key {
+	return datastore.NewKey(c, "LocalAccount", strings.ToLower(email), 0, nil)
+}
+
+// LocalAccountByEmail gets the local account of an email.
+//
+func LocalAccountByEmail(c appengine.Context, email string) (*LocalAccount, error) {
+	var a LocalAccount
+	if err := datastore.Get(c, localAccountKey(c, email), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateLocalAccount creates an unverified local account.
+// An unverified account of the same email is replaced, so that a user can register again
+// if the verification email is lost. It returns ErrAccountExists if a verified account exists.
+//
+func CreateLocalAccount(c appengine.Context, email, passwordHash string) (*LocalAccount, error) {
+	email = strings.ToLower(email)
+	a := &LocalAccount{Email: email, PasswordHash: passwordHash, Created: time.Now()}
+
+	err := datastore.RunInTransaction(c, func(tc appengine.Context) error {
+		var existing LocalAccount
+		err := datastore.Get(tc, localAccountKey(tc, email), &existing)
+		if err == nil && existing.Verified {
+			return ErrAccountExists
+		} else if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		_, err = datastore.Put(tc, localAccountKey(tc, email), a)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Update a local account.
+//
+func (a *LocalAccount) Update(c appengine.Context) error {
+	_, err := datastore.Put(c, localAccountKey(c, a.Email), a)
+	return err
+}
+
+// Link links a verified local account to a user.
+// The user is the existing user with the same email, it is created if there is none.
+//
+func (a *LocalAccount) Link(c appengine.Context) (*User, error) {
+	u, err := SigninUserByEmail(c, a.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.UserId != u.Id {
+		a.UserId = u.Id
+		if err = a.Update(c); err != nil {
+			log.Errorf(c, "LocalAccount.Link: unable to update account of user %v: %v", u.Id, err)
+		}
+	}
+	return u, nil
+}
+
+// SigninUserByEmail returns the user with an email, through SigninUser.
+// If there is none, the user is created with a username built from the email.
+//
+func SigninUserByEmail(c appengine.Context, email string) (*User, error) {
+	email = strings.ToLower(email)
+	if u := FindUser(c, "Email", email); u != nil {
+		return u, nil
+	}
+	username := availableUsername(c, strings.SplitN(email, "@", 2)[0])
+	return SigninUser(c, "Email", email, username, username)
+}
+
+// availableUsername returns a username not used by another user, built from a base name.
+func availableUsername(c appengine.Context, base string) string {
+	username := base
+	for i := 2; FindUser(c, "Username", username) != nil; i++ {
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+	return username
+}
+
+// CreateAccountToken creates a token for an email and a purpose and returns it.
+//
+func CreateAccountToken(c appengine.Context, email, purpose string) (string, error) {
+	duration, ok := accountTokenDurations[purpose]
+	if !ok {
+		return "", fmt.Errorf("model/account: unknown token purpose %v", purpose)
+	}
+
+	id, _, err := datastore.AllocateIDs(c, "AccountToken", nil, 1)
+	if err != nil {
+		return "", err
+	}
+
+	secret := GenerateAuthKey()
+	if len(secret) == 0 {
+		return "", errors.New("model/account: unable to generate token")
+	}
+
+	t := &AccountToken{id, strings.ToLower(email), purpose, HashToken(secret), time.Now().Add(duration)}
+	key := datastore.NewKey(c, "AccountToken", "", id, nil)
+	if _, err = datastore.Put(c, key, t); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%s", id, secret), nil
+}
+
+// Check verifies the secret and the purpose of a token at a given time.
+//
+func (t *AccountToken) Check(secret, purpose string, now time.Time) error {
+	if t.Purpose != purpose || !checkSecret(t.Hash, secret) || !now.Before(t.Expires) {
+		return ErrAccountTokenInvalid
+	}
+	return nil
+}
+
+// UseAccountToken returns the email of a token given its purpose.
+// A token can only be used once and before it expires.
+//
+func UseAccountToken(c appengine.Context, token, purpose string) (string, error) {
+	id, secret, err := ParseSessionToken(token)
+	if err != nil {
+		return "", ErrAccountTokenInvalid
+	}
+
+	key := datastore.NewKey(c, "AccountToken", "", id, nil)
+	var t AccountToken
+	if err = datastore.Get(c, key, &t); err != nil {
+		return "", ErrAccountTokenInvalid
+	}
+	if err = t.Check(secret, purpose, time.Now()); err != nil {
+		return "", err
+	}
+	if err = datastore.Delete(c, key); err != nil {
+		return "", err
+	}
+	return t.Email, nil
+}
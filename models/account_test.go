@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountTokenCheck(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+	token := AccountToken{Id: 42, Email: "john@example.com", Purpose: TokenResetPass, Hash: HashToken("abcdef"), Expires: now.Add(time.Hour)}
+
+	tests := []struct {
+		title   string
+		secret  string
+		purpose string
+		now     time.Time
+		err     error
+	}{
+		{"valid token", "abcdef", TokenResetPass, now, nil},
+		{"wrong secret", "abcdeg", TokenResetPass, now, ErrAccountTokenInvalid},
+		{"wrong purpose", "abcdef", TokenMagicLink, now, ErrAccountTokenInvalid},
+		{"expired token", "abcdef", TokenResetPass, now.Add(time.Hour), ErrAccountTokenInvalid},
+	}
+	for _, test := range tests {
+		if err := token.Check(test.secret, test.purpose, test.now); err != test.err {
+			t.Errorf("TestAccountTokenCheck(%q): got %v wanted %v", test.title, err, test.err)
+		}
+	}
+}
@@ -92,6 +92,13 @@ func FindActivities(c appengine.Context, u *User, count int64, page int64) []*Ac
 	return activities
 }
 
+// FindActivitiesByCursor returns count activities of a user starting after cursor, the most recent first.
+// It returns the cursor of the next page, empty after the last page.
+//
+func FindActivitiesByCursor(c appengine.Context, u *User, count int64, cursor string) ([]*Activity, string, error) {
+	return Repos(c).Activities.ByUserCursor(u, count, cursor)
+}
+
 // DestroyActivities deletes activities in array.
 //
 func DestroyActivities(c appengine.Context, activityIds []int64) error {
@@ -140,6 +147,20 @@ func (a *Activity) save(c appengine.Context) error {
 	return nil
 }
 
+// newActivity returns a new activity of an actor, its id is set when it is saved.
+//
+func newActivity(actor ActivityEntity, creatorID int64, activityType string, verb string, object ActivityEntity, target ActivityEntity) *Activity {
+	return &Activity{
+		Type:      activityType,
+		Verb:      verb,
+		Actor:     actor,
+		Object:    object,
+		Target:    target,
+		Published: time.Now(),
+		CreatorID: creatorID,
+	}
+}
+
 // AddNewActivityID adds new activity id for a specific user.
 //
 func (a *Activity) AddNewActivityID(c appengine.Context, u *User) error {
@@ -0,0 +1,210 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// API key scopes.
+//
+const (
+	ScopeReadRankings     = "read:rankings"     // get the rankings of tournaments and teams.
+	ScopeWritePredictions = "write:predictions" // predict matches.
+	ScopeAdminTournament  = "admin:tournament"  // administrate tournaments, the user must be an admin.
+	ScopeReadCalendar     = "read:calendar"     // subscribe to the calendar of the matches not predicted yet.
+)
+
+// APIKeyScopes holds all the API key scopes.
+//
+var APIKeyScopes = []string{ScopeReadRankings, ScopeWritePredictions, ScopeAdminTournament, ScopeReadCalendar}
+
+// apiKeyPrefix distinguishes an API key from a session token.
+const apiKeyPrefix = "gwk_"
+
+// ErrAPIKeyInvalid is returned when an API key does not match a stored key.
+//
+var ErrAPIKeyInvalid = errors.New("model/apikey: invalid api key")
+
+// APIKey represents a key created by a user to access the API from scripts and bots.
+// A key is of the form 'gwk_<key id>.<secret>', only the hash of the secret is stored.
+//
+type APIKey struct {
+	Id           int64
+	UserId       int64
+	Name         string
+	Scopes       []string
+	KeyHash      string `datastore:",noindex"`
+	SecondFactor bool   // created while two-factor authentication of the user was enabled.
+	Created      time.Time
+	LastUsed     time.Time
+}
+
+// APIKeyJSON is the JSON representation of an API key.
+//
+type APIKeyJSON struct {
+	Id       *int64     `json:",omitempty"`
+	UserId   *int64     `json:",omitempty"`
+	Name     *string    `json:",omitempty"`
+	Scopes   *[]string  `json:",omitempty"`
+	Created  *time.Time `json:",omitempty"`
+	LastUsed *time.Time `json:",omitempty"`
+}
+
+// IsAPIKeyScopeValid indicates if an API key scope exists.
+//
+func IsAPIKeyScopeValid(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKey indicates if a token has the form of an API key.
+//
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// ParseAPIKey returns the id and the secret of an API key.
+//
+func ParseAPIKey(key string) (int64, string, error) {
+	if !IsAPIKey(key) {
+		return 0, "", ErrAPIKeyInvalid
+	}
+	id, secret, err := ParseSessionToken(strings.TrimPrefix(key, apiKeyPrefix))
+	if err != nil {
+		return 0, "", ErrAPIKeyInvalid
+	}
+	return id, secret, nil
+}
+
+// HasScope indicates if an API key was granted a scope.
+//
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Check verifies the secret of an API key.
+//
+func (k *APIKey) Check(secret string) error {
+	if !checkSecret(k.KeyHash, secret) {
+		return ErrAPIKeyInvalid
+	}
+	return nil
+}
+
+// CreateAPIKey creates an API key of a user with a name and scopes.
+// secondFactor tells if the user had enabled two-factor authentication, the key is then created behind a second factor check.
+// It returns the key, it is only known at creation.
+//
+func CreateAPIKey(c appengine.Context, userID int64, name string, scopes []string, secondFactor bool) (*APIKey, string, error) {
+
+	id, _, err := datastore.AllocateIDs(c, "APIKey", nil, 1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret := GenerateAuthKey()
+	if len(secret) == 0 {
+		return nil, "", errors.New("model/apikey: unable to generate key")
+	}
+
+	k := &APIKey{id, userID, name, scopes, HashToken(secret), secondFactor, time.Now(), time.Time{}}
+	key := datastore.NewKey(c, "APIKey", "", id, nil)
+	if _, err = datastore.Put(c, key, k); err != nil {
+		return nil, "", err
+	}
+	return k, fmt.Sprintf("%s%d.%s", apiKeyPrefix, id, secret), nil
+}
+
+// APIKeyByID gets an API key given an id.
+//
+func APIKeyByID(c appengine.Context, id int64) (*APIKey, error) {
+
+	var k APIKey
+	key := datastore.NewKey(c, "APIKey", "", id, nil)
+
+	if err := datastore.Get(c, key, &k); err != nil {
+		log.Errorf(c, "APIKey not found : %v", err)
+		return nil, err
+	}
+	return &k, nil
+}
+
+// APIKeyByKey gets the API key matching a key.
+// It returns ErrAPIKeyInvalid if the key does not match a stored key.
+//
+func APIKeyByKey(c appengine.Context, key string) (*APIKey, error) {
+	id, secret, err := ParseAPIKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var k *APIKey
+	if k, err = APIKeyByID(c, id); err != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err = k.Check(secret); err != nil {
+		return nil, err
+	}
+
+	if now := time.Now(); now.Sub(k.LastUsed) > sessionLastUsedPrecision {
+		k.LastUsed = now
+		dkey := datastore.NewKey(c, "APIKey", "", k.Id, nil)
+		if _, err = datastore.Put(c, dkey, k); err != nil {
+			log.Errorf(c, "APIKey.APIKeyByKey: unable to update last use of key %v: %v", k.Id, err)
+		}
+	}
+	return k, nil
+}
+
+// FindAPIKeys returns the API keys of a user.
+//
+func FindAPIKeys(c appengine.Context, userID int64) []*APIKey {
+	desc := "APIKey.FindAPIKeys:"
+	q := datastore.NewQuery("APIKey").Filter("UserId"+" =", userID)
+
+	var keys []*APIKey
+	if _, err := q.GetAll(c, &keys); err != nil {
+		log.Errorf(c, "%s an error occurred during GetAll: %v", desc, err)
+		return nil
+	}
+	return keys
+}
+
+// Destroy an API key entity, the key is no longer valid.
+//
+func (k *APIKey) Destroy(c appengine.Context) error {
+	key := datastore.NewKey(c, "APIKey", "", k.Id, nil)
+	return datastore.Delete(c, key)
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import "testing"
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		id     int64
+		secret string
+		err    error
+	}{
+		{"gwk_42.abcdef", 42, "abcdef", nil},
+		{"42.abcdef", 0, "", ErrAPIKeyInvalid},
+		{"gwk_42", 0, "", ErrAPIKeyInvalid},
+		{"gwk_x.abcdef", 0, "", ErrAPIKeyInvalid},
+		{"", 0, "", ErrAPIKeyInvalid},
+	}
+	for _, test := range tests {
+		id, secret, err := ParseAPIKey(test.key)
+		if id != test.id || secret != test.secret || err != test.err {
+			t.Errorf("TestParseAPIKey(%q): got %v, %q, %v wanted %v, %q, %v", test.key, id, secret, err, test.id, test.secret, test.err)
+		}
+		if IsSessionToken(test.key) && IsAPIKey(test.key) {
+			t.Errorf("TestParseAPIKey(%q): key is both a session token and an api key", test.key)
+		}
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	k := APIKey{Scopes: []string{ScopeReadRankings, ScopeWritePredictions}, KeyHash: HashToken("abcdef")}
+
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{ScopeReadRankings, true},
+		{ScopeWritePredictions, true},
+		{ScopeAdminTournament, false},
+		{"write:rankings", false},
+	}
+	for _, test := range tests {
+		if got := k.HasScope(test.scope); got != test.want {
+			t.Errorf("TestAPIKeyScopes(%q): got %v wanted %v", test.scope, got, test.want)
+		}
+	}
+
+	if err := k.Check("abcdef"); err != nil {
+		t.Errorf("TestAPIKeyScopes(%q): got %v wanted %v", "valid secret", err, nil)
+	}
+	if err := k.Check("abcdeg"); err != ErrAPIKeyInvalid {
+		t.Errorf("TestAPIKeyScopes(%q): got %v wanted %v", "wrong secret", err, ErrAPIKeyInvalid)
+	}
+}
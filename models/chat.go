@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// ChatLinkCodeDuration is the validity of a code used to link a chat account to a user.
+const ChatLinkCodeDuration = 10 * time.Minute
+
+// ChatAccount links an account of a chat application to a gonawin user.
+// Its key name is built from the provider, the workspace id and the chat user id.
+//
+type ChatAccount struct {
+	Provider   string // chat application, e.g. "slack".
+	TeamId     string // id of the chat workspace.
+	ChatUserId string // id of the user in the chat workspace.
+	UserId     int64  // id of the gonawin user.
+	Created    time.Time
+}
+
+// ChatLinkCode is a short lived code a user sends from a chat application to link their chat account.
+// Its key name is the code.
+//
+type ChatLinkCode struct {
+	Code    string
+	UserId  int64
+	Expires time.Time
+}
+
+func chatAccountKey(c appengine.Context, provider, teamID, chatUserID string) *datastore.Key {
+	return datastore.NewKey(c, "ChatAccount", fmt.Sprintf("%s:%s:%s", provider, teamID, chatUserID), 0, nil)
+}
+
+// ChatAccountByChatUser gets the chat account of a chat user.
+// It returns datastore.ErrNoSuchEntity when the chat user is not linked to a gonawin user.
+//
+func ChatAccountByChatUser(c appengine.Context, provider, teamID, chatUserID string) (*ChatAccount, error) {
+	var a ChatAccount
+	if err := datastore.Get(c, chatAccountKey(c, provider, teamID, chatUserID), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// LinkChatAccount links a chat user to a gonawin user.
+// A chat user previously linked to another gonawin user is linked to the new one.
+//
+func LinkChatAccount(c appengine.Context, provider, teamID, chatUserID string, userID int64) (*ChatAccount, error) {
+	a := &ChatAccount{provider, teamID, chatUserID, userID, time.Now()}
+	if _, err := datastore.Put(c, chatAccountKey(c, provider, teamID, chatUserID), a); err != nil {
+		log.Errorf(c, "ChatAccount.LinkChatAccount: unable to put chat account: %v", err)
+		return nil, err
+	}
+	return a, nil
+}
+
+// Destroy unlinks a chat account.
+//
+func (a *ChatAccount) Destroy(c appengine.Context) error {
+	return datastore.Delete(c, chatAccountKey(c, a.Provider, a.TeamId, a.ChatUserId))
+}
+
+// CreateChatLinkCode creates a code to link a chat account to a user.
+//
+func CreateChatLinkCode(c appengine.Context, userID int64) (*ChatLinkCode, error) {
+	code := GenerateAuthKey()
+	if len(code) == 0 {
+		return nil, errors.New("model/chat: unable to generate a link code")
+	}
+	// a short code is easier to type in a chat application.
+	code = code[:8]
+
+	lc := &ChatLinkCode{code, userID, time.Now().Add(ChatLinkCodeDuration)}
+	key := datastore.NewKey(c, "ChatLinkCode", code, 0, nil)
+	if _, err := datastore.Put(c, key, lc); err != nil {
+		log.Errorf(c, "ChatLinkCode.CreateChatLinkCode: unable to put link code: %v", err)
+		return nil, err
+	}
+	return lc, nil
+}
+
+// UseChatLinkCode returns the id of the user who created a link code.
+// A code can only be used once and before it expires.
+//
+func UseChatLinkCode(c appengine.Context, code string) (int64, error) {
+	key := datastore.NewKey(c, "ChatLinkCode", code, 0, nil)
+
+	var lc ChatLinkCode
+	if err := datastore.Get(c, key, &lc); err != nil {
+		return 0, err
+	}
+	if err := datastore.Delete(c, key); err != nil {
+		log.Errorf(c, "ChatLinkCode.UseChatLinkCode: unable to delete link code: %v", err)
+	}
+	if time.Now().After(lc.Expires) {
+		return 0, errors.New("model/chat: link code expired")
+	}
+	return lc.UserId, nil
+}
@@ -0,0 +1,665 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+
+	"github.com/taironas/gonawin/helpers"
+)
+
+const (
+	// ConsistencyBatch is the number of entities checked by a step of a consistency check.
+	ConsistencyBatch = 100
+	// maxConsistencyProblems is the maximum number of inconsistencies described in the report of a check.
+	maxConsistencyProblems = 1000
+)
+
+// ConsistencyKinds are the kinds checked by a consistency check, in order.
+//
+var ConsistencyKinds = []string{"User", "Team", "Tournament", "Predict", "Score", "Accuracy"}
+
+// Problems found by a consistency check.
+//
+const (
+	ConsistencyProblemDangling     = "dangling id"         // the entity of an id does not exist, or is not linked to the entity holding the id.
+	ConsistencyProblemAsymmetric   = "asymmetric link"     // the entity of an id does not link back to the entity holding the id.
+	ConsistencyProblemOrphan       = "orphan"              // the user, team or tournament the entity belongs to does not exist.
+	ConsistencyProblemMembersCount = "wrong members count" // the members count of a team is not its number of members.
+)
+
+var errConsistencyKind = errors.New("model/consistency: unknown kind")
+
+// consistencyTypes are the types of the entities of the checked kinds.
+var consistencyTypes = map[string]reflect.Type{
+	"User":       reflect.TypeOf(User{}),
+	"Team":       reflect.TypeOf(Team{}),
+	"Tournament": reflect.TypeOf(Tournament{}),
+	"Predict":    reflect.TypeOf(Predict{}),
+	"Score":      reflect.TypeOf(Score{}),
+	"Accuracy":   reflect.TypeOf(Accuracy{}),
+}
+
+// ConsistencyCheck is a check of the links between users, teams, tournaments, predicts, scores and accuracies.
+// It runs in steps, each step checks a batch of entities of a kind and reports the dangling ids, the asymmetric
+// links and the wrong members counts. With Fix set, the inconsistencies are repaired:
+// dangling ids are removed, missing sides of links are added, members counts are set and orphans are deleted.
+//
+type ConsistencyCheck struct {
+	Id              int64
+	Fix             bool
+	Kind            string // kind of the next step.
+	Cursor          string `datastore:",noindex"` // cursor of the next step in the kind.
+	Checked         int64
+	Inconsistencies int64
+	Fixed           int64
+	Problems        []string `datastore:",noindex"` // first inconsistencies found.
+	Started         time.Time
+	Finished        time.Time
+}
+
+// ConsistencyCheckJSON is the JSON representation of a consistency check.
+//
+type ConsistencyCheckJSON struct {
+	Id              *int64     `json:",omitempty"`
+	Fix             *bool      `json:",omitempty"`
+	Kind            *string    `json:",omitempty"`
+	Checked         *int64     `json:",omitempty"`
+	Inconsistencies *int64     `json:",omitempty"`
+	Fixed           *int64     `json:",omitempty"`
+	Problems        *[]string  `json:",omitempty"`
+	Started         *time.Time `json:",omitempty"`
+	Finished        *time.Time `json:",omitempty"`
+}
+
+// CreateConsistencyCheck creates a consistency check, it starts with the first kind.
+//
+func CreateConsistencyCheck(c appengine.Context, fix bool) (*ConsistencyCheck, error) {
+	id, _, err := datastore.AllocateIDs(c, "ConsistencyCheck", nil, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &ConsistencyCheck{
+		Id:      id,
+		Fix:     fix,
+		Kind:    ConsistencyKinds[0],
+		Started: time.Now(),
+	}
+	if err = ch.Update(c); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// ConsistencyCheckByID returns a consistency check given its id.
+//
+func ConsistencyCheckByID(c appengine.Context, id int64) (*ConsistencyCheck, error) {
+	var ch ConsistencyCheck
+	if err := datastore.Get(c, datastore.NewKey(c, "ConsistencyCheck", "", id, nil), &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// Update saves a consistency check.
+//
+func (ch *ConsistencyCheck) Update(c appengine.Context) error {
+	_, err := datastore.Put(c, datastore.NewKey(c, "ConsistencyCheck", "", ch.Id, nil), ch)
+	return err
+}
+
+// Done reports whether all the kinds were checked.
+//
+func (ch *ConsistencyCheck) Done() bool {
+	return !ch.Finished.IsZero()
+}
+
+// Enqueue adds a task running the next step of the check.
+//
+func (ch *ConsistencyCheck) Enqueue(c appengine.Context) error {
+	task := taskqueue.NewPOSTTask("/a/consistency/check/", url.Values{
+		"checkId": []string{strconv.FormatInt(ch.Id, 10)},
+	})
+	_, err := taskqueue.Add(c, task, "")
+	return err
+}
+
+// report adds an inconsistency to the report and counts it as fixed when the check fixes it.
+func (ch *ConsistencyCheck) report(kind string, id int64, problem string, fixable bool) {
+	ch.Inconsistencies++
+	if ch.Fix && fixable {
+		ch.Fixed++
+	}
+	if len(ch.Problems) < maxConsistencyProblems {
+		ch.Problems = append(ch.Problems, fmt.Sprintf("%s %d: %s", kind, id, problem))
+	}
+}
+
+// next moves the check to the next step: the same kind from cursor if there is more to check,
+// else the next kind. The check is finished after the last kind.
+func (ch *ConsistencyCheck) next(cursor string, more bool, now time.Time) {
+	ch.Cursor = cursor
+	if more {
+		return
+	}
+	ch.Cursor = ""
+	for i, k := range ConsistencyKinds {
+		if k == ch.Kind && i+1 < len(ConsistencyKinds) {
+			ch.Kind = ConsistencyKinds[i+1]
+			return
+		}
+	}
+	ch.Finished = now
+}
+
+// Step checks the next batch of entities, fixes them if the check fixes, and saves the check.
+//
+func (ch *ConsistencyCheck) Step(c appengine.Context) error {
+	if ch.Done() {
+		return nil
+	}
+
+	t, ok := consistencyTypes[ch.Kind]
+	if !ok {
+		return errConsistencyKind
+	}
+	q, err := startQuery(datastore.NewQuery(ch.Kind).Limit(ConsistencyBatch), ch.Cursor)
+	if err != nil {
+		return err
+	}
+
+	b := newConsistencyBatch()
+	var entities []interface{}
+	it := q.Run(c)
+	for {
+		e := reflect.New(t).Interface()
+		var key *datastore.Key
+		if key, err = it.Next(e); err == datastore.Done {
+			break
+		} else if err != nil {
+			return err
+		}
+		b.add(ch.Kind, key.IntID(), e)
+		entities = append(entities, e)
+	}
+
+	for kind, ids := range b.references(entities) {
+		if err = b.load(c, kind, ids); err != nil {
+			return err
+		}
+	}
+	for _, e := range entities {
+		ch.Checked++
+		ch.check(b, e)
+	}
+	if ch.Fix {
+		if err = b.save(c); err != nil {
+			return err
+		}
+	}
+
+	cursor, more, err := nextCursor(it, len(entities), ConsistencyBatch)
+	if err != nil {
+		return err
+	}
+	ch.next(cursor, more, time.Now())
+	return ch.Update(c)
+}
+
+// check checks an entity against the entities it links to.
+func (ch *ConsistencyCheck) check(b *consistencyBatch, e interface{}) {
+	switch e := e.(type) {
+	case *User:
+		ch.checkUser(b, e)
+	case *Team:
+		ch.checkTeam(b, e)
+	case *Tournament:
+		ch.checkTournament(b, e)
+	case *Predict:
+		ch.checkPredict(b, e)
+	case *Score:
+		ch.checkScore(b, e)
+	case *Accuracy:
+		ch.checkAccuracy(b, e)
+	}
+}
+
+// checkUser checks the teams, tournaments, predicts and scores of a user.
+func (ch *ConsistencyCheck) checkUser(b *consistencyBatch, u *User) {
+	ch.checkLinks(b, "User", u.Id, "TeamIds", u.TeamIds, "Team", "UserIds")
+	ch.checkLinks(b, "User", u.Id, "TournamentIds", u.TournamentIds, "Tournament", "UserIds")
+	ch.checkIds(b, "User", u.Id, "PredictIds", u.PredictIds, func(id int64) bool {
+		p := b.predict(id)
+		return p != nil && p.UserId == u.Id
+	})
+
+	for _, sot := range u.ScoreOfTournaments {
+		if s := b.score(sot.ScoreId); s != nil && s.UserId == u.Id && s.TournamentId == sot.TournamentId {
+			continue
+		}
+		ch.report("User", u.Id, fmt.Sprintf("%s ScoreOfTournaments %d", ConsistencyProblemDangling, sot.ScoreId), true)
+		if ch.Fix {
+			scoreID := sot.ScoreId
+			b.fix("User", u.Id, func(e interface{}) {
+				u := e.(*User)
+				var scores []ScoreOfTournament
+				for _, sot := range u.ScoreOfTournaments {
+					if sot.ScoreId != scoreID {
+						scores = append(scores, sot)
+					}
+				}
+				u.ScoreOfTournaments = scores
+			})
+		}
+	}
+}
+
+// checkTeam checks the members, admins, tournaments, accuracies and members count of a team.
+func (ch *ConsistencyCheck) checkTeam(b *consistencyBatch, t *Team) {
+	var members int64
+	for _, id := range t.UserIds {
+		if b.user(id) != nil {
+			members++
+		}
+	}
+	ch.checkLinks(b, "Team", t.Id, "UserIds", t.UserIds, "User", "TeamIds")
+	ch.checkIds(b, "Team", t.Id, "AdminIds", t.AdminIds, func(id int64) bool { return b.user(id) != nil })
+	ch.checkLinks(b, "Team", t.Id, "TournamentIds", t.TournamentIds, "Tournament", "TeamIds")
+
+	for _, aot := range t.AccOfTournaments {
+		if a := b.accuracy(aot.AccuracyId); a != nil && a.TeamId == t.Id && a.TournamentId == aot.TournamentId {
+			continue
+		}
+		ch.report("Team", t.Id, fmt.Sprintf("%s AccOfTournaments %d", ConsistencyProblemDangling, aot.AccuracyId), true)
+		if ch.Fix {
+			accuracyID := aot.AccuracyId
+			b.fix("Team", t.Id, func(e interface{}) {
+				t := e.(*Team)
+				var accs []AccOfTournaments
+				for _, aot := range t.AccOfTournaments {
+					if aot.AccuracyId != accuracyID {
+						accs = append(accs, aot)
+					}
+				}
+				t.AccOfTournaments = accs
+			})
+		}
+	}
+
+	if t.MembersCount != members {
+		ch.report("Team", t.Id, fmt.Sprintf("%s %d for %d members", ConsistencyProblemMembersCount, t.MembersCount, members), true)
+		if ch.Fix {
+			b.fix("Team", t.Id, func(e interface{}) {
+				t := e.(*Team)
+				t.MembersCount = int64(len(t.UserIds))
+			})
+		}
+	}
+}
+
+// checkTournament checks the participants, teams and admins of a tournament.
+func (ch *ConsistencyCheck) checkTournament(b *consistencyBatch, t *Tournament) {
+	ch.checkLinks(b, "Tournament", t.Id, "UserIds", t.UserIds, "User", "TournamentIds")
+	ch.checkLinks(b, "Tournament", t.Id, "TeamIds", t.TeamIds, "Team", "TournamentIds")
+	ch.checkIds(b, "Tournament", t.Id, "AdminIds", t.AdminIds, func(id int64) bool { return b.user(id) != nil })
+}
+
+// checkPredict checks that the user of a predict exists and has the predict.
+func (ch *ConsistencyCheck) checkPredict(b *consistencyBatch, p *Predict) {
+	u := b.user(p.UserId)
+	if u == nil {
+		ch.report("Predict", p.Id, fmt.Sprintf("%s of user %d", ConsistencyProblemOrphan, p.UserId), true)
+		if ch.Fix {
+			b.remove("Predict", p.Id)
+		}
+		return
+	}
+	if hasPredict(u, p.Id) {
+		return
+	}
+	ch.report("Predict", p.Id, fmt.Sprintf("%s User %d", ConsistencyProblemAsymmetric, u.Id), true)
+	if ch.Fix {
+		b.fix("User", u.Id, func(e interface{}) {
+			if u := e.(*User); !hasPredict(u, p.Id) {
+				u.PredictIds = append(u.PredictIds, p.Id)
+			}
+		})
+	}
+}
+
+// hasPredict reports whether a user has a predict, current or archived.
+func hasPredict(u *User, id int64) bool {
+	has, _ := helpers.Contains(u.PredictIds, id)
+	archived, _ := helpers.Contains(u.ArchivedPredictInds, id)
+	return has || archived
+}
+
+// checkScore checks that the user and the tournament of a score exist and that the user has the score.
+// A missing score is not added to a user who has another score in the tournament.
+func (ch *ConsistencyCheck) checkScore(b *consistencyBatch, s *Score) {
+	u := b.user(s.UserId)
+	if u == nil || b.tournament(s.TournamentId) == nil {
+		ch.report("Score", s.Id, fmt.Sprintf("%s of user %d in tournament %d", ConsistencyProblemOrphan, s.UserId, s.TournamentId), true)
+		if ch.Fix {
+			b.remove("Score", s.Id)
+		}
+		return
+	}
+	has, other := hasScore(u, s)
+	if has {
+		return
+	}
+	ch.report("Score", s.Id, fmt.Sprintf("%s User %d", ConsistencyProblemAsymmetric, u.Id), !other)
+	if ch.Fix && !other {
+		b.fix("User", u.Id, func(e interface{}) {
+			u := e.(*User)
+			if has, other := hasScore(u, s); !has && !other {
+				u.ScoreOfTournaments = append(u.ScoreOfTournaments, ScoreOfTournament{ScoreId: s.Id, TournamentId: s.TournamentId})
+			}
+		})
+	}
+}
+
+// hasScore reports whether a user has a score, and whether they have another score in its tournament.
+func hasScore(u *User, s *Score) (has, other bool) {
+	for _, sot := range u.ScoreOfTournaments {
+		if sot.ScoreId == s.Id {
+			return true, false
+		}
+		other = other || sot.TournamentId == s.TournamentId
+	}
+	return false, other
+}
+
+// checkAccuracy checks that the team and the tournament of an accuracy exist and that the team has the accuracy.
+// A missing accuracy is not added to a team which has another accuracy in the tournament.
+func (ch *ConsistencyCheck) checkAccuracy(b *consistencyBatch, a *Accuracy) {
+	t := b.team(a.TeamId)
+	if t == nil || b.tournament(a.TournamentId) == nil {
+		ch.report("Accuracy", a.Id, fmt.Sprintf("%s of team %d in tournament %d", ConsistencyProblemOrphan, a.TeamId, a.TournamentId), true)
+		if ch.Fix {
+			b.remove("Accuracy", a.Id)
+		}
+		return
+	}
+	has, other := hasAccuracy(t, a)
+	if has {
+		return
+	}
+	ch.report("Accuracy", a.Id, fmt.Sprintf("%s Team %d", ConsistencyProblemAsymmetric, t.Id), !other)
+	if ch.Fix && !other {
+		b.fix("Team", t.Id, func(e interface{}) {
+			t := e.(*Team)
+			if has, other := hasAccuracy(t, a); !has && !other {
+				t.AccOfTournaments = append(t.AccOfTournaments, AccOfTournaments{AccuracyId: a.Id, TournamentId: a.TournamentId})
+			}
+		})
+	}
+}
+
+// hasAccuracy reports whether a team has an accuracy, and whether it has another accuracy in its tournament.
+func hasAccuracy(t *Team, a *Accuracy) (has, other bool) {
+	for _, aot := range t.AccOfTournaments {
+		if aot.AccuracyId == a.Id {
+			return true, false
+		}
+		other = other || aot.TournamentId == a.TournamentId
+	}
+	return false, other
+}
+
+// checkIds reports the ids of field whose entity does not exist, and removes them when the check fixes.
+func (ch *ConsistencyCheck) checkIds(b *consistencyBatch, kind string, id int64, field string, ids []int64, exists func(int64) bool) {
+	for _, other := range ids {
+		if exists(other) {
+			continue
+		}
+		ch.report(kind, id, fmt.Sprintf("%s %s %d", ConsistencyProblemDangling, field, other), true)
+		if ch.Fix {
+			dangling := other
+			b.fix(kind, id, func(e interface{}) { linkID(e, field, dangling, false) })
+		}
+	}
+}
+
+// checkLinks checks the ids of field, which link to entities of otherKind whose backField lists the ids linking
+// back to the entity. Dangling ids are removed and the missing links back are added when the check fixes.
+func (ch *ConsistencyCheck) checkLinks(b *consistencyBatch, kind string, id int64, field string, ids []int64, otherKind, backField string) {
+	ch.checkIds(b, kind, id, field, ids, func(other int64) bool { return b.get(otherKind, other) != nil })
+	for _, other := range ids {
+		e := b.get(otherKind, other)
+		if e == nil {
+			continue
+		}
+		if has, _ := helpers.Contains(reflect.ValueOf(e).Elem().FieldByName(backField).Interface().([]int64), id); has {
+			continue
+		}
+		ch.report(kind, id, fmt.Sprintf("%s %s %d", ConsistencyProblemAsymmetric, otherKind, other), true)
+		if ch.Fix {
+			b.fix(otherKind, other, func(e interface{}) { linkID(e, backField, id, true) })
+		}
+	}
+}
+
+// consistencyBatch holds the entities of a batch and the entities they link to.
+// A nil entity is known not to exist.
+// The fixes of an entity are applied to the entity of the batch, then again to the stored
+// entity when the batch is saved.
+//
+type consistencyBatch struct {
+	entities map[string]map[int64]interface{}
+	fixes    map[string]map[int64][]func(e interface{})
+	removed  map[string]map[int64]bool
+}
+
+func newConsistencyBatch() *consistencyBatch {
+	b := &consistencyBatch{
+		entities: make(map[string]map[int64]interface{}),
+		fixes:    make(map[string]map[int64][]func(e interface{})),
+		removed:  make(map[string]map[int64]bool),
+	}
+	for _, kind := range ConsistencyKinds {
+		b.entities[kind] = make(map[int64]interface{})
+		b.fixes[kind] = make(map[int64][]func(e interface{}))
+		b.removed[kind] = make(map[int64]bool)
+	}
+	return b
+}
+
+// add adds an entity to the batch, e is nil if it does not exist.
+func (b *consistencyBatch) add(kind string, id int64, e interface{}) {
+	b.entities[kind][id] = e
+}
+
+// get returns the entity of a kind with the given id, or nil if it does not exist or was removed.
+func (b *consistencyBatch) get(kind string, id int64) interface{} {
+	if b.removed[kind][id] {
+		return nil
+	}
+	return b.entities[kind][id]
+}
+
+func (b *consistencyBatch) user(id int64) *User {
+	u, _ := b.get("User", id).(*User)
+	return u
+}
+
+func (b *consistencyBatch) team(id int64) *Team {
+	t, _ := b.get("Team", id).(*Team)
+	return t
+}
+
+func (b *consistencyBatch) tournament(id int64) *Tournament {
+	t, _ := b.get("Tournament", id).(*Tournament)
+	return t
+}
+
+func (b *consistencyBatch) predict(id int64) *Predict {
+	p, _ := b.get("Predict", id).(*Predict)
+	return p
+}
+
+func (b *consistencyBatch) score(id int64) *Score {
+	s, _ := b.get("Score", id).(*Score)
+	return s
+}
+
+func (b *consistencyBatch) accuracy(id int64) *Accuracy {
+	a, _ := b.get("Accuracy", id).(*Accuracy)
+	return a
+}
+
+// fix applies a fix to an entity of the batch and keeps it to fix the stored entity.
+func (b *consistencyBatch) fix(kind string, id int64, f func(e interface{})) {
+	if e := b.get(kind, id); e != nil {
+		f(e)
+	}
+	b.fixes[kind][id] = append(b.fixes[kind][id], f)
+}
+
+// remove marks an entity to be deleted.
+func (b *consistencyBatch) remove(kind string, id int64) {
+	b.removed[kind][id] = true
+}
+
+// references returns the ids of the entities linked to by entities, by kind.
+func (b *consistencyBatch) references(entities []interface{}) map[string][]int64 {
+	refs := make(map[string][]int64)
+	for _, e := range entities {
+		switch e := e.(type) {
+		case *User:
+			refs["Team"] = append(refs["Team"], e.TeamIds...)
+			refs["Tournament"] = append(refs["Tournament"], e.TournamentIds...)
+			refs["Predict"] = append(refs["Predict"], e.PredictIds...)
+			for _, sot := range e.ScoreOfTournaments {
+				refs["Score"] = append(refs["Score"], sot.ScoreId)
+			}
+		case *Team:
+			refs["User"] = append(append(refs["User"], e.UserIds...), e.AdminIds...)
+			refs["Tournament"] = append(refs["Tournament"], e.TournamentIds...)
+			for _, aot := range e.AccOfTournaments {
+				refs["Accuracy"] = append(refs["Accuracy"], aot.AccuracyId)
+			}
+		case *Tournament:
+			refs["User"] = append(append(refs["User"], e.UserIds...), e.AdminIds...)
+			refs["Team"] = append(refs["Team"], e.TeamIds...)
+		case *Predict:
+			refs["User"] = append(refs["User"], e.UserId)
+		case *Score:
+			refs["User"] = append(refs["User"], e.UserId)
+			refs["Tournament"] = append(refs["Tournament"], e.TournamentId)
+		case *Accuracy:
+			refs["Team"] = append(refs["Team"], e.TeamId)
+			refs["Tournament"] = append(refs["Tournament"], e.TournamentId)
+		}
+	}
+	return refs
+}
+
+// load gets the entities of a kind with the given ids which are not in the batch yet.
+func (b *consistencyBatch) load(c appengine.Context, kind string, ids []int64) error {
+	t, ok := consistencyTypes[kind]
+	if !ok {
+		return errConsistencyKind
+	}
+	var missing []int64
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if _, loaded := b.entities[kind][id]; !loaded && !seen[id] {
+			seen[id] = true
+			missing = append(missing, id)
+		}
+	}
+
+	for start := 0; start < len(missing); start += maxGetMulti {
+		end := start + maxGetMulti
+		if end > len(missing) {
+			end = len(missing)
+		}
+		chunk := missing[start:end]
+		dst := reflect.MakeSlice(reflect.SliceOf(t), len(chunk), len(chunk))
+		found, err := getMultiFound(c, datastoreKeys(c, kind, chunk), dst.Interface())
+		if err != nil {
+			return err
+		}
+		for i, id := range chunk {
+			if found[i] {
+				b.add(kind, id, dst.Index(i).Addr().Interface())
+			} else {
+				b.add(kind, id, nil)
+			}
+		}
+	}
+	return nil
+}
+
+// save applies the fixes to the stored entities and deletes the removed ones.
+// Each entity is read again and fixed in its own transaction, so that the changes made
+// since the batch was loaded are kept.
+func (b *consistencyBatch) save(c appengine.Context) error {
+	for _, kind := range ConsistencyKinds {
+		t := consistencyTypes[kind]
+		for id, fixes := range b.fixes[kind] {
+			if b.removed[kind][id] {
+				continue
+			}
+			key := datastore.NewKey(c, kind, "", id, nil)
+			fixes := fixes
+			err := datastore.RunInTransaction(c, func(tc appengine.Context) error {
+				e := reflect.New(t).Interface()
+				if err := datastore.Get(tc, key, e); err == datastore.ErrNoSuchEntity {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				for _, f := range fixes {
+					f(e)
+				}
+				_, err := datastore.Put(tc, key, e)
+				return err
+			}, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		var removed []int64
+		for id := range b.removed[kind] {
+			removed = append(removed, id)
+		}
+		for start := 0; start < len(removed); start += maxPutMulti {
+			end := start + maxPutMulti
+			if end > len(removed) {
+				end = len(removed)
+			}
+			if err := datastore.DeleteMulti(c, datastoreKeys(c, kind, removed[start:end])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConsistencyCheckNext(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		title    string
+		kind     string
+		cursor   string
+		more     bool
+		wantKind string
+		done     bool
+	}{
+		{"more in the kind", "Team", "abc", true, "Team", false},
+		{"next kind", "Team", "", false, "Tournament", false},
+		{"last kind", "Accuracy", "", false, "Accuracy", true},
+	}
+	for _, test := range tests {
+		ch := ConsistencyCheck{Kind: test.kind, Cursor: "previous"}
+		ch.next(test.cursor, test.more, now)
+		if ch.Kind != test.wantKind || ch.Cursor != test.cursor || ch.Done() != test.done {
+			t.Errorf("TestConsistencyCheckNext(%q): got %v %q done %v wanted %v %q done %v", test.title, ch.Kind, ch.Cursor, ch.Done(), test.wantKind, test.cursor, test.done)
+		}
+	}
+}
+
+// consistencyTestBatch returns a batch where
+//	- the user is in a team and a tournament which do not exist,
+//	- the team does not list the user as a member and counts 3 members,
+//	- the tournament lists a team which does not exist,
+//	- the user has a predict and a score of another user.
+func consistencyTestBatch() *consistencyBatch {
+	b := newConsistencyBatch()
+	b.add("User", 1, &User{Id: 1, TeamIds: []int64{10, 11}, TournamentIds: []int64{20, 21}, PredictIds: []int64{30, 31}, ScoreOfTournaments: []ScoreOfTournament{{ScoreId: 40, TournamentId: 20}}})
+	b.add("User", 2, &User{Id: 2})
+	b.add("Team", 10, &Team{Id: 10, UserIds: []int64{2}, MembersCount: 3, TournamentIds: []int64{20}})
+	b.add("Team", 11, nil)
+	b.add("Tournament", 20, &Tournament{Id: 20, UserIds: []int64{1}, TeamIds: []int64{10, 12}})
+	b.add("Tournament", 21, nil)
+	b.add("Team", 12, nil)
+	b.add("Predict", 30, &Predict{Id: 30, UserId: 1})
+	b.add("Predict", 31, &Predict{Id: 31, UserId: 2})
+	b.add("Score", 40, &Score{Id: 40, UserId: 2, TournamentId: 20})
+	return b
+}
+
+func TestConsistencyCheckUser(t *testing.T) {
+	tests := []struct {
+		title           string
+		fix             bool
+		inconsistencies int64
+		fixed           int64
+		teamIds         []int64
+		predictIds      []int64
+		members         []int64
+	}{
+		{"report", false, 5, 0, []int64{10, 11}, []int64{30, 31}, []int64{2}},
+		{"fix", true, 5, 5, []int64{10}, []int64{30}, []int64{2, 1}},
+	}
+	for _, test := range tests {
+		b := consistencyTestBatch()
+		ch := ConsistencyCheck{Fix: test.fix}
+		u := b.user(1)
+		ch.check(b, u)
+		if ch.Inconsistencies != test.inconsistencies || ch.Fixed != test.fixed {
+			t.Errorf("TestConsistencyCheckUser(%q): got %d inconsistencies %d fixed wanted %d %d: %v", test.title, ch.Inconsistencies, ch.Fixed, test.inconsistencies, test.fixed, ch.Problems)
+		}
+		if !reflect.DeepEqual(u.TeamIds, test.teamIds) || !reflect.DeepEqual(u.PredictIds, test.predictIds) {
+			t.Errorf("TestConsistencyCheckUser(%q): got teams %v predicts %v wanted %v %v", test.title, u.TeamIds, u.PredictIds, test.teamIds, test.predictIds)
+		}
+		if members := b.team(10).UserIds; !reflect.DeepEqual(members, test.members) {
+			t.Errorf("TestConsistencyCheckUser(%q): got members %v wanted %v", test.title, members, test.members)
+		}
+		if test.fix && (len(b.fixes["User"][1]) == 0 || len(b.fixes["Team"][10]) == 0 || len(u.ScoreOfTournaments) != 0) {
+			t.Errorf("TestConsistencyCheckUser(%q): got fixes %v scores %v wanted user and team fixed and no scores", test.title, b.fixes, u.ScoreOfTournaments)
+		}
+	}
+}
+
+func TestConsistencyCheckTeam(t *testing.T) {
+	tests := []struct {
+		title        string
+		fix          bool
+		members      []int64
+		membersCount int64
+	}{
+		{"report", false, []int64{2}, 3},
+		{"fix", true, []int64{2}, 1},
+	}
+	for _, test := range tests {
+		b := consistencyTestBatch()
+		ch := ConsistencyCheck{Fix: test.fix}
+		team := b.team(10)
+		ch.check(b, team)
+		// user 2 is not in team 10, tournament 20 does not list team 10 and the members count is wrong.
+		if ch.Inconsistencies != 2 {
+			t.Errorf("TestConsistencyCheckTeam(%q): got %d inconsistencies wanted 2: %v", test.title, ch.Inconsistencies, ch.Problems)
+		}
+		if !reflect.DeepEqual(team.UserIds, test.members) || team.MembersCount != test.membersCount {
+			t.Errorf("TestConsistencyCheckTeam(%q): got members %v count %d wanted %v %d", test.title, team.UserIds, team.MembersCount, test.members, test.membersCount)
+		}
+		if has := len(b.user(2).TeamIds) == 1; has != test.fix {
+			t.Errorf("TestConsistencyCheckTeam(%q): got user 2 in team %v wanted %v", test.title, has, test.fix)
+		}
+	}
+}
+
+func TestConsistencyCheckTournament(t *testing.T) {
+	b := consistencyTestBatch()
+	ch := ConsistencyCheck{Fix: true}
+	tournament := b.tournament(20)
+	ch.check(b, tournament)
+	// team 12 does not exist and team 10 already lists the tournament.
+	if ch.Inconsistencies != 1 || ch.Fixed != 1 {
+		t.Errorf("TestConsistencyCheckTournament: got %d inconsistencies %d fixed wanted 1 1: %v", ch.Inconsistencies, ch.Fixed, ch.Problems)
+	}
+	if !reflect.DeepEqual(tournament.TeamIds, []int64{10}) {
+		t.Errorf("TestConsistencyCheckTournament: got teams %v wanted [10]", tournament.TeamIds)
+	}
+}
+
+func TestConsistencyCheckOrphans(t *testing.T) {
+	b := consistencyTestBatch()
+	b.add("Predict", 32, &Predict{Id: 32, UserId: 3})
+	b.add("User", 3, nil)
+	b.add("Accuracy", 50, &Accuracy{Id: 50, TeamId: 10, TournamentId: 20})
+	b.add("Accuracy", 51, &Accuracy{Id: 51, TeamId: 11, TournamentId: 20})
+
+	ch := ConsistencyCheck{Fix: true}
+	for _, e := range []interface{}{b.predict(32), b.predict(31), b.accuracy(50), b.accuracy(51)} {
+		ch.check(b, e)
+	}
+	if ch.Inconsistencies != 4 || ch.Fixed != 4 {
+		t.Errorf("TestConsistencyCheckOrphans: got %d inconsistencies %d fixed wanted 4 4: %v", ch.Inconsistencies, ch.Fixed, ch.Problems)
+	}
+	if !b.removed["Predict"][32] || !b.removed["Accuracy"][51] || b.predict(32) != nil {
+		t.Errorf("TestConsistencyCheckOrphans: got removed %v wanted predict 32 and accuracy 51", b.removed)
+	}
+	if !reflect.DeepEqual(b.user(2).PredictIds, []int64{31}) {
+		t.Errorf("TestConsistencyCheckOrphans: got predicts of user 2 %v wanted [31]", b.user(2).PredictIds)
+	}
+	if want := []AccOfTournaments{{AccuracyId: 50, TournamentId: 20}}; !reflect.DeepEqual(b.team(10).AccOfTournaments, want) {
+		t.Errorf("TestConsistencyCheckOrphans: got accuracies of team 10 %v wanted %v", b.team(10).AccOfTournaments, want)
+	}
+}
+
+func TestConsistencyCheckScore(t *testing.T) {
+	b := consistencyTestBatch()
+	b.add("Score", 41, &Score{Id: 41, UserId: 1, TournamentId: 20})
+
+	ch := ConsistencyCheck{Fix: true}
+	ch.check(b, b.score(40))
+	ch.check(b, b.score(41))
+	// score 40 is added to user 2, score 41 is not added to user 1 which has a score in the tournament.
+	if ch.Inconsistencies != 2 || ch.Fixed != 1 {
+		t.Errorf("TestConsistencyCheckScore: got %d inconsistencies %d fixed wanted 2 1: %v", ch.Inconsistencies, ch.Fixed, ch.Problems)
+	}
+	if want := []ScoreOfTournament{{ScoreId: 40, TournamentId: 20}}; !reflect.DeepEqual(b.user(2).ScoreOfTournaments, want) {
+		t.Errorf("TestConsistencyCheckScore: got scores of user 2 %v wanted %v", b.user(2).ScoreOfTournaments, want)
+	}
+}
+
+func TestConsistencyFixesStoredEntity(t *testing.T) {
+	b := consistencyTestBatch()
+	ch := ConsistencyCheck{Fix: true}
+	ch.check(b, b.user(1))
+
+	// user 1 joined team 13 and made predict 33 since the batch was loaded.
+	stored := &User{Id: 1, TeamIds: []int64{10, 11, 13}, TournamentIds: []int64{20, 21}, PredictIds: []int64{30, 31, 33}, ScoreOfTournaments: []ScoreOfTournament{{ScoreId: 40, TournamentId: 20}}}
+	for _, f := range b.fixes["User"][1] {
+		f(stored)
+	}
+	if want := []int64{10, 13}; !reflect.DeepEqual(stored.TeamIds, want) {
+		t.Errorf("TestConsistencyFixesStoredEntity: got teams %v wanted %v", stored.TeamIds, want)
+	}
+	if want := []int64{30, 33}; !reflect.DeepEqual(stored.PredictIds, want) {
+		t.Errorf("TestConsistencyFixesStoredEntity: got predicts %v wanted %v", stored.PredictIds, want)
+	}
+	if len(stored.ScoreOfTournaments) != 0 {
+		t.Errorf("TestConsistencyFixesStoredEntity: got scores %v wanted none", stored.ScoreOfTournaments)
+	}
+}
+
+func TestConsistencyBatchReferences(t *testing.T) {
+	b := consistencyTestBatch()
+	refs := b.references([]interface{}{b.user(1), b.tournament(20)})
+	want := map[string][]int64{
+		"Team":       {10, 11, 10, 12},
+		"Tournament": {20, 21},
+		"Predict":    {30, 31},
+		"Score":      {40},
+		"User":       {1},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("TestConsistencyBatchReferences: got %v wanted %v", refs, want)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"appengine"
+	"appengine/datastore"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+//
+var ErrInvalidCursor = errors.New("model/cursor: invalid cursor")
+
+// encodeIDCursor returns the opaque cursor of a list of ids after the id at index i.
+func encodeIDCursor(i int, id int64) string {
+	return base64.URLEncoding.EncodeToString([]byte(strconv.Itoa(i) + "." + strconv.FormatInt(id, 10)))
+}
+
+// decodeIDCursor returns the index and the id of a cursor of a list of ids.
+func decodeIDCursor(cursor string) (int, int64, error) {
+	b, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	parts := strings.Split(string(b), ".")
+	if len(parts) != 2 {
+		return 0, 0, ErrInvalidCursor
+	}
+	i, err := strconv.Atoi(parts[0])
+	if err != nil || i < 0 {
+		return 0, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	return i, id, nil
+}
+
+// idsByCursor returns count ids of a list walked backward, the most recent first, starting after cursor,
+// and the cursor of the next page or an empty cursor after the last page. An empty cursor starts with the
+// last id. The cursor holds the last id returned so that the next page does not skip nor repeat ids when
+// ids are added or removed meanwhile.
+func idsByCursor(ids []int64, count int64, cursor string) ([]int64, string, error) {
+	start := len(ids) - 1
+	if len(cursor) > 0 {
+		i, id, err := decodeIDCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = i - 1
+		if i >= len(ids) || ids[i] != id {
+			// the list has changed, start after the id if it is still in the list.
+			for j := len(ids) - 1; j >= 0; j-- {
+				if ids[j] == id {
+					start = j - 1
+					break
+				}
+			}
+		}
+		if start >= len(ids) {
+			start = len(ids) - 1
+		}
+	}
+
+	var paged []int64
+	i := start
+	for ; i >= 0 && int64(len(paged)) < count; i-- {
+		paged = append(paged, ids[i])
+	}
+	if i < 0 || len(paged) == 0 {
+		return paged, "", nil
+	}
+	return paged, encodeIDCursor(i+1, ids[i+1]), nil
+}
+
+// queryByCursor runs q ordered by descending key from cursor. next gets the next entity of
+// the iterator and reports whether it is kept in the page, it is called until count entities are kept.
+// It returns the cursor of the next page or an empty cursor after the last page.
+func queryByCursor(c appengine.Context, q *datastore.Query, count int64, cursor string, next func(it *datastore.Iterator) (bool, error)) (string, error) {
+	q, err := startQuery(q.Order("-__key__"), cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+
+	it := q.Run(c)
+	for n := int64(0); n < count; {
+		kept, err := next(it)
+		if err == datastore.Done {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		if kept {
+			n++
+		}
+	}
+	cur, err := it.Cursor()
+	if err != nil {
+		return "", err
+	}
+	return cur.String(), nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIDsByCursor(t *testing.T) {
+	ids := []int64{10, 20, 30, 40, 50}
+	after := func(i int) string { return encodeIDCursor(i, ids[i]) }
+	tests := []struct {
+		title  string
+		ids    []int64
+		count  int64
+		cursor string
+		want   []int64
+		next   string
+	}{
+		{"first page", ids, 2, "", []int64{50, 40}, after(3)},
+		{"next page", ids, 2, after(3), []int64{30, 20}, after(1)},
+		{"last page", ids, 2, after(1), []int64{10}, ""},
+		{"whole list", ids, 5, "", []int64{50, 40, 30, 20, 10}, ""},
+		{"empty list", nil, 2, "", nil, ""},
+		{"id added", []int64{10, 20, 30, 40, 50, 60}, 2, after(3), []int64{30, 20}, encodeIDCursor(1, 20)},
+		{"id removed before", []int64{20, 30, 40, 50}, 2, after(3), []int64{30, 20}, ""},
+		{"id of cursor removed", []int64{10, 20, 30, 50}, 2, after(3), []int64{30, 20}, encodeIDCursor(1, 20)},
+	}
+	for _, test := range tests {
+		got, next, err := idsByCursor(test.ids, test.count, test.cursor)
+		if err != nil {
+			t.Errorf("TestIDsByCursor(%q): got error %v", test.title, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) || next != test.next {
+			t.Errorf("TestIDsByCursor(%q): got %v %q wanted %v %q", test.title, got, next, test.want, test.next)
+		}
+	}
+}
+
+func TestIDsByCursorInvalid(t *testing.T) {
+	tests := []string{"not base64!", "MTI=", "eC4xMg==", "LTEuMTI="}
+	for _, cursor := range tests {
+		if _, _, err := idsByCursor([]int64{1, 2}, 2, cursor); err != ErrInvalidCursor {
+			t.Errorf("TestIDsByCursorInvalid(%q): got %v wanted %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// Frequencies of the activity digest email.
+//
+const (
+	DigestNone   = "none"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// maxDigestActivities is the maximum number of activities fetched to build a digest.
+const maxDigestActivities = 200
+
+// Digest holds the activities of a user summarized in a digest email.
+//
+type Digest struct {
+	Results  []*Activity // match results.
+	Score    *Activity   // latest score of the user.
+	Rankings []*Activity // accuracy changes of the user's teams.
+	Requests []*Activity // requests to join the teams the user is admin of.
+}
+
+// IsDigestFrequencyValid indicates if a digest frequency is supported.
+//
+func IsDigestFrequencyValid(frequency string) bool {
+	return frequency == DigestNone || frequency == DigestDaily || frequency == DigestWeekly
+}
+
+// DigestPeriod returns the duration covered by a digest of a given frequency.
+// Zero is returned when no digest has to be sent.
+//
+func DigestPeriod(frequency string) time.Duration {
+	switch frequency {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// NewDigest builds a digest from activities sorted from the newest to the oldest.
+//
+func NewDigest(activities []*Activity) Digest {
+	var d Digest
+	for _, a := range activities {
+		switch a.Type {
+		case "match":
+			d.Results = append(d.Results, a)
+		case "score":
+			if d.Score == nil {
+				d.Score = a
+			}
+		case "accuracy":
+			d.Rankings = append(d.Rankings, a)
+		case "request":
+			d.Requests = append(d.Requests, a)
+		}
+	}
+	return d
+}
+
+// IsEmpty indicates if there is nothing to report in a digest.
+//
+func (d Digest) IsEmpty() bool {
+	return len(d.Results) == 0 && d.Score == nil && len(d.Rankings) == 0 && len(d.Requests) == 0
+}
+
+// ActivitiesSince returns the activities of a user published after a given date,
+// from the newest to the oldest.
+//
+func (u *User) ActivitiesSince(c appengine.Context, since time.Time) []*Activity {
+	var activities []*Activity
+
+	// activity ids are stored in publication order, loop backward until an older activity is found.
+	for i := len(u.ActivityIds) - 1; i >= 0 && len(activities) < maxDigestActivities; i-- {
+		key := datastore.NewKey(c, "Activity", "", u.ActivityIds[i], nil)
+
+		var activity Activity
+		if err := datastore.Get(c, key, &activity); err != nil {
+			log.Errorf(c, " User.ActivitiesSince: error occurred during Get call id: %v: %v", u.ActivityIds[i], err)
+			continue // skip activity if not found..
+		}
+		if !activity.Published.After(since) {
+			break
+		}
+		activities = append(activities, &activity)
+	}
+
+	return activities
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewDigest(t *testing.T) {
+	result := &Activity{Id: 1, Type: "match"}
+	newScore := &Activity{Id: 2, Type: "score"}
+	oldScore := &Activity{Id: 3, Type: "score"}
+	accuracy := &Activity{Id: 4, Type: "accuracy"}
+	request := &Activity{Id: 5, Type: "request"}
+	predict := &Activity{Id: 6, Type: "predict"}
+
+	tests := []struct {
+		name       string
+		activities []*Activity
+		want       Digest
+		empty      bool
+	}{
+		{"no activities", nil, Digest{}, true},
+		{"no digest activities", []*Activity{predict}, Digest{}, true},
+		{
+			"all activities",
+			[]*Activity{result, newScore, predict, accuracy, oldScore, request},
+			Digest{
+				Results:  []*Activity{result},
+				Score:    newScore,
+				Rankings: []*Activity{accuracy},
+				Requests: []*Activity{request},
+			},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		got := NewDigest(test.activities)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestNewDigest(%q): got %+v wanted %+v", test.name, got, test.want)
+		}
+		if got.IsEmpty() != test.empty {
+			t.Errorf("TestNewDigest(%q): got empty %v wanted %v", test.name, got.IsEmpty(), test.empty)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// Identity errors.
+//
+var (
+	ErrIdentityLinked = errors.New("model/identity: identity linked to another user")
+	ErrIdentityLast   = errors.New("model/identity: last way to sign in")
+)
+
+// Identity links a user to their account at a sign in provider.
+// Its key name is the provider and the id of the account at the provider, the subject.
+//
+type Identity struct {
+	Provider string
+	Subject  string
+	UserId   int64
+	Email    string `datastore:",noindex"`
+	Created  time.Time
+}
+
+// IdentityJSON is the JSON representation of an identity.
+//
+type IdentityJSON struct {
+	Provider *string    `json:",omitempty"`
+	Email    *string    `json:",omitempty"`
+	Created  *time.Time `json:",omitempty"`
+}
+
+// IdentityProfile is the profile of a user as returned by a provider, it is used when the user is created.
+//
+type IdentityProfile struct {
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+}
+
+func identityKey(c appengine.Context, provider, subject string) *datastore.Key {
+	return datastore.NewKey(c, "Identity", provider+":"+subject, 0, nil)
+}
+
+// IdentityByProvider gets the identity of an account at a provider.
+//
+func IdentityByProvider(c appengine.Context, provider, subject string) (*Identity, error) {
+	var i Identity
+	if err := datastore.Get(c, identityKey(c, provider, subject), &i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// LinkIdentity links an account at a provider to a user.
+// It returns ErrIdentityLinked if the account is linked to another user.
+//
+func LinkIdentity(c appengine.Context, userID int64, provider, subject, email string) (*Identity, error) {
+	i := &Identity{Provider: provider, Subject: subject, UserId: userID, Email: email, Created: time.Now()}
+
+	err := datastore.RunInTransaction(c, func(tc appengine.Context) error {
+		var existing Identity
+		err := datastore.Get(tc, identityKey(tc, provider, subject), &existing)
+		if err == nil {
+			if existing.UserId != userID {
+				return ErrIdentityLinked
+			}
+			*i = existing
+			return nil
+		} else if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		_, err = datastore.Put(tc, identityKey(tc, provider, subject), i)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// FindIdentities returns the identities of a user.
+//
+func FindIdentities(c appengine.Context, userID int64) []*Identity {
+	desc := "Identity.FindIdentities:"
+	q := datastore.NewQuery("Identity").Filter("UserId"+" =", userID)
+
+	var identities []*Identity
+	if _, err := q.GetAll(c, &identities); err != nil {
+		log.Errorf(c, "%s an error occurred during GetAll: %v", desc, err)
+		return nil
+	}
+	return identities
+}
+
+// Update an identity.
+//
+func (i *Identity) Update(c appengine.Context) error {
+	_, err := datastore.Put(c, identityKey(c, i.Provider, i.Subject), i)
+	return err
+}
+
+// Destroy an identity, the user can no longer sign in with it.
+//
+func (i *Identity) Destroy(c appengine.Context) error {
+	return datastore.Delete(c, identityKey(c, i.Provider, i.Subject))
+}
+
+// hasLocalAccount indicates if a user can sign in with an email and a password.
+func hasLocalAccount(c appengine.Context, userID int64) bool {
+	n, err := datastore.NewQuery("LocalAccount").Filter("UserId"+" =", userID).KeysOnly().Count(c)
+	return err == nil && n > 0
+}
+
+// UnlinkIdentity unlinks the accounts of a provider from a user.
+// It returns ErrIdentityLast if the user would have no way left to sign in, and datastore.ErrNoSuchEntity if nothing is linked.
+//
+func UnlinkIdentity(c appengine.Context, userID int64, provider string) error {
+	identities := FindIdentities(c, userID)
+
+	var unlinked []*Identity
+	for _, i := range identities {
+		if i.Provider == provider {
+			unlinked = append(unlinked, i)
+		}
+	}
+	if len(unlinked) == 0 {
+		return datastore.ErrNoSuchEntity
+	}
+	if len(unlinked) == len(identities) && !hasLocalAccount(c, userID) {
+		return ErrIdentityLast
+	}
+
+	for _, i := range unlinked {
+		if err := i.Destroy(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signinProfile finds or creates the user of a profile which is not linked yet.
+// A verified email finds the user of the email. The username of a profile never finds a user,
+// it is chosen by the owner of the account at the provider and may be the username of another user.
+func signinProfile(c appengine.Context, provider string, p IdentityProfile) (*User, error) {
+	if len(p.Email) > 0 && p.EmailVerified {
+		return SigninUserByEmail(c, p.Email)
+	}
+
+	base := p.Username
+	if len(base) == 0 {
+		base = p.Name
+	}
+	if len(base) == 0 {
+		base = provider
+	}
+	username := availableUsername(c, base)
+
+	name := p.Name
+	if len(name) == 0 {
+		name = username
+	}
+	return SigninUser(c, "Username", "", username, name)
+}
+
+// SigninIdentity returns the user linked to an account at a provider.
+// If the account is not linked yet, the user is found or created from the profile and the account is linked to them.
+//
+func SigninIdentity(c appengine.Context, provider, subject string, p IdentityProfile) (*User, error) {
+	if i, err := IdentityByProvider(c, provider, subject); err == nil {
+		return UserByID(c, i.UserId)
+	} else if err != datastore.ErrNoSuchEntity {
+		return nil, err
+	}
+
+	u, err := signinProfile(c, provider, p)
+	if err != nil {
+		return nil, err
+	}
+
+	email := ""
+	if p.EmailVerified {
+		email = strings.ToLower(p.Email)
+	}
+	if _, err = LinkIdentity(c, u.Id, provider, subject, email); err == ErrIdentityLinked {
+		// linked by a concurrent sign in.
+		var i *Identity
+		if i, err = IdentityByProvider(c, provider, subject); err != nil {
+			return nil, err
+		}
+		return UserByID(c, i.UserId)
+	} else if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
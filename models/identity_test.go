@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"testing"
+
+	"appengine/aetest"
+)
+
+// TestIdentitySigninIdentity tests that an account which is not linked yet only finds a user by verified email.
+//
+func TestIdentitySigninIdentity(t *testing.T) {
+	var c aetest.Context
+	var err error
+	options := aetest.Options{StronglyConsistentDatastore: true}
+
+	if c, err = aetest.NewContext(&options); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var victim *User
+	if victim, err = CreateUser(c, "foo@bar.com", "john.snow", "john snow", "crow", false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title   string
+		subject string
+		profile IdentityProfile
+		found   bool
+	}{
+		{"display name of an existing username", "1", IdentityProfile{Name: "john.snow"}, false},
+		{"username of an existing username", "2", IdentityProfile{Username: "john.snow", Name: "john snow"}, false},
+		{"unverified email", "3", IdentityProfile{Email: "foo@bar.com", Name: "john.snow"}, false},
+		{"verified email", "4", IdentityProfile{Email: "foo@bar.com", EmailVerified: true, Name: "someone"}, true},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		var got *User
+		if got, err = SigninIdentity(c, "facebook", test.subject, test.profile); err != nil {
+			t.Errorf("Error: %v", err)
+			continue
+		}
+		if found := got.Id == victim.Id; found != test.found {
+			t.Errorf("Error: want existing user found: %v, got user %v (%v)", test.found, got.Id, got.Username)
+		}
+		if !test.found && got.Username == victim.Username {
+			t.Errorf("Error: a new user should not have the username %v", victim.Username)
+		}
+
+		// the account is now linked to the user.
+		var again *User
+		if again, err = SigninIdentity(c, "facebook", test.subject, IdentityProfile{}); err != nil || again.Id != got.Id {
+			t.Errorf("Error: want user %v signed in again, got %v, %v", got.Id, again, err)
+		}
+	}
+}
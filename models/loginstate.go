@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+)
+
+// LoginStateDuration is the time a user has to sign in with a provider.
+const LoginStateDuration = 10 * time.Minute
+
+// ErrLoginStateInvalid is returned when the state of a login is unknown, expired, or used by another browser.
+//
+var ErrLoginStateInvalid = errors.New("model/loginstate: invalid login state")
+
+// LoginState holds a login with an identity provider until its callback.
+// Its key name is the provider and the state of the login, so that concurrent logins do not clash.
+// The login is bound to the browser which started it with a cookie, only the hash of the cookie is stored.
+//
+type LoginState struct {
+	Provider    string
+	State       string
+	Nonce       string `datastore:",noindex"`
+	Verifier    string `datastore:",noindex"`
+	Secret      string `datastore:",noindex"`
+	BindingHash string `datastore:",noindex"`
+	LinkUserId  int64  `datastore:",noindex"` // user the identity is linked to, 0 to sign in.
+	Expires     time.Time
+}
+
+func loginStateKey(c appengine.Context, provider, state string) *datastore.Key {
+	return datastore.NewKey(c, "LoginState", provider+":"+state, 0, nil)
+}
+
+// CreateLoginState stores the state of a login bound to a browser.
+//
+func CreateLoginState(c appengine.Context, s *LoginState, binding string) error {
+	s.BindingHash = HashToken(binding)
+	s.Expires = time.Now().Add(LoginStateDuration)
+	_, err := datastore.Put(c, loginStateKey(c, s.Provider, s.State), s)
+	return err
+}
+
+// Check verifies the browser binding of a login state at a given time.
+//
+func (s *LoginState) Check(binding string, now time.Time) error {
+	if len(binding) == 0 || !checkSecret(s.BindingHash, binding) || !now.Before(s.Expires) {
+		return ErrLoginStateInvalid
+	}
+	return nil
+}
+
+// UseLoginState gets the state of a login and deletes it, a login state is used once.
+// It returns ErrLoginStateInvalid if the state is unknown, expired, or bound to another browser.
+//
+func UseLoginState(c appengine.Context, provider, state, binding string) (*LoginState, error) {
+	if len(state) == 0 {
+		return nil, ErrLoginStateInvalid
+	}
+
+	var s LoginState
+	err := datastore.RunInTransaction(c, func(tc appengine.Context) error {
+		key := loginStateKey(tc, provider, state)
+		if err := datastore.Get(tc, key, &s); err == datastore.ErrNoSuchEntity {
+			return ErrLoginStateInvalid
+		} else if err != nil {
+			return err
+		}
+		return datastore.Delete(tc, key)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.Check(binding, time.Now()); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginStateCheck(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+	s := LoginState{Provider: "github", State: "thestate", BindingHash: HashToken("thebinding"), Expires: now.Add(LoginStateDuration)}
+
+	tests := []struct {
+		title   string
+		binding string
+		now     time.Time
+		err     error
+	}{
+		{"same browser", "thebinding", now, nil},
+		{"other browser", "otherbinding", now, ErrLoginStateInvalid},
+		{"no cookie", "", now, ErrLoginStateInvalid},
+		{"expired login", "thebinding", now.Add(LoginStateDuration), ErrLoginStateInvalid},
+	}
+	for _, test := range tests {
+		if err := s.Check(test.binding, test.now); err != test.err {
+			t.Errorf("TestLoginStateCheck(%q): got %v wanted %v", test.title, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// ErrMergeSameUser is returned when a user is merged into themselves.
+//
+var ErrMergeSameUser = errors.New("model/merge: cannot merge a user into themselves")
+
+// MergeReport describes what was merged from a user into another.
+//
+type MergeReport struct {
+	SourceId         int64
+	TargetId         int64
+	Predicts         int // predictions moved to the target.
+	PredictConflicts int // predictions of the source dropped as the target predicted the same match.
+	Tournaments      int
+	Scores           int // scores moved to the target.
+	ScoreConflicts   int // scores of the source dropped as the target has a score in the same tournament.
+	Teams            int
+	Activities       int // activities performed by the source.
+	Identities       int // identities, local accounts and chat accounts moved to the target.
+}
+
+// mergeIDs returns the ids of a followed by the ids of b which are not in a.
+func mergeIDs(a, b []int64) []int64 {
+	seen := make(map[int64]bool, len(a))
+	merged := make([]int64, 0, len(a)+len(b))
+	for _, id := range a {
+		seen[id] = true
+		merged = append(merged, id)
+	}
+	for _, id := range b {
+		if !seen[id] {
+			seen[id] = true
+			merged = append(merged, id)
+		}
+	}
+	return merged
+}
+
+// replaceID replaces an id by another in ids, the other id is not added twice.
+// It returns the new ids and whether the id was found.
+func replaceID(ids []int64, old, new int64) ([]int64, bool) {
+	found := false
+	replaced := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id == old {
+			found = true
+			continue
+		}
+		replaced = append(replaced, id)
+	}
+	if !found {
+		return ids, false
+	}
+	return mergeIDs(replaced, []int64{new}), true
+}
+
+// splitPredicts splits the predictions of a source user between the ones moved to a target user
+// and the ones dropped because the target predicted the same match.
+func splitPredicts(source, target []*Predict) (moved, dropped []*Predict) {
+	matches := make(map[int64]bool, len(target))
+	for _, p := range target {
+		matches[p.MatchId] = true
+	}
+	for _, p := range source {
+		if matches[p.MatchId] {
+			dropped = append(dropped, p)
+		} else {
+			matches[p.MatchId] = true
+			moved = append(moved, p)
+		}
+	}
+	return
+}
+
+// mergePredicts moves the predictions of a list of the source to the same list of the target.
+func mergePredicts(c appengine.Context, source, target *User, sourceIds []int64, targetIds *[]int64, report *MergeReport) error {
+	sp, err := PredictsByIds(c, sourceIds)
+	if err != nil {
+		return err
+	}
+	var tp []*Predict
+	if tp, err = PredictsByIds(c, append(append([]int64{}, target.PredictIds...), target.ArchivedPredictInds...)); err != nil {
+		return err
+	}
+
+	moved, dropped := splitPredicts(sp, tp)
+	for _, p := range moved {
+		p.UserId = target.Id
+		if err = p.Update(c); err != nil {
+			return err
+		}
+		*targetIds = append(*targetIds, p.Id)
+	}
+	for _, p := range dropped {
+		if err = p.Destroy(c); err != nil {
+			return err
+		}
+	}
+	report.Predicts += len(moved)
+	report.PredictConflicts += len(dropped)
+	return nil
+}
+
+// mergeTournaments replaces the source by the target in the participants and admins of its tournaments.
+func mergeTournaments(c appengine.Context, source, target *User, report *MergeReport) error {
+	for _, tID := range mergeIDs(source.TournamentIds, source.ArchivedTournamentIds) {
+		t, err := TournamentByID(c, tID)
+		if err != nil {
+			log.Errorf(c, "MergeUsers: tournament %v of user %v not found: %v", tID, source.Id, err)
+			continue
+		}
+		var users, admins bool
+		t.UserIds, users = replaceID(t.UserIds, source.Id, target.Id)
+		t.AdminIds, admins = replaceID(t.AdminIds, source.Id, target.Id)
+		if users || admins {
+			if err = t.Update(c); err != nil {
+				return err
+			}
+		}
+		report.Tournaments++
+	}
+	target.TournamentIds = mergeIDs(target.TournamentIds, source.TournamentIds)
+	target.ArchivedTournamentIds = mergeIDs(target.ArchivedTournamentIds, source.ArchivedTournamentIds)
+	return nil
+}
+
+// mergeScores moves the scores of the source to the target, unless the target has a score in the same tournament.
+// Only the points of the moved scores are added to the score of the target.
+func mergeScores(c appengine.Context, source, target *User, report *MergeReport) error {
+	tournaments := make(map[int64]bool, len(target.ScoreOfTournaments))
+	for _, s := range target.ScoreOfTournaments {
+		tournaments[s.TournamentId] = true
+	}
+
+	for _, sot := range source.ScoreOfTournaments {
+		if tournaments[sot.TournamentId] {
+			if err := datastore.Delete(c, ScoreKeyByID(c, sot.ScoreId)); err != nil && err != datastore.ErrNoSuchEntity {
+				return err
+			}
+			report.ScoreConflicts++
+			continue
+		}
+
+		s, err := ScoreByID(c, sot.ScoreId)
+		if err != nil {
+			log.Errorf(c, "MergeUsers: score %v of user %v not found: %v", sot.ScoreId, source.Id, err)
+			continue
+		}
+		s.UserId = target.Id
+		if err = s.Update(c); err != nil {
+			return err
+		}
+		tournaments[sot.TournamentId] = true
+		target.ScoreOfTournaments = append(target.ScoreOfTournaments, sot)
+		target.Score += sumInt64(&s.Scores)
+		report.Scores++
+	}
+	return nil
+}
+
+// mergeTeams replaces the source by the target in the members and admins of its teams.
+func mergeTeams(c appengine.Context, source, target *User, report *MergeReport) error {
+	for _, tID := range source.TeamIds {
+		t, err := TeamByID(c, tID)
+		if err != nil {
+			log.Errorf(c, "MergeUsers: team %v of user %v not found: %v", tID, source.Id, err)
+			continue
+		}
+		t.UserIds, _ = replaceID(t.UserIds, source.Id, target.Id)
+		t.AdminIds, _ = replaceID(t.AdminIds, source.Id, target.Id)
+		t.MembersCount = int64(len(t.UserIds))
+		if err = t.Update(c); err != nil {
+			return err
+		}
+		report.Teams++
+	}
+	target.TeamIds = mergeIDs(target.TeamIds, source.TeamIds)
+	return nil
+}
+
+// mergeActivities makes the target the actor of the activities of the source and adds them to the activities of the target.
+func mergeActivities(c appengine.Context, source, target *User, report *MergeReport) error {
+	var activities []*Activity
+	if _, err := datastore.NewQuery("Activity").Filter("CreatorID"+" =", source.Id).GetAll(c, &activities); err != nil {
+		return err
+	}
+	for _, a := range activities {
+		a.CreatorID = target.Id
+		if a.Actor.Type == "user" && a.Actor.Id == source.Id {
+			a.Actor = target.Entity()
+		}
+	}
+	if len(activities) > 0 {
+		if err := SaveActivities(c, activities); err != nil {
+			return err
+		}
+	}
+	report.Activities = len(activities)
+	target.ActivityIds = mergeIDs(target.ActivityIds, source.ActivityIds)
+	return nil
+}
+
+// mergeAccounts moves the identities, local accounts and chat accounts of the source to the target.
+// The sessions, API keys and two-factor authentication of the source are revoked.
+func mergeAccounts(c appengine.Context, source, target *User, report *MergeReport) error {
+	for _, i := range FindIdentities(c, source.Id) {
+		i.UserId = target.Id
+		if err := i.Update(c); err != nil {
+			return err
+		}
+		report.Identities++
+	}
+
+	var accounts []*LocalAccount
+	if _, err := datastore.NewQuery("LocalAccount").Filter("UserId"+" =", source.Id).GetAll(c, &accounts); err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		a.UserId = target.Id
+		if err := a.Update(c); err != nil {
+			return err
+		}
+		report.Identities++
+	}
+
+	var chats []*ChatAccount
+	if _, err := datastore.NewQuery("ChatAccount").Filter("UserId"+" =", source.Id).GetAll(c, &chats); err != nil {
+		return err
+	}
+	for _, a := range chats {
+		a.UserId = target.Id
+		if _, err := datastore.Put(c, chatAccountKey(c, a.Provider, a.TeamId, a.ChatUserId), a); err != nil {
+			return err
+		}
+		report.Identities++
+	}
+
+	if err := DestroySessions(c, source.Id); err != nil {
+		return err
+	}
+	for _, k := range FindAPIKeys(c, source.Id) {
+		if err := k.Destroy(c); err != nil {
+			return err
+		}
+	}
+	if tf, err := TwoFactorByUser(c, source.Id); err == nil {
+		return tf.Destroy(c)
+	}
+	return nil
+}
+
+// MergeUsers merges a source user into a target user: the predictions, scores, tournaments, teams, activities
+// and ways to sign in of the source are moved to the target, then the source is destroyed.
+// When both users predicted the same match, or have a score in the same tournament, the ones of the target are kept.
+// The merge is not transactional, it can be run again if it fails.
+//
+func MergeUsers(c appengine.Context, source, target *User) (*MergeReport, error) {
+	if source.Id == target.Id {
+		return nil, ErrMergeSameUser
+	}
+	report := &MergeReport{SourceId: source.Id, TargetId: target.Id}
+
+	if err := mergePredicts(c, source, target, source.PredictIds, &target.PredictIds, report); err != nil {
+		return nil, err
+	}
+	if err := mergePredicts(c, source, target, source.ArchivedPredictInds, &target.ArchivedPredictInds, report); err != nil {
+		return nil, err
+	}
+
+	steps := []func(appengine.Context, *User, *User, *MergeReport) error{mergeTournaments, mergeScores, mergeTeams, mergeActivities, mergeAccounts}
+	for _, step := range steps {
+		if err := step(c, source, target, report); err != nil {
+			return nil, err
+		}
+	}
+
+	target.ReminderOptOutIds = mergeIDs(target.ReminderOptOutIds, source.ReminderOptOutIds)
+
+	if err := target.Update(c); err != nil {
+		return nil, err
+	}
+	if err := source.Destroy(c); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeIDs(t *testing.T) {
+	tests := []struct {
+		title string
+		a, b  []int64
+		want  []int64
+	}{
+		{"disjoint ids", []int64{1, 2}, []int64{3, 4}, []int64{1, 2, 3, 4}},
+		{"common ids", []int64{1, 2, 3}, []int64{3, 2, 5}, []int64{1, 2, 3, 5}},
+		{"empty target", nil, []int64{7}, []int64{7}},
+		{"empty source", []int64{7}, nil, []int64{7}},
+	}
+	for _, test := range tests {
+		if got := mergeIDs(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestMergeIDs(%q): got %v wanted %v", test.title, got, test.want)
+		}
+	}
+}
+
+func TestReplaceID(t *testing.T) {
+	tests := []struct {
+		title string
+		ids   []int64
+		want  []int64
+		found bool
+	}{
+		{"source only", []int64{1, 10, 2}, []int64{1, 2, 20}, true},
+		{"source and target", []int64{10, 20, 3}, []int64{20, 3}, true},
+		{"target only", []int64{20, 3}, []int64{20, 3}, false},
+		{"none", []int64{1}, []int64{1}, false},
+	}
+	for _, test := range tests {
+		got, found := replaceID(test.ids, 10, 20)
+		if !reflect.DeepEqual(got, test.want) || found != test.found {
+			t.Errorf("TestReplaceID(%q): got %v, %v wanted %v, %v", test.title, got, found, test.want, test.found)
+		}
+	}
+}
+
+func TestSplitPredicts(t *testing.T) {
+	source := []*Predict{
+		{Id: 1, UserId: 10, MatchId: 100},
+		{Id: 2, UserId: 10, MatchId: 101},
+		{Id: 3, UserId: 10, MatchId: 102},
+	}
+	target := []*Predict{
+		{Id: 4, UserId: 20, MatchId: 101},
+	}
+
+	moved, dropped := splitPredicts(source, target)
+
+	ids := func(predicts []*Predict) []int64 {
+		var ids []int64
+		for _, p := range predicts {
+			ids = append(ids, p.Id)
+		}
+		return ids
+	}
+	if got, want := ids(moved), []int64{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestSplitPredicts(%q): got %v wanted %v", "moved", got, want)
+	}
+	if got, want := ids(dropped), []int64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestSplitPredicts(%q): got %v wanted %v", "dropped", got, want)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// Types of notification.
+//
+const (
+	NotificationInvitation = "invitation" // a user is invited to join a team.
+	NotificationRequest    = "request"    // a user requests to join a team.
+	NotificationAdmin      = "admin"      // a user is added as admin of a team or a tournament.
+	NotificationResult     = "result"     // the result of a match is set.
+)
+
+// NotificationTypes holds all the types of notification.
+//
+var NotificationTypes = []string{NotificationInvitation, NotificationRequest, NotificationAdmin, NotificationResult}
+
+// Channels used to deliver a notification.
+//
+const (
+	ChannelInApp = "inapp" // notification is only displayed in gonawin.
+	ChannelEmail = "email" // notification is displayed in gonawin and sent by email.
+	ChannelNone  = "none"  // notification is discarded.
+)
+
+// Notification represents a notification sent to a user.
+//
+type Notification struct {
+	Id      int64
+	UserId  int64          // id of the user notified.
+	Type    string         // type of the notification (invitation, request, admin, result).
+	Message string         // message to display.
+	Object  ActivityEntity // entity the notification is about.
+	Read    bool           // has the user read the notification.
+	Created time.Time
+}
+
+// NotificationJSON is the JSON representation of a notification.
+//
+type NotificationJSON struct {
+	Id      *int64          `json:",omitempty"`
+	UserId  *int64          `json:",omitempty"`
+	Type    *string         `json:",omitempty"`
+	Message *string         `json:",omitempty"`
+	Object  *ActivityEntity `json:",omitempty"`
+	Read    *bool           `json:",omitempty"`
+	Created *time.Time      `json:",omitempty"`
+}
+
+// NotificationPreference holds the channel used to deliver a type of notification to a user.
+//
+type NotificationPreference struct {
+	Type    string
+	Channel string
+}
+
+// IsNotificationTypeValid indicates if a type of notification exists.
+//
+func IsNotificationTypeValid(notificationType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsChannelValid indicates if a notification channel exists.
+//
+func IsChannelValid(channel string) bool {
+	return channel == ChannelInApp || channel == ChannelEmail || channel == ChannelNone
+}
+
+// NotificationChannel returns the channel used to deliver a type of notification to the user.
+// Notifications are delivered in app by default.
+//
+func (u *User) NotificationChannel(notificationType string) string {
+	for _, p := range u.NotificationPreferences {
+		if p.Type == notificationType {
+			return p.Channel
+		}
+	}
+	return ChannelInApp
+}
+
+// SetNotificationChannel sets the channel used to deliver a type of notification to the user.
+//
+func (u *User) SetNotificationChannel(c appengine.Context, notificationType, channel string) error {
+	if !IsNotificationTypeValid(notificationType) || !IsChannelValid(channel) {
+		return errors.New("model/notification: unknown notification type or channel")
+	}
+
+	found := false
+	for i := range u.NotificationPreferences {
+		if u.NotificationPreferences[i].Type == notificationType {
+			u.NotificationPreferences[i].Channel = channel
+			found = true
+		}
+	}
+	if !found {
+		u.NotificationPreferences = append(u.NotificationPreferences, NotificationPreference{notificationType, channel})
+	}
+	return u.Update(c)
+}
+
+// maxPutMulti is the maximum number of entities written by a datastore PutMulti call.
+const maxPutMulti = 500
+
+// Notify creates a notification for each user with respect to their preferences.
+// Notifications delivered by email are sent through the task queue.
+// The notifications are written in batches of maxPutMulti.
+//
+func Notify(c appengine.Context, users []*User, notificationType, message string, object ActivityEntity) error {
+	desc := "model/notification, Notify:"
+
+	var recipients []*User
+	for _, u := range users {
+		if u != nil && u.NotificationChannel(notificationType) != ChannelNone {
+			recipients = append(recipients, u)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	low, _, err := datastore.AllocateIDs(c, "Notification", nil, len(recipients))
+	if err != nil {
+		log.Errorf(c, "%s error occurred during AllocateIDs call: %v", desc, err)
+		return errors.New("model/notification: unable to allocate identifiers for notifications")
+	}
+
+	now := time.Now()
+	keys := make([]*datastore.Key, len(recipients))
+	notifications := make([]*Notification, len(recipients))
+	for i, u := range recipients {
+		id := low + int64(i)
+		keys[i] = datastore.NewKey(c, "Notification", "", id, nil)
+		notifications[i] = &Notification{id, u.Id, notificationType, message, object, false, now}
+	}
+
+	for start := 0; start < len(keys); start += maxPutMulti {
+		end := start + maxPutMulti
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if _, err = datastore.PutMulti(c, keys[start:end], notifications[start:end]); err != nil {
+			log.Errorf(c, "%s error occurred during PutMulti call: %v", desc, err)
+			return errors.New("model/notification: unable to put notifications in Datastore")
+		}
+	}
+
+	for i, u := range recipients {
+		if u.NotificationChannel(notificationType) != ChannelEmail || len(u.Email) == 0 {
+			continue
+		}
+		task := taskqueue.NewPOSTTask("/a/notify/email/", url.Values{
+			"notificationId": []string{strconv.FormatInt(notifications[i].Id, 10)},
+		})
+		if _, err := taskqueue.Add(c, task, ""); err != nil {
+			log.Errorf(c, "%s unable to add task to taskqueue %v", desc, err)
+		}
+	}
+	return nil
+}
+
+// NotificationByID gets a notification given an id.
+//
+func NotificationByID(c appengine.Context, id int64) (*Notification, error) {
+
+	var n Notification
+	key := datastore.NewKey(c, "Notification", "", id, nil)
+
+	if err := datastore.Get(c, key, &n); err != nil {
+		log.Errorf(c, " notification not found : %v", err)
+		return nil, err
+	}
+	return &n, nil
+}
+
+// FindNotifications returns the notifications of a user, from the newest to the oldest.
+// Set unreadOnly to only get the notifications the user has not read yet.
+//
+func FindNotifications(c appengine.Context, u *User, unreadOnly bool, count, page int64) []*Notification {
+
+	q := datastore.NewQuery("Notification").Filter("UserId =", u.Id)
+	if unreadOnly {
+		q = q.Filter("Read =", false)
+	}
+	q = q.Order("-Created").Offset(int((page - 1) * count)).Limit(int(count))
+
+	var notifications []*Notification
+	if _, err := q.GetAll(c, &notifications); err != nil {
+		log.Errorf(c, " Notification.FindNotifications: error occurred during GetAll call: %v", err)
+		return nil
+	}
+	return notifications
+}
+
+// UnreadNotificationsCount returns the number of notifications the user has not read yet.
+//
+func UnreadNotificationsCount(c appengine.Context, u *User) int {
+
+	q := datastore.NewQuery("Notification").Filter("UserId =", u.Id).Filter("Read =", false)
+	n, err := q.Count(c)
+	if err != nil {
+		log.Errorf(c, " Notification.UnreadNotificationsCount: error occurred during Count call: %v", err)
+		return 0
+	}
+	return n
+}
+
+// MarkNotificationsRead marks notifications of a user as read.
+// When ids is empty, all the unread notifications of the user are marked as read.
+// Notifications of other users are ignored.
+//
+func MarkNotificationsRead(c appengine.Context, u *User, ids []int64) error {
+
+	var keys []*datastore.Key
+	var notifications []*Notification
+
+	if len(ids) == 0 {
+		q := datastore.NewQuery("Notification").Filter("UserId =", u.Id).Filter("Read =", false)
+		var err error
+		if keys, err = q.GetAll(c, &notifications); err != nil {
+			return err
+		}
+	} else {
+		keys = make([]*datastore.Key, len(ids))
+		for i, id := range ids {
+			keys[i] = datastore.NewKey(c, "Notification", "", id, nil)
+		}
+		notifications = make([]*Notification, len(ids))
+		for i := range notifications {
+			notifications[i] = new(Notification)
+		}
+		if err := datastore.GetMulti(c, keys, notifications); err != nil {
+			return err
+		}
+	}
+
+	var toUpdate []*Notification
+	var toUpdateKeys []*datastore.Key
+	for i, n := range notifications {
+		if n.UserId == u.Id && !n.Read {
+			n.Read = true
+			toUpdate = append(toUpdate, n)
+			toUpdateKeys = append(toUpdateKeys, keys[i])
+		}
+	}
+	if len(toUpdate) == 0 {
+		return nil
+	}
+
+	_, err := datastore.PutMulti(c, toUpdateKeys, toUpdate)
+	return err
+}
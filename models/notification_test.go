@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestUserNotificationChannel(t *testing.T) {
+	u := &User{
+		NotificationPreferences: []NotificationPreference{
+			{NotificationResult, ChannelNone},
+			{NotificationInvitation, ChannelEmail},
+		},
+	}
+
+	tests := []struct {
+		notificationType string
+		want             string
+	}{
+		{NotificationResult, ChannelNone},
+		{NotificationInvitation, ChannelEmail},
+		{NotificationAdmin, ChannelInApp},
+		{NotificationRequest, ChannelInApp},
+	}
+
+	for _, test := range tests {
+		if got := u.NotificationChannel(test.notificationType); got != test.want {
+			t.Errorf("TestUserNotificationChannel(%q): got %q wanted %q", test.notificationType, got, test.want)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package models
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"appengine"
@@ -53,6 +54,13 @@ func CreatePredict(c appengine.Context, userID, result1, result2, matchID int64)
 	return p, nil
 }
 
+// SetPredict creates the prediction of a user on a match or updates it if it already exists.
+// It returns the prediction and true when it was created.
+//
+func (u *User) SetPredict(c appengine.Context, m *Tmatch, result1, result2 int64) (*Predict, bool, error) {
+	return Repos(c).Predict(u, m, result1, result2)
+}
+
 // Destroy a Predict entity.
 //
 func (p *Predict) Destroy(c appengine.Context) error {
@@ -223,3 +231,76 @@ func (a Predicts) ContainsMatchID(id int64) (bool, int) {
 	}
 	return false, -1
 }
+
+// ContainsUserID indicates if a user id exists in the array of predicts
+//
+func (a Predicts) ContainsUserID(id int64) (bool, int) {
+	for i, e := range a {
+		if e.UserId == id {
+			return true, i
+		}
+	}
+	return false, -1
+}
+
+// PredictResult holds the number of predicts done on a specific result.
+//
+type PredictResult struct {
+	Result1 int64
+	Result2 int64
+	Count   int64
+}
+
+// PredictDistribution holds the distribution of the predicts of a match.
+//
+type PredictDistribution struct {
+	Total   int64           // total number of predicts.
+	Win1    int64           // number of predicts where the 1st team wins.
+	Tie     int64           // number of predicts with a tie.
+	Win2    int64           // number of predicts where the 2nd team wins.
+	Results []PredictResult // number of predicts by result, most predicted first.
+}
+
+// Distribution computes the distribution of an array of predicts.
+// The predicts should all be bound to the same match.
+//
+func (a Predicts) Distribution() PredictDistribution {
+	var d PredictDistribution
+
+	counts := make(map[[2]int64]int64)
+	for _, p := range a {
+		d.Total++
+		if p.Result1 > p.Result2 {
+			d.Win1++
+		} else if p.Result1 < p.Result2 {
+			d.Win2++
+		} else {
+			d.Tie++
+		}
+		counts[[2]int64{p.Result1, p.Result2}]++
+	}
+
+	d.Results = make([]PredictResult, 0, len(counts))
+	for r, count := range counts {
+		d.Results = append(d.Results, PredictResult{r[0], r[1], count})
+	}
+	sort.Sort(PredictResultByCount(d.Results))
+	return d
+}
+
+// PredictResultByCount implements the sort.Interface for []PredictResult.
+// Results are sorted by count desc, then by result.
+//
+type PredictResultByCount []PredictResult
+
+func (a PredictResultByCount) Len() int      { return len(a) }
+func (a PredictResultByCount) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a PredictResultByCount) Less(i, j int) bool {
+	if a[i].Count != a[j].Count {
+		return a[i].Count > a[j].Count
+	}
+	if a[i].Result1 != a[j].Result1 {
+		return a[i].Result1 < a[j].Result1
+	}
+	return a[i].Result2 < a[j].Result2
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPredictsDistribution(t *testing.T) {
+	tests := []struct {
+		name     string
+		predicts Predicts
+		want     PredictDistribution
+	}{
+		{
+			name:     "no predicts",
+			predicts: Predicts{},
+			want:     PredictDistribution{Results: []PredictResult{}},
+		},
+		{
+			name: "mixed predicts",
+			predicts: Predicts{
+				&Predict{Result1: 2, Result2: 1},
+				&Predict{Result1: 1, Result2: 1},
+				&Predict{Result1: 2, Result2: 1},
+				&Predict{Result1: 0, Result2: 3},
+				&Predict{Result1: 0, Result2: 0},
+			},
+			want: PredictDistribution{
+				Total: 5,
+				Win1:  2,
+				Tie:   2,
+				Win2:  1,
+				Results: []PredictResult{
+					{2, 1, 2},
+					{0, 0, 1},
+					{0, 3, 1},
+					{1, 1, 1},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := test.predicts.Distribution()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestPredictsDistribution(%q): got %+v wanted %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/taironas/gonawin/helpers"
+)
+
+// UserRepository stores the users.
+//
+type UserRepository interface {
+	ByID(id int64) (*User, error)
+	ByIDs(ids []int64) ([]*User, error)
+	Find(filter string, value interface{}) ([]*User, error)
+	Save(u *User) error
+	Delete(id int64) error
+}
+
+// TeamRepository stores the teams.
+//
+type TeamRepository interface {
+	ByID(id int64) (*Team, error)
+	ByIDs(ids []int64) ([]*Team, error)
+	Find(filter string, value interface{}) ([]*Team, error)
+	Save(t *Team) error
+	Delete(id int64) error
+}
+
+// TournamentRepository stores the tournaments.
+//
+type TournamentRepository interface {
+	ByID(id int64) (*Tournament, error)
+	ByIDs(ids []int64) ([]*Tournament, error)
+	Find(filter string, value interface{}) ([]*Tournament, error)
+	Save(t *Tournament) error
+	Delete(id int64) error
+}
+
+// MatchRepository stores the matches of the tournaments.
+//
+type MatchRepository interface {
+	ByID(id int64) (*Tmatch, error)
+	ByIDs(ids []int64) ([]*Tmatch, error)
+	Find(filter string, value interface{}) ([]*Tmatch, error)
+	Save(m *Tmatch) error
+	Delete(id int64) error
+}
+
+// PredictRepository stores the predictions of the users.
+//
+type PredictRepository interface {
+	ByID(id int64) (*Predict, error)
+	ByIDs(ids []int64) ([]*Predict, error)
+	Find(filter string, value interface{}) ([]*Predict, error)
+	ByUserMatch(userID, matchID int64) (*Predict, error)
+	Save(p *Predict) error
+	Delete(id int64) error
+}
+
+// ScoreRepository stores the scores of the users in the tournaments.
+//
+type ScoreRepository interface {
+	ByID(id int64) (*Score, error)
+	ByIDs(ids []int64) ([]*Score, error)
+	Find(filter string, value interface{}) ([]*Score, error)
+	ByUserTournament(userID, tournamentID int64) ([]*Score, error)
+	Save(s *Score) error
+	Delete(id int64) error
+}
+
+// ActivityRepository stores the activities of the users.
+//
+type ActivityRepository interface {
+	ByID(id int64) (*Activity, error)
+	ByIDs(ids []int64) ([]*Activity, error)
+	Find(filter string, value interface{}) ([]*Activity, error)
+	ByUser(u *User, count, page int64) ([]*Activity, error)
+	ByUserCursor(u *User, count int64, cursor string) ([]*Activity, string, error)
+	Save(a *Activity) error
+	Delete(id int64) error
+}
+
+// Relation is a relationship between users, teams and tournaments.
+// Both sides of a relation hold the ids of the other side in a list, see relations.
+//
+type Relation int
+
+// The relations, each one is given by the id of the entity named first, then the id of the other entity.
+//
+const (
+	TeamMembers            Relation = iota // Team.UserIds and User.TeamIds.
+	TeamAdmins                             // Team.AdminIds.
+	TournamentParticipants                 // Tournament.UserIds and User.TournamentIds.
+	TournamentTeams                        // Tournament.TeamIds and Team.TournamentIds.
+	TournamentAdmins                       // Tournament.AdminIds.
+)
+
+// LinkRepository stores the relations between users, teams and tournaments.
+// Link and Unlink change both sides of a relation at once, they do nothing when
+// the entities are already linked or unlinked.
+//
+type LinkRepository interface {
+	Link(rel Relation, id, otherID int64) error
+	Unlink(rel Relation, id, otherID int64) error
+}
+
+// EventPublisher posts the events of the teams to their webhooks.
+// A failure to post an event does not undo the change it reports, the publisher logs it.
+//
+type EventPublisher interface {
+	PublishTeamEvent(t *Team, event string, tournament ActivityEntity, data interface{})
+}
+
+// Repositories holds a repository for each aggregate of the app.
+// Saving an entity with a zero Id creates it and sets its Id.
+// Looking up a missing entity by id returns datastore.ErrNoSuchEntity,
+// lookups by ids only return the entities found.
+//
+type Repositories struct {
+	Users       UserRepository
+	Teams       TeamRepository
+	Tournaments TournamentRepository
+	Matches     MatchRepository
+	Predicts    PredictRepository
+	Scores      ScoreRepository
+	Activities  ActivityRepository
+	Links       LinkRepository
+	Events      EventPublisher
+}
+
+// Repos returns the repositories of a request.
+// It defaults to the datastore, it can be replaced to run the app on another store.
+//
+var Repos = DatastoreRepositories
+
+// activitiesPage returns a page of the activities of a user, the most recent first.
+//
+func activitiesPage(r ActivityRepository, u *User, count, page int64) ([]*Activity, error) {
+	ids := u.ActivityIds
+	start, end := calculateStartAndEnd(int64(len(ids)), count, page)
+
+	var pageIds []int64
+	for i := start; i >= end && i >= 0; i-- {
+		pageIds = append(pageIds, ids[i])
+	}
+	return r.ByIDs(pageIds)
+}
+
+// activitiesByCursor returns the activities of a user starting after cursor, the most recent first,
+// and the cursor of the next page.
+//
+func activitiesByCursor(r ActivityRepository, u *User, count int64, cursor string) ([]*Activity, string, error) {
+	ids, next, err := idsByCursor(u.ActivityIds, count, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	activities, err := r.ByIDs(ids)
+	if err != nil {
+		return nil, "", err
+	}
+	return activities, next, nil
+}
+
+// relationFields are the lists holding a relation, otherField is empty when only the first entity holds it.
+//
+type relationFields struct {
+	kind       string
+	field      string
+	otherKind  string
+	otherField string
+}
+
+var relations = map[Relation]relationFields{
+	TeamMembers:            {"Team", "UserIds", "User", "TeamIds"},
+	TeamAdmins:             {"Team", "AdminIds", "User", ""},
+	TournamentParticipants: {"Tournament", "UserIds", "User", "TournamentIds"},
+	TournamentTeams:        {"Tournament", "TeamIds", "Team", "TournamentIds"},
+	TournamentAdmins:       {"Tournament", "AdminIds", "User", ""},
+}
+
+// newRelationEntity returns a new entity of a kind holding relations.
+//
+func newRelationEntity(kind string) interface{} {
+	switch kind {
+	case "User":
+		return new(User)
+	case "Team":
+		return new(Team)
+	}
+	return new(Tournament)
+}
+
+// setLink links or unlinks two entities on both sides of a relation.
+// The entities are loaded and stored with get and put, both must exist.
+//
+func setLink(rel Relation, id, otherID int64, linked bool, get func(kind string, id int64, dst interface{}) error, put func(kind string, id int64, src interface{}) error) error {
+	f, ok := relations[rel]
+	if !ok {
+		return fmt.Errorf("models: unknown relation %d", rel)
+	}
+	e := newRelationEntity(f.kind)
+	if err := get(f.kind, id, e); err != nil {
+		return err
+	}
+	other := newRelationEntity(f.otherKind)
+	if err := get(f.otherKind, otherID, other); err != nil {
+		return err
+	}
+	if linkID(e, f.field, otherID, linked) {
+		if err := put(f.kind, id, e); err != nil {
+			return err
+		}
+	}
+	if f.otherField != "" && linkID(other, f.otherField, id, linked) {
+		return put(f.otherKind, otherID, other)
+	}
+	return nil
+}
+
+// linkEntities links or unlinks two loaded entities the way Link and Unlink do in the repositories.
+//
+func linkEntities(rel Relation, e, other interface{}, linked bool) {
+	f := relations[rel]
+	linkID(e, f.field, entityID(other), linked)
+	if f.otherField != "" {
+		linkID(other, f.otherField, entityID(e), linked)
+	}
+}
+
+// linkID adds or removes an id in a list of ids of an entity and reports whether the list changed.
+// The members count of a team follows its members.
+//
+func linkID(e interface{}, field string, id int64, linked bool) bool {
+	f := reflect.ValueOf(e).Elem().FieldByName(field)
+	ids := f.Interface().([]int64)
+	found, i := helpers.Contains(ids, id)
+	if found == linked {
+		return false
+	}
+	if linked {
+		ids = append(ids, id)
+	} else {
+		ids = append(append([]int64{}, ids[:i]...), ids[i+1:]...)
+	}
+	f.Set(reflect.ValueOf(ids))
+	if t, ok := e.(*Team); ok {
+		t.MembersCount = int64(len(t.UserIds))
+	}
+	return true
+}
+
+func entityID(e interface{}) int64 {
+	return reflect.ValueOf(e).Elem().FieldByName("Id").Int()
+}
@@ -0,0 +1,351 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers"
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// DatastoreRepositories returns the repositories storing the entities in the datastore.
+//
+func DatastoreRepositories(c appengine.Context) *Repositories {
+	return &Repositories{
+		Users:       datastoreUsers{c},
+		Teams:       datastoreTeams{c},
+		Tournaments: datastoreTournaments{c},
+		Matches:     datastoreMatches{c},
+		Predicts:    datastorePredicts{c},
+		Scores:      datastoreScores{c},
+		Activities:  datastoreActivities{c},
+		Links:       datastoreLinks{c},
+		Events:      datastoreEvents{c},
+	}
+}
+
+// datastoreCreate puts a new entity of a kind in the datastore and sets its id.
+// Entities of the search index are indexed.
+//
+func datastoreCreate(c appengine.Context, kind string, id *int64, src interface{}) error {
+	newID, _, err := datastore.AllocateIDs(c, kind, nil, 1)
+	if err != nil {
+		return err
+	}
+	*id = newID
+	if _, err = datastore.Put(c, datastore.NewKey(c, kind, "", newID, nil), src); err != nil {
+		return err
+	}
+	if d, ok := src.(Indexable); ok {
+		if err = Index(c, d); err != nil {
+			log.Errorf(c, " datastoreCreate, unable to index %s %v: %v", kind, newID, err)
+		}
+	}
+	return nil
+}
+
+// datastoreFind gets all the entities of a kind matching a filter and a value.
+//
+func datastoreFind(c appengine.Context, kind, filter string, value interface{}, dst interface{}) error {
+	_, err := datastore.NewQuery(kind).Filter(filter+" =", value).GetAll(c, dst)
+	return err
+}
+
+// datastoreKeys returns the keys of the entities of a kind given their ids.
+//
+func datastoreKeys(c appengine.Context, kind string, ids []int64) []*datastore.Key {
+	keys := make([]*datastore.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = datastore.NewKey(c, kind, "", id, nil)
+	}
+	return keys
+}
+
+type datastoreUsers struct{ c appengine.Context }
+
+func (r datastoreUsers) ByID(id int64) (*User, error) { return UserByID(r.c, id) }
+
+func (r datastoreUsers) ByIDs(ids []int64) ([]*User, error) { return UsersByIds(r.c, ids) }
+
+func (r datastoreUsers) Find(filter string, value interface{}) ([]*User, error) {
+	var users []*User
+	err := datastoreFind(r.c, "User", filter, value, &users)
+	return users, err
+}
+
+func (r datastoreUsers) Save(u *User) error {
+	if u.Id == 0 {
+		return datastoreCreate(r.c, "User", &u.Id, u)
+	}
+	return u.Update(r.c)
+}
+
+func (r datastoreUsers) Delete(id int64) error {
+	return (&User{Id: id}).Destroy(r.c)
+}
+
+type datastoreTeams struct{ c appengine.Context }
+
+func (r datastoreTeams) ByID(id int64) (*Team, error) { return TeamByID(r.c, id) }
+
+func (r datastoreTeams) ByIDs(ids []int64) ([]*Team, error) { return TeamsByIDs(r.c, ids) }
+
+func (r datastoreTeams) Find(filter string, value interface{}) ([]*Team, error) {
+	var teams []*Team
+	err := datastoreFind(r.c, "Team", filter, value, &teams)
+	return teams, err
+}
+
+func (r datastoreTeams) Save(t *Team) error {
+	if t.Id == 0 {
+		t.KeyName = helpers.TrimLower(t.Name)
+		return datastoreCreate(r.c, "Team", &t.Id, t)
+	}
+	return t.Update(r.c)
+}
+
+func (r datastoreTeams) Delete(id int64) error {
+	return (&Team{Id: id}).Destroy(r.c)
+}
+
+type datastoreTournaments struct{ c appengine.Context }
+
+func (r datastoreTournaments) ByID(id int64) (*Tournament, error) { return TournamentByID(r.c, id) }
+
+func (r datastoreTournaments) ByIDs(ids []int64) ([]*Tournament, error) {
+	return TournamentsByIds(r.c, ids)
+}
+
+func (r datastoreTournaments) Find(filter string, value interface{}) ([]*Tournament, error) {
+	var tournaments []*Tournament
+	err := datastoreFind(r.c, "Tournament", filter, value, &tournaments)
+	return tournaments, err
+}
+
+func (r datastoreTournaments) Save(t *Tournament) error {
+	if t.Id == 0 {
+		t.KeyName = helpers.TrimLower(t.Name)
+		return datastoreCreate(r.c, "Tournament", &t.Id, t)
+	}
+	return t.Update(r.c)
+}
+
+func (r datastoreTournaments) Delete(id int64) error {
+	return (&Tournament{Id: id}).Destroy(r.c)
+}
+
+type datastoreMatches struct{ c appengine.Context }
+
+func (r datastoreMatches) ByID(id int64) (*Tmatch, error) { return MatchByID(r.c, id) }
+
+func (r datastoreMatches) ByIDs(ids []int64) ([]*Tmatch, error) {
+	matches := make([]Tmatch, len(ids))
+	found, err := getMultiFound(r.c, datastoreKeys(r.c, "Tmatch", ids), matches)
+	if err != nil {
+		return nil, err
+	}
+	var existing []*Tmatch
+	for i := range matches {
+		if found[i] {
+			existing = append(existing, &matches[i])
+		}
+	}
+	return existing, nil
+}
+
+func (r datastoreMatches) Find(filter string, value interface{}) ([]*Tmatch, error) {
+	var matches []*Tmatch
+	err := datastoreFind(r.c, "Tmatch", filter, value, &matches)
+	return matches, err
+}
+
+func (r datastoreMatches) Save(m *Tmatch) error {
+	if m.Id == 0 {
+		return datastoreCreate(r.c, "Tmatch", &m.Id, m)
+	}
+	return UpdateMatch(r.c, m)
+}
+
+func (r datastoreMatches) Delete(id int64) error { return DestroyMatches(r.c, []int64{id}) }
+
+type datastorePredicts struct{ c appengine.Context }
+
+func (r datastorePredicts) ByID(id int64) (*Predict, error) {
+	p, err := PredictByID(r.c, id)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r datastorePredicts) ByIDs(ids []int64) ([]*Predict, error) { return PredictsByIds(r.c, ids) }
+
+func (r datastorePredicts) Find(filter string, value interface{}) ([]*Predict, error) {
+	var predicts []*Predict
+	err := datastoreFind(r.c, "Predict", filter, value, &predicts)
+	return predicts, err
+}
+
+func (r datastorePredicts) ByUserMatch(userID, matchID int64) (*Predict, error) {
+	if p := FindPredictByUserMatch(r.c, userID, matchID); p != nil {
+		return p, nil
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
+
+func (r datastorePredicts) Save(p *Predict) error {
+	if p.Id == 0 {
+		return datastoreCreate(r.c, "Predict", &p.Id, p)
+	}
+	return p.Update(r.c)
+}
+
+func (r datastorePredicts) Delete(id int64) error { return DestroyPredicts(r.c, []int64{id}) }
+
+type datastoreScores struct{ c appengine.Context }
+
+func (r datastoreScores) ByID(id int64) (*Score, error) {
+	s, err := ScoreByID(r.c, id)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r datastoreScores) ByIDs(ids []int64) ([]*Score, error) {
+	scores := make([]Score, len(ids))
+	found, err := getMultiFound(r.c, datastoreKeys(r.c, "Score", ids), scores)
+	if err != nil {
+		return nil, err
+	}
+	var existing []*Score
+	for i := range scores {
+		if found[i] {
+			existing = append(existing, &scores[i])
+		}
+	}
+	return existing, nil
+}
+
+func (r datastoreScores) Find(filter string, value interface{}) ([]*Score, error) {
+	var scores []*Score
+	err := datastoreFind(r.c, "Score", filter, value, &scores)
+	return scores, err
+}
+
+func (r datastoreScores) ByUserTournament(userID, tournamentID int64) ([]*Score, error) {
+	var scores []*Score
+	_, err := datastore.NewQuery("Score").
+		Filter("UserId =", userID).
+		Filter("TournamentId =", tournamentID).
+		GetAll(r.c, &scores)
+	return scores, err
+}
+
+func (r datastoreScores) Save(s *Score) error {
+	if s.Id == 0 {
+		return datastoreCreate(r.c, "Score", &s.Id, s)
+	}
+	return s.Update(r.c)
+}
+
+func (r datastoreScores) Delete(id int64) error {
+	return datastore.Delete(r.c, ScoreKeyByID(r.c, id))
+}
+
+type datastoreActivities struct{ c appengine.Context }
+
+func (r datastoreActivities) ByID(id int64) (*Activity, error) {
+	var a Activity
+	if err := datastore.Get(r.c, datastore.NewKey(r.c, "Activity", "", id, nil), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r datastoreActivities) ByIDs(ids []int64) ([]*Activity, error) {
+	activities := make([]Activity, len(ids))
+	found, err := getMultiFound(r.c, datastoreKeys(r.c, "Activity", ids), activities)
+	if err != nil {
+		return nil, err
+	}
+	var existing []*Activity
+	for i := range activities {
+		if found[i] {
+			existing = append(existing, &activities[i])
+		}
+	}
+	return existing, nil
+}
+
+func (r datastoreActivities) Find(filter string, value interface{}) ([]*Activity, error) {
+	var activities []*Activity
+	err := datastoreFind(r.c, "Activity", filter, value, &activities)
+	return activities, err
+}
+
+func (r datastoreActivities) ByUser(u *User, count, page int64) ([]*Activity, error) {
+	return activitiesPage(r, u, count, page)
+}
+
+func (r datastoreActivities) ByUserCursor(u *User, count int64, cursor string) ([]*Activity, string, error) {
+	return activitiesByCursor(r, u, count, cursor)
+}
+
+func (r datastoreActivities) Save(a *Activity) error {
+	if a.Id == 0 {
+		return a.save(r.c)
+	}
+	return SaveActivities(r.c, []*Activity{a})
+}
+
+func (r datastoreActivities) Delete(id int64) error { return DestroyActivities(r.c, []int64{id}) }
+
+type datastoreLinks struct{ c appengine.Context }
+
+func (r datastoreLinks) Link(rel Relation, id, otherID int64) error {
+	return r.set(rel, id, otherID, true)
+}
+
+func (r datastoreLinks) Unlink(rel Relation, id, otherID int64) error {
+	return r.set(rel, id, otherID, false)
+}
+
+// set changes both sides of a relation in a cross group transaction, so that they always agree.
+//
+func (r datastoreLinks) set(rel Relation, id, otherID int64, linked bool) error {
+	return datastore.RunInTransaction(r.c, func(tc appengine.Context) error {
+		get := func(kind string, id int64, dst interface{}) error {
+			return datastore.Get(tc, datastore.NewKey(tc, kind, "", id, nil), dst)
+		}
+		put := func(kind string, id int64, src interface{}) error {
+			_, err := datastore.Put(tc, datastore.NewKey(tc, kind, "", id, nil), src)
+			return err
+		}
+		return setLink(rel, id, otherID, linked, get, put)
+	}, &datastore.TransactionOptions{XG: true})
+}
+
+type datastoreEvents struct{ c appengine.Context }
+
+func (r datastoreEvents) PublishTeamEvent(t *Team, event string, tournament ActivityEntity, data interface{}) {
+	if err := t.PublishEvent(r.c, event, tournament, data); err != nil {
+		log.Errorf(r.c, "datastoreEvents.PublishTeamEvent: unable to publish %s of team %d: %v", event, t.Id, err)
+	}
+}
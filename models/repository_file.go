@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSnapshot is the content of the file of file repositories.
+//
+type fileSnapshot struct {
+	Kinds map[string]fileKind
+}
+
+type fileKind struct {
+	LastID   int64
+	Entities map[int64][]byte
+}
+
+// fileStore writes the memory kinds to a file after each change.
+//
+type fileStore struct {
+	sync.Mutex
+	path  string
+	kinds map[string]*memoryKind
+}
+
+// OpenFileRepositories returns memory repositories persisted in the file at path.
+// The file is read if it exists and is rewritten after each put or delete.
+//
+func OpenFileRepositories(path string) (*Repositories, error) {
+	fs := &fileStore{path: path, kinds: newMemoryKinds()}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	for _, k := range fs.kinds {
+		k.changed = fs.save
+	}
+	return memoryRepositories(fs.kinds), nil
+}
+
+// load reads the kinds from the file, a missing file is an empty store.
+//
+func (fs *fileStore) load() error {
+	f, err := os.Open(fs.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snapshot fileSnapshot
+	if err = gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return err
+	}
+	for name, fk := range snapshot.Kinds {
+		k, ok := fs.kinds[name]
+		if !ok {
+			continue
+		}
+		k.lastID = fk.LastID
+		for id, b := range fk.Entities {
+			k.entities[id] = b
+		}
+	}
+	return nil
+}
+
+// save writes the kinds to a temporary file and renames it so that the file is never partially written.
+//
+func (fs *fileStore) save() error {
+	fs.Lock()
+	defer fs.Unlock()
+
+	snapshot := fileSnapshot{Kinds: make(map[string]fileKind)}
+	for name, k := range fs.kinds {
+		k.Lock()
+		fk := fileKind{LastID: k.lastID, Entities: make(map[int64][]byte, len(k.entities))}
+		for id, b := range k.entities {
+			fk.Entities[id] = b
+		}
+		k.Unlock()
+		snapshot.Kinds[name] = fk
+	}
+
+	tmp, err := os.Create(filepath.Join(filepath.Dir(fs.path), "."+filepath.Base(fs.path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(tmp).Encode(snapshot); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.path)
+}
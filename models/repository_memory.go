@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"appengine/datastore"
+)
+
+// NewMemoryRepositories returns repositories keeping the entities in memory.
+// Entities are copied in and out of the repositories, like they would be by a datastore.
+//
+func NewMemoryRepositories() *Repositories {
+	return memoryRepositories(newMemoryKinds())
+}
+
+// memoryKindNames are the kinds of the memory repositories.
+//
+var memoryKindNames = []string{"User", "Team", "Tournament", "Tmatch", "Predict", "Score", "Activity"}
+
+func newMemoryKinds() map[string]*memoryKind {
+	kinds := make(map[string]*memoryKind)
+	for _, name := range memoryKindNames {
+		kinds[name] = newMemoryKind()
+	}
+	return kinds
+}
+
+func memoryRepositories(kinds map[string]*memoryKind) *Repositories {
+	return &Repositories{
+		Users:       storeUsers{kinds["User"]},
+		Teams:       storeTeams{kinds["Team"]},
+		Tournaments: storeTournaments{kinds["Tournament"]},
+		Matches:     storeMatches{kinds["Tmatch"]},
+		Predicts:    storePredicts{kinds["Predict"]},
+		Scores:      storeScores{kinds["Score"]},
+		Activities:  storeActivities{kinds["Activity"]},
+		Links: &storeLinks{kinds: map[string]entityStore{
+			"User":       kinds["User"],
+			"Team":       kinds["Team"],
+			"Tournament": kinds["Tournament"],
+		}},
+		Events: noEvents{},
+	}
+}
+
+// memoryKind holds the encoded entities of a kind by id.
+//
+type memoryKind struct {
+	sync.Mutex
+	lastID   int64
+	entities map[int64][]byte
+	changed  func() error // called after each put or delete when set.
+}
+
+func newMemoryKind() *memoryKind {
+	return &memoryKind{entities: make(map[int64][]byte)}
+}
+
+// get decodes the entity with the given id in dst.
+//
+func (m *memoryKind) get(id int64, dst interface{}) error {
+	m.Lock()
+	b, ok := m.entities[id]
+	m.Unlock()
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(dst)
+}
+
+// put encodes src under the id it points to, a zero id is allocated first.
+//
+func (m *memoryKind) put(id *int64, src interface{}) error {
+	if err := m.putLocked(id, src); err != nil {
+		return err
+	}
+	return m.notify()
+}
+
+func (m *memoryKind) putLocked(id *int64, src interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+	if *id == 0 {
+		m.lastID++
+		*id = m.lastID
+	} else if *id > m.lastID {
+		m.lastID = *id
+	}
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(src); err != nil {
+		return err
+	}
+	m.entities[*id] = b.Bytes()
+	return nil
+}
+
+// delete removes the entity with the given id.
+//
+func (m *memoryKind) delete(id int64) error {
+	m.Lock()
+	if _, ok := m.entities[id]; !ok {
+		m.Unlock()
+		return datastore.ErrNoSuchEntity
+	}
+	delete(m.entities, id)
+	m.Unlock()
+	return m.notify()
+}
+
+// notify calls the changed function of the kind if any.
+//
+func (m *memoryKind) notify() error {
+	if m.changed == nil {
+		return nil
+	}
+	return m.changed()
+}
+
+// ids returns the ids of the entities in increasing order.
+//
+func (m *memoryKind) ids() []int64 {
+	m.Lock()
+	defer m.Unlock()
+	ids := make(int64Slice, 0, len(m.entities))
+	for id := range m.entities {
+		ids = append(ids, id)
+	}
+	sort.Sort(ids)
+	return ids
+}
+
+// find calls add with every entity whose field matches value.
+// A list field matches when one of its elements does, like in a datastore query.
+//
+func (m *memoryKind) find(filter string, value interface{}, newEntity func() interface{}, add func(interface{})) error {
+	for _, id := range m.ids() {
+		e := newEntity()
+		if err := m.get(id, e); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				continue
+			}
+			return err
+		}
+		ok, err := fieldMatches(e, filter, value)
+		if err != nil {
+			return err
+		}
+		if ok {
+			add(e)
+		}
+	}
+	return nil
+}
+
+// fieldMatches reports whether the field of an entity has the given value.
+//
+func fieldMatches(e interface{}, field string, value interface{}) (bool, error) {
+	f := reflect.ValueOf(e).Elem().FieldByName(field)
+	if !f.IsValid() {
+		return false, fmt.Errorf("models: no field %s in %T", field, e)
+	}
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < f.Len(); i++ {
+			if valueEquals(f.Index(i), value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return valueEquals(f, value), nil
+}
+
+// valueEquals reports whether v equals value once converted to the type of v.
+//
+func valueEquals(v reflect.Value, value interface{}) bool {
+	w := reflect.ValueOf(value)
+	if !w.IsValid() || !w.Type().ConvertibleTo(v.Type()) {
+		return false
+	}
+	return reflect.DeepEqual(v.Interface(), w.Convert(v.Type()).Interface())
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,424 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"appengine/datastore"
+)
+
+func TestMemoryUsers(t *testing.T) {
+	users := NewMemoryRepositories().Users
+
+	u := &User{Username: "john", TeamIds: []int64{1, 2}}
+	if err := users.Save(u); err != nil {
+		t.Fatalf("TestMemoryUsers: save: %v", err)
+	}
+	if u.Id == 0 {
+		t.Errorf("TestMemoryUsers: got id 0 wanted an allocated id")
+	}
+
+	got, err := users.ByID(u.Id)
+	if err != nil || got.Username != "john" {
+		t.Errorf("TestMemoryUsers(%d): got %v, %v wanted john", u.Id, got, err)
+	}
+
+	got.Username = "changed"
+	if again, _ := users.ByID(u.Id); again.Username != "john" {
+		t.Errorf("TestMemoryUsers: got %q wanted the stored user to be a copy", again.Username)
+	}
+
+	if err := users.Delete(u.Id); err != nil {
+		t.Errorf("TestMemoryUsers: delete: %v", err)
+	}
+	if _, err := users.ByID(u.Id); err != datastore.ErrNoSuchEntity {
+		t.Errorf("TestMemoryUsers: got %v wanted %v", err, datastore.ErrNoSuchEntity)
+	}
+}
+
+func TestMemoryFind(t *testing.T) {
+	teams := NewMemoryRepositories().Teams
+	for _, team := range []*Team{
+		{Name: "Foo", UserIds: []int64{1, 2}},
+		{Name: "Bar", UserIds: []int64{2, 3}, Private: true},
+		{Name: "Baz"},
+	} {
+		if err := teams.Save(team); err != nil {
+			t.Fatalf("TestMemoryFind: save: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter string
+		value  interface{}
+		want   []string
+	}{
+		{"KeyName", "foo", []string{"Foo"}},
+		{"UserIds", 2, []string{"Foo", "Bar"}},
+		{"UserIds", int64(3), []string{"Bar"}},
+		{"Private", false, []string{"Foo", "Baz"}},
+		{"Name", "qux", nil},
+	}
+	for _, test := range tests {
+		found, err := teams.Find(test.filter, test.value)
+		if err != nil {
+			t.Errorf("TestMemoryFind(%q): %v", test.filter, err)
+			continue
+		}
+		var names []string
+		for _, team := range found {
+			names = append(names, team.Name)
+		}
+		if !equalStrings(names, test.want) {
+			t.Errorf("TestMemoryFind(%q, %v): got %v wanted %v", test.filter, test.value, names, test.want)
+		}
+	}
+
+	if _, err := teams.Find("Unknown", 1); err == nil {
+		t.Errorf("TestMemoryFind(%q): got no error wanted an error", "Unknown")
+	}
+}
+
+func TestMemoryByIDs(t *testing.T) {
+	matches := NewMemoryRepositories().Matches
+	m1, m2 := &Tmatch{IdNumber: 1}, &Tmatch{IdNumber: 2}
+	matches.Save(m1)
+	matches.Save(m2)
+
+	found, err := matches.ByIDs([]int64{m2.Id, 42, m1.Id})
+	if err != nil {
+		t.Fatalf("TestMemoryByIDs: %v", err)
+	}
+	if len(found) != 2 || found[0].IdNumber != 2 || found[1].IdNumber != 1 {
+		t.Errorf("TestMemoryByIDs: got %v wanted matches 2 and 1", found)
+	}
+}
+
+func TestMemoryPredictsAndScores(t *testing.T) {
+	repos := NewMemoryRepositories()
+	repos.Predicts.Save(&Predict{UserId: 1, MatchId: 10, Result1: 2})
+	repos.Predicts.Save(&Predict{UserId: 1, MatchId: 11, Result1: 3})
+	repos.Scores.Save(&Score{UserId: 1, TournamentId: 5})
+	repos.Scores.Save(&Score{UserId: 2, TournamentId: 5})
+
+	p, err := repos.Predicts.ByUserMatch(1, 11)
+	if err != nil || p.Result1 != 3 {
+		t.Errorf("TestMemoryPredictsAndScores: got %v, %v wanted the predict of match 11", p, err)
+	}
+	if _, err = repos.Predicts.ByUserMatch(2, 11); err != datastore.ErrNoSuchEntity {
+		t.Errorf("TestMemoryPredictsAndScores: got %v wanted %v", err, datastore.ErrNoSuchEntity)
+	}
+
+	scores, err := repos.Scores.ByUserTournament(2, 5)
+	if err != nil || len(scores) != 1 || scores[0].UserId != 2 {
+		t.Errorf("TestMemoryPredictsAndScores: got %v, %v wanted the score of user 2", scores, err)
+	}
+}
+
+func TestMemoryActivitiesByUser(t *testing.T) {
+	activities := NewMemoryRepositories().Activities
+	u := &User{}
+	for _, verb := range []string{"a", "b", "c"} {
+		a := &Activity{Verb: verb}
+		activities.Save(a)
+		u.ActivityIds = append(u.ActivityIds, a.Id)
+	}
+
+	tests := []struct {
+		count, page int64
+		want        []string
+	}{
+		{2, 1, []string{"c", "b"}},
+		{2, 2, []string{"a"}},
+		{2, 3, nil},
+		{5, 1, []string{"c", "b", "a"}},
+	}
+	for _, test := range tests {
+		found, err := activities.ByUser(u, test.count, test.page)
+		if err != nil {
+			t.Errorf("TestMemoryActivitiesByUser(%d, %d): %v", test.count, test.page, err)
+			continue
+		}
+		var verbs []string
+		for _, a := range found {
+			verbs = append(verbs, a.Verb)
+		}
+		if !equalStrings(verbs, test.want) {
+			t.Errorf("TestMemoryActivitiesByUser(%d, %d): got %v wanted %v", test.count, test.page, verbs, test.want)
+		}
+	}
+}
+
+func TestMemoryActivitiesByUserCursor(t *testing.T) {
+	activities := NewMemoryRepositories().Activities
+	u := &User{}
+	for _, verb := range []string{"a", "b", "c", "d", "e"} {
+		a := &Activity{Verb: verb}
+		activities.Save(a)
+		u.ActivityIds = append(u.ActivityIds, a.Id)
+	}
+
+	var verbs []string
+	cursor := ""
+	for pages := 0; pages < 5; pages++ {
+		found, next, err := activities.ByUserCursor(u, 2, cursor)
+		if err != nil {
+			t.Fatalf("TestMemoryActivitiesByUserCursor: %v", err)
+		}
+		for _, a := range found {
+			verbs = append(verbs, a.Verb)
+		}
+		// an activity published between two pages does not shift the next page.
+		if pages == 0 {
+			a := &Activity{Verb: "f"}
+			activities.Save(a)
+			u.ActivityIds = append(u.ActivityIds, a.Id)
+		}
+		if cursor = next; len(cursor) == 0 {
+			break
+		}
+	}
+	if want := []string{"e", "d", "c", "b", "a"}; !equalStrings(verbs, want) {
+		t.Errorf("TestMemoryActivitiesByUserCursor: got %v wanted %v", verbs, want)
+	}
+}
+
+func TestMemoryJoinTeam(t *testing.T) {
+	repos := NewMemoryRepositories()
+	u := &User{Username: "john"}
+	repos.Users.Save(u)
+	running := &Tournament{Name: "running", End: time.Now().Add(time.Hour)}
+	over := &Tournament{Name: "over", End: time.Now().Add(-time.Hour)}
+	repos.Tournaments.Save(running)
+	repos.Tournaments.Save(over)
+	team := &Team{Name: "Foo", TournamentIds: []int64{running.Id, over.Id}}
+	repos.Teams.Save(team)
+
+	if err := repos.JoinTeam(team, u); err != nil {
+		t.Fatalf("TestMemoryJoinTeam: %v", err)
+	}
+	if err := repos.JoinTeam(team, u); err == nil {
+		t.Errorf("TestMemoryJoinTeam: got no error wanted an error joining twice")
+	}
+
+	storedTeam, _ := repos.Teams.ByID(team.Id)
+	storedUser, _ := repos.Users.ByID(u.Id)
+	storedRunning, _ := repos.Tournaments.ByID(running.Id)
+	storedOver, _ := repos.Tournaments.ByID(over.Id)
+	tests := []struct {
+		name      string
+		got, want []int64
+	}{
+		{"team members", storedTeam.UserIds, []int64{u.Id}},
+		{"user teams", storedUser.TeamIds, []int64{team.Id}},
+		{"user tournaments", storedUser.TournamentIds, []int64{running.Id}},
+		{"running participants", storedRunning.UserIds, []int64{u.Id}},
+		{"over participants", storedOver.UserIds, nil},
+		{"loaded user teams", u.TeamIds, []int64{team.Id}},
+		{"loaded user tournaments", u.TournamentIds, []int64{running.Id}},
+	}
+	for _, test := range tests {
+		if !equalIDs(test.got, test.want) {
+			t.Errorf("TestMemoryJoinTeam(%s): got %v wanted %v", test.name, test.got, test.want)
+		}
+	}
+	if storedTeam.MembersCount != 1 || team.MembersCount != 1 {
+		t.Errorf("TestMemoryJoinTeam: got %d, %d members wanted 1", storedTeam.MembersCount, team.MembersCount)
+	}
+
+	if err := repos.LeaveTeam(team, u); err != nil {
+		t.Fatalf("TestMemoryJoinTeam: leave: %v", err)
+	}
+	storedTeam, _ = repos.Teams.ByID(team.Id)
+	storedUser, _ = repos.Users.ByID(u.Id)
+	if len(storedTeam.UserIds) != 0 || len(storedUser.TeamIds) != 0 || storedTeam.MembersCount != 0 {
+		t.Errorf("TestMemoryJoinTeam: got %v, %v wanted the user to leave the team", storedTeam.UserIds, storedUser.TeamIds)
+	}
+}
+
+func TestMemoryNewTeam(t *testing.T) {
+	repos := NewMemoryRepositories()
+	u := &User{Username: "john"}
+	repos.Users.Save(u)
+
+	team, err := repos.NewTeam(u, "Foo", "", false)
+	if err != nil {
+		t.Fatalf("TestMemoryNewTeam: %v", err)
+	}
+	if _, err = repos.NewTeam(u, "foo", "", false); err != ErrTeamExists {
+		t.Errorf("TestMemoryNewTeam: got %v wanted %v", err, ErrTeamExists)
+	}
+
+	stored, _ := repos.Teams.ByID(team.Id)
+	if !equalIDs(stored.AdminIds, []int64{u.Id}) || !equalIDs(stored.UserIds, []int64{u.Id}) {
+		t.Errorf("TestMemoryNewTeam: got admins %v, members %v wanted %v", stored.AdminIds, stored.UserIds, []int64{u.Id})
+	}
+	if storedUser, _ := repos.Users.ByID(u.Id); !equalIDs(storedUser.TeamIds, []int64{team.Id}) || len(storedUser.ActivityIds) != 1 {
+		t.Errorf("TestMemoryNewTeam: got teams %v, activities %v wanted the team and its creation", storedUser.TeamIds, storedUser.ActivityIds)
+	}
+}
+
+func TestMemoryLinks(t *testing.T) {
+	repos := NewMemoryRepositories()
+	u := &User{Username: "john"}
+	repos.Users.Save(u)
+	team := &Team{Name: "Foo"}
+	repos.Teams.Save(team)
+
+	if err := repos.Links.Link(TeamMembers, team.Id, u.Id+1); err != datastore.ErrNoSuchEntity {
+		t.Errorf("TestMemoryLinks: got %v wanted %v linking a missing user", err, datastore.ErrNoSuchEntity)
+	}
+	if stored, _ := repos.Teams.ByID(team.Id); len(stored.UserIds) != 0 {
+		t.Errorf("TestMemoryLinks: got %v wanted no member", stored.UserIds)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repos.Links.Link(TeamAdmins, team.Id, u.Id); err != nil {
+			t.Fatalf("TestMemoryLinks: %v", err)
+		}
+	}
+	if stored, _ := repos.Teams.ByID(team.Id); !equalIDs(stored.AdminIds, []int64{u.Id}) {
+		t.Errorf("TestMemoryLinks: got %v wanted %v", stored.AdminIds, []int64{u.Id})
+	}
+}
+
+func TestMemoryPredict(t *testing.T) {
+	repos := NewMemoryRepositories()
+	u := &User{Username: "john"}
+	repos.Users.Save(u)
+	m := &Tmatch{}
+	repos.Matches.Save(m)
+
+	tests := []struct {
+		result1, result2 int64
+		created          bool
+	}{
+		{1, 0, true},
+		{2, 2, false},
+	}
+	for _, test := range tests {
+		p, created, err := repos.Predict(u, m, test.result1, test.result2)
+		if err != nil {
+			t.Fatalf("TestMemoryPredict: %v", err)
+		}
+		if created != test.created || p.Result1 != test.result1 || p.Result2 != test.result2 {
+			t.Errorf("TestMemoryPredict(%d, %d): got %v, %v wanted created %v", test.result1, test.result2, p, created, test.created)
+		}
+	}
+	if stored, _ := repos.Users.ByID(u.Id); len(stored.PredictIds) != 1 {
+		t.Errorf("TestMemoryPredict: got %v wanted a single predict", stored.PredictIds)
+	}
+}
+
+func TestMemoryPublish(t *testing.T) {
+	repos := NewMemoryRepositories()
+	u := &User{Username: "john"}
+	admin := &User{Username: "jane"}
+	repos.Users.Save(u)
+	repos.Users.Save(admin)
+	team := &Team{Name: "Foo", UserIds: []int64{u.Id, admin.Id}}
+	repos.Teams.Save(team)
+
+	repos.Publish(u, "team", "joined team", team.Entity(), ActivityEntity{})
+	repos.PublishTo(u, []*User{admin}, "request", "requested to join team", team.Entity(), ActivityEntity{})
+	repos.PublishTeam(team, "accuracy", "improved", ActivityEntity{}, ActivityEntity{})
+
+	tests := []struct {
+		user  *User
+		verbs []string
+	}{
+		{u, []string{"improved", "joined team"}},
+		{admin, []string{"improved", "requested to join team"}},
+	}
+	for _, test := range tests {
+		stored, _ := repos.Users.ByID(test.user.Id)
+		activities, err := repos.Activities.ByUser(stored, 10, 1)
+		if err != nil {
+			t.Fatalf("TestMemoryPublish: %v", err)
+		}
+		var verbs []string
+		for _, a := range activities {
+			verbs = append(verbs, a.Verb)
+		}
+		if !equalStrings(verbs, test.verbs) {
+			t.Errorf("TestMemoryPublish(%s): got %v wanted %v", test.user.Username, verbs, test.verbs)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFileRepositories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonawin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gonawin.db")
+
+	repos, err := OpenFileRepositories(path)
+	if err != nil {
+		t.Fatalf("TestFileRepositories: open: %v", err)
+	}
+	u1, u2 := &User{Username: "john"}, &User{Username: "jane"}
+	repos.Users.Save(u1)
+	repos.Users.Save(u2)
+	repos.Users.Delete(u1.Id)
+
+	if repos, err = OpenFileRepositories(path); err != nil {
+		t.Fatalf("TestFileRepositories: reopen: %v", err)
+	}
+	if _, err = repos.Users.ByID(u1.Id); err != datastore.ErrNoSuchEntity {
+		t.Errorf("TestFileRepositories(%d): got %v wanted %v", u1.Id, err, datastore.ErrNoSuchEntity)
+	}
+	if u, err := repos.Users.ByID(u2.Id); err != nil || u.Username != "jane" {
+		t.Errorf("TestFileRepositories(%d): got %v, %v wanted jane", u2.Id, u, err)
+	}
+
+	u3 := &User{Username: "joe"}
+	repos.Users.Save(u3)
+	if u3.Id <= u2.Id {
+		t.Errorf("TestFileRepositories: got id %d wanted an id after %d", u3.Id, u2.Id)
+	}
+}
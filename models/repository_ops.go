@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers"
+)
+
+// ErrTeamExists is returned when a new team has the name of another team.
+//
+var ErrTeamExists = errors.New("model/team: a team with this name already exists")
+
+// NewTeam creates a team administrated by a user, the user joins it and the creation is
+// published in the activities of the user.
+//
+func (r *Repositories) NewTeam(u *User, name, description string, private bool) (*Team, error) {
+	existing, err := r.Teams.Find("KeyName", helpers.TrimLower(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, ErrTeamExists
+	}
+
+	team := &Team{Name: name, Description: description, Private: private, Created: time.Now()}
+	if err = r.Teams.Save(team); err != nil {
+		return nil, fmt.Errorf("unable to create team %s: %v", name, err)
+	}
+	if err = r.Links.Link(TeamAdmins, team.Id, u.Id); err != nil {
+		return nil, fmt.Errorf("unable to make user %d admin of team %d: %v", u.Id, team.Id, err)
+	}
+	linkEntities(TeamAdmins, team, u, true)
+	if err = r.JoinTeam(team, u); err != nil {
+		return nil, err
+	}
+	return team, r.Publish(u, "team", "created a new team", team.Entity(), ActivityEntity{})
+}
+
+// JoinTeam makes a user join a team and the tournaments of the team that are not over,
+// then posts the member.joined event of the team.
+// The team and the user are updated with their new links.
+//
+func (r *Repositories) JoinTeam(t *Team, u *User) error {
+	if joined, _ := u.ContainsTeamID(t.Id); joined {
+		return fmt.Errorf("user %d is already a member of team %d", u.Id, t.Id)
+	}
+	if err := r.Links.Link(TeamMembers, t.Id, u.Id); err != nil {
+		return fmt.Errorf("unable to add user %d to team %d: %v", u.Id, t.Id, err)
+	}
+	linkEntities(TeamMembers, t, u, true)
+
+	tournaments, err := r.Tournaments.ByIDs(t.TournamentIds)
+	if err != nil {
+		return fmt.Errorf("unable to get the tournaments of team %d: %v", t.Id, err)
+	}
+	now := time.Now()
+	for _, tournament := range tournaments {
+		if !now.Before(tournament.End) {
+			continue
+		}
+		if err = r.Links.Link(TournamentParticipants, tournament.Id, u.Id); err != nil {
+			return fmt.Errorf("unable to add user %d to tournament %d: %v", u.Id, tournament.Id, err)
+		}
+		linkID(u, "TournamentIds", tournament.Id, true)
+	}
+
+	r.Events.PublishTeamEvent(t, EventMemberJoined, ActivityEntity{}, u.Entity())
+	return nil
+}
+
+// LeaveTeam makes a user leave a team, the user stays in the tournaments of the team.
+// The team and the user are updated with their new links.
+//
+func (r *Repositories) LeaveTeam(t *Team, u *User) error {
+	if joined, _ := u.ContainsTeamID(t.Id); !joined {
+		return fmt.Errorf("user %d is not a member of team %d", u.Id, t.Id)
+	}
+	if err := r.Links.Unlink(TeamMembers, t.Id, u.Id); err != nil {
+		return fmt.Errorf("unable to remove user %d from team %d: %v", u.Id, t.Id, err)
+	}
+	linkEntities(TeamMembers, t, u, false)
+	return nil
+}
+
+// JoinTournament makes a user join a tournament.
+// The tournament and the user are updated with their new links.
+//
+func (r *Repositories) JoinTournament(t *Tournament, u *User) error {
+	if joined, _ := u.ContainsTournamentID(t.Id); joined {
+		return fmt.Errorf("user %d already joined tournament %d", u.Id, t.Id)
+	}
+	if err := r.Links.Link(TournamentParticipants, t.Id, u.Id); err != nil {
+		return fmt.Errorf("unable to add user %d to tournament %d: %v", u.Id, t.Id, err)
+	}
+	linkEntities(TournamentParticipants, t, u, true)
+	return nil
+}
+
+// Predict creates the prediction of a user on a match or updates it if it already exists.
+// It returns the prediction and true when it was created.
+//
+func (r *Repositories) Predict(u *User, m *Tmatch, result1, result2 int64) (*Predict, bool, error) {
+	p, err := r.Predicts.ByUserMatch(u.Id, m.Id)
+	if err == nil {
+		p.Result1 = result1
+		p.Result2 = result2
+		if err = r.Predicts.Save(p); err != nil {
+			return nil, false, fmt.Errorf("unable to edit predict entity: %v", err)
+		}
+		return p, false, nil
+	} else if err != datastore.ErrNoSuchEntity {
+		return nil, false, err
+	}
+
+	p = &Predict{UserId: u.Id, Result1: result1, Result2: result2, MatchId: m.Id, Created: time.Now()}
+	if err = r.Predicts.Save(p); err != nil {
+		return nil, false, fmt.Errorf("unable to create Predict for match with id:%v error: %v", m.Id, err)
+	}
+	u.PredictIds = append(u.PredictIds, p.Id)
+	if err = r.Users.Save(u); err != nil {
+		return nil, false, fmt.Errorf("unable to add predict id in user entity: error: %v", err)
+	}
+	return p, true, nil
+}
+
+// Publish publishes an activity of a user in the activities of the user.
+//
+func (r *Repositories) Publish(u *User, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
+	return r.PublishTo(u, []*User{u}, activityType, verb, object, target)
+}
+
+// PublishTo publishes an activity of a user in the activities of other users.
+// Use it when the activity concerns other users than the actor.
+//
+func (r *Repositories) PublishTo(u *User, recipients []*User, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
+	return r.publish(newActivity(u.Entity(), u.Id, activityType, verb, object, target), recipients)
+}
+
+// PublishTeam publishes an activity of a team in the activities of its members.
+//
+func (r *Repositories) PublishTeam(t *Team, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
+	members, err := r.Users.ByIDs(t.UserIds)
+	if err != nil {
+		return err
+	}
+	return r.publish(newActivity(t.Entity(), t.Id, activityType, verb, object, target), members)
+}
+
+// PublishTournament publishes an activity of a tournament in the activities of its participants.
+//
+func (r *Repositories) PublishTournament(t *Tournament, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
+	participants, err := r.Users.ByIDs(t.UserIds)
+	if err != nil {
+		return err
+	}
+	return r.publish(newActivity(t.Entity(), t.Id, activityType, verb, object, target), participants)
+}
+
+// publish saves an activity and adds it to the activities of the recipients.
+//
+func (r *Repositories) publish(a *Activity, recipients []*User) error {
+	if err := r.Activities.Save(a); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		recipient.ActivityIds = append(recipient.ActivityIds, a.Id)
+		if err := r.Users.Save(recipient); err != nil {
+			return err
+		}
+	}
+	return nil
+}
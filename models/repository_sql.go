@@ -0,0 +1,468 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+
+	"appengine/datastore"
+)
+
+// sqlMigrations are the migrations of the SQL schema, in order.
+// A migration is never modified once released, changes of the schema are new migrations.
+// The statements are written for SQLite.
+//
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY,
+			username TEXT NOT NULL,
+			email TEXT NOT NULL,
+			auth TEXT NOT NULL,
+			data BLOB NOT NULL)`,
+		`CREATE INDEX users_username ON users (username)`,
+		`CREATE INDEX users_email ON users (email)`,
+		`CREATE INDEX users_auth ON users (auth)`,
+		`CREATE TABLE teams (
+			id INTEGER PRIMARY KEY,
+			key_name TEXT NOT NULL,
+			private BOOLEAN NOT NULL,
+			data BLOB NOT NULL)`,
+		`CREATE INDEX teams_key_name ON teams (key_name)`,
+		`CREATE TABLE tournaments (
+			id INTEGER PRIMARY KEY,
+			key_name TEXT NOT NULL,
+			name TEXT NOT NULL,
+			data BLOB NOT NULL)`,
+		`CREATE INDEX tournaments_key_name ON tournaments (key_name)`,
+		`CREATE TABLE matches (
+			id INTEGER PRIMARY KEY,
+			data BLOB NOT NULL)`,
+		`CREATE TABLE predicts (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			match_id INTEGER NOT NULL REFERENCES matches (id) ON DELETE CASCADE,
+			data BLOB NOT NULL)`,
+		`CREATE INDEX predicts_user_match ON predicts (user_id, match_id)`,
+		`CREATE INDEX predicts_match ON predicts (match_id)`,
+		`CREATE TABLE scores (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			tournament_id INTEGER NOT NULL REFERENCES tournaments (id) ON DELETE CASCADE,
+			data BLOB NOT NULL)`,
+		`CREATE INDEX scores_user_tournament ON scores (user_id, tournament_id)`,
+		`CREATE TABLE activities (
+			id INTEGER PRIMARY KEY,
+			creator_id INTEGER NOT NULL,
+			data BLOB NOT NULL)`,
+		`CREATE TABLE team_members (
+			team_id INTEGER NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			PRIMARY KEY (team_id, user_id))`,
+		`CREATE INDEX team_members_user ON team_members (user_id)`,
+		`CREATE TABLE team_admins (
+			team_id INTEGER NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			PRIMARY KEY (team_id, user_id))`,
+		`CREATE INDEX team_admins_user ON team_admins (user_id)`,
+		`CREATE TABLE tournament_participants (
+			tournament_id INTEGER NOT NULL REFERENCES tournaments (id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			PRIMARY KEY (tournament_id, user_id))`,
+		`CREATE INDEX tournament_participants_user ON tournament_participants (user_id)`,
+		`CREATE TABLE tournament_teams (
+			tournament_id INTEGER NOT NULL REFERENCES tournaments (id) ON DELETE CASCADE,
+			team_id INTEGER NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+			PRIMARY KEY (tournament_id, team_id))`,
+		`CREATE INDEX tournament_teams_team ON tournament_teams (team_id)`,
+		`CREATE TABLE tournament_admins (
+			tournament_id INTEGER NOT NULL REFERENCES tournaments (id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			PRIMARY KEY (tournament_id, user_id))`,
+		`CREATE INDEX tournament_admins_user ON tournament_admins (user_id)`,
+	},
+}
+
+// sqlColumn is a column of a table holding a field of the entities.
+//
+type sqlColumn struct {
+	name  string
+	field string
+}
+
+// sqlLink is a join table holding a list of ids of the entities.
+// The ids of the list are in the other column of the rows whose column is the id of the entity.
+// The rows are only written by Link and Unlink, saving an entity does not change its lists.
+//
+type sqlLink struct {
+	field  string
+	table  string
+	column string
+	other  string
+}
+
+// sqlTable describes how the entities of a kind are stored.
+// The columns can be queried, the entity without its links is encoded in the data column.
+//
+type sqlTable struct {
+	name    string
+	columns []sqlColumn
+	links   []sqlLink
+	loaded  func(e interface{}) // called once an entity and its links are loaded, if set.
+}
+
+var (
+	sqlUsers = &sqlTable{
+		name:    "users",
+		columns: []sqlColumn{{"username", "Username"}, {"email", "Email"}, {"auth", "Auth"}},
+		links: []sqlLink{
+			{"TeamIds", "team_members", "user_id", "team_id"},
+			{"TournamentIds", "tournament_participants", "user_id", "tournament_id"},
+		},
+	}
+	sqlTeams = &sqlTable{
+		name:    "teams",
+		columns: []sqlColumn{{"key_name", "KeyName"}, {"private", "Private"}},
+		links: []sqlLink{
+			{"UserIds", "team_members", "team_id", "user_id"},
+			{"AdminIds", "team_admins", "team_id", "user_id"},
+			{"TournamentIds", "tournament_teams", "team_id", "tournament_id"},
+		},
+		loaded: func(e interface{}) {
+			t := e.(*Team)
+			t.MembersCount = int64(len(t.UserIds))
+		},
+	}
+	sqlTournaments = &sqlTable{
+		name:    "tournaments",
+		columns: []sqlColumn{{"key_name", "KeyName"}, {"name", "Name"}},
+		links: []sqlLink{
+			{"UserIds", "tournament_participants", "tournament_id", "user_id"},
+			{"TeamIds", "tournament_teams", "tournament_id", "team_id"},
+			{"AdminIds", "tournament_admins", "tournament_id", "user_id"},
+		},
+	}
+	sqlMatches    = &sqlTable{name: "matches"}
+	sqlPredicts   = &sqlTable{name: "predicts", columns: []sqlColumn{{"user_id", "UserId"}, {"match_id", "MatchId"}}}
+	sqlScores     = &sqlTable{name: "scores", columns: []sqlColumn{{"user_id", "UserId"}, {"tournament_id", "TournamentId"}}}
+	sqlActivities = &sqlTable{name: "activities", columns: []sqlColumn{{"creator_id", "CreatorID"}}}
+)
+
+// OpenSQLRepositories returns repositories storing the entities in a SQL database.
+// The relationships between users, teams and tournaments are stored once, in join tables
+// with foreign keys, so both sides of a relationship always agree. They are changed by Links,
+// saving an entity leaves its relationships as they are.
+// The database is migrated to the last version of the schema.
+// The driver of the database must be registered, the schema is written for SQLite ("sqlite3").
+//
+func OpenSQLRepositories(driver, dataSource string) (*Repositories, error) {
+	db, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// foreign keys are enabled per connection.
+		db.SetMaxOpenConns(1)
+		if _, err = db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err = migrateSQL(db, sqlMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return sqlRepositories(db), nil
+}
+
+func sqlRepositories(db *sql.DB) *Repositories {
+	return &Repositories{
+		Users:       storeUsers{sqlKind{db, sqlUsers}},
+		Teams:       storeTeams{sqlKind{db, sqlTeams}},
+		Tournaments: storeTournaments{sqlKind{db, sqlTournaments}},
+		Matches:     storeMatches{sqlKind{db, sqlMatches}},
+		Predicts:    storePredicts{sqlKind{db, sqlPredicts}},
+		Scores:      storeScores{sqlKind{db, sqlScores}},
+		Activities:  storeActivities{sqlKind{db, sqlActivities}},
+		Links:       sqlLinks{db},
+		Events:      noEvents{},
+	}
+}
+
+// migrateSQL applies the migrations the database does not have yet, each one in a transaction.
+// The version of the database is the number of migrations applied.
+//
+func migrateSQL(db *sql.DB, migrations [][]string) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)"); err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return err
+	}
+	for v := version; v < len(migrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range migrations[v] {
+			if _, err = tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("models: migration %d: %v", v+1, err)
+			}
+		}
+		if _, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", v+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlKind stores the entities of a table.
+//
+type sqlKind struct {
+	db    *sql.DB
+	table *sqlTable
+}
+
+// get loads the entity with the given id and its links in dst.
+//
+func (k sqlKind) get(id int64, dst interface{}) error {
+	var data []byte
+	err := k.db.QueryRow("SELECT data FROM "+k.table.name+" WHERE id = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return datastore.ErrNoSuchEntity
+	} else if err != nil {
+		return err
+	}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(dst); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	v.FieldByName("Id").SetInt(id)
+	for _, l := range k.table.links {
+		var ids []int64
+		if ids, err = k.queryIDs("SELECT "+l.other+" FROM "+l.table+" WHERE "+l.column+" = ? ORDER BY rowid", id); err != nil {
+			return err
+		}
+		v.FieldByName(l.field).Set(reflect.ValueOf(ids))
+	}
+	if k.table.loaded != nil {
+		k.table.loaded(dst)
+	}
+	return nil
+}
+
+// put saves an entity without its links, a zero id is allocated by the database.
+//
+func (k sqlKind) put(id *int64, src interface{}) error {
+	data, err := k.encode(src)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(src).Elem()
+	values := []interface{}{data}
+	names := "data"
+	params := "?"
+	sets := "data = ?"
+	for _, c := range k.table.columns {
+		values = append(values, v.FieldByName(c.field).Interface())
+		names += ", " + c.name
+		params += ", ?"
+		sets += ", " + c.name + " = ?"
+	}
+
+	tx, err := k.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = k.putRow(tx, id, values, names, params, sets); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (k sqlKind) putRow(tx *sql.Tx, id *int64, values []interface{}, names, params, sets string) error {
+	if *id == 0 {
+		res, err := tx.Exec("INSERT INTO "+k.table.name+" ("+names+") VALUES ("+params+")", values...)
+		if err != nil {
+			return err
+		}
+		*id, err = res.LastInsertId()
+		return err
+	}
+
+	res, err := tx.Exec("UPDATE "+k.table.name+" SET "+sets+" WHERE id = ?", append(values, *id)...)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if n, err = res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO "+k.table.name+" (id, "+names+") VALUES (?, "+params+")", append([]interface{}{*id}, values...)...)
+	return err
+}
+
+// encode encodes an entity without its links, they are stored in the join tables.
+//
+func (k sqlKind) encode(src interface{}) ([]byte, error) {
+	v := reflect.ValueOf(src).Elem()
+	saved := make([]reflect.Value, len(k.table.links))
+	for i, l := range k.table.links {
+		f := v.FieldByName(l.field)
+		saved[i] = reflect.ValueOf(f.Interface())
+		f.Set(reflect.Zero(f.Type()))
+	}
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(src)
+	for i, l := range k.table.links {
+		v.FieldByName(l.field).Set(saved[i])
+	}
+	return b.Bytes(), err
+}
+
+// delete removes an entity, its links are removed by the foreign keys.
+//
+func (k sqlKind) delete(id int64) error {
+	res, err := k.db.Exec("DELETE FROM "+k.table.name+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if n, err = res.RowsAffected(); err != nil {
+		return err
+	}
+	if n == 0 {
+		return datastore.ErrNoSuchEntity
+	}
+	return nil
+}
+
+// find calls add with every entity whose field matches value.
+// Columns and links are queried, other fields are matched on every entity.
+//
+func (k sqlKind) find(filter string, value interface{}, newEntity func() interface{}, add func(interface{})) error {
+	ids, scan, err := k.findIDs(filter, value)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		e := newEntity()
+		if err = k.get(id, e); err == datastore.ErrNoSuchEntity {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if scan {
+			var ok bool
+			if ok, err = fieldMatches(e, filter, value); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+		}
+		add(e)
+	}
+	return nil
+}
+
+// findIDs returns the ids of the entities matching a filter, or all the ids to scan when the field is not in a column.
+//
+func (k sqlKind) findIDs(filter string, value interface{}) ([]int64, bool, error) {
+	for _, c := range k.table.columns {
+		if c.field == filter {
+			ids, err := k.queryIDs("SELECT id FROM "+k.table.name+" WHERE "+c.name+" = ? ORDER BY id", value)
+			return ids, false, err
+		}
+	}
+	for _, l := range k.table.links {
+		if l.field == filter {
+			ids, err := k.queryIDs("SELECT "+l.column+" FROM "+l.table+" WHERE "+l.other+" = ? ORDER BY "+l.column, value)
+			return ids, false, err
+		}
+	}
+	ids, err := k.queryIDs("SELECT id FROM " + k.table.name + " ORDER BY id")
+	return ids, true, err
+}
+
+func (k sqlKind) queryIDs(query string, args ...interface{}) ([]int64, error) {
+	rows, err := k.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// sqlKindTables are the tables of the kinds holding relations.
+//
+var sqlKindTables = map[string]*sqlTable{"User": sqlUsers, "Team": sqlTeams, "Tournament": sqlTournaments}
+
+// sqlLinks stores the relations in the join tables, a link is a row.
+//
+type sqlLinks struct{ db *sql.DB }
+
+func (r sqlLinks) Link(rel Relation, id, otherID int64) error {
+	l, err := sqlRelationLink(rel)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("INSERT OR IGNORE INTO "+l.table+" ("+l.column+", "+l.other+") VALUES (?, ?)", id, otherID)
+	return err
+}
+
+func (r sqlLinks) Unlink(rel Relation, id, otherID int64) error {
+	l, err := sqlRelationLink(rel)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("DELETE FROM "+l.table+" WHERE "+l.column+" = ? AND "+l.other+" = ?", id, otherID)
+	return err
+}
+
+// sqlRelationLink returns the join table of a relation, its column holds the id of the entity named first.
+//
+func sqlRelationLink(rel Relation) (sqlLink, error) {
+	if f, ok := relations[rel]; ok {
+		for _, l := range sqlKindTables[f.kind].links {
+			if l.field == f.field {
+				return l, nil
+			}
+		}
+	}
+	return sqlLink{}, fmt.Errorf("models: unknown relation %d", rel)
+}
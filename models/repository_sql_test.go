@@ -0,0 +1,265 @@
+//go:build sqlite
+// +build sqlite
+
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"appengine/datastore"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestSQL opens repositories on an embedded SQLite database, the SQL tests run with:
+//
+//	go test -tags sqlite -run SQL
+//
+func openTestSQL(t *testing.T) (*Repositories, func()) {
+	dir, err := ioutil.TempDir("", "gonawin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err := OpenSQLRepositories("sqlite3", filepath.Join(dir, "gonawin.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("OpenSQLRepositories: %v", err)
+	}
+	return repos, func() { os.RemoveAll(dir) }
+}
+
+func TestSQLMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonawin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gonawin.db")
+
+	for i := 0; i < 2; i++ {
+		if _, err = OpenSQLRepositories("sqlite3", path); err != nil {
+			t.Fatalf("TestSQLMigrate(%d): %v", i, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var version int
+	if err = db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(sqlMigrations) {
+		t.Errorf("TestSQLMigrate: got version %d wanted %d", version, len(sqlMigrations))
+	}
+}
+
+func TestSQLLinks(t *testing.T) {
+	repos, done := openTestSQL(t)
+	defer done()
+
+	john, jane := &User{Username: "john"}, &User{Username: "jane"}
+	repos.Users.Save(john)
+	repos.Users.Save(jane)
+	team := &Team{Name: "Foo"}
+	repos.Teams.Save(team)
+
+	for _, link := range []struct {
+		rel         Relation
+		id, otherID int64
+	}{
+		{TeamMembers, team.Id, john.Id},
+		{TeamAdmins, team.Id, john.Id},
+		{TeamMembers, team.Id, jane.Id},
+		{TeamMembers, team.Id, jane.Id},
+	} {
+		if err := repos.Links.Link(link.rel, link.id, link.otherID); err != nil {
+			t.Fatalf("TestSQLLinks: link %d: %v", link.otherID, err)
+		}
+	}
+
+	got, err := repos.Teams.ByID(team.Id)
+	if err != nil {
+		t.Fatalf("TestSQLLinks: %v", err)
+	}
+	if !equalIDs(got.UserIds, []int64{john.Id, jane.Id}) || got.MembersCount != 2 {
+		t.Errorf("TestSQLLinks: got members %v (%d) wanted %v", got.UserIds, got.MembersCount, []int64{john.Id, jane.Id})
+	}
+	if u, _ := repos.Users.ByID(jane.Id); !equalIDs(u.TeamIds, []int64{team.Id}) {
+		t.Errorf("TestSQLLinks: got teams of jane %v wanted %v", u.TeamIds, []int64{team.Id})
+	}
+
+	// saving a stale copy of an entity does not change its links.
+	jane.TeamIds = nil
+	if err = repos.Users.Save(jane); err != nil {
+		t.Fatalf("TestSQLLinks: save user: %v", err)
+	}
+	if u, _ := repos.Users.ByID(jane.Id); !equalIDs(u.TeamIds, []int64{team.Id}) {
+		t.Errorf("TestSQLLinks: got teams of jane %v wanted %v after a stale save", u.TeamIds, []int64{team.Id})
+	}
+
+	if err = repos.Links.Unlink(TeamMembers, team.Id, jane.Id); err != nil {
+		t.Fatalf("TestSQLLinks: unlink: %v", err)
+	}
+	if u, _ := repos.Users.ByID(jane.Id); len(u.TeamIds) != 0 {
+		t.Errorf("TestSQLLinks: got teams of jane %v wanted none", u.TeamIds)
+	}
+
+	// deleting john removes him from the team.
+	if err = repos.Users.Delete(john.Id); err != nil {
+		t.Fatalf("TestSQLLinks: delete: %v", err)
+	}
+	got, _ = repos.Teams.ByID(team.Id)
+	if len(got.UserIds) != 0 || len(got.AdminIds) != 0 {
+		t.Errorf("TestSQLLinks: got members %v and admins %v wanted none", got.UserIds, got.AdminIds)
+	}
+}
+
+func TestSQLForeignKeys(t *testing.T) {
+	repos, done := openTestSQL(t)
+	defer done()
+
+	team := &Team{Name: "Foo", UserIds: []int64{42}}
+	if err := repos.Teams.Save(team); err != nil {
+		t.Fatalf("TestSQLForeignKeys: got %v wanted the lists of a saved entity to be ignored", err)
+	}
+	if got, _ := repos.Teams.ByID(team.Id); len(got.UserIds) != 0 {
+		t.Errorf("TestSQLForeignKeys: got members %v wanted none", got.UserIds)
+	}
+	if err := repos.Links.Link(TeamMembers, team.Id, 42); err == nil {
+		t.Errorf("TestSQLForeignKeys: got no error wanted an error for a missing member")
+	}
+	if err := repos.Predicts.Save(&Predict{UserId: 42, MatchId: 42}); err == nil {
+		t.Errorf("TestSQLForeignKeys: got no error wanted an error for a missing user and match")
+	}
+}
+
+func TestSQLJoinTeam(t *testing.T) {
+	repos, done := openTestSQL(t)
+	defer done()
+
+	john, jane := &User{Username: "john"}, &User{Username: "jane"}
+	repos.Users.Save(john)
+	repos.Users.Save(jane)
+
+	team, err := repos.NewTeam(john, "Foo", "", false)
+	if err != nil {
+		t.Fatalf("TestSQLJoinTeam: %v", err)
+	}
+	if err = repos.JoinTeam(team, jane); err != nil {
+		t.Fatalf("TestSQLJoinTeam: join: %v", err)
+	}
+
+	got, _ := repos.Teams.ByID(team.Id)
+	if !equalIDs(got.UserIds, []int64{john.Id, jane.Id}) || !equalIDs(got.AdminIds, []int64{john.Id}) {
+		t.Errorf("TestSQLJoinTeam: got members %v and admins %v wanted %v and %v", got.UserIds, got.AdminIds, []int64{john.Id, jane.Id}, []int64{john.Id})
+	}
+	if u, _ := repos.Users.ByID(john.Id); !equalIDs(u.TeamIds, []int64{team.Id}) || len(u.ActivityIds) != 1 {
+		t.Errorf("TestSQLJoinTeam: got teams %v and activities %v of john wanted the team and its creation", u.TeamIds, u.ActivityIds)
+	}
+}
+
+func TestSQLFind(t *testing.T) {
+	repos, done := openTestSQL(t)
+	defer done()
+
+	u := &User{Username: "john"}
+	repos.Users.Save(u)
+	for _, team := range []*Team{
+		{Name: "Foo", Description: "first"},
+		{Name: "Bar", Private: true},
+	} {
+		if err := repos.Teams.Save(team); err != nil {
+			t.Fatalf("TestSQLFind: save: %v", err)
+		}
+		if team.Name == "Foo" {
+			repos.Links.Link(TeamMembers, team.Id, u.Id)
+		}
+	}
+
+	tests := []struct {
+		filter string
+		value  interface{}
+		want   []string
+	}{
+		{"KeyName", "bar", []string{"Bar"}},
+		{"Private", false, []string{"Foo"}},
+		{"UserIds", u.Id, []string{"Foo"}},
+		{"Description", "first", []string{"Foo"}},
+		{"Name", "qux", nil},
+	}
+	for _, test := range tests {
+		found, err := repos.Teams.Find(test.filter, test.value)
+		if err != nil {
+			t.Errorf("TestSQLFind(%q): %v", test.filter, err)
+			continue
+		}
+		var names []string
+		for _, team := range found {
+			names = append(names, team.Name)
+		}
+		if !equalStrings(names, test.want) {
+			t.Errorf("TestSQLFind(%q, %v): got %v wanted %v", test.filter, test.value, names, test.want)
+		}
+	}
+}
+
+func TestSQLPredictsAndScores(t *testing.T) {
+	repos, done := openTestSQL(t)
+	defer done()
+
+	u := &User{Username: "john"}
+	repos.Users.Save(u)
+	m := &Tmatch{IdNumber: 1}
+	repos.Matches.Save(m)
+	tournament := &Tournament{Name: "Cup"}
+	repos.Tournaments.Save(tournament)
+	repos.Links.Link(TournamentParticipants, tournament.Id, u.Id)
+
+	if err := repos.Predicts.Save(&Predict{UserId: u.Id, MatchId: m.Id, Result1: 2}); err != nil {
+		t.Fatalf("TestSQLPredictsAndScores: save predict: %v", err)
+	}
+	if err := repos.Scores.Save(&Score{UserId: u.Id, TournamentId: tournament.Id, Scores: []int64{3}}); err != nil {
+		t.Fatalf("TestSQLPredictsAndScores: save score: %v", err)
+	}
+
+	p, err := repos.Predicts.ByUserMatch(u.Id, m.Id)
+	if err != nil || p.Result1 != 2 {
+		t.Errorf("TestSQLPredictsAndScores: got %v, %v wanted the predict of the match", p, err)
+	}
+	scores, err := repos.Scores.ByUserTournament(u.Id, tournament.Id)
+	if err != nil || len(scores) != 1 || !equalIDs(scores[0].Scores, []int64{3}) {
+		t.Errorf("TestSQLPredictsAndScores: got %v, %v wanted the score of the tournament", scores, err)
+	}
+	if got, _ := repos.Users.ByID(u.Id); !equalIDs(got.TournamentIds, []int64{tournament.Id}) {
+		t.Errorf("TestSQLPredictsAndScores: got tournaments %v wanted %v", got.TournamentIds, []int64{tournament.Id})
+	}
+
+	// deleting the match deletes its predicts.
+	repos.Matches.Delete(m.Id)
+	if _, err = repos.Predicts.ByUserMatch(u.Id, m.Id); err != datastore.ErrNoSuchEntity {
+		t.Errorf("TestSQLPredictsAndScores: got %v wanted %v", err, datastore.ErrNoSuchEntity)
+	}
+}
@@ -0,0 +1,332 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"sync"
+
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers"
+)
+
+// entityStore stores the entities of a kind by id.
+// The memory and the SQL repositories are built on an entityStore for each kind.
+//
+type entityStore interface {
+	get(id int64, dst interface{}) error
+	put(id *int64, src interface{}) error
+	delete(id int64) error
+	find(filter string, value interface{}, newEntity func() interface{}, add func(interface{})) error
+}
+
+type storeUsers struct{ entityStore }
+
+func (r storeUsers) ByID(id int64) (*User, error) {
+	var u User
+	if err := r.get(id, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r storeUsers) ByIDs(ids []int64) ([]*User, error) {
+	var users []*User
+	for _, id := range ids {
+		if u, err := r.ByID(id); err == nil {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (r storeUsers) Find(filter string, value interface{}) ([]*User, error) {
+	var users []*User
+	err := r.find(filter, value,
+		func() interface{} { return new(User) },
+		func(e interface{}) { users = append(users, e.(*User)) })
+	return users, err
+}
+
+func (r storeUsers) Save(u *User) error { return r.put(&u.Id, u) }
+
+func (r storeUsers) Delete(id int64) error { return r.delete(id) }
+
+type storeTeams struct{ entityStore }
+
+func (r storeTeams) ByID(id int64) (*Team, error) {
+	var t Team
+	if err := r.get(id, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r storeTeams) ByIDs(ids []int64) ([]*Team, error) {
+	var teams []*Team
+	for _, id := range ids {
+		if t, err := r.ByID(id); err == nil {
+			teams = append(teams, t)
+		}
+	}
+	return teams, nil
+}
+
+func (r storeTeams) Find(filter string, value interface{}) ([]*Team, error) {
+	var teams []*Team
+	err := r.find(filter, value,
+		func() interface{} { return new(Team) },
+		func(e interface{}) { teams = append(teams, e.(*Team)) })
+	return teams, err
+}
+
+func (r storeTeams) Save(t *Team) error {
+	t.KeyName = helpers.TrimLower(t.Name)
+	return r.put(&t.Id, t)
+}
+
+func (r storeTeams) Delete(id int64) error { return r.delete(id) }
+
+type storeTournaments struct{ entityStore }
+
+func (r storeTournaments) ByID(id int64) (*Tournament, error) {
+	var t Tournament
+	if err := r.get(id, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r storeTournaments) ByIDs(ids []int64) ([]*Tournament, error) {
+	var tournaments []*Tournament
+	for _, id := range ids {
+		if t, err := r.ByID(id); err == nil {
+			tournaments = append(tournaments, t)
+		}
+	}
+	return tournaments, nil
+}
+
+func (r storeTournaments) Find(filter string, value interface{}) ([]*Tournament, error) {
+	var tournaments []*Tournament
+	err := r.find(filter, value,
+		func() interface{} { return new(Tournament) },
+		func(e interface{}) { tournaments = append(tournaments, e.(*Tournament)) })
+	return tournaments, err
+}
+
+func (r storeTournaments) Save(t *Tournament) error {
+	t.KeyName = helpers.TrimLower(t.Name)
+	return r.put(&t.Id, t)
+}
+
+func (r storeTournaments) Delete(id int64) error { return r.delete(id) }
+
+type storeMatches struct{ entityStore }
+
+func (r storeMatches) ByID(id int64) (*Tmatch, error) {
+	var m Tmatch
+	if err := r.get(id, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r storeMatches) ByIDs(ids []int64) ([]*Tmatch, error) {
+	var matches []*Tmatch
+	for _, id := range ids {
+		if m, err := r.ByID(id); err == nil {
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+func (r storeMatches) Find(filter string, value interface{}) ([]*Tmatch, error) {
+	var matches []*Tmatch
+	err := r.find(filter, value,
+		func() interface{} { return new(Tmatch) },
+		func(e interface{}) { matches = append(matches, e.(*Tmatch)) })
+	return matches, err
+}
+
+func (r storeMatches) Save(m *Tmatch) error { return r.put(&m.Id, m) }
+
+func (r storeMatches) Delete(id int64) error { return r.delete(id) }
+
+type storePredicts struct{ entityStore }
+
+func (r storePredicts) ByID(id int64) (*Predict, error) {
+	var p Predict
+	if err := r.get(id, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r storePredicts) ByIDs(ids []int64) ([]*Predict, error) {
+	var predicts []*Predict
+	for _, id := range ids {
+		if p, err := r.ByID(id); err == nil {
+			predicts = append(predicts, p)
+		}
+	}
+	return predicts, nil
+}
+
+func (r storePredicts) Find(filter string, value interface{}) ([]*Predict, error) {
+	var predicts []*Predict
+	err := r.find(filter, value,
+		func() interface{} { return new(Predict) },
+		func(e interface{}) { predicts = append(predicts, e.(*Predict)) })
+	return predicts, err
+}
+
+func (r storePredicts) ByUserMatch(userID, matchID int64) (*Predict, error) {
+	predicts, err := r.Find("UserId", userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range predicts {
+		if p.MatchId == matchID {
+			return p, nil
+		}
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
+
+func (r storePredicts) Save(p *Predict) error { return r.put(&p.Id, p) }
+
+func (r storePredicts) Delete(id int64) error { return r.delete(id) }
+
+type storeScores struct{ entityStore }
+
+func (r storeScores) ByID(id int64) (*Score, error) {
+	var s Score
+	if err := r.get(id, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r storeScores) ByIDs(ids []int64) ([]*Score, error) {
+	var scores []*Score
+	for _, id := range ids {
+		if s, err := r.ByID(id); err == nil {
+			scores = append(scores, s)
+		}
+	}
+	return scores, nil
+}
+
+func (r storeScores) Find(filter string, value interface{}) ([]*Score, error) {
+	var scores []*Score
+	err := r.find(filter, value,
+		func() interface{} { return new(Score) },
+		func(e interface{}) { scores = append(scores, e.(*Score)) })
+	return scores, err
+}
+
+func (r storeScores) ByUserTournament(userID, tournamentID int64) ([]*Score, error) {
+	scores, err := r.Find("UserId", userID)
+	if err != nil {
+		return nil, err
+	}
+	var found []*Score
+	for _, s := range scores {
+		if s.TournamentId == tournamentID {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+func (r storeScores) Save(s *Score) error { return r.put(&s.Id, s) }
+
+func (r storeScores) Delete(id int64) error { return r.delete(id) }
+
+type storeActivities struct{ entityStore }
+
+func (r storeActivities) ByID(id int64) (*Activity, error) {
+	var a Activity
+	if err := r.get(id, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r storeActivities) ByIDs(ids []int64) ([]*Activity, error) {
+	var activities []*Activity
+	for _, id := range ids {
+		if a, err := r.ByID(id); err == nil {
+			activities = append(activities, a)
+		}
+	}
+	return activities, nil
+}
+
+func (r storeActivities) Find(filter string, value interface{}) ([]*Activity, error) {
+	var activities []*Activity
+	err := r.find(filter, value,
+		func() interface{} { return new(Activity) },
+		func(e interface{}) { activities = append(activities, e.(*Activity)) })
+	return activities, err
+}
+
+func (r storeActivities) ByUser(u *User, count, page int64) ([]*Activity, error) {
+	return activitiesPage(r, u, count, page)
+}
+
+func (r storeActivities) ByUserCursor(u *User, count int64, cursor string) ([]*Activity, string, error) {
+	return activitiesByCursor(r, u, count, cursor)
+}
+
+func (r storeActivities) Save(a *Activity) error { return r.put(&a.Id, a) }
+
+func (r storeActivities) Delete(id int64) error { return r.delete(id) }
+
+// storeLinks stores the relations in the lists of the entities of the kinds.
+// The lists are changed under a lock so that concurrent links do not overwrite each other.
+//
+type storeLinks struct {
+	sync.Mutex
+	kinds map[string]entityStore
+}
+
+func (r *storeLinks) Link(rel Relation, id, otherID int64) error {
+	return r.set(rel, id, otherID, true)
+}
+
+func (r *storeLinks) Unlink(rel Relation, id, otherID int64) error {
+	return r.set(rel, id, otherID, false)
+}
+
+func (r *storeLinks) set(rel Relation, id, otherID int64, linked bool) error {
+	r.Lock()
+	defer r.Unlock()
+	get := func(kind string, id int64, dst interface{}) error { return r.kinds[kind].get(id, dst) }
+	put := func(kind string, id int64, src interface{}) error { return r.kinds[kind].put(&id, src) }
+	return setLink(rel, id, otherID, linked, get, put)
+}
+
+// noEvents drops the events of the teams: webhooks are delivered by the task queue of App Engine,
+// the memory and the SQL repositories have none.
+//
+type noEvents struct{}
+
+func (noEvents) PublishTeamEvent(t *Team, event string, tournament ActivityEntity, data interface{}) {
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+// Role is the role of a user on a team or a tournament, it defines what the user is allowed to do.
+//
+type Role int
+
+// Roles of a user. A site admin has every role.
+//
+const (
+	RoleViewer          Role = iota // any signed in user.
+	RoleMember                      // member of a team or participant of a tournament.
+	RoleTeamAdmin                   // admin of a team.
+	RoleTournamentAdmin             // admin of a tournament.
+	RoleSiteAdmin                   // gonawin admin.
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:          "viewer",
+	RoleMember:          "member",
+	RoleTeamAdmin:       "team admin",
+	RoleTournamentAdmin: "tournament admin",
+	RoleSiteAdmin:       "site admin",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// Includes indicates if a role grants the rights of a required role.
+// The admin of a team is a member of the team but is not the admin of a tournament.
+//
+func (r Role) Includes(required Role) bool {
+	if r == required || r == RoleSiteAdmin {
+		return true
+	}
+	switch required {
+	case RoleViewer:
+		return true
+	case RoleMember:
+		return r == RoleTeamAdmin || r == RoleTournamentAdmin
+	}
+	return false
+}
+
+// RoleOf returns the role of a user on a team.
+//
+func (t *Team) RoleOf(u *User) Role {
+	if u.IsAdmin {
+		return RoleSiteAdmin
+	}
+	if ok, _ := t.ContainsAdminID(u.Id); ok {
+		return RoleTeamAdmin
+	}
+	if ok, _ := t.ContainsUserID(u.Id); ok {
+		return RoleMember
+	}
+	return RoleViewer
+}
+
+// RoleOf returns the role of a user on a tournament.
+//
+func (t *Tournament) RoleOf(u *User) Role {
+	if u.IsAdmin {
+		return RoleSiteAdmin
+	}
+	if ok, _ := t.ContainsAdminID(u.Id); ok {
+		return RoleTournamentAdmin
+	}
+	if ok, _ := t.ContainsUserID(u.Id); ok {
+		return RoleMember
+	}
+	return RoleViewer
+}
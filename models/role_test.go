@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import "testing"
+
+func TestRoleIncludes(t *testing.T) {
+	roles := []Role{RoleViewer, RoleMember, RoleTeamAdmin, RoleTournamentAdmin, RoleSiteAdmin}
+
+	// want[i][j] indicates if roles[i] includes roles[j].
+	want := [][]bool{
+		{true, false, false, false, false},
+		{true, true, false, false, false},
+		{true, true, true, false, false},
+		{true, true, false, true, false},
+		{true, true, true, true, true},
+	}
+
+	for i, r := range roles {
+		for j, required := range roles {
+			if got := r.Includes(required); got != want[i][j] {
+				t.Errorf("TestRoleIncludes(%q includes %q): got %v wanted %v", r, required, got, want[i][j])
+			}
+		}
+	}
+}
+
+func TestRoleOf(t *testing.T) {
+	team := Team{AdminIds: []int64{1}, UserIds: []int64{1, 2}}
+	tournament := Tournament{AdminIds: []int64{3}, UserIds: []int64{2, 3}}
+
+	tests := []struct {
+		user           User
+		teamRole       Role
+		tournamentRole Role
+	}{
+		{User{Id: 1}, RoleTeamAdmin, RoleViewer},
+		{User{Id: 2}, RoleMember, RoleMember},
+		{User{Id: 3}, RoleViewer, RoleTournamentAdmin},
+		{User{Id: 4}, RoleViewer, RoleViewer},
+		{User{Id: 4, IsAdmin: true}, RoleSiteAdmin, RoleSiteAdmin},
+	}
+	for _, test := range tests {
+		if got := team.RoleOf(&test.user); got != test.teamRole {
+			t.Errorf("TestRoleOf(team, user %v): got %q wanted %q", test.user.Id, got, test.teamRole)
+		}
+		if got := tournament.RoleOf(&test.user); got != test.tournamentRole {
+			t.Errorf("TestRoleOf(tournament, user %v): got %q wanted %q", test.user.Id, got, test.tournamentRole)
+		}
+	}
+}
@@ -19,227 +19,430 @@ package models
 import (
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 
 	"appengine"
+	"appengine/datastore"
 
-	helpers "github.com/taironas/gonawin/helpers"
-	"github.com/taironas/gonawin/helpers/log"
+	"github.com/taironas/gonawin/helpers/search"
 )
 
-// TournamentScore computes a score vector, given a query string and an array of ids,
-// that has the doc ids and the score of each id with respect to the query.
+// maxSearchTerms is the maximum number of words of the index starting with a query word read to match it.
+const maxSearchTerms = 1000
+
+// maxFuzzySearchTerms is the maximum number of words of the index starting with the first letter of a query word
+// read to match it with typos.
+const maxFuzzySearchTerms = 1000
+
+// SearchKinds are the kinds of the entities in the search index.
 //
-func TournamentScore(c appengine.Context, query string, ids []int64) []int64 {
-
-	words := strings.Split(query, " ")
-	setOfWords := helpers.SetOfStrings(query)
-	nbTournamentWords, _ := TournamentInvertedIndexGetWordCount(c)
-
-	// query vector
-	q := make([]float64, len(setOfWords))
-	for i, w := range setOfWords {
-		dft := 0
-		if invID, err := FindTournamentInvertedIndex(c, "KeyName", w); err != nil {
-			log.Errorf(c, " search.TournamentScore, unable to find KeyName=%s: %v", w, err)
-		} else if invID != nil {
-			dft = len(strings.Split(string(invID.TournamentIds), " "))
-		}
-		q[i] = math.Log10(1+float64(helpers.CountTerm(words, w))) * math.Log10(float64(nbTournamentWords+1)/float64(dft+1))
-	}
-
-	// d vector
-	dVect := make([][]float64, len(ids))
-	for i, id := range ids {
-		d := make([]float64, len(setOfWords))
-		for j, wi := range setOfWords {
-			// get word frequency by tournament (id, wi)
-			wordFreqByTournament := GetWordFrequencyForTournament(c, id, wi)
-			// get number of tournaments with word (wi)
-			tournamentFreqForWord, err := GetTournamentFrequencyForWord(c, wi)
-			if err != nil {
-				log.Errorf(c, " search.TournamentScore, error occurred when getting tournament frequency for word=%s: %v", wi, err)
-			}
+var SearchKinds = []string{"Team", "Tournament", "User"}
+
+// Indexable is implemented by the entities found by search.
+//
+type Indexable interface {
+	IndexKind() string // kind of the entity, one of SearchKinds.
+	IndexID() int64    // id of the entity.
+	IndexText() string // text the entity is found by.
+	IndexName() string // name the entity is suggested with.
+}
+
+// SearchTerm holds the entities of a kind which have a word in their text, with the number of times they have it.
+// Its key name is the kind and the word.
+//
+type SearchTerm struct {
+	Kind        string
+	Word        string
+	Ids         []int64 `datastore:",noindex"`
+	Frequencies []int64 `datastore:",noindex"`
+}
+
+// SearchDocument holds the words an entity is indexed by, so that its terms can be updated
+// when its text changes. Its key name is the kind and the id of the entity.
+//
+type SearchDocument struct {
+	Kind  string
+	Id    int64
+	Name  string   `datastore:",noindex"`
+	Words []string `datastore:",noindex"`
+}
+
+// SearchCount holds the number of entities and words in the index of a kind, its key name is the kind.
+//
+type SearchCount struct {
+	Documents int64
+	Words     int64
+}
+
+// SearchResult is an entity matching a search query.
+//
+type SearchResult struct {
+	Kind  string
+	Id    int64
+	Score float64
+}
+
+// searchMatch is a term of the index matching a query word, with the weight of the match.
+type searchMatch struct {
+	term   *SearchTerm
+	weight float64
+}
+
+// searchTermKey returns the key of the term of a word in the index of a kind.
+func searchTermKey(c appengine.Context, kind, word string) *datastore.Key {
+	return datastore.NewKey(c, "SearchTerm", kind+":"+word, 0, nil)
+}
+
+// searchDocumentKey returns the key of the document of an entity in the index.
+func searchDocumentKey(c appengine.Context, kind string, id int64) *datastore.Key {
+	return datastore.NewKey(c, "SearchDocument", kind+":"+strconv.FormatInt(id, 10), 0, nil)
+}
 
-			d[j] = math.Log10(float64(1+wordFreqByTournament)) * math.Log10(float64(nbTournamentWords+1)/float64(tournamentFreqForWord+1))
+// searchCountKey returns the key of the counters of the index of a kind.
+func searchCountKey(c appengine.Context, kind string) *datastore.Key {
+	return datastore.NewKey(c, "SearchCount", kind, 0, nil)
+}
+
+// frequency returns the number of times the entity id has the word of the term.
+func (t *SearchTerm) frequency(id int64) int64 {
+	for i, v := range t.Ids {
+		if v == id {
+			return t.Frequencies[i]
 		}
-		dVect[i] = make([]float64, len(setOfWords))
-		dVect[i] = d
 	}
+	return 0
+}
 
-	// compute score vector
-	var score map[int64]float64
-	score = make(map[int64]float64)
-	for i, dVectIndex := range dVect {
-		score[ids[i]] = dotProduct(dVectIndex, q)
+// set sets the number of times the entity id has the word of the term, the entity is removed when it is 0.
+func (t *SearchTerm) set(id, frequency int64) {
+	for i, v := range t.Ids {
+		if v != id {
+			continue
+		}
+		if frequency > 0 {
+			t.Frequencies[i] = frequency
+		} else {
+			t.Ids = append(t.Ids[:i], t.Ids[i+1:]...)
+			t.Frequencies = append(t.Frequencies[:i], t.Frequencies[i+1:]...)
+		}
+		return
+	}
+	if frequency > 0 {
+		t.Ids = append(t.Ids, id)
+		t.Frequencies = append(t.Frequencies, frequency)
 	}
-
-	sortedScore := sortMapByValueDesc(score)
-
-	return getKeysFrompairList(sortedScore)
 }
 
-// TeamScore computes a score vector, given a query string and an array of ids,
-//  that has the doc ids and the score of each id with respect to the query.
+// setSearchTerm sets the number of times an entity has a word in the index of its kind.
+// It returns 1 if the word was added to the index, -1 if it was removed and 0 otherwise.
 //
-func TeamScore(c appengine.Context, query string, ids []int64) []int64 {
-
-	words := strings.Split(query, " ")
-	setOfWords := helpers.SetOfStrings(query)
-	nbTeamWords, _ := TeamInvertedIndexGetWordCount(c)
-
-	// query vector
-	q := make([]float64, len(setOfWords))
-	for i, w := range setOfWords {
-		dft := 0
-		if invID, err := FindTeamInvertedIndex(c, "KeyName", w); err != nil {
-			log.Errorf(c, " search.TeamScore, unable to find KeyName=%s: %v", w, err)
-		} else if invID != nil {
-			dft = len(strings.Split(string(invID.TeamIds), " "))
-		}
-		q[i] = math.Log10(1+float64(helpers.CountTerm(words, w))) * math.Log10(float64(nbTeamWords+1)/float64(dft+1))
-	}
-
-	// d vector
-	dVect := make([][]float64, len(ids))
-	for i, id := range ids {
-		d := make([]float64, len(setOfWords))
-		for j, wi := range setOfWords {
-			// get word frequency by team (id, wi)
-			wordFreqByTeam := GetWordFrequencyForTeam(c, id, wi)
-			// get number of teams with word (wi)
-			teamFreqForWord, err := GetTeamFrequencyForWord(c, wi)
-			if err != nil {
-				log.Errorf(c, " search.TeamScore, error occurred when getting team frequency for word=%s: %v", wi, err)
+func setSearchTerm(c appengine.Context, kind, word string, id, frequency int64) (int64, error) {
+	var delta int64
+	err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+		delta = 0
+		key := searchTermKey(c, kind, word)
+		var t SearchTerm
+		if err := datastore.Get(c, key, &t); err == datastore.ErrNoSuchEntity {
+			if frequency == 0 {
+				return nil
 			}
+			t = SearchTerm{Kind: kind, Word: word}
+			delta = 1
+		} else if err != nil {
+			return err
+		}
 
-			d[j] = math.Log10(float64(1+wordFreqByTeam)) * math.Log10(float64(nbTeamWords+1)/float64(teamFreqForWord+1))
+		t.set(id, frequency)
+		if len(t.Ids) == 0 {
+			delta = -1
+			return datastore.Delete(c, key)
+		}
+		_, err := datastore.Put(c, key, &t)
+		return err
+	}, nil)
+	return delta, err
+}
+
+// updateSearchTerms updates the terms of an entity whose words changed from oldWords to newWords.
+// It returns the change of the number of words in the index of the kind.
+//
+func updateSearchTerms(c appengine.Context, kind string, id int64, oldWords, newWords []string) (int64, error) {
+	oldFrequencies := search.Frequencies(oldWords)
+	newFrequencies := search.Frequencies(newWords)
+
+	changed := make(map[string]int64)
+	for w, f := range newFrequencies {
+		if oldFrequencies[w] != f {
+			changed[w] = f
+		}
+	}
+	for w := range oldFrequencies {
+		if _, ok := newFrequencies[w]; !ok {
+			changed[w] = 0
 		}
-		dVect[i] = make([]float64, len(setOfWords))
-		dVect[i] = d
 	}
 
-	// compute score vector
-	var score map[int64]float64
-	score = make(map[int64]float64)
-	for i, dVectIndex := range dVect {
-		score[ids[i]] = dotProduct(dVectIndex, q)
+	var words int64
+	for w, f := range changed {
+		delta, err := setSearchTerm(c, kind, w, id, f)
+		if err != nil {
+			return words, err
+		}
+		words += delta
 	}
+	return words, nil
+}
 
-	sortedScore := sortMapByValueDesc(score)
+// addSearchCount adds to the number of entities and words of the index of a kind.
+func addSearchCount(c appengine.Context, kind string, documents, words int64) error {
+	if documents == 0 && words == 0 {
+		return nil
+	}
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		key := searchCountKey(c, kind)
+		var count SearchCount
+		if err := datastore.Get(c, key, &count); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		count.Documents += documents
+		count.Words += words
+		_, err := datastore.Put(c, key, &count)
+		return err
+	}, nil)
+}
 
-	return getKeysFrompairList(sortedScore)
+// SearchCountByKind returns the number of entities and words in the index of a kind.
+//
+func SearchCountByKind(c appengine.Context, kind string) (*SearchCount, error) {
+	var count SearchCount
+	if err := datastore.Get(c, searchCountKey(c, kind), &count); err != nil && err != datastore.ErrNoSuchEntity {
+		return nil, err
+	}
+	return &count, nil
 }
 
-// UserScore computes a score vector, given a query string and an array of ids,
-// that has the doc ids and the score of each id with respect to the query.
+// Index adds an entity to the search index or updates it when its text changed.
+// Indexing an entity again repairs its terms if a previous update failed halfway.
 //
-func UserScore(c appengine.Context, query string, ids []int64) []int64 {
-
-	words := strings.Split(query, " ")
-	setOfWords := helpers.SetOfStrings(query)
-	nbUserWords, _ := UserInvertedIndexGetWordCount(c)
-
-	// query vector
-	q := make([]float64, len(setOfWords))
-	for i, w := range setOfWords {
-		dft := 0
-		if invID, err := FindUserInvertedIndex(c, "KeyName", w); err != nil {
-			log.Errorf(c, "search.UserScore, unable to find KeyName=%s: %v", w, err)
-		} else if invID != nil {
-			dft = len(strings.Split(string(invID.UserIds), " "))
-		}
-		q[i] = math.Log10(1+float64(helpers.CountTerm(words, w))) * math.Log10(float64(nbUserWords+1)/float64(dft+1))
-	}
-
-	// d vector
-	dVect := make([][]float64, len(ids))
-	for i, id := range ids {
-		d := make([]float64, len(setOfWords))
-		for j, wi := range setOfWords {
-			// get word frequency by user (id, wi)
-			wordFreqByUser := GetWordFrequencyForUser(c, id, wi)
-			// get number of users with word (wi)
-			userFreqForWord, err := GetUserFrequencyForWord(c, wi)
-			if err != nil {
-				log.Errorf(c, " search.UserScore, error occurred when getting user frequency for word=%s: %v", wi, err)
-			}
+func Index(c appengine.Context, d Indexable) error {
+	kind, id := d.IndexKind(), d.IndexID()
+	key := searchDocumentKey(c, kind, id)
+
+	var doc SearchDocument
+	var documents int64
+	if err := datastore.Get(c, key, &doc); err == datastore.ErrNoSuchEntity {
+		documents = 1
+	} else if err != nil {
+		return err
+	}
 
-			d[j] = math.Log10(float64(1+wordFreqByUser)) * math.Log10(float64(nbUserWords+1)/float64(userFreqForWord+1))
-		}
-		dVect[i] = make([]float64, len(setOfWords))
-		dVect[i] = d
+	newWords := search.Words(d.IndexText())
+	words, err := updateSearchTerms(c, kind, id, doc.Words, newWords)
+	if err != nil {
+		addSearchCount(c, kind, 0, words)
+		return err
+	}
+
+	doc = SearchDocument{Kind: kind, Id: id, Name: d.IndexName(), Words: newWords}
+	if _, err = datastore.Put(c, key, &doc); err != nil {
+		return err
 	}
+	suggestions.add(&doc)
+	return addSearchCount(c, kind, documents, words)
+}
 
-	// compute score vector
-	var score map[int64]float64
-	score = make(map[int64]float64)
-	for i, dVectIndex := range dVect {
-		score[ids[i]] = dotProduct(dVectIndex, q)
+// Unindex removes an entity from the search index.
+//
+func Unindex(c appengine.Context, kind string, id int64) error {
+	key := searchDocumentKey(c, kind, id)
+
+	var doc SearchDocument
+	if err := datastore.Get(c, key, &doc); err == datastore.ErrNoSuchEntity {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	sortedScore := sortMapByValueDesc(score)
+	words, err := updateSearchTerms(c, kind, id, doc.Words, nil)
+	if err != nil {
+		addSearchCount(c, kind, 0, words)
+		return err
+	}
 
-	return getKeysFrompairList(sortedScore)
+	if err = datastore.Delete(c, key); err != nil {
+		return err
+	}
+	suggestions.remove(kind, id)
+	return addSearchCount(c, kind, -1, words)
 }
 
-// A data structure to hold a key/value pair.
-type pair struct {
-	Key   int64
-	Value float64
+// searchTermKeys returns the keys of the terms of the index of a kind whose word starts with a prefix,
+// in the order of the words and up to a limit.
+func searchTermKeys(c appengine.Context, kind, prefix string, limit int) ([]*datastore.Key, error) {
+	q := datastore.NewQuery("SearchTerm").Filter("Kind =", kind).Filter("Word >=", prefix).Filter("Word <", prefix+"\ufffd").KeysOnly().Limit(limit)
+	return q.GetAll(c, nil)
 }
 
-// A slice of pairs that implements sort.Interface to sort by Value.
-type pairList []pair
+// searchTermsMatching returns the terms of the index of a kind matching a query word.
+// The words of the index are read with keys only queries: first the words starting with the query word,
+// so that an exact or a prefix match is always found, then when typos are allowed, the words starting
+// with its first letter up to maxFuzzySearchTerms. Only the matching terms are read.
+//
+func searchTermsMatching(c appengine.Context, kind, word string) ([]searchMatch, error) {
+	keys, err := searchTermKeys(c, kind, word, maxSearchTerms)
+	if err != nil {
+		return nil, err
+	}
+
+	if search.MaxEdits(word) > 0 {
+		var fuzzy []*datastore.Key
+		if fuzzy, err = searchTermKeys(c, kind, string([]rune(word)[:1]), maxFuzzySearchTerms); err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			seen[k.StringID()] = true
+		}
+		for _, k := range fuzzy {
+			if !seen[k.StringID()] {
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	var matched []*datastore.Key
+	var weights []float64
+	for _, k := range keys {
+		if weight := search.Match(word, strings.TrimPrefix(k.StringID(), kind+":")); weight > 0 {
+			matched = append(matched, k)
+			weights = append(weights, weight)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
 
-func (p pairList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
-func (p pairList) Len() int           { return len(p) }
-func (p pairList) Less(i, j int) bool { return p[i].Value < p[j].Value }
+	terms := make([]SearchTerm, len(matched))
+	if err = datastore.GetMulti(c, matched, terms); err != nil {
+		return nil, err
+	}
 
-// A function to turn a map into a pairList, then sort and return it.
-func sortMapByValue(m map[int64]float64) pairList {
-	p := make(pairList, len(m))
-	i := 0
-	for k, v := range m {
-		p[i] = pair{k, v}
-		i++
+	matches := make([]searchMatch, len(terms))
+	for i := range terms {
+		matches[i] = searchMatch{&terms[i], weights[i]}
 	}
-	sort.Sort(p)
-	return p
+	return matches, nil
 }
 
-// A function to turn a map into a pairList, then sort in descending order and return it.
-func sortMapByValueDesc(m map[int64]float64) pairList {
-	p := make(pairList, len(m))
-	i := 0
-	for k, v := range m {
-		p[i] = pair{k, v}
-		i++
+// scoreSearch returns the entities of a kind having a match for every word of the query.
+// The score of an entity is the sum over the query words of the tf-idf weight of its best
+// matching term, scaled by the weight of the match.
+//
+func scoreSearch(kind string, query map[string]int64, matches map[string][]searchMatch, documents int64) []SearchResult {
+	scores := make(map[int64]float64)
+	hits := make(map[int64]int)
+	for w, qf := range query {
+		best := make(map[int64]float64)
+		for _, m := range matches[w] {
+			n := documents
+			if df := int64(len(m.term.Ids)); n < df {
+				n = df
+			}
+			idf := 1 + math.Log10(float64(n+1)/float64(len(m.term.Ids)+1))
+			for i, id := range m.term.Ids {
+				s := m.weight * math.Log10(1+float64(qf)) * math.Log10(1+float64(m.term.Frequencies[i])) * idf * idf
+				if s > best[id] {
+					best[id] = s
+				}
+			}
+		}
+		for id, s := range best {
+			scores[id] += s
+			hits[id]++
+		}
+	}
+
+	var results []SearchResult
+	for id, s := range scores {
+		if hits[id] == len(query) {
+			results = append(results, SearchResult{kind, id, s})
+		}
 	}
-	sort.Sort(sort.Reverse(p))
-	return p
+	return results
 }
 
-// From a pair list returns an array of keys present in the pair list.
-func getKeysFrompairList(p pairList) []int64 {
-	keys := make([]int64, len(p))
-	i := 0
-	for _, pair := range p {
-		keys[i] = pair.Key
-		i++
+// searchResults implements sort.Interface to sort results by descending score, then by kind and id so that pages are stable.
+type searchResults []SearchResult
+
+func (r searchResults) Len() int      { return len(r) }
+func (r searchResults) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r searchResults) Less(i, j int) bool {
+	if r[i].Score != r[j].Score {
+		return r[i].Score > r[j].Score
 	}
-	return keys
+	if r[i].Kind != r[j].Kind {
+		return r[i].Kind < r[j].Kind
+	}
+	return r[i].Id < r[j].Id
 }
 
-// Compute the dot product of two float vectors.
-func dotProduct(vec1 []float64, vec2 []float64) float64 {
-	if len(vec1) != len(vec2) {
-		return 0
+// searchPage returns the page of results, pages start at 1.
+func searchPage(results []SearchResult, count, page int64) []SearchResult {
+	if count <= 0 || page <= 0 {
+		return nil
+	}
+	from := (page - 1) * count
+	if from >= int64(len(results)) {
+		return nil
+	}
+	to := from + count
+	if to > int64(len(results)) {
+		to = int64(len(results))
 	}
+	return results[from:to]
+}
 
-	sum := float64(0)
-	for i := range vec1 {
-		sum = sum + vec1[i]*vec2[i]
+// Search returns a page of the entities of the given kinds matching every word of the query, the best matches first,
+// and the total number of matching entities. Query words match words of the index exactly, as a prefix or with a few typos,
+// accents and case are ignored.
+//
+func Search(c appengine.Context, kinds []string, query string, count, page int64) ([]SearchResult, int, error) {
+	queryFrequencies := search.Frequencies(search.Words(query))
+	if len(queryFrequencies) == 0 {
+		return nil, 0, nil
+	}
+
+	var results []SearchResult
+	for _, kind := range kinds {
+		sc, err := SearchCountByKind(c, kind)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		matches := make(map[string][]searchMatch)
+		for w := range queryFrequencies {
+			if matches[w], err = searchTermsMatching(c, kind, w); err != nil {
+				return nil, 0, err
+			} else if len(matches[w]) == 0 {
+				break
+			}
+		}
+		results = append(results, scoreSearch(kind, queryFrequencies, matches, sc.Documents)...)
+	}
+
+	sort.Sort(searchResults(results))
+	return searchPage(results, count, page), len(results), nil
+}
+
+// SearchResultIds returns the ids of the results of a kind, in the order of the results.
+//
+func SearchResultIds(results []SearchResult, kind string) []int64 {
+	var ids []int64
+	for _, r := range results {
+		if r.Kind == kind {
+			ids = append(ids, r.Id)
+		}
 	}
-	return sum
+	return ids
 }
@@ -0,0 +1,607 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+
+	"github.com/taironas/gonawin/helpers/search"
+)
+
+const (
+	// SearchRebuildBatch is the number of entities, documents or terms checked by a step of a rebuild.
+	SearchRebuildBatch = 100
+	// maxSearchRebuildProblems is the maximum number of inconsistencies described in the report of a rebuild.
+	maxSearchRebuildProblems = 1000
+	// maxGetMulti is the maximum number of entities read at once.
+	maxGetMulti = 1000
+)
+
+// Stages of the rebuild of the search index of a kind, in order.
+//
+const (
+	SearchStageLegacy    = "legacy"    // deletes the entities of the inverted indexes replaced by the search index.
+	SearchStageEntities  = "entities"  // checks the document and the terms of each entity.
+	SearchStageDocuments = "documents" // checks that the entity of each document exists.
+	SearchStageTerms     = "terms"     // checks that each entity of a term has its word.
+	SearchStageCounts    = "counts"    // counts the documents and the words.
+)
+
+var searchStages = []string{SearchStageLegacy, SearchStageEntities, SearchStageDocuments, SearchStageTerms, SearchStageCounts}
+
+// Problems found by a rebuild of the search index.
+//
+const (
+	SearchProblemMissingDocument = "missing document" // the entity is not in the index.
+	SearchProblemStaleDocument   = "stale document"   // the words of the document are not the words of the entity.
+	SearchProblemOrphanDocument  = "orphan document"  // the entity of the document does not exist.
+	SearchProblemMissingTerm     = "missing term"     // the term of a word of the entity does not have the entity.
+	SearchProblemOrphanTerm      = "orphan term"      // the term has an entity without its word.
+	SearchProblemWrongCount      = "wrong count"      // the counters of the kind are not the number of documents and words.
+)
+
+var errSearchKind = errors.New("model/search: unknown kind")
+
+// SearchRebuild is a rebuild of the search index from the teams, tournaments and users.
+// It runs in steps, each step checks a batch and repairs the index, or only reports in dry run.
+// The rebuild holds the report of the inconsistencies found and where the next step starts.
+//
+type SearchRebuild struct {
+	Id              int64
+	DryRun          bool
+	Kind            string // kind of the next step.
+	Stage           string // stage of the next step.
+	Cursor          string `datastore:",noindex"` // cursor of the next step in the stage.
+	Checked         int64  // entities, documents and terms checked.
+	Inconsistencies int64
+	Legacy          int64    // entities of the former inverted indexes.
+	Problems        []string `datastore:",noindex"` // first inconsistencies found.
+	Started         time.Time
+	Finished        time.Time
+}
+
+// SearchRebuildJSON is the JSON representation of a search rebuild.
+//
+type SearchRebuildJSON struct {
+	Id              *int64     `json:",omitempty"`
+	DryRun          *bool      `json:",omitempty"`
+	Kind            *string    `json:",omitempty"`
+	Stage           *string    `json:",omitempty"`
+	Checked         *int64     `json:",omitempty"`
+	Inconsistencies *int64     `json:",omitempty"`
+	Legacy          *int64     `json:",omitempty"`
+	Problems        *[]string  `json:",omitempty"`
+	Started         *time.Time `json:",omitempty"`
+	Finished        *time.Time `json:",omitempty"`
+}
+
+// CreateSearchRebuild creates a rebuild of the search index, it starts with the first stage of the first kind.
+//
+func CreateSearchRebuild(c appengine.Context, dryRun bool) (*SearchRebuild, error) {
+	id, _, err := datastore.AllocateIDs(c, "SearchRebuild", nil, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &SearchRebuild{
+		Id:      id,
+		DryRun:  dryRun,
+		Kind:    SearchKinds[0],
+		Stage:   searchStages[0],
+		Started: time.Now(),
+	}
+	if err = r.Update(c); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SearchRebuildByID returns a rebuild of the search index given its id.
+//
+func SearchRebuildByID(c appengine.Context, id int64) (*SearchRebuild, error) {
+	var r SearchRebuild
+	if err := datastore.Get(c, datastore.NewKey(c, "SearchRebuild", "", id, nil), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Update saves a rebuild of the search index.
+//
+func (r *SearchRebuild) Update(c appengine.Context) error {
+	_, err := datastore.Put(c, datastore.NewKey(c, "SearchRebuild", "", r.Id, nil), r)
+	return err
+}
+
+// Done reports whether all the stages of all the kinds were run.
+//
+func (r *SearchRebuild) Done() bool {
+	return !r.Finished.IsZero()
+}
+
+// Enqueue adds a task running the next step of the rebuild.
+//
+func (r *SearchRebuild) Enqueue(c appengine.Context) error {
+	task := taskqueue.NewPOSTTask("/a/search/rebuild/", url.Values{
+		"rebuildId": []string{strconv.FormatInt(r.Id, 10)},
+	})
+	_, err := taskqueue.Add(c, task, "")
+	return err
+}
+
+// report adds an inconsistency to the report, id is 0 for a problem of the whole kind.
+func (r *SearchRebuild) report(kind string, id int64, problem string) {
+	r.Inconsistencies++
+	if len(r.Problems) >= maxSearchRebuildProblems {
+		return
+	}
+	if id == 0 {
+		r.Problems = append(r.Problems, fmt.Sprintf("%s: %s", kind, problem))
+	} else {
+		r.Problems = append(r.Problems, fmt.Sprintf("%s %d: %s", kind, id, problem))
+	}
+}
+
+// next moves the rebuild to the next step: the same stage from cursor if there is more to check,
+// else the next stage, else the first stage of the next kind. The rebuild is finished after the last kind.
+func (r *SearchRebuild) next(cursor string, more bool, now time.Time) {
+	r.Cursor = cursor
+	if more {
+		return
+	}
+	r.Cursor = ""
+
+	for i, s := range searchStages {
+		if s == r.Stage && i+1 < len(searchStages) {
+			r.Stage = searchStages[i+1]
+			return
+		}
+	}
+	r.Stage = searchStages[0]
+
+	for i, k := range SearchKinds {
+		if k == r.Kind && i+1 < len(SearchKinds) {
+			r.Kind = SearchKinds[i+1]
+			return
+		}
+	}
+	r.Finished = now
+}
+
+// Step runs the next step of the rebuild and saves it.
+//
+func (r *SearchRebuild) Step(c appengine.Context) error {
+	if r.Done() {
+		return nil
+	}
+
+	var cursor string
+	var more bool
+	var err error
+	switch r.Stage {
+	case SearchStageLegacy:
+		more, err = r.stepLegacy(c)
+	case SearchStageEntities:
+		cursor, more, err = r.stepEntities(c)
+	case SearchStageDocuments:
+		cursor, more, err = r.stepDocuments(c)
+	case SearchStageTerms:
+		cursor, more, err = r.stepTerms(c)
+	case SearchStageCounts:
+		err = r.stepCounts(c)
+	default:
+		err = fmt.Errorf("model/search: unknown stage %q", r.Stage)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.next(cursor, more, time.Now())
+	return r.Update(c)
+}
+
+// stepLegacy deletes a batch of the inverted index and word count entities of the kind, they are only counted in dry run.
+func (r *SearchRebuild) stepLegacy(c appengine.Context) (bool, error) {
+	more := false
+	for _, legacy := range []string{r.Kind + "InvertedIndex", "WordCount" + r.Kind} {
+		q := datastore.NewQuery(legacy).KeysOnly()
+		if r.DryRun {
+			n, err := q.Count(c)
+			if err != nil {
+				return false, err
+			}
+			r.Legacy += int64(n)
+			continue
+		}
+
+		keys, err := q.Limit(SearchRebuildBatch).GetAll(c, nil)
+		if err != nil {
+			return false, err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if err = datastore.DeleteMulti(c, keys); err != nil {
+			return false, err
+		}
+		r.Legacy += int64(len(keys))
+		more = more || len(keys) == SearchRebuildBatch
+	}
+	return more, nil
+}
+
+// newIndexable returns a new entity of a kind of the search index.
+func newIndexable(kind string) (Indexable, error) {
+	switch kind {
+	case "Team":
+		return new(Team), nil
+	case "Tournament":
+		return new(Tournament), nil
+	case "User":
+		return new(User), nil
+	}
+	return nil, errSearchKind
+}
+
+// startQuery starts q at cursor if it is set.
+func startQuery(q *datastore.Query, cursor string) (*datastore.Query, error) {
+	if len(cursor) == 0 {
+		return q, nil
+	}
+	cur, err := datastore.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return q.Start(cur), nil
+}
+
+// nextCursor returns the cursor after the results of it, or an empty cursor when there were less than n results.
+func nextCursor(it *datastore.Iterator, results, n int) (string, bool, error) {
+	if results < n {
+		return "", false, nil
+	}
+	cur, err := it.Cursor()
+	if err != nil {
+		return "", false, err
+	}
+	return cur.String(), true, nil
+}
+
+// getMultiFound gets the entities of keys into dst and reports which ones exist.
+func getMultiFound(c appengine.Context, keys []*datastore.Key, dst interface{}) ([]bool, error) {
+	found := make([]bool, len(keys))
+	for i := range found {
+		found[i] = true
+	}
+	if err := datastore.GetMulti(c, keys, dst); err != nil {
+		me, ok := err.(appengine.MultiError)
+		if !ok {
+			return nil, err
+		}
+		for i, e := range me {
+			if e == datastore.ErrNoSuchEntity {
+				found[i] = false
+			} else if e != nil {
+				return nil, e
+			}
+		}
+	}
+	return found, nil
+}
+
+// sameWords reports whether a and b have the same words the same number of times.
+func sameWords(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	fa, fb := search.Frequencies(a), search.Frequencies(b)
+	for w, f := range fa {
+		if fb[w] != f {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedWords returns the words of frequencies in alphabetical order.
+func sortedWords(frequencies map[string]int64) []string {
+	words := make([]string, 0, len(frequencies))
+	for w := range frequencies {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// stepEntities checks the document and the terms of a batch of entities of the kind.
+// The index of an inconsistent entity is rebuilt from its text.
+func (r *SearchRebuild) stepEntities(c appengine.Context) (string, bool, error) {
+	q, err := startQuery(datastore.NewQuery(r.Kind).Limit(SearchRebuildBatch), r.Cursor)
+	if err != nil {
+		return "", false, err
+	}
+
+	var entities []Indexable
+	it := q.Run(c)
+	for {
+		var e Indexable
+		if e, err = newIndexable(r.Kind); err != nil {
+			return "", false, err
+		}
+		if _, err = it.Next(e); err == datastore.Done {
+			break
+		} else if err != nil {
+			return "", false, err
+		}
+		entities = append(entities, e)
+	}
+
+	for _, e := range entities {
+		r.Checked++
+		if err = r.checkEntity(c, e); err != nil {
+			return "", false, err
+		}
+	}
+	return nextCursor(it, len(entities), SearchRebuildBatch)
+}
+
+// checkEntity checks the document and the terms of an entity and rebuilds them if they are inconsistent.
+func (r *SearchRebuild) checkEntity(c appengine.Context, e Indexable) error {
+	kind, id := e.IndexKind(), e.IndexID()
+	words := search.Words(e.IndexText())
+	frequencies := search.Frequencies(words)
+
+	docKey := searchDocumentKey(c, kind, id)
+	var doc SearchDocument
+	problems := 0
+	if err := datastore.Get(c, docKey, &doc); err == datastore.ErrNoSuchEntity {
+		r.report(kind, id, SearchProblemMissingDocument)
+		problems++
+	} else if err != nil {
+		return err
+	} else if doc.Name != e.IndexName() || !sameWords(doc.Words, words) {
+		r.report(kind, id, SearchProblemStaleDocument)
+		problems++
+	}
+
+	sorted := sortedWords(frequencies)
+	keys := make([]*datastore.Key, len(sorted))
+	for i, w := range sorted {
+		keys[i] = searchTermKey(c, kind, w)
+	}
+	terms := make([]SearchTerm, len(keys))
+	found, err := getMultiFound(c, keys, terms)
+	if err != nil {
+		return err
+	}
+	for i, w := range sorted {
+		if !found[i] || terms[i].frequency(id) != frequencies[w] {
+			r.report(kind, id, fmt.Sprintf("%s %q", SearchProblemMissingTerm, w))
+			problems++
+		}
+	}
+
+	if problems == 0 || r.DryRun {
+		return nil
+	}
+
+	// every term is set again, as a term may miss the entity while its document is right.
+	for w, f := range frequencies {
+		if _, err = setSearchTerm(c, kind, w, id, f); err != nil {
+			return err
+		}
+	}
+	for _, w := range doc.Words {
+		if frequencies[w] == 0 {
+			if _, err = setSearchTerm(c, kind, w, id, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	doc = SearchDocument{Kind: kind, Id: id, Name: e.IndexName(), Words: words}
+	if _, err = datastore.Put(c, docKey, &doc); err != nil {
+		return err
+	}
+	suggestions.add(&doc)
+	return nil
+}
+
+// existingIDs returns the ids of the entities of a kind which exist among ids.
+func existingIDs(c appengine.Context, kind string, ids []int64) (map[int64]bool, error) {
+	keys := make([]*datastore.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = datastore.NewKey(c, kind, "", id, nil)
+	}
+
+	var found []bool
+	var err error
+	switch kind {
+	case "Team":
+		found, err = getMultiFound(c, keys, make([]Team, len(keys)))
+	case "Tournament":
+		found, err = getMultiFound(c, keys, make([]Tournament, len(keys)))
+	case "User":
+		found, err = getMultiFound(c, keys, make([]User, len(keys)))
+	default:
+		err = errSearchKind
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[int64]bool)
+	for i, id := range ids {
+		if found[i] {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
+
+// stepDocuments checks that the entities of a batch of documents of the kind exist.
+// The documents of entities which no longer exist are removed with their terms.
+func (r *SearchRebuild) stepDocuments(c appengine.Context) (string, bool, error) {
+	q, err := startQuery(datastore.NewQuery("SearchDocument").Filter("Kind =", r.Kind).Limit(SearchRebuildBatch), r.Cursor)
+	if err != nil {
+		return "", false, err
+	}
+
+	var docs []*SearchDocument
+	it := q.Run(c)
+	for {
+		var doc SearchDocument
+		if _, err = it.Next(&doc); err == datastore.Done {
+			break
+		} else if err != nil {
+			return "", false, err
+		}
+		docs = append(docs, &doc)
+	}
+
+	ids := make([]int64, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.Id
+	}
+	existing, err := existingIDs(c, r.Kind, ids)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, doc := range docs {
+		r.Checked++
+		if existing[doc.Id] {
+			continue
+		}
+		r.report(r.Kind, doc.Id, SearchProblemOrphanDocument)
+		if r.DryRun {
+			continue
+		}
+		if _, err = updateSearchTerms(c, r.Kind, doc.Id, doc.Words, nil); err != nil {
+			return "", false, err
+		}
+		if err = datastore.Delete(c, searchDocumentKey(c, r.Kind, doc.Id)); err != nil {
+			return "", false, err
+		}
+		suggestions.remove(r.Kind, doc.Id)
+	}
+	return nextCursor(it, len(docs), SearchRebuildBatch)
+}
+
+// stepTerms checks that the entities of a batch of terms of the kind have the word of the term
+// as many times as the term says, according to their documents. The wrong entities are set again.
+func (r *SearchRebuild) stepTerms(c appengine.Context) (string, bool, error) {
+	q, err := startQuery(datastore.NewQuery("SearchTerm").Filter("Kind =", r.Kind).Limit(SearchRebuildBatch), r.Cursor)
+	if err != nil {
+		return "", false, err
+	}
+
+	n := 0
+	it := q.Run(c)
+	for {
+		var t SearchTerm
+		if _, err = it.Next(&t); err == datastore.Done {
+			break
+		} else if err != nil {
+			return "", false, err
+		}
+		n++
+		r.Checked++
+		if err = r.checkTerm(c, &t); err != nil {
+			return "", false, err
+		}
+	}
+	return nextCursor(it, n, SearchRebuildBatch)
+}
+
+// checkTerm checks the entities of a term against their documents.
+func (r *SearchRebuild) checkTerm(c appengine.Context, t *SearchTerm) error {
+	// the documents are read before the term is repaired.
+	ids := append([]int64(nil), t.Ids...)
+	frequencies := make([]int64, len(ids))
+	for from := 0; from < len(ids); from += maxGetMulti {
+		to := from + maxGetMulti
+		if to > len(ids) {
+			to = len(ids)
+		}
+		keys := make([]*datastore.Key, to-from)
+		for i := range keys {
+			keys[i] = searchDocumentKey(c, t.Kind, ids[from+i])
+		}
+		docs := make([]SearchDocument, len(keys))
+		found, err := getMultiFound(c, keys, docs)
+		if err != nil {
+			return err
+		}
+		for i := range docs {
+			if found[i] {
+				frequencies[from+i] = search.Frequencies(docs[i].Words)[t.Word]
+			}
+		}
+	}
+
+	for i, id := range ids {
+		f := frequencies[i]
+		if f == t.frequency(id) {
+			continue
+		}
+		r.report(t.Kind, id, fmt.Sprintf("%s %q", SearchProblemOrphanTerm, t.Word))
+		if r.DryRun {
+			continue
+		}
+		if _, err := setSearchTerm(c, t.Kind, t.Word, id, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stepCounts counts the documents and the words of the kind and sets the counters of the kind.
+func (r *SearchRebuild) stepCounts(c appengine.Context) error {
+	documents, err := datastore.NewQuery("SearchDocument").Filter("Kind =", r.Kind).KeysOnly().Count(c)
+	if err != nil {
+		return err
+	}
+	var words int
+	if words, err = datastore.NewQuery("SearchTerm").Filter("Kind =", r.Kind).KeysOnly().Count(c); err != nil {
+		return err
+	}
+
+	var sc *SearchCount
+	if sc, err = SearchCountByKind(c, r.Kind); err != nil {
+		return err
+	}
+	if sc.Documents == int64(documents) && sc.Words == int64(words) {
+		return nil
+	}
+
+	r.report(r.Kind, 0, fmt.Sprintf("%s of %d documents and %d words, counted %d and %d", SearchProblemWrongCount, sc.Documents, sc.Words, documents, words))
+	if r.DryRun {
+		return nil
+	}
+	_, err = datastore.Put(c, searchCountKey(c, r.Kind), &SearchCount{int64(documents), int64(words)})
+	return err
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearchRebuildNext(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		title     string
+		kind      string
+		stage     string
+		cursor    string
+		more      bool
+		wantKind  string
+		wantStage string
+		done      bool
+	}{
+		{"more in the stage", "Team", SearchStageEntities, "abc", true, "Team", SearchStageEntities, false},
+		{"next stage", "Team", SearchStageEntities, "", false, "Team", SearchStageDocuments, false},
+		{"next kind", "Team", SearchStageCounts, "", false, "Tournament", SearchStageLegacy, false},
+		{"last kind", "User", SearchStageCounts, "", false, "User", SearchStageLegacy, true},
+	}
+	for _, test := range tests {
+		r := SearchRebuild{Kind: test.kind, Stage: test.stage, Cursor: "previous"}
+		r.next(test.cursor, test.more, now)
+		if r.Kind != test.wantKind || r.Stage != test.wantStage || r.Cursor != test.cursor || r.Done() != test.done {
+			t.Errorf("TestSearchRebuildNext(%q): got %v %v %q done %v wanted %v %v %q done %v", test.title, r.Kind, r.Stage, r.Cursor, r.Done(), test.wantKind, test.wantStage, test.cursor, test.done)
+		}
+	}
+}
+
+func TestSearchRebuildSteps(t *testing.T) {
+	r := SearchRebuild{Kind: SearchKinds[0], Stage: searchStages[0]}
+	steps := 0
+	for !r.Done() {
+		r.next("", false, time.Now())
+		steps++
+	}
+	if want := len(SearchKinds) * len(searchStages); steps != want {
+		t.Errorf("TestSearchRebuildSteps: got %d steps wanted %d", steps, want)
+	}
+}
+
+func TestSearchRebuildReport(t *testing.T) {
+	var r SearchRebuild
+	r.report("Team", 12, SearchProblemMissingDocument)
+	r.report("User", 0, SearchProblemWrongCount)
+	for i := 0; i < maxSearchRebuildProblems; i++ {
+		r.report("Team", int64(i+1), SearchProblemOrphanDocument)
+	}
+
+	if r.Inconsistencies != maxSearchRebuildProblems+2 {
+		t.Errorf("TestSearchRebuildReport: got %d inconsistencies wanted %d", r.Inconsistencies, maxSearchRebuildProblems+2)
+	}
+	if len(r.Problems) != maxSearchRebuildProblems {
+		t.Errorf("TestSearchRebuildReport: got %d problems wanted %d", len(r.Problems), maxSearchRebuildProblems)
+	}
+	want := []string{fmt.Sprintf("Team 12: %s", SearchProblemMissingDocument), fmt.Sprintf("User: %s", SearchProblemWrongCount)}
+	for i, w := range want {
+		if r.Problems[i] != w {
+			t.Errorf("TestSearchRebuildReport: got %q wanted %q", r.Problems[i], w)
+		}
+	}
+}
+
+func TestSameWords(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want bool
+	}{
+		{[]string{"real", "madrid"}, []string{"madrid", "real"}, true},
+		{[]string{"go", "go"}, []string{"go"}, false},
+		{[]string{"go", "go", "team"}, []string{"go", "team", "team"}, false},
+		{nil, []string{}, true},
+	}
+	for _, test := range tests {
+		if got := sameWords(test.a, test.b); got != test.want {
+			t.Errorf("TestSameWords(%q, %q): got %v wanted %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/taironas/gonawin/helpers/search"
+)
+
+func TestSearchTermSet(t *testing.T) {
+	tests := []struct {
+		title     string
+		ids       []int64
+		id        int64
+		frequency int64
+		wantIds   []int64
+		wantFreqs []int64
+	}{
+		{"add an entity", []int64{1}, 2, 1, []int64{1, 2}, []int64{1, 1}},
+		{"update an entity", []int64{1, 2}, 2, 3, []int64{1, 2}, []int64{1, 3}},
+		{"remove an entity", []int64{1, 2}, 1, 0, []int64{2}, []int64{1}},
+		{"remove a missing entity", []int64{1}, 2, 0, []int64{1}, []int64{1}},
+	}
+	for _, test := range tests {
+		term := SearchTerm{Ids: test.ids, Frequencies: make([]int64, len(test.ids))}
+		for i := range term.Frequencies {
+			term.Frequencies[i] = 1
+		}
+		term.set(test.id, test.frequency)
+		if !reflect.DeepEqual(term.Ids, test.wantIds) || !reflect.DeepEqual(term.Frequencies, test.wantFreqs) {
+			t.Errorf("TestSearchTermSet(%q): got %v %v wanted %v %v", test.title, term.Ids, term.Frequencies, test.wantIds, test.wantFreqs)
+		}
+		if got := term.frequency(test.id); got != test.frequency {
+			t.Errorf("TestSearchTermSet(%q): got frequency %v wanted %v", test.title, got, test.frequency)
+		}
+	}
+}
+
+// searchIndex builds the terms of an index of teams, as Index does, and matches the words of a query on it.
+func searchIndex(names map[int64]string, query string) []SearchResult {
+	terms := make(map[string]*SearchTerm)
+	for id, name := range names {
+		for w, f := range search.Frequencies(search.Words(name)) {
+			if terms[w] == nil {
+				terms[w] = &SearchTerm{Kind: "Team", Word: w}
+			}
+			terms[w].set(id, f)
+		}
+	}
+
+	queryFrequencies := search.Frequencies(search.Words(query))
+	matches := make(map[string][]searchMatch)
+	for q := range queryFrequencies {
+		for w, term := range terms {
+			if weight := search.Match(q, w); weight > 0 {
+				matches[q] = append(matches[q], searchMatch{term, weight})
+			}
+		}
+	}
+
+	results := scoreSearch("Team", queryFrequencies, matches, int64(len(names)))
+	sort.Sort(searchResults(results))
+	return results
+}
+
+func TestScoreSearch(t *testing.T) {
+	names := map[int64]string{
+		1: "Real Madrid",
+		2: "Atlético de Madrid",
+		3: "Real Sociedad",
+		4: "FC Barcelona",
+		5: "Madrid Madrid fans",
+	}
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"madrid", []int64{5, 1, 2}},
+		{"real madrid", []int64{1}},
+		{"atletico", []int64{2}},
+		{"barc", []int64{4}},
+		{"barcelnoa", []int64{4}},
+		{"real valencia", nil},
+	}
+	for _, test := range tests {
+		results := searchIndex(names, test.query)
+		if got := SearchResultIds(results, "Team"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestScoreSearch(%q): got %v wanted %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestScoreSearchExactFirst(t *testing.T) {
+	names := map[int64]string{1: "Manchester United", 2: "Man City"}
+	results := searchIndex(names, "man")
+	if got := SearchResultIds(results, "Team"); !reflect.DeepEqual(got, []int64{2, 1}) {
+		t.Errorf("TestScoreSearchExactFirst: got %v wanted %v", got, []int64{2, 1})
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	results := make([]SearchResult, 5)
+	for i := range results {
+		results[i].Id = int64(i + 1)
+	}
+	tests := []struct {
+		count, page int64
+		want        []int64
+	}{
+		{2, 1, []int64{1, 2}},
+		{2, 3, []int64{5}},
+		{2, 4, nil},
+		{10, 1, []int64{1, 2, 3, 4, 5}},
+		{2, 0, nil},
+	}
+	for _, test := range tests {
+		if got := SearchResultIds(searchPage(results, test.count, test.page), ""); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestSearchPage(%d, %d): got %v wanted %v", test.count, test.page, got, test.want)
+		}
+	}
+}
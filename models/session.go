@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+const (
+	// SessionDuration is the validity of a session token.
+	SessionDuration = 7 * 24 * time.Hour
+	// SessionRefreshDuration is the validity of a session refresh token.
+	SessionRefreshDuration = 60 * 24 * time.Hour
+	// sessionLastUsedPrecision is the precision of the LastUsed field, it avoids a write on each request.
+	sessionLastUsedPrecision = time.Hour
+)
+
+// Session errors.
+//
+var (
+	ErrSessionInvalid = errors.New("model/session: invalid session token")
+	ErrSessionExpired = errors.New("model/session: session token expired")
+)
+
+// Session represents a device a user is signed in on.
+// A session is authenticated with a token and a refresh token of the form '<session id>.<secret>'.
+// Only the hashes of the secrets are stored.
+//
+type Session struct {
+	Id             int64
+	UserId         int64
+	TokenHash      string `datastore:",noindex"`
+	RefreshHash    string `datastore:",noindex"`
+	Device         string // name of the device, sent by the client or its user agent.
+	UserAgent      string `datastore:",noindex"`
+	Created        time.Time
+	LastUsed       time.Time
+	Expires        time.Time // expiration of the token.
+	RefreshExpires time.Time // expiration of the refresh token.
+	SecondFactorAt time.Time // last time a second factor was verified on the session.
+}
+
+// SessionJSON is the JSON representation of a session.
+//
+type SessionJSON struct {
+	Id             *int64     `json:",omitempty"`
+	UserId         *int64     `json:",omitempty"`
+	Device         *string    `json:",omitempty"`
+	UserAgent      *string    `json:",omitempty"`
+	Created        *time.Time `json:",omitempty"`
+	LastUsed       *time.Time `json:",omitempty"`
+	Expires        *time.Time `json:",omitempty"`
+	RefreshExpires *time.Time `json:",omitempty"`
+}
+
+// SessionTokens holds the tokens of a session, they are only known when the session is created or refreshed.
+//
+type SessionTokens struct {
+	Token          string
+	RefreshToken   string
+	Expires        time.Time
+	RefreshExpires time.Time
+}
+
+// HashToken returns the hash of the secret of a token as it is stored.
+//
+func HashToken(secret string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(secret)))
+}
+
+// ParseSessionToken returns the session id and the secret of a token.
+//
+func ParseSessionToken(token string) (int64, string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return 0, "", ErrSessionInvalid
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, "", ErrSessionInvalid
+	}
+	return id, parts[1], nil
+}
+
+// IsSessionToken indicates if a token has the form of a session token,
+// as opposed to the legacy authentication key of a user.
+//
+func IsSessionToken(token string) bool {
+	_, _, err := ParseSessionToken(token)
+	return err == nil
+}
+
+// rotate generates new tokens for the session and updates their hashes and expirations.
+func (s *Session) rotate(now time.Time) (SessionTokens, error) {
+	secret, refreshSecret := GenerateAuthKey(), GenerateAuthKey()
+	if len(secret) == 0 || len(refreshSecret) == 0 {
+		return SessionTokens{}, errors.New("model/session: unable to generate tokens")
+	}
+
+	s.TokenHash = HashToken(secret)
+	s.RefreshHash = HashToken(refreshSecret)
+	s.Expires = now.Add(SessionDuration)
+	s.RefreshExpires = now.Add(SessionRefreshDuration)
+	s.LastUsed = now
+
+	return SessionTokens{
+		Token:          fmt.Sprintf("%d.%s", s.Id, secret),
+		RefreshToken:   fmt.Sprintf("%d.%s", s.Id, refreshSecret),
+		Expires:        s.Expires,
+		RefreshExpires: s.RefreshExpires,
+	}, nil
+}
+
+// checkSecret compares the hash of a secret with a stored hash in constant time.
+func checkSecret(hash, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashToken(secret))) == 1
+}
+
+// Check verifies the secret of a session token at a given time.
+//
+func (s *Session) Check(secret string, now time.Time) error {
+	if !checkSecret(s.TokenHash, secret) {
+		return ErrSessionInvalid
+	}
+	if !now.Before(s.Expires) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// CheckRefresh verifies the secret of a session refresh token at a given time.
+//
+func (s *Session) CheckRefresh(secret string, now time.Time) error {
+	if !checkSecret(s.RefreshHash, secret) {
+		return ErrSessionInvalid
+	}
+	if !now.Before(s.RefreshExpires) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// SecondFactorRecent indicates if a second factor was verified on the session less than SecondFactorMaxAge ago.
+//
+func (s *Session) SecondFactorRecent(now time.Time) bool {
+	return !s.SecondFactorAt.IsZero() && now.Sub(s.SecondFactorAt) < SecondFactorMaxAge
+}
+
+// CreateSession creates a session of a user on a device and returns its tokens.
+//
+func CreateSession(c appengine.Context, userID int64, device, userAgent string) (*Session, SessionTokens, error) {
+
+	id, _, err := datastore.AllocateIDs(c, "Session", nil, 1)
+	if err != nil {
+		return nil, SessionTokens{}, err
+	}
+
+	now := time.Now()
+	s := &Session{Id: id, UserId: userID, Device: device, UserAgent: userAgent, Created: now}
+
+	var tokens SessionTokens
+	if tokens, err = s.rotate(now); err != nil {
+		return nil, SessionTokens{}, err
+	}
+
+	key := datastore.NewKey(c, "Session", "", id, nil)
+	if _, err = datastore.Put(c, key, s); err != nil {
+		return nil, SessionTokens{}, err
+	}
+	return s, tokens, nil
+}
+
+// SessionByID gets a session given an id.
+//
+func SessionByID(c appengine.Context, id int64) (*Session, error) {
+
+	var s Session
+	key := datastore.NewKey(c, "Session", "", id, nil)
+
+	if err := datastore.Get(c, key, &s); err != nil {
+		log.Errorf(c, "Session not found : %v", err)
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SessionByToken gets the session of a token.
+// It returns ErrSessionInvalid if the token does not match a session and ErrSessionExpired if it has expired.
+//
+func SessionByToken(c appengine.Context, token string) (*Session, error) {
+	id, secret, err := ParseSessionToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var s *Session
+	if s, err = SessionByID(c, id); err != nil {
+		return nil, ErrSessionInvalid
+	}
+
+	now := time.Now()
+	if err = s.Check(secret, now); err != nil {
+		return nil, err
+	}
+
+	if now.Sub(s.LastUsed) > sessionLastUsedPrecision {
+		s.LastUsed = now
+		if err = s.Update(c); err != nil {
+			log.Errorf(c, "Session.SessionByToken: unable to update last use of session %v: %v", s.Id, err)
+		}
+	}
+	return s, nil
+}
+
+// RefreshSession gets the session of a refresh token and generates new tokens for it.
+// The previous tokens of the session are no longer valid.
+//
+func RefreshSession(c appengine.Context, refreshToken string) (*Session, SessionTokens, error) {
+	id, secret, err := ParseSessionToken(refreshToken)
+	if err != nil {
+		return nil, SessionTokens{}, err
+	}
+
+	var s *Session
+	if s, err = SessionByID(c, id); err != nil {
+		return nil, SessionTokens{}, ErrSessionInvalid
+	}
+
+	now := time.Now()
+	if err = s.CheckRefresh(secret, now); err != nil {
+		return nil, SessionTokens{}, err
+	}
+
+	var tokens SessionTokens
+	if tokens, err = s.rotate(now); err != nil {
+		return nil, SessionTokens{}, err
+	}
+	if err = s.Update(c); err != nil {
+		return nil, SessionTokens{}, err
+	}
+	return s, tokens, nil
+}
+
+// Update a session entity.
+//
+func (s *Session) Update(c appengine.Context) error {
+	key := datastore.NewKey(c, "Session", "", s.Id, nil)
+	_, err := datastore.Put(c, key, s)
+	return err
+}
+
+// Destroy a session entity, its tokens are no longer valid.
+//
+func (s *Session) Destroy(c appengine.Context) error {
+	key := datastore.NewKey(c, "Session", "", s.Id, nil)
+	return datastore.Delete(c, key)
+}
+
+// FindSessions returns the sessions of a user.
+//
+func FindSessions(c appengine.Context, userID int64) []*Session {
+	desc := "Session.FindSessions:"
+	q := datastore.NewQuery("Session").Filter("UserId"+" =", userID).Order("-LastUsed")
+
+	var sessions []*Session
+	if _, err := q.GetAll(c, &sessions); err != nil {
+		log.Errorf(c, "%s an error occurred during GetAll: %v", desc, err)
+		return nil
+	}
+	return sessions
+}
+
+// DestroySessions destroys all the sessions of a user.
+//
+func DestroySessions(c appengine.Context, userID int64) error {
+	keys, err := datastore.NewQuery("Session").Filter("UserId"+" =", userID).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		return err
+	}
+	return datastore.DeleteMulti(c, keys)
+}
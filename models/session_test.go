@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSessionToken(t *testing.T) {
+	tests := []struct {
+		token  string
+		id     int64
+		secret string
+		err    error
+	}{
+		{"42.abcdef", 42, "abcdef", nil},
+		{"42.abc.def", 42, "abc.def", nil},
+		{"0123456789abcdef0123456789abcdef", 0, "", ErrSessionInvalid},
+		{"42.", 0, "", ErrSessionInvalid},
+		{"-1.abcdef", 0, "", ErrSessionInvalid},
+		{"x.abcdef", 0, "", ErrSessionInvalid},
+		{"", 0, "", ErrSessionInvalid},
+	}
+	for _, test := range tests {
+		id, secret, err := ParseSessionToken(test.token)
+		if id != test.id || secret != test.secret || err != test.err {
+			t.Errorf("TestParseSessionToken(%q): got %v, %q, %v wanted %v, %q, %v", test.token, id, secret, err, test.id, test.secret, test.err)
+		}
+	}
+}
+
+func TestSessionCheck(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+
+	s := Session{Id: 42}
+	tokens, err := s.rotate(now)
+	if err != nil {
+		t.Fatalf("TestSessionCheck: unable to rotate tokens: %v", err)
+	}
+
+	_, secret, _ := ParseSessionToken(tokens.Token)
+	_, refreshSecret, _ := ParseSessionToken(tokens.RefreshToken)
+
+	tests := []struct {
+		title   string
+		secret  string
+		refresh bool
+		now     time.Time
+		err     error
+	}{
+		{"valid token", secret, false, now, nil},
+		{"token about to expire", secret, false, now.Add(SessionDuration - time.Second), nil},
+		{"expired token", secret, false, now.Add(SessionDuration), ErrSessionExpired},
+		{"refresh secret as token", refreshSecret, false, now, ErrSessionInvalid},
+		{"wrong token", "abcdef", false, now, ErrSessionInvalid},
+		{"valid refresh token", refreshSecret, true, now.Add(SessionDuration), nil},
+		{"expired refresh token", refreshSecret, true, now.Add(SessionRefreshDuration), ErrSessionExpired},
+		{"token as refresh token", secret, true, now, ErrSessionInvalid},
+	}
+	for _, test := range tests {
+		var err error
+		if test.refresh {
+			err = s.CheckRefresh(test.secret, test.now)
+		} else {
+			err = s.Check(test.secret, test.now)
+		}
+		if err != test.err {
+			t.Errorf("TestSessionCheck(%q): got %v wanted %v", test.title, err, test.err)
+		}
+	}
+
+	// tokens are no longer valid once rotated.
+	if _, err = s.rotate(now); err != nil {
+		t.Fatalf("TestSessionCheck: unable to rotate tokens: %v", err)
+	}
+	if err = s.Check(secret, now); err != ErrSessionInvalid {
+		t.Errorf("TestSessionCheck(%q): got %v wanted %v", "rotated token", err, ErrSessionInvalid)
+	}
+}
+
+func TestSessionSecondFactorRecent(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		title string
+		at    time.Time
+		want  bool
+	}{
+		{"never verified", time.Time{}, false},
+		{"verified now", now, true},
+		{"verified recently", now.Add(-SecondFactorMaxAge + time.Minute), true},
+		{"verified too long ago", now.Add(-SecondFactorMaxAge), false},
+	}
+	for _, test := range tests {
+		s := Session{SecondFactorAt: test.at}
+		if got := s.SecondFactorRecent(now); got != test.want {
+			t.Errorf("TestSessionSecondFactorRecent(%q): got %v wanted %v", test.title, got, test.want)
+		}
+	}
+}
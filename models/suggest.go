@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/search"
+)
+
+// SuggestCacheDuration is the duration the prefix index of an instance is used before it is loaded again,
+// so that the entities indexed by other instances are suggested.
+const SuggestCacheDuration = 10 * time.Minute
+
+// suggestCache holds the prefix index of the search documents of the instance.
+// The index is loaded outside the lock and swapped when it is ready, the previous index is used meanwhile.
+type suggestCache struct {
+	sync.Mutex
+	index   *search.PrefixIndex
+	loaded  time.Time
+	loading chan struct{}   // closed when the load in progress ends, nil if there is none.
+	changes []suggestChange // changes made during the load in progress, applied to the loaded index.
+}
+
+// suggestChange is a search document added to the prefix index, or an entity removed from it when doc is nil.
+type suggestChange struct {
+	doc  *SearchDocument
+	kind string
+	id   int64
+}
+
+// suggestions is the prefix index of the instance, it is loaded on the first suggestion.
+var suggestions suggestCache
+
+// apply applies a change to a prefix index.
+func (ch suggestChange) apply(index *search.PrefixIndex) {
+	if ch.doc == nil {
+		index.Remove(ch.kind, ch.id)
+		return
+	}
+	index.Add(search.Suggestion{Kind: ch.doc.Kind, Id: ch.doc.Id, Name: ch.doc.Name}, strings.Join(ch.doc.Words, " "))
+}
+
+// change applies a change to the prefix index if it is loaded, and keeps it for the load in progress.
+func (s *suggestCache) change(ch suggestChange) {
+	s.Lock()
+	defer s.Unlock()
+	if s.index != nil {
+		ch.apply(s.index)
+	}
+	if s.loading != nil {
+		s.changes = append(s.changes, ch)
+	}
+}
+
+// add adds a search document to the prefix index.
+func (s *suggestCache) add(doc *SearchDocument) {
+	s.change(suggestChange{doc: doc})
+}
+
+// remove removes an entity from the prefix index.
+func (s *suggestCache) remove(kind string, id int64) {
+	s.change(suggestChange{kind: kind, id: id})
+}
+
+// refresh loads the prefix index from the search documents when it is older than SuggestCacheDuration.
+// Only one load runs at a time: while it runs, the other callers use the previous index,
+// or wait for the load when there is none.
+func (s *suggestCache) refresh(now time.Time, docs func() ([]*SearchDocument, error)) error {
+	s.Lock()
+	for s.index == nil && s.loading != nil {
+		wait := s.loading
+		s.Unlock()
+		<-wait
+		s.Lock()
+	}
+	if s.loading != nil || (s.index != nil && now.Sub(s.loaded) < SuggestCacheDuration) {
+		s.Unlock()
+		return nil
+	}
+	s.loading = make(chan struct{})
+	s.changes = nil
+	s.Unlock()
+
+	loaded, err := docs()
+	var index *search.PrefixIndex
+	if err == nil {
+		index = search.NewPrefixIndex()
+		for _, doc := range loaded {
+			suggestChange{doc: doc}.apply(index)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if err == nil {
+		for _, ch := range s.changes {
+			ch.apply(index)
+		}
+		s.index = index
+		s.loaded = now
+	}
+	s.changes = nil
+	close(s.loading)
+	s.loading = nil
+	if s.index != nil {
+		// a failed load keeps the previous index.
+		return nil
+	}
+	return err
+}
+
+// Suggest returns at most n entities of the given kinds with, for every word of the query, a word starting with it.
+// It is meant to be called as the user types, the entities are matched in memory on a prefix index of the instance.
+//
+func Suggest(c appengine.Context, query string, kinds []string, n int) ([]search.Suggestion, error) {
+	err := suggestions.refresh(time.Now(), func() ([]*SearchDocument, error) {
+		var docs []*SearchDocument
+		_, err := datastore.NewQuery("SearchDocument").GetAll(c, &docs)
+		return docs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions.Lock()
+	defer suggestions.Unlock()
+	return suggestions.index.Suggest(query, n, kinds...), nil
+}
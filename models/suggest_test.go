@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func suggestedIds(s *suggestCache, query string) []int64 {
+	s.Lock()
+	defer s.Unlock()
+	var ids []int64
+	for _, sg := range s.index.Suggest(query, 10) {
+		ids = append(ids, sg.Id)
+	}
+	return ids
+}
+
+func TestSuggestCacheRefresh(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+	paris := &SearchDocument{Kind: "Team", Id: 1, Name: "Paris", Words: []string{"paris"}}
+	porto := &SearchDocument{Kind: "Team", Id: 2, Name: "Porto", Words: []string{"porto"}}
+	prague := &SearchDocument{Kind: "Team", Id: 3, Name: "Prague", Words: []string{"prague"}}
+
+	var s suggestCache
+	loads := 0
+	if err := s.refresh(now, func() ([]*SearchDocument, error) {
+		loads++
+		return []*SearchDocument{paris}, nil
+	}); err != nil {
+		t.Fatalf("TestSuggestCacheRefresh: unable to load: %v", err)
+	}
+
+	// a recent index is not loaded again.
+	s.refresh(now.Add(SuggestCacheDuration-time.Second), func() ([]*SearchDocument, error) {
+		loads++
+		return nil, nil
+	})
+	if loads != 1 {
+		t.Errorf("TestSuggestCacheRefresh(%q): got %v loads wanted %v", "recent index", loads, 1)
+	}
+
+	// the changes made while the index is loaded are applied to the new index.
+	later := now.Add(SuggestCacheDuration)
+	s.refresh(later, func() ([]*SearchDocument, error) {
+		s.add(prague)
+		s.remove("Team", 1)
+		if ids := suggestedIds(&s, "pra"); len(ids) != 1 || ids[0] != 3 {
+			t.Errorf("TestSuggestCacheRefresh(%q): got %v wanted %v", "previous index during load", ids, []int64{3})
+		}
+		return []*SearchDocument{paris, porto}, nil
+	})
+	tests := []struct {
+		query string
+		ids   []int64
+	}{
+		{"pa", nil},
+		{"po", []int64{2}},
+		{"pr", []int64{3}},
+	}
+	for _, test := range tests {
+		if ids := suggestedIds(&s, test.query); len(ids) != len(test.ids) || (len(ids) > 0 && ids[0] != test.ids[0]) {
+			t.Errorf("TestSuggestCacheRefresh(%q): got %v wanted %v", test.query, ids, test.ids)
+		}
+	}
+
+	// a failed load keeps the previous index.
+	if err := s.refresh(later.Add(SuggestCacheDuration), func() ([]*SearchDocument, error) {
+		return nil, errors.New("datastore error")
+	}); err != nil {
+		t.Errorf("TestSuggestCacheRefresh(%q): got %v wanted no error", "failed load", err)
+	}
+	if ids := suggestedIds(&s, "po"); len(ids) != 1 {
+		t.Errorf("TestSuggestCacheRefresh(%q): got %v wanted %v", "failed load", ids, []int64{2})
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strings"
 	"time"
 
 	"appengine"
@@ -76,27 +75,11 @@ type TeamJSON struct {
 // CreateTeam creates a team given a name, description, an admin id and a private mode.
 //
 func CreateTeam(c appengine.Context, name string, description string, adminID int64, private bool) (*Team, error) {
-	// create new team
-	teamID, _, err := datastore.AllocateIDs(c, "Team", nil, 1)
-	if err != nil {
+	team := &Team{Name: name, Description: description, AdminIds: []int64{adminID}, Private: private, Created: time.Now()}
+	if err := Repos(c).Teams.Save(team); err != nil {
 		return nil, err
 	}
-
-	key := datastore.NewKey(c, "Team", "", teamID, nil)
-	admins := make([]int64, 1)
-	admins[0] = adminID
-	var emptyArray []int64
-	var emtpyArrayOfAccOfTournament []AccOfTournaments
-	team := &Team{teamID, helpers.TrimLower(name), name, description, admins, private, time.Now(), emptyArray, emptyArray, float64(0), emtpyArrayOfAccOfTournament, emptyArray, 0}
-
-	_, err = datastore.Put(c, key, team)
-	if err != nil {
-		return nil, err
-	}
-	// udpate inverted index
-	AddToTeamInvertedIndex(c, helpers.TrimLower(name), teamID)
-
-	return team, err
+	return team, nil
 }
 
 // Destroy a team given a team id.
@@ -112,8 +95,7 @@ func (t *Team) Destroy(c appengine.Context) error {
 		return errd
 	}
 
-	// remove key name.
-	return UpdateTeamInvertedIndex(c, t.KeyName, "", t.Id)
+	return Unindex(c, t.IndexKind(), t.Id)
 }
 
 // FindTeams searches for all Team entities with respect of a filter and a value.
@@ -164,8 +146,11 @@ func (t *Team) Update(c appengine.Context) error {
 		if _, err = datastore.Put(c, k, t); err != nil {
 			return err
 		}
-		// use lower trim names as team inverted index store them like this.
-		UpdateTeamInvertedIndex(c, oldTeam.KeyName, t.KeyName, t.Id)
+		if oldTeam.Name != t.Name {
+			if errIndex := Index(c, t); errIndex != nil {
+				log.Errorf(c, " Team.Update, unable to index team %v: %v", t.Id, errIndex)
+			}
+		}
 	}
 	return err
 }
@@ -211,6 +196,28 @@ func GetNotJoinedTeams(c appengine.Context, u *User, count, page int64) []*Team
 	return paged
 }
 
+// GetNotJoinedTeamsByCursor gets count teams that a user has not joined starting after cursor,
+// in the order of GetNotJoinedTeams. It returns the cursor of the next page, empty after the last page.
+//
+func GetNotJoinedTeamsByCursor(c appengine.Context, u *User, count int64, cursor string) ([]*Team, string, error) {
+	var notJoined []*Team
+	next, err := queryByCursor(c, datastore.NewQuery("Team"), count, cursor, func(it *datastore.Iterator) (bool, error) {
+		var t Team
+		if _, err := it.Next(&t); err != nil {
+			return false, err
+		}
+		if t.Joined(c, u) {
+			return false, nil
+		}
+		notJoined = append(notJoined, &t)
+		return true, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return notJoined, next, nil
+}
+
 // TeamsByIDs returns an array of teams from a given team IDs array.
 // An error could be returned.
 //
@@ -269,68 +276,45 @@ func (t *Team) Joined(c appengine.Context, u *User) bool {
 // UserId is added to all current tournaments joined by the team entity.
 //
 func (t *Team) Join(c appengine.Context, u *User) error {
-	if err := u.AddTeamID(c, t.Id); err != nil {
-		return fmt.Errorf(" Team.Join, error joining team for user:%d Error: %v", u.Id, err)
-	}
-
-	if err := t.AddUserID(c, u.Id); err != nil {
-		return fmt.Errorf(" Team.Join, error joining team for user:%d Error: %v", u.Id, err)
-	}
-
-	if err := t.AddUserToTournaments(c, u.Id); err != nil {
-		return fmt.Errorf("Team.Join, error adding user:%d to teams tournaments Error: %v", u.Id, err)
-	}
-	return nil
+	return Repos(c).JoinTeam(t, u)
 }
 
 // Leave makes a user leave a team.
-// Todo: Should we check that the user is indeed a member of the team?
+// The user stays in the tournaments of the team.
 //
 func (t *Team) Leave(c appengine.Context, u *User) error {
-	if err := u.RemoveTeamID(c, t.Id); err != nil {
-		return fmt.Errorf(" Team.Leave, error leaving team for user:%v Error: %v", u.Id, err)
-	}
-	if err := t.RemoveUserID(c, u.Id); err != nil {
-		return fmt.Errorf(" Team.Leave, error leaving team for user:%v Error: %v", u.Id, err)
-	}
-	// sar: 7 mar 2014
-	// when a user leaves a team should we unsubscribe him from the tournaments of that team?
-	// for now I would say no.
-
-	// if err := t.RemoveUserFromTournaments(c, u); err != nil{
-	// 	return fmt.Errorf("Team.Leave, error leaving teams tournaments for user:%v Error: %v", u.Id, err)
-	// }
-	return nil
+	return Repos(c).LeaveTeam(t, u)
 }
 
 // IsTeamAdmin checks if user is admin of the team with id 'teamId'.
 //
 func IsTeamAdmin(c appengine.Context, teamID int64, userID int64) bool {
 
-	var team *Team
-	if team, err := TeamByID(c, teamID); team == nil || err != nil {
+	team, err := TeamByID(c, teamID)
+	if team == nil || err != nil {
 		log.Errorf(c, " Team.IsTeamAdmin, error occurred during ById call: %v", err)
 		return false
 	}
 
-	for _, aid := range team.AdminIds {
-		if aid == userID {
-			return true
-		}
-	}
-	return false
+	isAdmin, _ := team.ContainsAdminID(userID)
+	return isAdmin
 }
 
-// GetWordFrequencyForTeam will get the frequency of that word in the team terms
-// given a id, and a word.
+// IndexKind returns the kind of a team in the search index.
 //
-func GetWordFrequencyForTeam(c appengine.Context, Id int64, word string) int64 {
+func (t *Team) IndexKind() string { return "Team" }
 
-	if teams := FindTeams(c, "Id", Id); teams != nil {
-		return helpers.CountTerm(strings.Split(teams[0].KeyName, " "), word)
-	}
-	return 0
-}
+// IndexID returns the id of a team in the search index.
+//
+func (t *Team) IndexID() int64 { return t.Id }
+
+// IndexText returns the text a team is found by, its name.
+//
+func (t *Team) IndexText() string { return t.Name }
+
+// IndexName returns the name a team is suggested with, its name.
+//
+func (t *Team) IndexName() string { return t.Name }
 
 // Players returns an array of users/ players that participates the given team.
 //
@@ -761,6 +745,11 @@ func (t *Team) UpdateAccuracy(c appengine.Context, tID int64, newAccuracy float6
 		// publish new activity
 		verb := fmt.Sprintf("has a new accuracy of %.2f%%", newAccuracy*100)
 		t.Publish(c, "accuracy", verb, ActivityEntity{}, ActivityEntity{})
+
+		tournament := ActivityEntity{Id: tID, Type: "tournament"}
+		if err := t.PublishEvent(c, EventRankingChanged, tournament, WebhookRanking{t.Accuracy, newAccuracy}); err != nil {
+			log.Errorf(c, "Team.UpdateAccuracy: unable to publish event %v", err)
+		}
 	}
 	return nil
 }
@@ -768,37 +757,7 @@ func (t *Team) UpdateAccuracy(c appengine.Context, tID int64, newAccuracy float6
 // Publish publishes new team activity.
 //
 func (t *Team) Publish(c appengine.Context, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
-	var activity Activity
-	activity.Type = activityType
-	activity.Verb = verb
-	activity.Actor = t.Entity()
-	activity.Object = object
-	activity.Target = target
-	activity.Published = time.Now()
-	activity.CreatorID = t.Id
-
-	if err := activity.save(c); err != nil {
-		return err
-	}
-	// add new activity id in user activity table for each member of the team
-	var players []*User
-	var err error
-	if players, err = t.Players(c); err != nil {
-		log.Errorf(c, "model/team, Publish: error occurred during Players call: %v", err)
-		return nil
-	}
-
-	for _, p := range players {
-		if err := activity.AddNewActivityID(c, p); err != nil {
-			log.Errorf(c, "model/team, Publish: error occurred during AddNewActivityID call: %v", err)
-		} else {
-			if err1 := p.Update(c); err1 != nil {
-				log.Errorf(c, "model/team, Publish: error occurred during update call: %v", err1)
-			}
-		}
-	}
-
-	return nil
+	return Repos(c).PublishTeam(t, activityType, verb, object, target)
 }
 
 // Entity is the Activity entity representation of a team
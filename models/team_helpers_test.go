@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/taironas/gonawin/helpers"
-
 	"appengine/aetest"
 )
 
@@ -40,13 +38,11 @@ func checkTeam(got *Team, want testTeam) error {
 //
 func checkTeamInvertedIndex(t *testing.T, c aetest.Context, got *Team, want testTeam) error {
 
-	var ids []int64
-	var err error
-	words := helpers.SetOfStrings(want.name)
-	if ids, err = GetTeamInvertedIndexes(c, words); err != nil {
-		return fmt.Errorf("failed calling GetTeamInvertedIndexes %v", err)
+	results, _, err := Search(c, []string{"Team"}, want.name, 100, 1)
+	if err != nil {
+		return fmt.Errorf("failed calling Search %v", err)
 	}
-	for _, id := range ids {
+	for _, id := range SearchResultIds(results, "Team") {
 		if id == got.Id {
 			return nil
 		}
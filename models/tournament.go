@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"appengine"
@@ -109,7 +108,9 @@ func CreateTournament(c appengine.Context, name string, description string, star
 		return nil, err
 	}
 
-	AddToTournamentInvertedIndex(c, helpers.TrimLower(name), tournamentId)
+	if err = Index(c, tournament); err != nil {
+		log.Errorf(c, " Tournament.Create, unable to index tournament %v: %v", tournamentId, err)
+	}
 	return tournament, nil
 }
 
@@ -126,8 +127,7 @@ func (t *Tournament) Destroy(c appengine.Context) error {
 		return errd
 	}
 
-	// remove key name.
-	return UpdateTournamentInvertedIndex(c, t.KeyName, "", t.Id)
+	return Unindex(c, t.IndexKind(), t.Id)
 }
 
 // FindTournaments finds all entity tournaments with respect of a filter and value.
@@ -177,8 +177,11 @@ func (t *Tournament) Update(c appengine.Context) error {
 		if _, err = datastore.Put(c, k, t); err != nil {
 			return err
 		}
-		// use name with trim lower as tournament inverted index stores lower key names.
-		UpdateTournamentInvertedIndex(c, oldTournament.KeyName, t.KeyName, t.Id)
+		if oldTournament.Name != t.Name {
+			if errIndex := Index(c, t); errIndex != nil {
+				log.Errorf(c, " Tournament.Update, unable to index tournament %v: %v", t.Id, errIndex)
+			}
+		}
 	}
 	return nil
 }
@@ -206,6 +209,39 @@ func FindAllTournaments(c appengine.Context, count, page int64) []*Tournament {
 	return paged
 }
 
+// FindAllTournamentsByCursor finds count tournaments in the datastore starting after cursor, in the order of
+// FindAllTournaments. It returns the cursor of the next page, empty after the last page.
+//
+func FindAllTournamentsByCursor(c appengine.Context, count int64, cursor string) ([]*Tournament, string, error) {
+	var tournaments []*Tournament
+	next, err := queryByCursor(c, datastore.NewQuery("Tournament"), count, cursor, func(it *datastore.Iterator) (bool, error) {
+		var t Tournament
+		if _, err := it.Next(&t); err != nil {
+			return false, err
+		}
+		tournaments = append(tournaments, &t)
+		return true, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return tournaments, next, nil
+}
+
+// TournamentsEndingAfter finds all tournaments that end after a given date.
+//
+func TournamentsEndingAfter(c appengine.Context, date time.Time) []*Tournament {
+
+	q := datastore.NewQuery("Tournament").Filter("End >", date)
+	var tournaments []*Tournament
+	if _, err := q.GetAll(c, &tournaments); err != nil {
+		log.Errorf(c, " Tournament.TournamentsEndingAfter, error occurred during GetAll: %v", err)
+		return nil
+	}
+
+	return tournaments
+}
+
 // TournamentsByIds finds all tournaments with respect to array of ids.
 //
 func TournamentsByIds(c appengine.Context, ids []int64) ([]*Tournament, error) {
@@ -260,15 +296,7 @@ func (t *Tournament) Joined(c appengine.Context, u *User) bool {
 // Join let a user join a tournament.
 //
 func (t *Tournament) Join(c appengine.Context, u *User) error {
-	// add
-	if err := u.AddTournamentID(c, t.Id); err != nil {
-		return fmt.Errorf(" Tournament.Join, error joining tournament for user:%v Error: %v", u.Id, err)
-	}
-	if err := t.AddUserID(c, u.Id); err != nil {
-		return fmt.Errorf(" Tournament.Join, error joining tournament for user:%v Error: %v", u.Id, err)
-	}
-
-	return nil
+	return Repos(c).JoinTournament(t, u)
 }
 
 // IsTournamentAdmin checks if user is admin of tournament with id 'tournamentId'.
@@ -396,15 +424,21 @@ func (t *Tournament) TeamLeave(c appengine.Context, team *Team) error {
 	return nil
 }
 
-// GetWordFrequencyForTournament gets the frequency of given word with respect to tournament id.
+// IndexKind returns the kind of a tournament in the search index.
 //
-func GetWordFrequencyForTournament(c appengine.Context, id int64, word string) int64 {
+func (t *Tournament) IndexKind() string { return "Tournament" }
 
-	if tournaments := FindTournaments(c, "Id", id); tournaments != nil {
-		return helpers.CountTerm(strings.Split(tournaments[0].KeyName, " "), word)
-	}
-	return 0
-}
+// IndexID returns the id of a tournament in the search index.
+//
+func (t *Tournament) IndexID() int64 { return t.Id }
+
+// IndexText returns the text a tournament is found by, its name.
+//
+func (t *Tournament) IndexText() string { return t.Name }
+
+// IndexName returns the name a tournament is suggested with, its name.
+//
+func (t *Tournament) IndexName() string { return t.Name }
 
 // Reset tournament values: Points, GoalsF, GoalsA to zero.
 func (t *Tournament) Reset(c appengine.Context) error {
@@ -641,31 +675,7 @@ func (t *Tournament) RankingByTeam(c appengine.Context, limit int) []*Team {
 // Publish tournament activity.
 //
 func (t *Tournament) Publish(c appengine.Context, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
-	var activity Activity
-	activity.Type = activityType
-	activity.Verb = verb
-	activity.Actor = t.Entity()
-	activity.Object = object
-	activity.Target = target
-	activity.Published = time.Now()
-	activity.CreatorID = t.Id
-
-	if err := activity.save(c); err != nil {
-		return err
-	}
-	// add new activity id in user activity table for each participant of the tournament
-	for _, p := range t.Participants(c) {
-		if err := activity.AddNewActivityID(c, p); err != nil {
-			log.Errorf(c, "model/tournament, Publish: error occurred during AddNewActivityID call: %v", err)
-		} else {
-			if err1 := p.Update(c); err1 != nil {
-				log.Errorf(c, "model/tournament, Publish: error occurred during update call: %v", err1)
-			}
-
-		}
-	}
-
-	return nil
+	return Repos(c).PublishTournament(t, activityType, verb, object, target)
 }
 
 // Entity is the Activity entity representation of a tournament.
@@ -679,7 +689,14 @@ func (t *Tournament) Entity() ActivityEntity {
 // with respect of today's date and start and end date of tournament.
 //
 func (t *Tournament) Progress(c appengine.Context) float64 {
-	now := time.Now()
+	p := t.ProgressAt(time.Now())
+	log.Infof(c, "ratio: %v", p)
+	return p
+}
+
+// ProgressAt is the progression of the tournament at a given time.
+//
+func (t *Tournament) ProgressAt(now time.Time) float64 {
 	if now.Before(t.Start) {
 		return float64(0)
 	}
@@ -688,7 +705,6 @@ func (t *Tournament) Progress(c appengine.Context) float64 {
 	}
 	d := t.Start.Sub(now)
 	dt := t.Start.Sub(t.End)
-	log.Infof(c, "ratio: %v", d.Seconds()/dt.Seconds())
 	return d.Seconds() / dt.Seconds()
 }
 
@@ -202,7 +202,7 @@ func CreateChampionsLeague(c appengine.Context, adminID int64) (*Tournament, err
 		matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 		log.Infof(c, "Champions League: match: new key ok")
 
-		matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+		matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 		matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 
 		emptyrule := ""
@@ -220,6 +220,7 @@ func CreateChampionsLeague(c appengine.Context, adminID int64) (*Tournament, err
 			false,
 			true,
 			true,
+			kickoff,
 		}
 		log.Infof(c, "Champions League: match 2nd round: build match ok")
 
@@ -250,7 +251,7 @@ func CreateChampionsLeague(c appengine.Context, adminID int64) (*Tournament, err
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "Champions League: match: new key ok")
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 
 			rule := fmt.Sprintf("%s %s", matchData[cMatchTeam1], matchData[cMatchTeam2])
@@ -268,6 +269,7 @@ func CreateChampionsLeague(c appengine.Context, adminID int64) (*Tournament, err
 				false,
 				false,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "Champions League: match 2nd round: build match ok")
 
@@ -183,7 +183,7 @@ func CreateChampionsLeague20152016(c appengine.Context, adminId int64) (*Tournam
 		matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 		log.Infof(c, "Champions League: match: new key ok")
 
-		matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+		matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 		matchInternalId, _ := strconv.Atoi(matchData[cMatchID])
 
 		emptyrule := ""
@@ -201,6 +201,7 @@ func CreateChampionsLeague20152016(c appengine.Context, adminId int64) (*Tournam
 			false,
 			true,
 			true,
+			kickoff,
 		}
 		log.Infof(c, "Champions League: match 2nd round: build match ok")
 
@@ -231,7 +232,7 @@ func CreateChampionsLeague20152016(c appengine.Context, adminId int64) (*Tournam
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "Champions League: match: new key ok")
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalId, _ := strconv.Atoi(matchData[cMatchID])
 
 			rule := fmt.Sprintf("%s %s", matchData[cMatchTeam1], matchData[cMatchTeam2])
@@ -249,6 +250,7 @@ func CreateChampionsLeague20152016(c appengine.Context, adminId int64) (*Tournam
 				false,
 				false,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "Champions League: match 2nd round: build match ok")
 
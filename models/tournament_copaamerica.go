@@ -288,7 +288,7 @@ func CreateCopaAmerica(c appengine.Context, adminID int64) (*Tournament, error)
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "%s: match: new key ok", desc)
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 			emptyrule := ""
 			emptyresult := int64(0)
@@ -305,6 +305,7 @@ func CreateCopaAmerica(c appengine.Context, adminID int64) (*Tournament, error)
 				false,
 				true,
 				true,
+				kickoff,
 			}
 
 			log.Infof(c, "%s: match: build match ok", desc)
@@ -374,7 +375,7 @@ func CreateCopaAmerica(c appengine.Context, adminID int64) (*Tournament, error)
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "%s: match: new key ok", desc)
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 
 			rule := fmt.Sprintf("%s %s", matchData[cMatchTeam1], matchData[cMatchTeam2])
@@ -392,6 +393,7 @@ func CreateCopaAmerica(c appengine.Context, adminID int64) (*Tournament, error)
 				false,
 				false,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "%s: match 2nd round: build match ok", desc)
 
@@ -306,7 +306,7 @@ func CreateCopaAmerica2016(c appengine.Context, adminID int64) (*Tournament, err
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "%s: match: new key ok", desc)
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 			emptyrule := ""
 			emptyresult := int64(0)
@@ -323,6 +323,7 @@ func CreateCopaAmerica2016(c appengine.Context, adminID int64) (*Tournament, err
 				false,
 				true,
 				true,
+				kickoff,
 			}
 
 			log.Infof(c, "%s: match: build match ok", desc)
@@ -392,7 +393,7 @@ func CreateCopaAmerica2016(c appengine.Context, adminID int64) (*Tournament, err
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "%s: match: new key ok", desc)
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 
 			rule := fmt.Sprintf("%s %s", matchData[cMatchTeam1], matchData[cMatchTeam2])
@@ -410,6 +411,7 @@ func CreateCopaAmerica2016(c appengine.Context, adminID int64) (*Tournament, err
 				false,
 				false,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "%s: match 2nd round: build match ok", desc)
 
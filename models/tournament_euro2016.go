@@ -337,7 +337,7 @@ func CreateEuro2016(c appengine.Context, adminID int64) (*Tournament, error) {
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "Euro: match: new key ok")
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 			emptyrule := ""
 			emptyresult := int64(0)
@@ -354,6 +354,7 @@ func CreateEuro2016(c appengine.Context, adminID int64) (*Tournament, error) {
 				false,
 				true,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "Euro: match: build match ok")
 
@@ -421,7 +422,7 @@ func CreateEuro2016(c appengine.Context, adminID int64) (*Tournament, error) {
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "Euro: match: new key ok")
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 
 			rule := fmt.Sprintf("%s %s", matchData[cMatchTeam1], matchData[cMatchTeam2])
@@ -439,6 +440,7 @@ func CreateEuro2016(c appengine.Context, adminID int64) (*Tournament, error) {
 				false,
 				false,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "Euro: match 2nd round: build match ok")
 
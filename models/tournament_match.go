@@ -18,7 +18,9 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"appengine"
@@ -43,6 +45,69 @@ type Tmatch struct {
 	Finished   bool      // is match finished
 	Ready      bool      // is match ready for predictions.
 	CanPredict bool      // can user make a prediction (used to block predictions when match has started).
+	Kickoff    bool      // does the date hold the kickoff time, else only the day of the match at midnight UTC.
+}
+
+// ParseMatchDate parses the date of a match as defined in a tournament builder.
+// The date can hold the kickoff time and its offset, "Jun/14/2018 18:00 +0300",
+// or only the day, "Jun/14/2018", in which case the match is set at midnight UTC.
+// It reports whether the date holds the kickoff time.
+//
+func ParseMatchDate(s string) (time.Time, bool, error) {
+	const (
+		shortForm   = "Jan/02/2006"
+		kickoffForm = "Jan/02/2006 15:04 -0700"
+	)
+	if t, err := time.Parse(kickoffForm, s); err == nil {
+		return t.UTC(), true, nil
+	}
+	t, err := time.Parse(shortForm, s)
+	return t, false, err
+}
+
+// DateIn returns the date of the match in the given location.
+// A match without kickoff time stays on its day, at midnight in the location,
+// as converting midnight UTC would move it to the previous day west of UTC.
+//
+func (m *Tmatch) DateIn(loc *time.Location) time.Time {
+	return MatchDateIn(m.Date, m.Kickoff, loc)
+}
+
+// MatchDateIn returns the date of a match in the given location, see Tmatch.DateIn.
+//
+func MatchDateIn(date time.Time, kickoff bool, loc *time.Location) time.Time {
+	if kickoff {
+		return date.In(loc)
+	}
+	d := date.UTC()
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+}
+
+// TeamNames returns the names of the teams of a match.
+// When the teams of a match are not known yet, the match rule is used instead.
+//
+func (m *Tmatch) TeamNames(mapIDTeams map[int64]string) (string, string) {
+	rule := strings.Split(m.Rule, " ")
+	if len(rule) == 2 {
+		return rule[0], rule[1]
+	}
+
+	team1 := rule[0]
+	if m.TeamId1 > 0 {
+		team1 = mapIDTeams[m.TeamId1]
+	}
+	team2 := rule[len(rule)-1]
+	if m.TeamId2 > 0 {
+		team2 = mapIDTeams[m.TeamId2]
+	}
+	return team1, team2
+}
+
+// PredictionLock returns the time after which predictions on the match are no longer possible.
+// Predictions are locked at kickoff.
+//
+func (m *Tmatch) PredictionLock() time.Time {
+	return m.Date
 }
 
 // MatchByID gets a Tmatch entity by id.
@@ -138,6 +203,7 @@ func DestroyMatches(c appengine.Context, matchIds []int64) error {
 }
 
 // SetResults sets results on an array of matches and triggers a match update and group update.
+// It is used to simulate the results of a phase, the participants are not notified of simulated results.
 //
 func SetResults(c appengine.Context, matches []*Tmatch, results1 []int64, results2 []int64, t *Tournament) error {
 	desc := "Set Results:"
@@ -184,6 +250,7 @@ func SetResults(c appengine.Context, matches []*Tmatch, results1 []int64, result
 			if int(phaseID+1) < len(phases) {
 				UpdateNextPhase(c, t, &phases[phaseID], &phases[phaseID+1])
 			}
+			t.PublishEvent(c, EventPhaseCompleted, WebhookPhase{phases[phaseID].Name})
 			log.Infof(c, "%s -------------------------------------------------->", desc)
 			// update flag first phase complete.
 			if phaseID == 0 {
@@ -217,6 +284,15 @@ func SetResult(c appengine.Context, m *Tmatch, result1 int64, result2 int64, t *
 		return err
 	}
 
+	// notify participants of the new result.
+	mapIDTeams := MapOfIDTeams(c, t)
+	team1, team2 := m.TeamNames(mapIDTeams)
+	msg := fmt.Sprintf("%s: %s %d - %d %s", t.Name, team1, m.Result1, m.Result2, team2)
+	if err1 := Notify(c, t.Participants(c), NotificationResult, msg, t.Entity()); err1 != nil {
+		log.Errorf(c, "%s unable to notify participants on match with id: %v, %v", desc, m.Id, err1)
+	}
+	t.PublishEvent(c, EventMatchResult, NewWebhookMatch(m, mapIDTeams))
+
 	// update score for all users.
 	if err1 := t.UpdateUsersScore(c, m); err1 != nil {
 		log.Errorf(c, "%s unable to update users score on match with id: %v, %v", desc, m.Id, err)
@@ -244,6 +320,7 @@ func SetResult(c appengine.Context, m *Tmatch, result1 int64, result2 int64, t *
 			if int(phaseID+1) < len(phases) {
 				UpdateNextPhase(c, t, &phases[phaseID], &phases[phaseID+1])
 			}
+			t.PublishEvent(c, EventPhaseCompleted, WebhookPhase{phases[phaseID].Name})
 			log.Infof(c, "%s -------------------------------------------------->", desc)
 			// update flag first phase complete.
 			if phaseID == 0 {
@@ -296,6 +373,7 @@ func GetMatchesByPhase(c appengine.Context, t *Tournament, phaseName string) []*
 }
 
 // MatchesGroupByPhase gets all matches grouped by phases. Returns an array of phases.
+// Days of each phase are computed in UTC.
 //
 func MatchesGroupByPhase(t *Tournament, matches []*Tmatch) []Tphase {
 
@@ -319,35 +397,30 @@ func MatchesGroupByPhase(t *Tournament, matches []*Tmatch) []Tphase {
 				filteredMatches = append(filteredMatches, *v)
 			}
 		}
-		phases[i].Days = MatchesGroupByDay(filteredMatches)
+		phases[i].Days = MatchesGroupByDay(filteredMatches, time.UTC)
 	}
 	return phases
 }
 
-// MatchesGroupByDay gets all matches grouped by days. Returns an array of days.
+// MatchesGroupByDay gets all matches grouped by days in the given location. Returns an array of days.
+// Matches of a day are sorted by kickoff time.
 //
-func MatchesGroupByDay(matches []Tmatch) []Tday {
+func MatchesGroupByDay(matches []Tmatch, loc *time.Location) []Tday {
 
 	mapOfDays := make(map[string][]Tmatch)
 
-	const shortForm = "Jan/02/2006"
+	const dayForm = "2006-01-02"
 	for _, m := range matches {
-		currentDate := m.Date.Format(shortForm)
-		_, ok := mapOfDays[currentDate]
-		if ok {
-			mapOfDays[currentDate] = append(mapOfDays[currentDate], m)
-		} else {
-			var arrayMatches []Tmatch
-			arrayMatches = append(arrayMatches, m)
-			mapOfDays[currentDate] = arrayMatches
-		}
+		currentDate := m.DateIn(loc).Format(dayForm)
+		mapOfDays[currentDate] = append(mapOfDays[currentDate], m)
 	}
 
 	var days []Tday
 	days = make([]Tday, len(mapOfDays))
 	i := 0
 	for key, value := range mapOfDays {
-		days[i].Date, _ = time.Parse(shortForm, key)
+		days[i].Date, _ = time.ParseInLocation(dayForm, key, loc)
+		sort.Stable(MatchesByDate(value))
 		days[i].Matches = value
 		i++
 	}
@@ -355,6 +428,13 @@ func MatchesGroupByDay(matches []Tmatch) []Tday {
 	return days
 }
 
+// MatchesByDate implements sort.Interface for []Tmatch based on the date field.
+type MatchesByDate []Tmatch
+
+func (a MatchesByDate) Len() int           { return len(a) }
+func (a MatchesByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a MatchesByDate) Less(i, j int) bool { return a[i].Date.Before(a[j].Date) }
+
 // OldMatches gets the number of matches in a tournament that are finished.
 //
 func (t *Tournament) OldMatches(c appengine.Context) int {
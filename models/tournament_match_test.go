@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMatchDate(t *testing.T) {
+	tests := []struct {
+		date    string
+		want    time.Time
+		kickoff bool
+		err     bool
+	}{
+		{"Jun/14/2018", time.Date(2018, 6, 14, 0, 0, 0, 0, time.UTC), false, false},
+		{"Jun/14/2018 18:00 +0300", time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC), true, false},
+		{"Jun/14/2018 16:00 -0300", time.Date(2018, 6, 14, 19, 0, 0, 0, time.UTC), true, false},
+		{"2018-06-14", time.Time{}, false, true},
+	}
+
+	for _, test := range tests {
+		got, kickoff, err := ParseMatchDate(test.date)
+		if (err != nil) != test.err {
+			t.Errorf("TestParseMatchDate(%q): got error %v", test.date, err)
+		}
+		if !got.Equal(test.want) || kickoff != test.kickoff {
+			t.Errorf("TestParseMatchDate(%q): got %v kickoff %v wanted %v kickoff %v", test.date, got, kickoff, test.want, test.kickoff)
+		}
+	}
+}
+
+func TestMatchesGroupByDay(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	buenosAires, _ := time.LoadLocation("America/Argentina/Buenos_Aires")
+
+	// kickoff times in UTC, match 5 only has its day.
+	matches := []Tmatch{
+		{IdNumber: 1, Date: time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC), Kickoff: true},
+		{IdNumber: 2, Date: time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC), Kickoff: true},
+		{IdNumber: 3, Date: time.Date(2018, 6, 15, 0, 30, 0, 0, time.UTC), Kickoff: true},
+		{IdNumber: 4, Date: time.Date(2018, 6, 15, 22, 30, 0, 0, time.UTC), Kickoff: true},
+		{IdNumber: 5, Date: time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		title string
+		loc   *time.Location
+		days  []time.Time
+		ids   [][]int64
+	}{
+		{
+			title: "UTC",
+			loc:   time.UTC,
+			days:  []time.Time{time.Date(2018, 6, 14, 0, 0, 0, 0, time.UTC), time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC)},
+			ids:   [][]int64{{1}, {5, 3, 2, 4}},
+		},
+		{
+			title: "Paris",
+			loc:   paris,
+			days:  []time.Time{time.Date(2018, 6, 14, 0, 0, 0, 0, paris), time.Date(2018, 6, 15, 0, 0, 0, 0, paris), time.Date(2018, 6, 16, 0, 0, 0, 0, paris)},
+			ids:   [][]int64{{1}, {5, 3, 2}, {4}},
+		},
+		{
+			title: "Buenos Aires",
+			loc:   buenosAires,
+			days:  []time.Time{time.Date(2018, 6, 14, 0, 0, 0, 0, buenosAires), time.Date(2018, 6, 15, 0, 0, 0, 0, buenosAires)},
+			ids:   [][]int64{{1, 3}, {5, 2, 4}},
+		},
+	}
+
+	for _, test := range tests {
+		days := MatchesGroupByDay(matches, test.loc)
+		if len(days) != len(test.days) {
+			t.Errorf("TestMatchesGroupByDay(%q): got %d days wanted %d", test.title, len(days), len(test.days))
+			continue
+		}
+		for i, d := range days {
+			if !d.Date.Equal(test.days[i]) {
+				t.Errorf("TestMatchesGroupByDay(%q): got day %v wanted %v", test.title, d.Date, test.days[i])
+			}
+			if len(d.Matches) != len(test.ids[i]) {
+				t.Errorf("TestMatchesGroupByDay(%q): got %d matches on %v wanted %d", test.title, len(d.Matches), d.Date, len(test.ids[i]))
+				continue
+			}
+			for j, m := range d.Matches {
+				if m.IdNumber != test.ids[i][j] {
+					t.Errorf("TestMatchesGroupByDay(%q): got match %d on %v wanted %d", test.title, m.IdNumber, d.Date, test.ids[i][j])
+				}
+			}
+		}
+	}
+}
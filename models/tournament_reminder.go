@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"time"
+)
+
+// DaysToRemind returns the days of a tournament for which prediction reminders must be sent at a given time.
+// A day is reminded when its first match starts in the interval [now+lead, now+lead+window).
+// Running the reminders every window duration reminds each day exactly once.
+// Days are computed in UTC.
+//
+func DaysToRemind(matches []*Tmatch, now time.Time, lead, window time.Duration) []Tday {
+
+	var all []Tmatch
+	for _, m := range matches {
+		all = append(all, *m)
+	}
+
+	from := now.Add(lead)
+	to := from.Add(window)
+
+	var days []Tday
+	for _, d := range MatchesGroupByDay(all, time.UTC) {
+		first := d.Matches[0].Date
+		if !first.Before(from) && first.Before(to) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// MissingMatches returns the matches that can still be predicted and have no prediction.
+//
+func (a Predicts) MissingMatches(matches []Tmatch) []Tmatch {
+	var missing []Tmatch
+	for _, m := range matches {
+		if !m.Ready || !m.CanPredict || m.Finished {
+			continue
+		}
+		if ok, _ := a.ContainsMatchID(m.Id); !ok {
+			missing = append(missing, m)
+		}
+	}
+	return missing
+}
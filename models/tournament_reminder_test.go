@@ -0,0 +1,71 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDaysToRemind(t *testing.T) {
+	matches := []*Tmatch{
+		{Id: 1, Date: time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC)},
+		{Id: 2, Date: time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC)},
+		{Id: 3, Date: time.Date(2018, 6, 15, 18, 0, 0, 0, time.UTC)},
+		{Id: 4, Date: time.Date(2018, 6, 16, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []int64
+	}{
+		{"first match in window", time.Date(2018, 6, 14, 12, 0, 0, 0, time.UTC), []int64{1}},
+		{"first match after window", time.Date(2018, 6, 14, 11, 0, 0, 0, time.UTC), nil},
+		{"first match before window", time.Date(2018, 6, 14, 12, 1, 0, 0, time.UTC), nil},
+		{"second match of day in window", time.Date(2018, 6, 15, 15, 0, 0, 0, time.UTC), nil},
+		{"day with two matches", time.Date(2018, 6, 15, 8, 30, 0, 0, time.UTC), []int64{2, 3}},
+		{"match without kickoff time", time.Date(2018, 6, 15, 21, 0, 0, 0, time.UTC), []int64{4}},
+	}
+
+	for _, test := range tests {
+		var got []int64
+		for _, d := range DaysToRemind(matches, test.now, 3*time.Hour, time.Hour) {
+			for _, m := range d.Matches {
+				got = append(got, m.Id)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestDaysToRemind(%q): got %v wanted %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPredictsMissingMatches(t *testing.T) {
+	matches := []Tmatch{
+		{Id: 1, Ready: true, CanPredict: true},
+		{Id: 2, Ready: true, CanPredict: true},
+		{Id: 3, Ready: true, CanPredict: false},
+		{Id: 4, Ready: false, CanPredict: true},
+		{Id: 5, Ready: true, CanPredict: true, Finished: true},
+	}
+
+	tests := []struct {
+		name     string
+		predicts Predicts
+		want     []int64
+	}{
+		{"no predicts", Predicts{}, []int64{1, 2}},
+		{"one predict", Predicts{&Predict{MatchId: 2}}, []int64{1}},
+		{"all predicted", Predicts{&Predict{MatchId: 1}, &Predict{MatchId: 2}}, nil},
+	}
+
+	for _, test := range tests {
+		var got []int64
+		for _, m := range test.predicts.MissingMatches(matches) {
+			got = append(got, m.Id)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestPredictsMissingMatches(%q): got %v wanted %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taironas/gonawin/helpers"
+)
+
+// ScheduleEntry represents a line of a tournament schedule.
+//
+type ScheduleEntry struct {
+	Line     int       // line of the entry in the schedule file.
+	IdNumber int64     // id of match in tournament
+	Date     time.Time // kickoff time of match
+	Location string    // match location
+	Team1    string    // name of 1st team
+	Team2    string    // name of 2nd team
+}
+
+// ScheduleChange represents the changes of the date and location of a match.
+//
+type ScheduleChange struct {
+	Match       *Tmatch
+	OldDate     time.Time
+	NewDate     time.Time
+	OldLocation string
+	NewLocation string
+}
+
+// ReadSchedule reads a tournament schedule in CSV format.
+// The first line is a header, each following line has the fields:
+//	IdNumber,date,time,timezone,location,team1,team2
+// date has format 2006-01-02, time has format 15:04 and timezone is an
+// IANA time zone name or an offset (cf helpers.LoadLocation).
+//
+func ReadSchedule(r io.Reader) ([]ScheduleEntry, []error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 7
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, []error{err}
+	}
+	if len(records) < 2 {
+		return nil, []error{fmt.Errorf("schedule is empty")}
+	}
+
+	var entries []ScheduleEntry
+	var errs []error
+	// first line is the header.
+	for i, record := range records[1:] {
+		line := i + 2
+		idNumber, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid match id %q", line, record[0]))
+			continue
+		}
+		loc, err := helpers.LoadLocation(record[3])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid timezone %q", line, record[3]))
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02 15:04", record[1]+" "+record[2], loc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid date or time %q %q", line, record[1], record[2]))
+			continue
+		}
+		entries = append(entries, ScheduleEntry{
+			Line:     line,
+			IdNumber: idNumber,
+			Date:     date.UTC(),
+			Location: strings.TrimSpace(record[4]),
+			Team1:    strings.TrimSpace(record[5]),
+			Team2:    strings.TrimSpace(record[6]),
+		})
+	}
+	return entries, errs
+}
+
+// ScheduleChanges validates schedule entries against the matches of a tournament
+// and returns the changes to apply on the matches.
+// Each entry should refer to an existing match and appear only once. The teams of
+// an entry should be the teams of the match, or its rule when the teams are not known yet.
+// The returned matches are not updated.
+//
+func ScheduleChanges(entries []ScheduleEntry, matches []*Tmatch, mapIDTeams map[int64]string) ([]ScheduleChange, []error) {
+
+	matchesByIDNumber := make(map[int64]*Tmatch)
+	for _, m := range matches {
+		matchesByIDNumber[m.IdNumber] = m
+	}
+
+	var changes []ScheduleChange
+	var errs []error
+	seen := make(map[int64]bool)
+	for _, e := range entries {
+		m, ok := matchesByIDNumber[e.IdNumber]
+		if !ok {
+			errs = append(errs, fmt.Errorf("line %d: match %d not found in tournament", e.Line, e.IdNumber))
+			continue
+		}
+		if seen[e.IdNumber] {
+			errs = append(errs, fmt.Errorf("line %d: match %d appears more than once", e.Line, e.IdNumber))
+			continue
+		}
+		seen[e.IdNumber] = true
+
+		team1, team2 := m.TeamNames(mapIDTeams)
+		if !strings.EqualFold(e.Team1, team1) || !strings.EqualFold(e.Team2, team2) {
+			errs = append(errs, fmt.Errorf("line %d: match %d is %s - %s, not %s - %s", e.Line, e.IdNumber, team1, team2, e.Team1, e.Team2))
+			continue
+		}
+
+		if m.Kickoff && m.Date.Equal(e.Date) && m.Location == e.Location {
+			continue
+		}
+		changes = append(changes, ScheduleChange{
+			Match:       m,
+			OldDate:     m.Date,
+			NewDate:     e.Date,
+			OldLocation: m.Location,
+			NewLocation: e.Location,
+		})
+	}
+	return changes, errs
+}
+
+// ApplyScheduleChanges sets the new dates and locations on the matches and
+// returns the updated matches. The dates of a schedule hold the kickoff time,
+// importing the schedule of a tournament created without kickoff times sets them.
+//
+func ApplyScheduleChanges(changes []ScheduleChange) []*Tmatch {
+	matches := make([]*Tmatch, len(changes))
+	for i, change := range changes {
+		change.Match.Date = change.NewDate
+		change.Match.Kickoff = true
+		change.Match.Location = change.NewLocation
+		matches[i] = change.Match
+	}
+	return matches
+}
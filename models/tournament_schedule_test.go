@@ -0,0 +1,110 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSchedule(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	tests := []struct {
+		title    string
+		schedule string
+		want     []ScheduleEntry
+		errs     int
+	}{
+		{
+			title: "valid schedule",
+			schedule: `IdNumber,date,time,timezone,location,team1,team2
+1,2018-06-14,18:00,Europe/Moscow,"Luzhniki Stadium, Moscow",Russia,Saudi Arabia
+49,2018-06-30,17:00,+03:00,Sochi,1A,2B
+50,2018-06-30,21:00,UTC,Kazan,1C,2D`,
+			want: []ScheduleEntry{
+				{2, 1, time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC), "Luzhniki Stadium, Moscow", "Russia", "Saudi Arabia"},
+				{3, 49, time.Date(2018, 6, 30, 14, 0, 0, 0, time.UTC), "Sochi", "1A", "2B"},
+				{4, 50, time.Date(2018, 6, 30, 21, 0, 0, 0, time.UTC), "Kazan", "1C", "2D"},
+			},
+		},
+		{
+			title: "invalid lines",
+			schedule: `IdNumber,date,time,timezone,location,team1,team2
+a,2018-06-14,18:00,Europe/Moscow,Moscow,Russia,Saudi Arabia
+2,2018-06-15,17:00,Mars/Olympus,Yekaterinburg,Egypt,Uruguay
+3,2018-06-15,25:00,UTC,Sochi,Portugal,Spain
+4,2018-06-16,13:00,Europe/Paris,Kazan,France,Australia`,
+			want: []ScheduleEntry{
+				{5, 4, time.Date(2018, 6, 16, 13, 0, 0, 0, paris).UTC(), "Kazan", "France", "Australia"},
+			},
+			errs: 3,
+		},
+		{
+			title:    "empty schedule",
+			schedule: `IdNumber,date,time,timezone,location,team1,team2`,
+			errs:     1,
+		},
+	}
+
+	for _, test := range tests {
+		got, errs := ReadSchedule(strings.NewReader(test.schedule))
+		if len(errs) != test.errs {
+			t.Errorf("TestReadSchedule(%q): got %d errors %v, wanted %d", test.title, len(errs), errs, test.errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestReadSchedule(%q): got %+v wanted %+v", test.title, got, test.want)
+		}
+	}
+}
+
+func TestScheduleChanges(t *testing.T) {
+	date := time.Date(2018, 6, 14, 0, 0, 0, 0, time.UTC)
+	kickoff := time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC)
+	mapIDTeams := map[int64]string{10: "Russia", 11: "Saudi Arabia"}
+
+	m1 := &Tmatch{IdNumber: 1, Date: date, TeamId1: 10, TeamId2: 11, Location: "Moscow"}
+	m49 := &Tmatch{IdNumber: 49, Date: kickoff, Rule: "1A 2B", Location: "Sochi", Kickoff: true}
+	matches := []*Tmatch{m1, m49}
+
+	tests := []struct {
+		title   string
+		entries []ScheduleEntry
+		want    []ScheduleChange
+		errs    int
+	}{
+		{
+			title: "changed and unchanged matches",
+			entries: []ScheduleEntry{
+				{2, 1, kickoff, "Luzhniki Stadium, Moscow", "russia", "Saudi Arabia"},
+				{3, 49, kickoff, "Sochi", "1A", "2B"},
+			},
+			want: []ScheduleChange{
+				{m1, date, kickoff, "Moscow", "Luzhniki Stadium, Moscow"},
+			},
+		},
+		{
+			title: "invalid entries",
+			entries: []ScheduleEntry{
+				{2, 2, kickoff, "Moscow", "Egypt", "Uruguay"},
+				{3, 49, kickoff, "Sochi", "1B", "2A"},
+				{4, 1, kickoff, "Moscow", "Russia", "Saudi Arabia"},
+				{5, 1, kickoff, "Moscow", "Russia", "Saudi Arabia"},
+			},
+			want: []ScheduleChange{
+				{m1, date, kickoff, "Moscow", "Moscow"},
+			},
+			errs: 3,
+		},
+	}
+
+	for _, test := range tests {
+		got, errs := ScheduleChanges(test.entries, matches, mapIDTeams)
+		if len(errs) != test.errs {
+			t.Errorf("TestScheduleChanges(%q): got %d errors %v, wanted %d", test.title, len(errs), errs, test.errs)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestScheduleChanges(%q): got %+v wanted %+v", test.title, got, test.want)
+		}
+	}
+}
@@ -130,6 +130,12 @@ func (t *Tournament) UpdateTeamsAccuracy(c appengine.Context, m *Tmatch) error {
 	return nil
 }
 
+// MatchScore returns the score given by a predict with respect to a finished match.
+//
+func MatchScore(c appengine.Context, m *Tmatch, p *Predict) int64 {
+	return computeScore(c, m, p)
+}
+
 // Computes the score to be given with respect to a match and a predict.
 //
 func computeScore(c appengine.Context, m *Tmatch, p *Predict) int64 {
@@ -383,7 +383,7 @@ func CreateWorldCup(c appengine.Context, adminID int64) (*Tournament, error) {
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "World Cup: match: new key ok")
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 			emptyrule := ""
 			emptyresult := int64(0)
@@ -400,6 +400,7 @@ func CreateWorldCup(c appengine.Context, adminID int64) (*Tournament, error) {
 				false,
 				true,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "World Cup: match: build match ok")
 
@@ -467,7 +468,7 @@ func CreateWorldCup(c appengine.Context, adminID int64) (*Tournament, error) {
 			matchkey := datastore.NewKey(c, "Tmatch", "", matchID, nil)
 			log.Infof(c, "World Cup: match: new key ok")
 
-			matchTime, _ := time.Parse(shortForm, matchData[cMatchDate])
+			matchTime, kickoff, _ := ParseMatchDate(matchData[cMatchDate])
 			matchInternalID, _ := strconv.Atoi(matchData[cMatchID])
 
 			rule := fmt.Sprintf("%s %s", matchData[cMatchTeam1], matchData[cMatchTeam2])
@@ -485,6 +486,7 @@ func CreateWorldCup(c appengine.Context, adminID int64) (*Tournament, error) {
 				false,
 				false,
 				true,
+				kickoff,
 			}
 			log.Infof(c, "World Cup: match 2nd round: build match ok")
 
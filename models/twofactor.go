@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"github.com/taironas/gonawin/helpers/totp"
+)
+
+const (
+	// RecoveryCodesCount is the number of recovery codes generated when two-factor authentication is enabled.
+	RecoveryCodesCount = 10
+	// SecondFactorMaxAge is how long a second factor check of a session is considered recent.
+	SecondFactorMaxAge = 15 * time.Minute
+	// MaxTwoFactorFailures is the number of invalid codes in a row after which the codes of a user are refused.
+	MaxTwoFactorFailures = 5
+	// TwoFactorLockout is how long the codes of a user are refused after too many invalid codes.
+	TwoFactorLockout = 15 * time.Minute
+)
+
+// Two-factor authentication errors.
+//
+var (
+	ErrTwoFactorInvalid  = errors.New("model/twofactor: invalid code")
+	ErrTwoFactorEnabled  = errors.New("model/twofactor: two-factor authentication already enabled")
+	ErrTwoFactorDisabled = errors.New("model/twofactor: two-factor authentication not enabled")
+	ErrTwoFactorLocked   = errors.New("model/twofactor: too many invalid codes")
+)
+
+// TwoFactor holds the two-factor authentication settings of a user. Its key id is the id of the user.
+// The secret is generated at setup and the second factor is enforced once a first code is verified.
+// Only the hashes of the recovery codes are stored, a recovery code is used once.
+//
+type TwoFactor struct {
+	UserId         int64
+	Secret         string `datastore:",noindex"`
+	Enabled        bool
+	LastCounter    int64     `datastore:",noindex"` // counter of the last code used, a code cannot be used twice.
+	RecoveryHashes []string  `datastore:",noindex"`
+	Failures       int       `datastore:",noindex"` // invalid codes in a row.
+	LockedUntil    time.Time `datastore:",noindex"` // codes are refused until then after too many failures.
+	Created        time.Time
+}
+
+// TwoFactorJSON is the JSON representation of the two-factor authentication settings of a user.
+//
+type TwoFactorJSON struct {
+	Enabled       bool
+	RecoveryCodes int // number of recovery codes left.
+}
+
+func twoFactorKey(c appengine.Context, userID int64) *datastore.Key {
+	return datastore.NewKey(c, "TwoFactor", "", userID, nil)
+}
+
+// TwoFactorByUser gets the two-factor authentication settings of a user.
+// It returns datastore.ErrNoSuchEntity if the user never set it up.
+//
+func TwoFactorByUser(c appengine.Context, userID int64) (*TwoFactor, error) {
+	var tf TwoFactor
+	if err := datastore.Get(c, twoFactorKey(c, userID), &tf); err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// TwoFactorEnabled indicates if a user has enabled two-factor authentication.
+//
+func TwoFactorEnabled(c appengine.Context, userID int64) bool {
+	tf, err := TwoFactorByUser(c, userID)
+	return err == nil && tf.Enabled
+}
+
+// SetupTwoFactor generates a new secret for a user, two-factor authentication is enabled once a code of this secret is verified.
+// It returns ErrTwoFactorEnabled if it is already enabled.
+//
+func SetupTwoFactor(c appengine.Context, userID int64) (*TwoFactor, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	tf := &TwoFactor{UserId: userID, Secret: secret, Created: time.Now()}
+
+	err = datastore.RunInTransaction(c, func(tc appengine.Context) error {
+		var existing TwoFactor
+		err := datastore.Get(tc, twoFactorKey(tc, userID), &existing)
+		if err == nil && existing.Enabled {
+			return ErrTwoFactorEnabled
+		} else if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		_, err = datastore.Put(tc, twoFactorKey(tc, userID), tf)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return tf, nil
+}
+
+// JSON returns the JSON representation of the two-factor authentication settings.
+//
+func (tf *TwoFactor) JSON() TwoFactorJSON {
+	return TwoFactorJSON{Enabled: tf.Enabled, RecoveryCodes: len(tf.RecoveryHashes)}
+}
+
+// normalizeRecoveryCode removes the separators of a recovery code as the user may type it.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// GenerateRecoveryCodes replaces the recovery codes and returns the new ones.
+//
+func (tf *TwoFactor) GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodesCount)
+	hashes := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = HashToken(code)
+	}
+	tf.RecoveryHashes = hashes
+	return codes, nil
+}
+
+// Verify checks a code of the authenticator application or a recovery code at a given time.
+// A code cannot be used twice, verify updates the settings accordingly and they should be saved.
+//
+func (tf *TwoFactor) Verify(code string, now time.Time) error {
+	if counter, ok := totp.Validate(tf.Secret, code, now); ok {
+		if counter <= tf.LastCounter {
+			return ErrTwoFactorInvalid
+		}
+		tf.LastCounter = counter
+		return nil
+	}
+
+	if !tf.Enabled {
+		return ErrTwoFactorInvalid
+	}
+	code = normalizeRecoveryCode(code)
+	for i, hash := range tf.RecoveryHashes {
+		if checkSecret(hash, code) {
+			tf.RecoveryHashes = append(tf.RecoveryHashes[:i], tf.RecoveryHashes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrTwoFactorInvalid
+}
+
+// Attempt checks a code as Verify does and counts the invalid codes in a row.
+// After MaxTwoFactorFailures invalid codes, the codes are refused with ErrTwoFactorLocked for TwoFactorLockout.
+// The settings should be saved whatever the result, unless it is ErrTwoFactorLocked.
+//
+func (tf *TwoFactor) Attempt(code string, now time.Time) error {
+	if now.Before(tf.LockedUntil) {
+		return ErrTwoFactorLocked
+	}
+	if err := tf.Verify(code, now); err != nil {
+		tf.Failures++
+		if tf.Failures >= MaxTwoFactorFailures {
+			tf.Failures = 0
+			tf.LockedUntil = now.Add(TwoFactorLockout)
+		}
+		return err
+	}
+	tf.Failures = 0
+	return nil
+}
+
+// EnableTwoFactor verifies a first code of the authenticator application of a user and enables two-factor authentication.
+// It returns the recovery codes of the user.
+//
+func EnableTwoFactor(c appengine.Context, userID int64, code string) ([]string, error) {
+	var codes []string
+	err := datastore.RunInTransaction(c, func(tc appengine.Context) error {
+		var tf TwoFactor
+		if err := datastore.Get(tc, twoFactorKey(tc, userID), &tf); err != nil {
+			return err
+		}
+		if tf.Enabled {
+			return ErrTwoFactorEnabled
+		}
+		if err := tf.Verify(code, time.Now()); err != nil {
+			return err
+		}
+
+		var err error
+		if codes, err = tf.GenerateRecoveryCodes(); err != nil {
+			return err
+		}
+		tf.Enabled = true
+		_, err = datastore.Put(tc, twoFactorKey(tc, userID), &tf)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactor checks a code or a recovery code of a user who enabled two-factor authentication.
+// It runs in a transaction so that a code cannot be used by two concurrent requests.
+// The invalid codes are counted, it returns ErrTwoFactorLocked after too many of them.
+//
+func VerifyTwoFactor(c appengine.Context, userID int64, code string) (*TwoFactor, error) {
+	var tf TwoFactor
+	var verifyErr error
+	err := datastore.RunInTransaction(c, func(tc appengine.Context) error {
+		if err := datastore.Get(tc, twoFactorKey(tc, userID), &tf); err != nil {
+			return err
+		}
+		if !tf.Enabled {
+			return ErrTwoFactorDisabled
+		}
+		if verifyErr = tf.Attempt(code, time.Now()); verifyErr == ErrTwoFactorLocked {
+			return verifyErr
+		}
+		// the failures are saved too.
+		_, err := datastore.Put(tc, twoFactorKey(tc, userID), &tf)
+		return err
+	}, nil)
+	if err == nil {
+		err = verifyErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// Update the two-factor authentication settings of a user.
+//
+func (tf *TwoFactor) Update(c appengine.Context) error {
+	_, err := datastore.Put(c, twoFactorKey(c, tf.UserId), tf)
+	return err
+}
+
+// Destroy the two-factor authentication settings of a user, it disables two-factor authentication.
+//
+func (tf *TwoFactor) Destroy(c appengine.Context) error {
+	return datastore.Delete(c, twoFactorKey(c, tf.UserId))
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taironas/gonawin/helpers/totp"
+)
+
+func TestTwoFactorVerify(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("TestTwoFactorVerify: unable to generate secret: %v", err)
+	}
+	tf := TwoFactor{UserId: 42, Secret: secret, Enabled: true}
+
+	var codes []string
+	if codes, err = tf.GenerateRecoveryCodes(); err != nil || len(codes) != RecoveryCodesCount {
+		t.Fatalf("TestTwoFactorVerify: unable to generate recovery codes: %v", err)
+	}
+	code, _ := totp.Code(secret, totp.Counter(now))
+	next, _ := totp.Code(secret, totp.Counter(now)+1)
+
+	tests := []struct {
+		title     string
+		code      string
+		err       error
+		recovered int
+	}{
+		{"valid code", code, nil, RecoveryCodesCount},
+		{"code used twice", code, ErrTwoFactorInvalid, RecoveryCodesCount},
+		{"next code", next, nil, RecoveryCodesCount},
+		{"wrong code", "000000", ErrTwoFactorInvalid, RecoveryCodesCount},
+		{"recovery code", codes[0], nil, RecoveryCodesCount - 1},
+		{"recovery code used twice", codes[0], ErrTwoFactorInvalid, RecoveryCodesCount - 1},
+		{"recovery code typed by user", " " + strings.ToUpper(codes[1]), nil, RecoveryCodesCount - 2},
+	}
+	for _, test := range tests {
+		if err = tf.Verify(test.code, now); err != test.err {
+			t.Errorf("TestTwoFactorVerify(%q): got %v wanted %v", test.title, err, test.err)
+		}
+		if len(tf.RecoveryHashes) != test.recovered {
+			t.Errorf("TestTwoFactorVerify(%q): got %v recovery codes wanted %v", test.title, len(tf.RecoveryHashes), test.recovered)
+		}
+	}
+
+	// recovery codes are not accepted until two-factor authentication is enabled.
+	tf.Enabled = false
+	if err = tf.Verify(codes[2], now); err != ErrTwoFactorInvalid {
+		t.Errorf("TestTwoFactorVerify(%q): got %v wanted %v", "recovery code not enabled", err, ErrTwoFactorInvalid)
+	}
+}
+
+func TestTwoFactorAttempt(t *testing.T) {
+	now := time.Date(2014, 6, 12, 20, 0, 0, 0, time.UTC)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("TestTwoFactorAttempt: unable to generate secret: %v", err)
+	}
+	tf := TwoFactor{UserId: 42, Secret: secret, Enabled: true}
+	code, _ := totp.Code(secret, totp.Counter(now))
+
+	for i := 1; i < MaxTwoFactorFailures; i++ {
+		if err = tf.Attempt("000000", now); err != ErrTwoFactorInvalid {
+			t.Errorf("TestTwoFactorAttempt(%q): got %v wanted %v", "wrong code", err, ErrTwoFactorInvalid)
+		}
+	}
+	if tf.Failures != MaxTwoFactorFailures-1 {
+		t.Errorf("TestTwoFactorAttempt(%q): got %v failures wanted %v", "wrong codes", tf.Failures, MaxTwoFactorFailures-1)
+	}
+
+	tests := []struct {
+		title string
+		code  string
+		now   time.Time
+		err   error
+	}{
+		{"last wrong code", "000000", now, ErrTwoFactorInvalid},
+		{"valid code while locked", code, now, ErrTwoFactorLocked},
+		{"valid code before end of lockout", code, now.Add(TwoFactorLockout - time.Second), ErrTwoFactorLocked},
+		{"valid code after lockout", code, now.Add(TwoFactorLockout), ErrTwoFactorInvalid}, // the code has expired.
+	}
+	for _, test := range tests {
+		if err = tf.Attempt(test.code, test.now); err != test.err {
+			t.Errorf("TestTwoFactorAttempt(%q): got %v wanted %v", test.title, err, test.err)
+		}
+	}
+
+	later := now.Add(TwoFactorLockout)
+	tf.Failures = 3
+	code, _ = totp.Code(secret, totp.Counter(later))
+	if err = tf.Attempt(code, later); err != nil || tf.Failures != 0 {
+		t.Errorf("TestTwoFactorAttempt(%q): got %v and %v failures wanted no error and no failures", "valid code", err, tf.Failures)
+	}
+}
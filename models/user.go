@@ -42,43 +42,57 @@ type ScoreOfTournament struct {
 // User represents the User entity.
 //
 type User struct {
-	Id                    int64
-	Email                 string
-	Username              string
-	Name                  string
-	Alias                 string              // name to display chosen by user if requested.
-	IsAdmin               bool                // is user gonawin admin.
-	Auth                  string              // authentication auth token
-	PredictIds            []int64             // current user predicts.
-	ArchivedPredictInds   []int64             // archived user predicts.
-	TournamentIds         []int64             // current tournament ids of user <=> tournaments user subscribed.
-	ArchivedTournamentIds []int64             // archived tournament ids of user <=> finnished tournametns user subscribed.
-	TeamIds               []int64             // current team ids of user <=> teams user belongs to.
-	Score                 int64               // overall user score.
-	ScoreOfTournaments    []ScoreOfTournament // ids of Scores for each tournament the user is participating on.
-	ActivityIds           []int64             // ids of user's activities
-	Created               time.Time
-}
+	Id                      int64
+	Email                   string
+	Username                string
+	Name                    string
+	Alias                   string                   // name to display chosen by user if requested.
+	IsAdmin                 bool                     // is user gonawin admin.
+	Auth                    string                   // authentication auth token
+	PredictIds              []int64                  // current user predicts.
+	ArchivedPredictInds     []int64                  // archived user predicts.
+	TournamentIds           []int64                  // current tournament ids of user <=> tournaments user subscribed.
+	ArchivedTournamentIds   []int64                  // archived tournament ids of user <=> finnished tournametns user subscribed.
+	TeamIds                 []int64                  // current team ids of user <=> teams user belongs to.
+	Score                   int64                    // overall user score.
+	ScoreOfTournaments      []ScoreOfTournament      // ids of Scores for each tournament the user is participating on.
+	ActivityIds             []int64                  // ids of user's activities
+	Timezone                string                   // time zone used to display dates, IANA name or offset.
+	Language                string                   // language of the emails sent to the user.
+	ReminderOptOutIds       []int64                  // ids of tournaments the user does not want prediction reminders for.
+	DigestFrequency         string                   // frequency of the activity digest email: none, daily or weekly.
+	NotificationPreferences []NotificationPreference // channels used to deliver each type of notification.
+	Created                 time.Time
+}
+
+// Languages supported by gonawin emails. The first one is the default language.
+//
+var Languages = []string{"en", "es", "fr"}
 
 // UserJSON is the JSON representation of the User entity.
 //
 type UserJSON struct {
-	Id                    *int64               `json:",omitempty"`
-	Email                 *string              `json:",omitempty"`
-	Username              *string              `json:",omitempty"`
-	Name                  *string              `json:",omitempty"`
-	Alias                 *string              `json:",omitempty"`
-	IsAdmin               *bool                `json:",omitempty"`
-	Auth                  *string              `json:",omitempty"`
-	PredictIds            *[]int64             `json:",omitempty"`
-	ArchivedPredictInds   *[]int64             `json:",omitempty"`
-	TournamentIds         *[]int64             `json:",omitempty"`
-	ArchivedTournamentIds *[]int64             `json:",omitempty"`
-	TeamIds               *[]int64             `json:",omitempty"`
-	Score                 *int64               `json:",omitempty"`
-	ScoreOfTournaments    *[]ScoreOfTournament `json:",omitempty"`
-	ActivityIds           *[]int64             `json:",omitempty"`
-	Created               *time.Time           `json:",omitempty"`
+	Id                      *int64                    `json:",omitempty"`
+	Email                   *string                   `json:",omitempty"`
+	Username                *string                   `json:",omitempty"`
+	Name                    *string                   `json:",omitempty"`
+	Alias                   *string                   `json:",omitempty"`
+	IsAdmin                 *bool                     `json:",omitempty"`
+	Auth                    *string                   `json:",omitempty"`
+	PredictIds              *[]int64                  `json:",omitempty"`
+	ArchivedPredictInds     *[]int64                  `json:",omitempty"`
+	TournamentIds           *[]int64                  `json:",omitempty"`
+	ArchivedTournamentIds   *[]int64                  `json:",omitempty"`
+	TeamIds                 *[]int64                  `json:",omitempty"`
+	Score                   *int64                    `json:",omitempty"`
+	ScoreOfTournaments      *[]ScoreOfTournament      `json:",omitempty"`
+	ActivityIds             *[]int64                  `json:",omitempty"`
+	Timezone                *string                   `json:",omitempty"`
+	Language                *string                   `json:",omitempty"`
+	ReminderOptOutIds       *[]int64                  `json:",omitempty"`
+	DigestFrequency         *string                   `json:",omitempty"`
+	NotificationPreferences *[]NotificationPreference `json:",omitempty"`
+	Created                 *time.Time                `json:",omitempty"`
 }
 
 // CreateUser lets you create a user entity.
@@ -118,16 +132,9 @@ func CreateUser(c appengine.Context, email, username, name, alias string, isAdmi
 		return nil, errors.New("model/user: Unable to put user in Datastore")
 	}
 
-	// add name to inverted index
-	// as name and username can have the same words.
-	// We build a string with a set of words between these two strings
-	allnames := name + " " + username
-	setOfNames := helpers.SetOfStrings(allnames)
-	names := ""
-	for _, n := range setOfNames {
-		names = names + " " + n
+	if err = Index(c, user); err != nil {
+		log.Errorf(c, "User.Create, unable to index user %v: %v", user.Id, err)
 	}
-	AddToUserInvertedIndex(c, names, user.Id)
 
 	return user, nil
 }
@@ -148,20 +155,7 @@ func (u *User) Destroy(c appengine.Context) error {
 		return errd
 	}
 
-	// remove key name.
-	if err = UpdateUserInvertedIndex(c, helpers.TrimLower(u.Name), "", u.Id); err != nil {
-		return err
-	}
-	// remove key username.
-	if err = UpdateUserInvertedIndex(c, helpers.TrimLower(u.Username), "", u.Id); err != nil {
-		return err
-	}
-	// remove key alias.
-	if err = UpdateUserInvertedIndex(c, helpers.TrimLower(u.Alias), "", u.Id); err != nil {
-		return err
-	}
-
-	return nil
+	return Unindex(c, u.IndexKind(), u.Id)
 }
 
 // FindUser searches for a user entity given a filter and value.
@@ -272,9 +266,11 @@ func (u *User) Update(c appengine.Context) error {
 		if _, err := datastore.Put(c, k, u); err != nil {
 			return err
 		}
-		// use lower trim names for alias as user inverted index store them like this.
-		// alias is the only field that can be changed.
-		UpdateUserInvertedIndex(c, helpers.TrimLower(oldUser.Alias), helpers.TrimLower(u.Alias), u.Id)
+		if oldUser.IndexText() != u.IndexText() {
+			if err := Index(c, u); err != nil {
+				log.Errorf(c, "User.Update, unable to index user %v: %v", u.Id, err)
+			}
+		}
 	}
 	return nil
 }
@@ -381,6 +377,21 @@ func (u *User) TournamentsByPage(c appengine.Context, count, page int64) []*Tour
 	return paged
 }
 
+// TournamentsByCursor returns count tournaments the user participates in starting after cursor, the last
+// joined first. It returns the cursor of the next page, empty after the last page.
+//
+func (u *User) TournamentsByCursor(c appengine.Context, count int64, cursor string) ([]*Tournament, string, error) {
+	ids, next, err := idsByCursor(u.TournamentIds, count, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	tournaments, err := TournamentsByIds(c, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	return tournaments, next, nil
+}
+
 // AddPredictID adds a predict Id in the PredictId array.
 //
 func (u *User) AddPredictID(c appengine.Context, pID int64) error {
@@ -513,6 +524,66 @@ func UpdateUsers(c appengine.Context, users []*User) error {
 	return nil
 }
 
+// Location returns the location of the user's time zone.
+// UTC is returned when the user has no valid time zone.
+//
+func (u *User) Location() *time.Location {
+	loc, err := helpers.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Lang returns the language of the emails sent to the user.
+// The default language is returned when the user has no supported language.
+//
+func (u *User) Lang() string {
+	if IsLanguageSupported(u.Language) {
+		return u.Language
+	}
+	return Languages[0]
+}
+
+// IsLanguageSupported indicates if gonawin emails can be written in a language.
+//
+func IsLanguageSupported(lang string) bool {
+	for _, l := range Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// RemindersEnabled indicates if the user wants to be reminded of the matches to predict in a tournament.
+//
+func (u *User) RemindersEnabled(tID int64) bool {
+	optOut, _ := helpers.Contains(u.ReminderOptOutIds, tID)
+	return !optOut
+}
+
+// SetReminders enables or disables the prediction reminders of the user for a tournament.
+//
+func (u *User) SetReminders(c appengine.Context, tID int64, enabled bool) error {
+
+	optOut, i := helpers.Contains(u.ReminderOptOutIds, tID)
+	if optOut != enabled {
+		return nil
+	}
+
+	if enabled {
+		// as the order of index in ReminderOptOutIds is not important,
+		// replace elem at index i with last element and resize slice.
+		u.ReminderOptOutIds[i] = u.ReminderOptOutIds[len(u.ReminderOptOutIds)-1]
+		u.ReminderOptOutIds = u.ReminderOptOutIds[0 : len(u.ReminderOptOutIds)-1]
+	} else {
+		u.ReminderOptOutIds = append(u.ReminderOptOutIds, tID)
+	}
+
+	return u.Update(c)
+}
+
 // PredictFromMatchID returns the user predictions for a specific match.
 //
 func (u *User) PredictFromMatchID(c appengine.Context, mID int64) (*Predict, error) {
@@ -561,35 +632,27 @@ func (a UserByScore) Less(i, j int) bool { return a[i].Score < a[j].Score }
 // Publish user activity.
 //
 func (u *User) Publish(c appengine.Context, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
-	activity := u.BuildActivity(c, activityType, verb, object, target)
-
-	if err := activity.save(c); err != nil {
-		return err
-	}
-	// add new activity id in user activity table
-	activity.AddNewActivityID(c, u)
+	return Repos(c).Publish(u, activityType, verb, object, target)
+}
 
-	return u.Update(c)
+// PublishTo publishes a user activity in the activities of other users.
+// Use it when the activity concerns other users than the actor.
+//
+func (u *User) PublishTo(c appengine.Context, recipients []*User, activityType string, verb string, object ActivityEntity, target ActivityEntity) error {
+	return Repos(c).PublishTo(u, recipients, activityType, verb, object, target)
 }
 
 // BuildActivity build an activity.
 //
 func (u *User) BuildActivity(c appengine.Context, activityType string, verb string, object ActivityEntity, target ActivityEntity) *Activity {
-	var activity Activity
-	activity.Type = activityType
-	activity.Verb = verb
-	activity.Actor = u.Entity()
-	activity.Object = object
-	activity.Target = target
-	activity.Published = time.Now()
-	activity.CreatorID = u.Id
+	activity := newActivity(u.Entity(), u.Id, activityType, verb, object, target)
 	id, _, err1 := datastore.AllocateIDs(c, "Activity", nil, 1)
 	if err1 != nil {
 		log.Errorf(c, " BuildActivity: error occurred during AllocateIDs call: %v", err1)
 		return nil
 	}
 	activity.Id = id
-	return &activity
+	return activity
 }
 
 // Entity is the Activity entity representation of an user.
@@ -730,12 +793,18 @@ func FindUsers(c appengine.Context, filter string, value interface{}) []*User {
 	return users
 }
 
-// GetWordFrequencyForUser gets the frequency of given word with respect to user id.
+// IndexKind returns the kind of a user in the search index.
 //
-func GetWordFrequencyForUser(c appengine.Context, id int64, word string) int64 {
+func (u *User) IndexKind() string { return "User" }
 
-	if users := FindUsers(c, "Id", id); users != nil {
-		return helpers.CountTerm(strings.Split(users[0].Name, " "), word)
-	}
-	return 0
-}
+// IndexID returns the id of a user in the search index.
+//
+func (u *User) IndexID() int64 { return u.Id }
+
+// IndexText returns the text a user is found by, their name, username and alias.
+//
+func (u *User) IndexText() string { return u.Name + " " + u.Username + " " + u.Alias }
+
+// IndexName returns the name a user is suggested with, their username.
+//
+func (u *User) IndexName() string { return u.Username }
@@ -7,8 +7,6 @@ import (
 	"time"
 
 	"appengine/aetest"
-
-	"github.com/taironas/gonawin/helpers"
 )
 
 type testUser struct {
@@ -43,13 +41,11 @@ func checkUser(got *User, want testUser) error {
 //
 func checkUserInvertedIndex(t *testing.T, c aetest.Context, got *User, want testUser) error {
 
-	var ids []int64
-	var err error
-	words := helpers.SetOfStrings(want.username)
-	if ids, err = GetUserInvertedIndexes(c, words); err != nil {
-		return fmt.Errorf("failed calling GetUserInvertedIndexes %v", err)
+	results, _, err := Search(c, []string{"User"}, want.username, 100, 1)
+	if err != nil {
+		return fmt.Errorf("failed calling Search %v", err)
 	}
-	for _, id := range ids {
+	for _, id := range SearchResultIds(results, "User") {
 		if id == got.Id {
 			return nil
 		}
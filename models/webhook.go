@@ -0,0 +1,245 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+
+	"github.com/taironas/gonawin/helpers/log"
+)
+
+// Webhook events.
+//
+const (
+	EventMatchResult    = "match.result"    // the result of a match is set.
+	EventPhaseCompleted = "phase.completed" // the last match of a phase is finished.
+	EventRankingChanged = "ranking.changed" // the accuracy of the team changed.
+	EventMemberJoined   = "member.joined"   // a user joined the team.
+	EventPredictionLock = "prediction.lock" // predictions of a match will be locked soon.
+)
+
+// WebhookEvents holds all the webhook events.
+//
+var WebhookEvents = []string{EventMatchResult, EventPhaseCompleted, EventRankingChanged, EventMemberJoined, EventPredictionLock}
+
+// Webhook represents a url of a team notified of the team events.
+//
+type Webhook struct {
+	Id      int64
+	TeamId  int64    // id of the team the webhook is registered on.
+	URL     string   // url the events are posted to.
+	Secret  string   // secret used to sign the payloads.
+	Events  []string // events sent to the url.
+	Created time.Time
+}
+
+// WebhookJSON is the JSON representation of a webhook.
+//
+type WebhookJSON struct {
+	Id      *int64     `json:",omitempty"`
+	TeamId  *int64     `json:",omitempty"`
+	URL     *string    `json:",omitempty"`
+	Secret  *string    `json:",omitempty"`
+	Events  *[]string  `json:",omitempty"`
+	Created *time.Time `json:",omitempty"`
+}
+
+// WebhookPayload is the JSON payload posted to a webhook.
+//
+type WebhookPayload struct {
+	Event      string
+	Team       ActivityEntity
+	Tournament ActivityEntity
+	Data       interface{} // data of the event.
+	Created    time.Time
+}
+
+// IsWebhookEventValid indicates if a webhook event exists.
+//
+func IsWebhookEventValid(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhook creates a webhook on a team for a url and events.
+// A secret is generated to sign the payloads.
+//
+func CreateWebhook(c appengine.Context, teamID int64, url string, events []string) (*Webhook, error) {
+
+	id, _, err := datastore.AllocateIDs(c, "Webhook", nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	key := datastore.NewKey(c, "Webhook", "", id, nil)
+	wh := &Webhook{id, teamID, url, GenerateAuthKey(), events, time.Now()}
+	if _, err = datastore.Put(c, key, wh); err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
+
+// Destroy a webhook entity.
+//
+func (wh *Webhook) Destroy(c appengine.Context) error {
+	key := datastore.NewKey(c, "Webhook", "", wh.Id, nil)
+	return datastore.Delete(c, key)
+}
+
+// WebhookByID gets a webhook given an id.
+//
+func WebhookByID(c appengine.Context, id int64) (*Webhook, error) {
+
+	var wh Webhook
+	key := datastore.NewKey(c, "Webhook", "", id, nil)
+
+	if err := datastore.Get(c, key, &wh); err != nil {
+		log.Errorf(c, "Webhook not found : %v", err)
+		return nil, err
+	}
+	return &wh, nil
+}
+
+// FindWebhooksByTeam returns the webhooks registered on a team.
+//
+func FindWebhooksByTeam(c appengine.Context, teamID int64) []*Webhook {
+	desc := "Webhook.FindWebhooksByTeam:"
+	q := datastore.NewQuery("Webhook").Filter("TeamId"+" =", teamID)
+
+	var webhooks []*Webhook
+	if _, err := q.GetAll(c, &webhooks); err != nil {
+		log.Errorf(c, "%s an error occurred during GetAll: %v", desc, err)
+		return nil
+	}
+	return webhooks
+}
+
+// Subscribed indicates if a webhook is sent an event.
+//
+func (wh *Webhook) Subscribed(event string) bool {
+	for _, e := range wh.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishEvent posts an event to the webhooks of the team subscribed to it.
+// Each delivery is a task of the webhooks queue, which retries failed deliveries with backoff.
+//
+func (t *Team) PublishEvent(c appengine.Context, event string, tournament ActivityEntity, data interface{}) error {
+	desc := "model/webhook, PublishEvent:"
+
+	var webhooks []*Webhook
+	for _, wh := range FindWebhooksByTeam(c, t.Id) {
+		if wh.Subscribed(event) {
+			webhooks = append(webhooks, wh)
+		}
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(WebhookPayload{event, t.Entity(), tournament, data, time.Now()})
+	if err != nil {
+		log.Errorf(c, "%s Error marshaling %v", desc, err)
+		return err
+	}
+
+	for _, wh := range webhooks {
+		task := taskqueue.NewPOSTTask("/a/webhooks/deliver/", url.Values{
+			"webhookId":  []string{strconv.FormatInt(wh.Id, 10)},
+			"event":      []string{event},
+			"deliveryId": []string{fmt.Sprintf("%d-%d", wh.Id, time.Now().UnixNano())},
+			"payload":    []string{string(payload)},
+		})
+		if _, err := taskqueue.Add(c, task, "webhooks"); err != nil {
+			log.Errorf(c, "%s unable to add task to taskqueue %v", desc, err)
+		}
+	}
+	return nil
+}
+
+// PublishEvent posts an event to the webhooks of the teams participating in the tournament.
+//
+func (t *Tournament) PublishEvent(c appengine.Context, event string, data interface{}) {
+	for _, team := range t.Teams(c) {
+		if err := team.PublishEvent(c, event, t.Entity(), data); err != nil {
+			log.Errorf(c, "model/webhook, PublishEvent: unable to publish %s to team %v: %v", event, team.Id, err)
+		}
+	}
+}
+
+// WebhookMatch is the data of the match events.
+//
+type WebhookMatch struct {
+	IdNumber int64
+	Team1    string
+	Team2    string
+	Result1  int64
+	Result2  int64
+	Finished bool
+	Date     time.Time
+	Location string
+}
+
+// WebhookPhase is the data of the phase events.
+//
+type WebhookPhase struct {
+	Phase string
+}
+
+// WebhookRanking is the data of the ranking events.
+//
+type WebhookRanking struct {
+	Accuracy           float64 // overall accuracy of the team.
+	TournamentAccuracy float64 // accuracy of the team in the tournament.
+}
+
+// NewWebhookMatch builds the data of a match event.
+//
+func NewWebhookMatch(m *Tmatch, mapIDTeams map[int64]string) WebhookMatch {
+	team1, team2 := m.TeamNames(mapIDTeams)
+	return WebhookMatch{m.IdNumber, team1, team2, m.Result1, m.Result2, m.Finished, m.Date, m.Location}
+}
+
+// MatchesLockedBetween returns the matches that can be predicted and whose predictions are locked in [from, to).
+//
+func MatchesLockedBetween(matches []*Tmatch, from, to time.Time) []*Tmatch {
+	var locked []*Tmatch
+	for _, m := range matches {
+		if !m.Ready || !m.CanPredict || m.Finished {
+			continue
+		}
+		if lock := m.PredictionLock(); !lock.Before(from) && lock.Before(to) {
+			locked = append(locked, m)
+		}
+	}
+	return locked
+}
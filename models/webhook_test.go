@@ -0,0 +1,30 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatchesLockedBetween(t *testing.T) {
+	from := time.Date(2018, 6, 14, 14, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	matches := []*Tmatch{
+		{Id: 1, Date: from, Ready: true, CanPredict: true},
+		{Id: 2, Date: from.Add(30 * time.Minute), Ready: true, CanPredict: true},
+		{Id: 3, Date: to, Ready: true, CanPredict: true},
+		{Id: 4, Date: from.Add(-time.Minute), Ready: true, CanPredict: true},
+		{Id: 5, Date: from, Ready: true, CanPredict: false},
+		{Id: 6, Date: from, Ready: false, CanPredict: true},
+		{Id: 7, Date: from, Ready: true, CanPredict: true, Finished: true},
+	}
+
+	var got []int64
+	for _, m := range MatchesLockedBetween(matches, from, to) {
+		got = append(got, m.Id)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestMatchesLockedBetween: got %v wanted %v", got, want)
+	}
+}
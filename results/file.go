@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package results
+
+import (
+	"os"
+
+	"appengine"
+)
+
+// FileProvider reads fixtures from a JSON or CSV file.
+//
+type FileProvider struct {
+	Path   string // path of the file.
+	Format string // FormatJSON or FormatCSV.
+}
+
+// Fixtures returns all the fixtures of the file.
+//
+func (p FileProvider) Fixtures(c appengine.Context) ([]Fixture, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f, p.Format)
+}
+
+// Results returns the finished fixtures of the file.
+//
+func (p FileProvider) Results(c appengine.Context) ([]Fixture, error) {
+	fixtures, err := p.Fixtures(c)
+	if err != nil {
+		return nil, err
+	}
+	return Finished(fixtures), nil
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package results
+
+import (
+	"fmt"
+	"net/http"
+
+	"appengine"
+	"appengine/urlfetch"
+)
+
+// HTTPProvider polls a URL serving fixtures in JSON or CSV format.
+//
+type HTTPProvider struct {
+	URL    string // URL of the feed, it can be a local stub.
+	Format string // FormatJSON or FormatCSV.
+	// Client returns the HTTP client used to fetch the feed.
+	// urlfetch.Client is used when nil.
+	Client func(c appengine.Context) *http.Client
+}
+
+// Fixtures returns all the fixtures served by the URL.
+//
+func (p HTTPProvider) Fixtures(c appengine.Context) ([]Fixture, error) {
+	client := p.Client
+	if client == nil {
+		client = urlfetch.Client
+	}
+
+	resp, err := client(c).Get(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("results: unable to fetch %s: %s", p.URL, resp.Status)
+	}
+	return Decode(resp.Body, p.Format)
+}
+
+// Results returns the finished fixtures served by the URL.
+//
+func (p HTTPProvider) Results(c appengine.Context) ([]Fixture, error) {
+	fixtures, err := p.Fixtures(c)
+	if err != nil {
+		return nil, err
+	}
+	return Finished(fixtures), nil
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2014 Santiago Arias | Remy Jourde
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package results provides a way to fetch fixtures and results of tournament
+// matches from external providers.
+//
+// A provider is anything implementing the Provider interface. Two
+// implementations are available: FileProvider reads a JSON or CSV file and
+// HTTPProvider polls any URL serving the same formats.
+//
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"appengine"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+// Supported formats of a feed.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Fixture is a match as described by an external provider.
+// Teams are identified by their external code, ie the values of TournamentBuilder.MapOfTeamCodes().
+//
+type Fixture struct {
+	IdNumber int64     `json:"id"`       // id of match in tournament, optional.
+	Date     time.Time `json:"date"`     // date of match.
+	Team1    string    `json:"team1"`    // code of 1st team.
+	Team2    string    `json:"team2"`    // code of 2nd team.
+	Location string    `json:"location"` // match location, optional.
+	Result1  int64     `json:"result1"`  // result of 1st team.
+	Result2  int64     `json:"result2"`  // result of 2nd team.
+	Finished bool      `json:"finished"` // is match finished.
+}
+
+// Provider is the interface implemented by the results feeds.
+//
+type Provider interface {
+	// Fixtures returns all the fixtures known by the provider.
+	Fixtures(c appengine.Context) ([]Fixture, error)
+	// Results returns the finished fixtures known by the provider.
+	Results(c appengine.Context) ([]Fixture, error)
+}
+
+// Update holds a match and the result to set on it.
+//
+type Update struct {
+	Match   *mdl.Tmatch
+	Result1 int64
+	Result2 int64
+}
+
+// Decode reads fixtures with respect to a format, FormatJSON or FormatCSV.
+//
+// JSON data is an array of Fixture objects.
+// CSV data has a header line followed by one line per fixture with the
+// following columns: id,date,team1,team2,location,result1,result2,finished.
+// Dates are in RFC 3339 format.
+//
+func Decode(r io.Reader, format string) ([]Fixture, error) {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		var fixtures []Fixture
+		if err := json.NewDecoder(r).Decode(&fixtures); err != nil {
+			return nil, err
+		}
+		return fixtures, nil
+	case FormatCSV:
+		return decodeCSV(r)
+	}
+	return nil, fmt.Errorf("results: unsupported format %q", format)
+}
+
+// Finished returns the finished fixtures of an array of fixtures.
+//
+func Finished(fixtures []Fixture) []Fixture {
+	var finished []Fixture
+	for _, f := range fixtures {
+		if f.Finished {
+			finished = append(finished, f)
+		}
+	}
+	return finished
+}
+
+// maxDateGap is the largest gap between the date of a fixture and the date of its match.
+// Dates of matches may only hold the day and providers may give local kickoff times.
+const maxDateGap = 24 * time.Hour
+
+// NewUpdates returns the updates to apply on matches with respect to finished fixtures.
+// External team codes are mapped to team ids through the team codes map (key: team name, value: code)
+// and the map of team ids (key: team id, value: team name) of the tournament.
+// A fixture is mapped to the match with the same teams, in any order, and the same id,
+// or the same date when the fixture has no id.
+// Fixtures of matches already finished and fixtures that cannot be mapped to a match are ignored.
+// A match listed more than once by the feed is only updated with its first fixture.
+//
+func NewUpdates(fixtures []Fixture, teamCodes map[string]string, mapIDTeams map[int64]string, matches []*mdl.Tmatch) []Update {
+
+	teamIDs := make(map[string]int64)
+	for id, name := range mapIDTeams {
+		teamIDs[strings.ToLower(name)] = id
+		if code, ok := teamCodes[name]; ok {
+			teamIDs[strings.ToLower(code)] = id
+		}
+	}
+
+	var updates []Update
+	updated := make(map[int64]bool)
+	for _, f := range fixtures {
+		if !f.Finished {
+			continue
+		}
+		id1, ok1 := teamIDs[strings.ToLower(f.Team1)]
+		id2, ok2 := teamIDs[strings.ToLower(f.Team2)]
+		if !ok1 || !ok2 {
+			continue
+		}
+		for _, m := range matches {
+			if !f.sameMatch(m) {
+				continue
+			}
+			var u Update
+			if m.TeamId1 == id1 && m.TeamId2 == id2 {
+				u = Update{m, f.Result1, f.Result2}
+			} else if m.TeamId1 == id2 && m.TeamId2 == id1 {
+				u = Update{m, f.Result2, f.Result1}
+			} else {
+				continue
+			}
+			if !m.Finished && !updated[m.Id] {
+				updated[m.Id] = true
+				updates = append(updates, u)
+			}
+			break
+		}
+	}
+	return updates
+}
+
+// sameMatch reports whether the fixture and the match have the same id,
+// or the same date when the fixture has no id.
+//
+func (f Fixture) sameMatch(m *mdl.Tmatch) bool {
+	if f.IdNumber > 0 {
+		return m.IdNumber == f.IdNumber
+	}
+	if f.Date.IsZero() {
+		return false
+	}
+	gap := f.Date.Sub(m.Date)
+	if gap < 0 {
+		gap = -gap
+	}
+	return gap <= maxDateGap
+}
+
+func decodeCSV(r io.Reader) ([]Fixture, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("results: empty csv")
+	}
+
+	var fixtures []Fixture
+	// first line is the header.
+	for i, record := range records[1:] {
+		if len(record) != 8 {
+			return nil, fmt.Errorf("results: line %d: wrong number of fields", i+2)
+		}
+		var f Fixture
+		if len(record[0]) > 0 {
+			if f.IdNumber, err = strconv.ParseInt(record[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("results: line %d: %v", i+2, err)
+			}
+		}
+		if f.Date, err = time.Parse(time.RFC3339, record[1]); err != nil {
+			return nil, fmt.Errorf("results: line %d: %v", i+2, err)
+		}
+		f.Team1 = record[2]
+		f.Team2 = record[3]
+		f.Location = record[4]
+		if f.Finished, err = strconv.ParseBool(record[7]); err != nil {
+			return nil, fmt.Errorf("results: line %d: %v", i+2, err)
+		}
+		if f.Finished {
+			if f.Result1, err = strconv.ParseInt(record[5], 10, 64); err != nil {
+				return nil, fmt.Errorf("results: line %d: %v", i+2, err)
+			}
+			if f.Result2, err = strconv.ParseInt(record[6], 10, 64); err != nil {
+				return nil, fmt.Errorf("results: line %d: %v", i+2, err)
+			}
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
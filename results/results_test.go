@@ -0,0 +1,166 @@
+package results
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"appengine"
+
+	mdl "github.com/taironas/gonawin/models"
+)
+
+var testFixtures = []Fixture{
+	{1, time.Date(2018, 6, 14, 15, 0, 0, 0, time.UTC), "ru", "sa", "Luzhniki Stadium, Moscow", 5, 0, true},
+	{2, time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC), "EG", "UY", "Ekaterinburg Arena, Ekaterinburg", 0, 1, true},
+	{3, time.Date(2018, 6, 15, 18, 0, 0, 0, time.UTC), "pt", "es", "Fisht Stadium, Sochi", 0, 0, false},
+}
+
+func TestFileProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider FileProvider
+	}{
+		{"json file", FileProvider{"testdata/results.json", FormatJSON}},
+		{"csv file", FileProvider{"testdata/results.csv", FormatCSV}},
+	}
+
+	for _, test := range tests {
+		fixtures, err := test.provider.Fixtures(nil)
+		if err != nil {
+			t.Errorf("TestFileProvider(%q): got error %v wanted no error", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(fixtures, testFixtures) {
+			t.Errorf("TestFileProvider(%q): got %+v wanted %+v", test.name, fixtures, testFixtures)
+		}
+		results, err := test.provider.Results(nil)
+		if err != nil {
+			t.Errorf("TestFileProvider(%q): got error %v wanted no error", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(results, testFixtures[:2]) {
+			t.Errorf("TestFileProvider(%q): got results %+v wanted %+v", test.name, results, testFixtures[:2])
+		}
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/results.json" {
+			http.NotFound(w, r)
+			return
+		}
+		f, _ := os.Open("testdata/results.json")
+		defer f.Close()
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, f)
+	}))
+	defer stub.Close()
+
+	client := func(c appengine.Context) *http.Client { return http.DefaultClient }
+
+	tests := []struct {
+		name     string
+		provider HTTPProvider
+		want     []Fixture
+		err      bool
+	}{
+		{"existing feed", HTTPProvider{stub.URL + "/results.json", FormatJSON, client}, testFixtures[:2], false},
+		{"missing feed", HTTPProvider{stub.URL + "/missing.json", FormatJSON, client}, nil, true},
+	}
+
+	for _, test := range tests {
+		results, err := test.provider.Results(nil)
+		if (err != nil) != test.err {
+			t.Errorf("TestHTTPProvider(%q): got error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(results, test.want) {
+			t.Errorf("TestHTTPProvider(%q): got %+v wanted %+v", test.name, results, test.want)
+		}
+	}
+}
+
+func TestNewUpdates(t *testing.T) {
+	teamCodes := map[string]string{"Russia": "ru", "Saudi Arabia": "sa", "Egypt": "eg", "Uruguay": "uy"}
+	mapIDTeams := map[int64]string{10: "Russia", 11: "Saudi Arabia", 12: "Egypt", 13: "Uruguay"}
+
+	m1 := &mdl.Tmatch{Id: 100, IdNumber: 1, Date: time.Date(2018, 6, 14, 0, 0, 0, 0, time.UTC), TeamId1: 10, TeamId2: 11}
+	m2 := &mdl.Tmatch{Id: 101, IdNumber: 2, Date: time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC), TeamId1: 13, TeamId2: 12}
+	m3 := &mdl.Tmatch{Id: 102, IdNumber: 3, Date: time.Date(2018, 6, 20, 0, 0, 0, 0, time.UTC), TeamId1: 10, TeamId2: 12, Finished: true}
+	day := func(d int) time.Time { return time.Date(2018, 6, d, 18, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		fixtures []Fixture
+		want     []Update
+	}{
+		{
+			name:     "teams in same order",
+			fixtures: []Fixture{{IdNumber: 1, Team1: "RU", Team2: "sa", Result1: 5, Result2: 0, Finished: true}},
+			want:     []Update{{m1, 5, 0}},
+		},
+		{
+			name:     "teams in reverse order",
+			fixtures: []Fixture{{Date: day(15), Team1: "eg", Team2: "uy", Result1: 0, Result2: 1, Finished: true}},
+			want:     []Update{{m2, 1, 0}},
+		},
+		{
+			name: "ignored fixtures",
+			fixtures: []Fixture{
+				{Date: day(14), Team1: "ru", Team2: "sa", Finished: false},
+				{Date: day(20), Team1: "ru", Team2: "eg", Result1: 1, Result2: 1, Finished: true},
+				{Date: day(14), Team1: "br", Team2: "de", Result1: 1, Result2: 7, Finished: true},
+				{IdNumber: 2, Team1: "ru", Team2: "sa", Result1: 1, Result2: 7, Finished: true},
+				{Team1: "ru", Team2: "sa", Result1: 5, Result2: 0, Finished: true},
+				{Date: day(20), Team1: "ru", Team2: "sa", Result1: 5, Result2: 0, Finished: true},
+			},
+			want: nil,
+		},
+		{
+			name: "match listed twice",
+			fixtures: []Fixture{
+				{IdNumber: 1, Team1: "ru", Team2: "sa", Result1: 5, Result2: 0, Finished: true},
+				{Date: day(14), Team1: "sa", Team2: "ru", Result1: 0, Result2: 5, Finished: true},
+			},
+			want: []Update{{m1, 5, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		got := NewUpdates(test.fixtures, teamCodes, mapIDTeams, []*mdl.Tmatch{m1, m2, m3})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestNewUpdates(%q): got %+v wanted %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewUpdatesTwoLegs(t *testing.T) {
+	teamCodes := map[string]string{"FC Bayern Munchen": "BYM", "Benfica": "BEN"}
+	mapIDTeams := map[int64]string{10: "FC Bayern Munchen", 11: "Benfica"}
+
+	first := &mdl.Tmatch{Id: 100, IdNumber: 1, Date: time.Date(2016, 4, 5, 0, 0, 0, 0, time.UTC), TeamId1: 10, TeamId2: 11}
+	second := &mdl.Tmatch{Id: 106, IdNumber: 7, Date: time.Date(2016, 4, 13, 0, 0, 0, 0, time.UTC), TeamId1: 11, TeamId2: 10}
+
+	firstLeg := Fixture{Date: time.Date(2016, 4, 5, 18, 45, 0, 0, time.UTC), Team1: "BYM", Team2: "BEN", Result1: 1, Result2: 0, Finished: true}
+	secondLeg := Fixture{Date: time.Date(2016, 4, 13, 18, 45, 0, 0, time.UTC), Team1: "BEN", Team2: "BYM", Result1: 2, Result2: 2, Finished: true}
+
+	if got, want := NewUpdates([]Fixture{firstLeg}, teamCodes, mapIDTeams, []*mdl.Tmatch{first, second}), []Update{{first, 1, 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestNewUpdatesTwoLegs(first leg): got %+v wanted %+v", got, want)
+	}
+
+	// the feed is read again once the first leg is finished.
+	first.Finished = true
+	if got := NewUpdates([]Fixture{firstLeg}, teamCodes, mapIDTeams, []*mdl.Tmatch{first, second}); got != nil {
+		t.Errorf("TestNewUpdatesTwoLegs(first leg finished): got %+v wanted no update", got)
+	}
+
+	if got, want := NewUpdates([]Fixture{firstLeg, secondLeg}, teamCodes, mapIDTeams, []*mdl.Tmatch{first, second}), []Update{{second, 2, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestNewUpdatesTwoLegs(second leg): got %+v wanted %+v", got, want)
+	}
+}